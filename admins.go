@@ -0,0 +1,19 @@
+package couch
+
+// SetAdmin creates a server admin on the node (e.g. LocalNode), or
+// changes an existing admin's password (ignores Database.Name).  The
+// node hashes the password.  Creating the first admin ends "admin
+// party", after which requests need an admin's credentials to do so.
+//
+// On a cluster, admins are per node, so each node needs the same ones.
+func (p Database) SetAdmin(node, name, password string) error {
+	_, err := p.SetConfigValue(node, "admins", name, password)
+	return err
+}
+
+// DeleteAdmin removes a server admin from the node (ignores
+// Database.Name).
+func (p Database) DeleteAdmin(node, name string) error {
+	_, err := p.DeleteConfigValue(node, "admins", name)
+	return err
+}
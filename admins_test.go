@@ -0,0 +1,47 @@
+package couch
+
+import (
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/dustin/go-couch/couchtest"
+)
+
+func TestSetAdmin(t *testing.T) {
+	f := &couchtest.Transport{Responses: []*http.Response{
+		docResponse(`""`), docResponse(`"-pbkdf2-abc,def,10"`)}}
+	defer uninstallFakeHTTP(installFakeHTTP(f))
+
+	d := Database{Host: "localhost", Port: "5984"}
+	if err := d.SetAdmin(LocalNode, "root", "s3cret"); err != nil {
+		t.Fatalf("Error setting admin: %v", err)
+	}
+	if err := d.DeleteAdmin("couchdb@node1", "root"); err != nil {
+		t.Fatalf("Error deleting admin: %v", err)
+	}
+
+	put, del := f.Requests[0], f.Requests[1]
+	body, _ := ioutil.ReadAll(put.Body)
+	if put.Method != "PUT" || put.URL.Path != "/_node/_local/_config/admins/root" ||
+		string(body) != `"s3cret"` {
+		t.Errorf("Unexpected request %v %v %s", put.Method, put.URL, body)
+	}
+	if del.Method != "DELETE" ||
+		del.URL.EscapedPath() != "/_node/couchdb@node1/_config/admins/root" {
+		t.Errorf("Unexpected request %v %v", del.Method, del.URL.EscapedPath())
+	}
+}
+
+func TestDeleteAdminMissing(t *testing.T) {
+	defer uninstallFakeHTTP(installFakeHTTP(couchtest.NewTransport(&http.Response{
+		StatusCode: 404,
+		Body: ioutil.NopCloser(strings.NewReader(
+			`{"error": "not_found", "reason": "unknown_config_value"}`)),
+	})))
+	if err := (Database{}).DeleteAdmin(LocalNode, "x"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected not found, got %v", err)
+	}
+}
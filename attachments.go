@@ -0,0 +1,212 @@
+package couch
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// ErrContentTypeNotAllowed is matched by a ContentTypeError.
+var ErrContentTypeNotAllowed = errors.New("couch: attachment content type not allowed")
+
+// ContentTypeError is returned by PutAttachment when an attachment's
+// content type is refused by the AttachmentPolicy.
+type ContentTypeError struct {
+	Name        string // the attachment
+	ContentType string
+	Sniffed     bool // whether ContentType was detected from the content
+}
+
+func (e *ContentTypeError) Error() string {
+	how := "declared"
+	if e.Sniffed {
+		how = "detected"
+	}
+	return fmt.Sprintf("couch: attachment %q: %s content type %q not allowed",
+		e.Name, how, e.ContentType)
+}
+
+// Is reports whether target is ErrContentTypeNotAllowed.
+func (e *ContentTypeError) Is(target error) bool {
+	return target == ErrContentTypeNotAllowed
+}
+
+// AttachmentPolicy controls the content types of uploaded attachments.
+//
+// Types are matched without parameters, and may be given as e.g.
+// "image/*" to match a whole family.
+type AttachmentPolicy struct {
+	// Sniff detects the content type of uploads that don't declare
+	// one with http.DetectContentType, rather than storing them as
+	// application/octet-stream.
+	Sniff bool
+	// Allow, if not empty, lists the only content types accepted.
+	Allow []string
+	// Deny lists content types refused, even if allowed above.
+	Deny []string
+}
+
+// WithAttachmentPolicy returns a copy of the database that applies ap
+// to attachments uploaded with PutAttachment.
+func (p Database) WithAttachmentPolicy(ap AttachmentPolicy) Database {
+	p.attachments = &ap
+	return p
+}
+
+// allowed reports whether the policy accepts the content type.
+func (ap *AttachmentPolicy) allowed(contentType string) bool {
+	t, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	if matchMediaType(t, ap.Deny) {
+		return false
+	}
+	return len(ap.Allow) == 0 || matchMediaType(t, ap.Allow)
+}
+
+func matchMediaType(t string, patterns []string) bool {
+	for _, pat := range patterns {
+		pat = strings.ToLower(strings.TrimSpace(pat))
+		switch {
+		case pat == "*/*" || pat == t:
+			return true
+		case strings.HasSuffix(pat, "/*") &&
+			strings.HasPrefix(t, strings.TrimSuffix(pat, "*")):
+			return true
+		}
+	}
+	return false
+}
+
+// attachmentType settles the content type of an upload, sniffing and
+// checking it as the policy requires.  It returns the reader to upload
+// from, which replays anything sniffed.
+func (p Database) attachmentType(name, contentType string,
+	r io.Reader) (string, io.Reader, error) {
+
+	sniffed := false
+	if contentType == "" && p.attachments != nil && p.attachments.Sniff {
+		buf := make([]byte, 512)
+		n, err := io.ReadFull(r, buf)
+		if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+			return "", nil, err
+		}
+		contentType = http.DetectContentType(buf[:n])
+		r = io.MultiReader(bytes.NewReader(buf[:n]), r)
+		sniffed = true
+	}
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+	if p.attachments != nil && !p.attachments.allowed(contentType) {
+		return "", nil, &ContentTypeError{name, contentType, sniffed}
+	}
+	return contentType, r, nil
+}
+
+// PutAttachment uploads the content of r as the named attachment of
+// the document with the given id, returning the document's new
+// revision.  rev is the document's current revision, or empty to
+// create the document.
+//
+// An empty contentType is sniffed or defaulted to
+// application/octet-stream; see WithAttachmentPolicy.
+//
+// With WithRetry or WithThrottleRetry, a failed upload is only sent
+// again if r is an io.Seeker, which is rewound to where it was;
+// otherwise the failure is returned.
+func (p Database) PutAttachment(id, rev, name, contentType string,
+	r io.Reader) (string, error) {
+
+	if id == "" {
+		return "", errNoID
+	}
+	// A seekable source can be read again if the upload is retried.
+	src, start := r, int64(-1)
+	if s, ok := r.(io.Seeker); ok {
+		if off, err := s.Seek(0, io.SeekCurrent); err == nil {
+			start = off
+		}
+	}
+	contentType, r, err := p.attachmentType(name, contentType, r)
+	if err != nil {
+		return "", err
+	}
+
+	u := p.attachmentURL(id, name)
+	if rev != "" {
+		u += "?rev=" + url.QueryEscape(rev)
+	}
+	req, err := http.NewRequest("PUT", u, p.limitReader(r))
+	if err != nil {
+		return "", err
+	}
+	if req.GetBody == nil && start >= 0 {
+		req.GetBody = func() (io.ReadCloser, error) {
+			if _, err := src.(io.Seeker).Seek(start, io.SeekStart); err != nil {
+				return nil, err
+			}
+			return ioutil.NopCloser(p.limitReader(src)), nil
+		}
+	}
+	req.Header = http.Header{}
+	for k, v := range p.defaultHdrs {
+		req.Header[k] = v
+	}
+	req.Header.Set("Content-Type", contentType)
+
+	if p.cache != nil {
+		p.cache.remove(p.docURL(id))
+	}
+
+	res, err := p.do(req)
+	if err != nil {
+		return "", err
+	}
+	defer res.Body.Close()
+	defer io.Copy(ioutil.Discard, res.Body)
+
+	if res.StatusCode < 200 || res.StatusCode >= 300 {
+		return "", newHTTPError(res)
+	}
+	ir := Response{}
+	if err := json.NewDecoder(res.Body).Decode(&ir); err != nil {
+		return "", err
+	}
+	return ir.Rev, nil
+}
+
+// attachmentURL returns the URL of the named attachment of a document.
+func (p Database) attachmentURL(id, name string) string {
+	return p.dbURL(docPath(id) + "/" + pathEscape(name))
+}
+
+// GetAttachment returns the content of the named attachment of the
+// document with the given id, along with its content type.  The caller
+// must close the content.
+func (p Database) GetAttachment(id, name string) (io.ReadCloser, string, error) {
+	if id == "" {
+		return nil, "", errNoID
+	}
+	req, err := createReq(p.attachmentURL(id, name))
+	if err != nil {
+		return nil, "", err
+	}
+	res, err := p.do(req)
+	if err != nil {
+		return nil, "", err
+	}
+	if res.StatusCode != 200 {
+		defer res.Body.Close()
+		return nil, "", newHTTPError(res)
+	}
+	return p.limitReadCloser(res.Body), res.Header.Get("Content-Type"), nil
+}
@@ -0,0 +1,159 @@
+package couch
+
+import (
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"syscall"
+	"testing"
+
+	"github.com/dustin/go-couch/couchtest"
+)
+
+func TestAttachmentPolicy(t *testing.T) {
+	ap := &AttachmentPolicy{
+		Allow: []string{"image/*", "text/plain", "application/pdf"},
+		Deny:  []string{"image/svg+xml"},
+	}
+	tests := map[string]bool{
+		"image/png":                 true,
+		"IMAGE/JPEG":                true,
+		"text/plain; charset=utf-8": true,
+		"application/pdf":           true,
+		"image/svg+xml":             false,
+		"text/html":                 false,
+		"application/octet-stream":  false,
+		"not a type":                false,
+	}
+	for ct, exp := range tests {
+		if got := ap.allowed(ct); got != exp {
+			t.Errorf("allowed(%q) = %v, expected %v", ct, got, exp)
+		}
+	}
+
+	deny := &AttachmentPolicy{Deny: []string{"application/*"}}
+	if !deny.allowed("text/csv") || deny.allowed("application/x-sh") {
+		t.Errorf("Expected deny list alone to allow everything else")
+	}
+}
+
+func TestPutAttachment(t *testing.T) {
+	f := couchtest.NewTransport(docResponse(`{"ok": true, "id": "a", "rev": "2-b"}`))
+	defer uninstallFakeHTTP(installFakeHTTP(f))
+
+	rev, err := Database{Name: "db"}.PutAttachment("a", "1-a", "notes.txt",
+		"", strings.NewReader("hello"))
+	if err != nil || rev != "2-b" {
+		t.Fatalf("Expected rev 2-b, got %v, %v", rev, err)
+	}
+	req := f.Requests[0]
+	if req.Method != "PUT" || req.URL.Path != "/db/a/notes.txt" ||
+		req.URL.Query().Get("rev") != "1-a" {
+		t.Errorf("Unexpected request: %v %v", req.Method, req.URL)
+	}
+	if ct := req.Header.Get("Content-Type"); ct != "application/octet-stream" {
+		t.Errorf("Expected default content type, got %q", ct)
+	}
+}
+
+func TestPutAttachmentSniffs(t *testing.T) {
+	f := couchtest.NewTransport(docResponse(`{"ok": true, "id": "a", "rev": "2-b"}`))
+	defer uninstallFakeHTTP(installFakeHTTP(f))
+
+	content := "\x89PNG\r\n\x1a\n" + strings.Repeat("x", 1000)
+	d := Database{}.WithAttachmentPolicy(AttachmentPolicy{
+		Sniff: true, Allow: []string{"image/*"}})
+	if _, err := d.PutAttachment("a", "1-a", "pic", "",
+		strings.NewReader(content)); err != nil {
+		t.Fatalf("Expected success, got %v", err)
+	}
+	req := f.Requests[0]
+	if ct := req.Header.Get("Content-Type"); ct != "image/png" {
+		t.Errorf("Expected image/png, got %q", ct)
+	}
+	if body, _ := ioutil.ReadAll(req.Body); string(body) != content {
+		t.Errorf("Expected the whole content uploaded, got %v bytes", len(body))
+	}
+}
+
+func TestPutAttachmentRetry(t *testing.T) {
+	content := "\x89PNG\r\n\x1a\n" + strings.Repeat("x", 1000)
+	f := &flakyHTTP{fails: 2, err: syscall.ECONNRESET}
+	d := Database{client: &http.Client{Transport: f}}.WithRetry(quickRetry).
+		WithAttachmentPolicy(AttachmentPolicy{Sniff: true}).WithBandwidthLimit(1 << 30)
+
+	r := strings.NewReader(content)
+	if rev, err := d.PutAttachment("a", "1-a", "pic", "", r); err != nil || rev != "2-x" {
+		t.Fatalf("Expected rev 2-x, got %v, %v", rev, err)
+	}
+	if len(f.bodies) != 3 {
+		t.Fatalf("Expected 3 attempts, got %v", len(f.bodies))
+	}
+	for i, b := range f.bodies {
+		if b != content {
+			t.Errorf("Attempt %v: expected the whole content, got %v bytes", i, len(b))
+		}
+	}
+}
+
+func TestPutAttachmentStreamedNotRetried(t *testing.T) {
+	f := &flakyHTTP{fails: 1, err: syscall.ECONNRESET}
+	d := Database{client: &http.Client{Transport: f}}.WithRetry(quickRetry)
+
+	r := io.MultiReader(strings.NewReader("hello "), strings.NewReader("world"))
+	if _, err := d.PutAttachment("a", "1-a", "notes.txt", "", r); !errors.Is(err, syscall.ECONNRESET) {
+		t.Errorf("Expected the failure returned, got %v", err)
+	}
+	if len(f.bodies) != 1 || f.bodies[0] != "hello world" {
+		t.Errorf("Expected a single attempt, got %q", f.bodies)
+	}
+}
+
+func TestPutAttachmentRefused(t *testing.T) {
+	f := couchtest.NewTransport(docResponse(`{"ok": true}`))
+	defer uninstallFakeHTTP(installFakeHTTP(f))
+
+	d := Database{}.WithAttachmentPolicy(AttachmentPolicy{
+		Sniff: true, Deny: []string{"text/html"}})
+	_, err := d.PutAttachment("a", "1-a", "page", "",
+		strings.NewReader("<html><body>hi</body></html>"))
+	if !errors.Is(err, ErrContentTypeNotAllowed) {
+		t.Fatalf("Expected ErrContentTypeNotAllowed, got %v", err)
+	}
+	cte := err.(*ContentTypeError)
+	if !cte.Sniffed || !strings.HasPrefix(cte.ContentType, "text/html") {
+		t.Errorf("Unexpected error details: %+v", cte)
+	}
+	if len(f.Requests) != 0 {
+		t.Errorf("Expected nothing uploaded")
+	}
+
+	// Declared types are checked too.
+	_, err = d.PutAttachment("a", "1-a", "page", "text/html",
+		strings.NewReader("hi"))
+	if cte, ok := err.(*ContentTypeError); !ok || cte.Sniffed {
+		t.Errorf("Expected declared type refused, got %v", err)
+	}
+}
+
+func TestGetAttachment(t *testing.T) {
+	res := docResponse("hello")
+	res.Header = http.Header{"Content-Type": []string{"text/plain"}}
+	f := couchtest.NewTransport(res)
+	defer uninstallFakeHTTP(installFakeHTTP(f))
+
+	d := Database{Name: "db"}.WithBandwidthLimit(1 << 20)
+	body, ct, err := d.GetAttachment("_design/x", "a b.txt")
+	if err != nil || ct != "text/plain" {
+		t.Fatalf("Expected text/plain, got %q, %v", ct, err)
+	}
+	defer body.Close()
+	if got, _ := ioutil.ReadAll(body); string(got) != "hello" {
+		t.Errorf("Expected hello, got %q", got)
+	}
+	if u := f.Requests[0].URL.EscapedPath(); u != "/db/_design/x/a%20b.txt" {
+		t.Errorf("Unexpected attachment URL %v", u)
+	}
+}
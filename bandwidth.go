@@ -0,0 +1,99 @@
+package couch
+
+import (
+	"context"
+	"io"
+	"sync"
+	"time"
+)
+
+// maxThrottledRead bounds each read of a throttled stream, so the
+// pauses between reads stay short and even.
+const maxThrottledRead = 32 * 1024
+
+// bandwidth paces transfers to a number of bytes per second.
+type bandwidth struct {
+	rate int64
+
+	mu   sync.Mutex
+	next time.Time // when the bytes granted so far will have been paid for
+}
+
+// WithBandwidthLimit returns a copy of the database that limits
+// attachment uploads and downloads, and streamed document bodies, to a
+// combined bytesPerSecond.  The database and every copy made from it
+// share the limit, so, say, a backup job can be kept from saturating a
+// link shared with production traffic.
+//
+// Other requests aren't limited.  A limit of 0 removes it.
+func (p Database) WithBandwidthLimit(bytesPerSecond int64) Database {
+	p.bandwidth = nil
+	if bytesPerSecond > 0 {
+		p.bandwidth = &bandwidth{rate: bytesPerSecond}
+	}
+	return p
+}
+
+// wait blocks until the transfer of n more bytes is within the rate,
+// or ctx is done.
+func (b *bandwidth) wait(ctx context.Context, n int) error {
+	b.mu.Lock()
+	now := time.Now()
+	if b.next.Before(now) {
+		b.next = now
+	}
+	b.next = b.next.Add(time.Duration(n) * time.Second / time.Duration(b.rate))
+	d := b.next.Sub(now)
+	b.mu.Unlock()
+
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+type throttledReader struct {
+	ctx context.Context
+	r   io.Reader
+	b   *bandwidth
+}
+
+func (t throttledReader) Read(p []byte) (int, error) {
+	if len(p) > maxThrottledRead {
+		p = p[:maxThrottledRead]
+	}
+	n, err := t.r.Read(p)
+	if n > 0 {
+		if werr := t.b.wait(t.ctx, n); werr != nil {
+			return n, werr
+		}
+	}
+	return n, err
+}
+
+type throttledReadCloser struct {
+	throttledReader
+	io.Closer
+}
+
+// limitReader returns r, throttled to the database's bandwidth limit.
+// Reads fail once the database's context is done.
+func (p Database) limitReader(r io.Reader) io.Reader {
+	if p.bandwidth == nil {
+		return r
+	}
+	return throttledReader{p.Context(), r, p.bandwidth}
+}
+
+// limitReadCloser returns rc, throttled to the database's bandwidth
+// limit.
+func (p Database) limitReadCloser(rc io.ReadCloser) io.ReadCloser {
+	if p.bandwidth == nil {
+		return rc
+	}
+	return throttledReadCloser{throttledReader{p.Context(), rc, p.bandwidth}, rc}
+}
@@ -0,0 +1,55 @@
+package couch
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"io/ioutil"
+	"testing"
+	"time"
+)
+
+func TestBandwidthLimit(t *testing.T) {
+	d := Database{}.WithBandwidthLimit(1 << 20)
+	data := make([]byte, 100<<10)
+
+	start := time.Now()
+	n, err := io.Copy(ioutil.Discard, d.limitReader(bytes.NewReader(data)))
+	if err != nil || n != int64(len(data)) {
+		t.Fatalf("Expected %v bytes, got %v, %v", len(data), n, err)
+	}
+	// 100KB at 1MB/s.
+	if elapsed := time.Since(start); elapsed < 90*time.Millisecond {
+		t.Errorf("Expected the copy to be throttled, took %v", elapsed)
+	}
+}
+
+func TestBandwidthLimitCanceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	d := Database{}.WithBandwidthLimit(1 << 10).WithContext(ctx)
+	cancel()
+
+	start := time.Now()
+	_, err := io.Copy(ioutil.Discard, d.limitReader(bytes.NewReader(make([]byte, 100<<10))))
+	if err != context.Canceled {
+		t.Errorf("Expected the copy to be canceled, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Expected the copy to stop at once, took %v", elapsed)
+	}
+}
+
+func TestBandwidthLimitShared(t *testing.T) {
+	d := Database{}.WithBandwidthLimit(1 << 20)
+	copied := d.WithPriority(LowPriority)
+	if copied.bandwidth != d.bandwidth {
+		t.Errorf("Expected copies to share the limit")
+	}
+}
+
+func TestBandwidthUnlimited(t *testing.T) {
+	r := bytes.NewReader(nil)
+	if got := (Database{}).WithBandwidthLimit(0).limitReader(r); got != r {
+		t.Errorf("Expected reader untouched without a limit")
+	}
+}
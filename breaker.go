@@ -0,0 +1,190 @@
+package couch
+
+import (
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// BreakerState is the state of a circuit breaker.
+type BreakerState int
+
+const (
+	// BreakerClosed lets requests through normally.
+	BreakerClosed BreakerState = iota
+	// BreakerOpen fails requests immediately with ErrCircuitOpen.
+	BreakerOpen
+	// BreakerHalfOpen lets a few probe requests through to see whether
+	// the server has recovered.
+	BreakerHalfOpen
+)
+
+func (s BreakerState) String() string {
+	switch s {
+	case BreakerClosed:
+		return "closed"
+	case BreakerOpen:
+		return "open"
+	case BreakerHalfOpen:
+		return "half-open"
+	}
+	return "unknown"
+}
+
+// ErrCircuitOpen is returned for requests refused by an open circuit
+// breaker.
+var ErrCircuitOpen = errors.New("couch: circuit breaker open")
+
+// BreakerPolicy configures a circuit breaker.  See WithCircuitBreaker.
+type BreakerPolicy struct {
+	// Consecutive failures that open the breaker.
+	FailureThreshold int
+	// How long the breaker stays open before probing the server.
+	OpenDuration time.Duration
+	// Number of probe requests allowed in flight while half open.
+	HalfOpenProbes int
+	// OnStateChange, if set, is called on every transition.
+	OnStateChange func(from, to BreakerState)
+}
+
+// DefaultBreakerPolicy is a reasonable starting point for
+// WithCircuitBreaker.  Zero fields in other policies take these values.
+var DefaultBreakerPolicy = BreakerPolicy{
+	FailureThreshold: 5,
+	OpenDuration:     30 * time.Second,
+	HalfOpenProbes:   1,
+}
+
+// WithCircuitBreaker returns a copy of the database whose requests,
+// along with those of every copy made from it, pass through a circuit
+// breaker.
+//
+// Connection failures and 5xx responses count as failures.  Once
+// FailureThreshold of them happen in a row, the breaker opens and
+// requests fail with ErrCircuitOpen instead of waiting on the server.
+// After OpenDuration, up to HalfOpenProbes high priority requests are
+// let through; the first to succeed closes the breaker, and a failure
+// opens it again.
+func (p Database) WithCircuitBreaker(b BreakerPolicy) Database {
+	p.breaker = newBreaker(b)
+	return p
+}
+
+type breaker struct {
+	policy BreakerPolicy
+
+	mu       sync.Mutex
+	state    BreakerState
+	failures int
+	openedAt time.Time
+	probes   int
+}
+
+func newBreaker(b BreakerPolicy) *breaker {
+	if b.FailureThreshold <= 0 {
+		b.FailureThreshold = DefaultBreakerPolicy.FailureThreshold
+	}
+	if b.OpenDuration <= 0 {
+		b.OpenDuration = DefaultBreakerPolicy.OpenDuration
+	}
+	if b.HalfOpenProbes <= 0 {
+		b.HalfOpenProbes = DefaultBreakerPolicy.HalfOpenProbes
+	}
+	return &breaker{policy: b}
+}
+
+// allow decides whether a request may proceed, and whether it's a
+// probe.  Every allowed request must be followed by done or abandon.
+func (b *breaker) allow(pri Priority) (bool, error) {
+	b.mu.Lock()
+	from := b.state
+	probe, err := b.admit(pri)
+	to := b.state
+	b.mu.Unlock()
+
+	b.notify(from, to)
+	return probe, err
+}
+
+func (b *breaker) admit(pri Priority) (bool, error) {
+	switch b.state {
+	case BreakerOpen:
+		if time.Since(b.openedAt) < b.policy.OpenDuration {
+			return false, ErrCircuitOpen
+		}
+		b.state = BreakerHalfOpen
+		fallthrough
+	case BreakerHalfOpen:
+		// Low priority traffic waits until the server has proven
+		// itself again.
+		if pri == LowPriority || b.probes >= b.policy.HalfOpenProbes {
+			return false, ErrCircuitOpen
+		}
+		b.probes++
+		return true, nil
+	}
+	return false, nil
+}
+
+// done records the outcome of an allowed request.
+func (b *breaker) done(probe, ok bool) {
+	b.mu.Lock()
+	from := b.state
+	switch {
+	case probe && b.state == BreakerHalfOpen:
+		b.release()
+		if ok {
+			b.failures = 0
+			b.state = BreakerClosed
+		} else {
+			b.trip()
+		}
+	case !probe && b.state == BreakerClosed:
+		if ok {
+			b.failures = 0
+		} else if b.failures++; b.failures >= b.policy.FailureThreshold {
+			b.trip()
+		}
+	}
+	to := b.state
+	b.mu.Unlock()
+
+	b.notify(from, to)
+}
+
+// abandon releases an allowed request whose outcome says nothing about
+// the server's health.
+func (b *breaker) abandon(probe bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if probe && b.state == BreakerHalfOpen {
+		b.release()
+	}
+}
+
+// release frees a probe slot.  Probes outstanding from before the
+// breaker last tripped have already been forgotten.
+func (b *breaker) release() {
+	if b.probes > 0 {
+		b.probes--
+	}
+}
+
+func (b *breaker) trip() {
+	b.state = BreakerOpen
+	b.openedAt = time.Now()
+	b.probes = 0
+}
+
+func (b *breaker) notify(from, to BreakerState) {
+	if from != to && b.policy.OnStateChange != nil {
+		b.policy.OnStateChange(from, to)
+	}
+}
+
+// healthy reports whether a request that ended this way suggests the
+// server is working.
+func healthy(res *http.Response, err error) bool {
+	return err == nil && res.StatusCode < 500
+}
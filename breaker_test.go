@@ -0,0 +1,113 @@
+package couch
+
+import (
+	"net/http"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/dustin/go-couch/couchtest"
+)
+
+func TestBreakerTransitions(t *testing.T) {
+	var changes []string
+	b := newBreaker(BreakerPolicy{
+		FailureThreshold: 2,
+		OpenDuration:     time.Millisecond,
+		OnStateChange: func(from, to BreakerState) {
+			changes = append(changes, from.String()+"->"+to.String())
+		},
+	})
+
+	// Successes reset the failure count.
+	for _, ok := range []bool{false, true, false} {
+		if _, err := b.allow(HighPriority); err != nil {
+			t.Fatalf("Expected closed breaker to allow, got %v", err)
+		}
+		b.done(false, ok)
+	}
+	b.allow(HighPriority)
+	b.done(false, false)
+	if _, err := b.allow(HighPriority); err != ErrCircuitOpen {
+		t.Fatalf("Expected open breaker, got %v", err)
+	}
+
+	time.Sleep(2 * time.Millisecond)
+	if _, err := b.allow(LowPriority); err != ErrCircuitOpen {
+		t.Fatalf("Expected low priority to be refused, got %v", err)
+	}
+	probe, err := b.allow(HighPriority)
+	if err != nil || !probe {
+		t.Fatalf("Expected probe, got %v, %v", probe, err)
+	}
+	if _, err := b.allow(HighPriority); err != ErrCircuitOpen {
+		t.Fatalf("Expected second probe to be refused, got %v", err)
+	}
+	b.done(true, false)
+	if _, err := b.allow(HighPriority); err != ErrCircuitOpen {
+		t.Fatalf("Expected failed probe to reopen, got %v", err)
+	}
+
+	time.Sleep(2 * time.Millisecond)
+	probe, _ = b.allow(HighPriority)
+	b.done(probe, true)
+	if _, err := b.allow(LowPriority); err != nil {
+		t.Fatalf("Expected closed breaker, got %v", err)
+	}
+
+	exp := []string{
+		"closed->open", "open->half-open", "half-open->open",
+		"open->half-open", "half-open->closed",
+	}
+	if !reflect.DeepEqual(changes, exp) {
+		t.Errorf("Expected %v, got %v", exp, changes)
+	}
+}
+
+func TestBreakerAbandonedProbe(t *testing.T) {
+	b := newBreaker(BreakerPolicy{FailureThreshold: 1, OpenDuration: time.Millisecond})
+	b.allow(HighPriority)
+	b.done(false, false)
+	time.Sleep(2 * time.Millisecond)
+
+	probe, _ := b.allow(HighPriority)
+	b.abandon(probe)
+	if probe, err := b.allow(HighPriority); err != nil || !probe {
+		t.Fatalf("Expected another probe, got %v, %v", probe, err)
+	}
+}
+
+func TestCircuitBreakerFailsFast(t *testing.T) {
+	f := &couchtest.Transport{Responses: []*http.Response{unavailable(), unavailable()}}
+	defer uninstallFakeHTTP(installFakeHTTP(f))
+
+	d := Database{}.WithCircuitBreaker(BreakerPolicy{FailureThreshold: 2})
+	for i := 0; i < 2; i++ {
+		if _, err := d.GetInfo(); err == nil || err == ErrCircuitOpen {
+			t.Fatalf("Expected server error, got %v", err)
+		}
+	}
+	// Copies share the breaker.
+	if _, err := d.WithPriority(LowPriority).GetInfo(); err != ErrCircuitOpen {
+		t.Fatalf("Expected open circuit, got %v", err)
+	}
+	if len(f.Requests) != 2 {
+		t.Errorf("Expected 2 requests to reach the server, got %v",
+			len(f.Requests))
+	}
+}
+
+func TestCircuitBreakerIgnoresClientErrors(t *testing.T) {
+	f := &couchtest.Transport{}
+	for i := 0; i < 3; i++ {
+		f.Responses = append(f.Responses, conflictResponse())
+	}
+	defer uninstallFakeHTTP(installFakeHTTP(f))
+
+	d := Database{}.WithCircuitBreaker(BreakerPolicy{FailureThreshold: 2})
+	for i := 0; i < 3; i++ {
+		if _, err := d.GetInfo(); err == ErrCircuitOpen {
+			t.Fatalf("Expected conflicts not to open the circuit")
+		}
+	}
+}
@@ -0,0 +1,129 @@
+package couch
+
+import (
+	"bytes"
+	"container/list"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Responses larger than this aren't worth holding on to.
+const maxCachedBody = 1 << 20
+
+// WithETagCache returns a copy of the database that keeps up to n
+// fetched documents (and other GET responses, such as views) along
+// with their ETags, sharing the cache with every copy made from it.
+//
+// Cached responses are always revalidated with If-None-Match, so stale
+// data is never returned, but an unchanged document costs the server a
+// 304 instead of the whole body.  Responses over 1MB aren't cached.
+// A cached response is only used for a request with the same query
+// parameters and headers, since those given with WithParams or
+// WithHeaders can change the body but not the ETag.
+func (p Database) WithETagCache(n int) Database {
+	p.cache = nil
+	if n > 0 {
+		p.cache = &etagCache{max: n, ll: list.New(), items: map[string]*list.Element{}}
+	}
+	return p
+}
+
+type cacheEntry struct {
+	url, etag string
+	vary      string // see cacheVary
+	body      []byte
+}
+
+// cacheVary summarizes a request's query and headers as sent, which
+// may change the body a server returns for a URL, e.g. with the
+// parameters of WithParams, without changing its ETag.
+func cacheVary(req *http.Request) string {
+	keys := make([]string, 0, len(req.Header))
+	for k := range req.Header {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	b := strings.Builder{}
+	b.WriteString(req.URL.RawQuery + "\n")
+	for _, k := range keys {
+		fmt.Fprintf(&b, "%s: %q\n", k, req.Header[k])
+	}
+	return b.String()
+}
+
+// etagCache is an LRU cache of response bodies by URL, holding one
+// variant of each.
+type etagCache struct {
+	max int
+
+	mu    sync.Mutex
+	ll    *list.List
+	items map[string]*list.Element
+}
+
+// get returns the entry for u, if it was fetched with the same query
+// and headers.
+func (c *etagCache) get(u, vary string) *cacheEntry {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e, ok := c.items[u]
+	if !ok || e.Value.(*cacheEntry).vary != vary {
+		return nil
+	}
+	c.ll.MoveToFront(e)
+	return e.Value.(*cacheEntry)
+}
+
+func (c *etagCache) put(ent *cacheEntry) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if e, ok := c.items[ent.url]; ok {
+		e.Value = ent
+		c.ll.MoveToFront(e)
+		return
+	}
+	c.items[ent.url] = c.ll.PushFront(ent)
+	for c.ll.Len() > c.max {
+		oldest := c.ll.Back()
+		c.ll.Remove(oldest)
+		delete(c.items, oldest.Value.(*cacheEntry).url)
+	}
+}
+
+func (c *etagCache) remove(u string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if e, ok := c.items[u]; ok {
+		c.ll.Remove(e)
+		delete(c.items, u)
+	}
+}
+
+// store caches a successful response from u, requested as summarized
+// by vary, if it can, returning a body to read in place of
+// the response's.
+func (c *etagCache) store(u, vary string, res *http.Response) (io.ReadCloser, error) {
+	etag := res.Header.Get("ETag")
+	if etag == "" || res.ContentLength > maxCachedBody {
+		return res.Body, nil
+	}
+	body, err := ioutil.ReadAll(io.LimitReader(res.Body, maxCachedBody+1))
+	if err != nil {
+		res.Body.Close()
+		return nil, err
+	}
+	if len(body) > maxCachedBody {
+		return struct {
+			io.Reader
+			io.Closer
+		}{io.MultiReader(bytes.NewReader(body), res.Body), res.Body}, nil
+	}
+	res.Body.Close()
+	c.put(&cacheEntry{url: u, etag: etag, vary: vary, body: body})
+	return ioutil.NopCloser(bytes.NewReader(body)), nil
+}
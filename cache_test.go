@@ -0,0 +1,115 @@
+package couch
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/dustin/go-couch/couchtest"
+)
+
+func etagResponse(status int, etag, body string) *http.Response {
+	res := couchtest.Response(status, body)
+	res.Header.Set("ETag", etag)
+	return res
+}
+
+func TestETagCache(t *testing.T) {
+	f := &couchtest.Transport{Responses: []*http.Response{
+		etagResponse(200, `"1-a"`, `{"_id": "x", "_rev": "1-a", "n": 1}`),
+		etagResponse(304, `"1-a"`, ``),
+		docResponse(`{"ok": true, "id": "x", "rev": "2-a"}`),
+		etagResponse(200, `"2-a"`, `{"_id": "x", "_rev": "2-a", "n": 2}`),
+	}}
+	defer uninstallFakeHTTP(installFakeHTTP(f))
+
+	d := Database{}.WithETagCache(10)
+	for i := 0; i < 2; i++ {
+		doc := map[string]interface{}{}
+		if err := d.Retrieve("x", &doc); err != nil {
+			t.Fatalf("Error retrieving: %v", err)
+		}
+		if doc["n"] != 1.0 {
+			t.Errorf("Expected n=1, got %v", doc)
+		}
+	}
+	if h := f.Requests[0].Header.Get("If-None-Match"); h != "" {
+		t.Errorf("Expected first request to be unconditional, got %q", h)
+	}
+	if h := f.Requests[1].Header.Get("If-None-Match"); h != `"1-a"` {
+		t.Errorf("Expected conditional request, got %q", h)
+	}
+
+	if _, err := d.EditWith(map[string]int{"n": 2}, "x", "1-a"); err != nil {
+		t.Fatalf("Error editing: %v", err)
+	}
+	doc := map[string]interface{}{}
+	if err := d.Retrieve("x", &doc); err != nil {
+		t.Fatalf("Error retrieving: %v", err)
+	}
+	if h := f.Requests[3].Header.Get("If-None-Match"); h != "" {
+		t.Errorf("Expected edit to invalidate the cache, got %q", h)
+	}
+	if doc["n"] != 2.0 {
+		t.Errorf("Expected n=2, got %v", doc)
+	}
+}
+
+func TestETagCacheEvicts(t *testing.T) {
+	c := Database{}.WithETagCache(2).cache
+	for _, u := range []string{"a", "b", "a", "c"} {
+		c.put(&cacheEntry{url: u, etag: u})
+	}
+	if c.get("b", "") != nil {
+		t.Errorf("Expected b to be evicted")
+	}
+	if c.get("a", "") == nil || c.get("c", "") == nil {
+		t.Errorf("Expected a and c to be cached")
+	}
+}
+
+func TestETagCacheSkipsLarge(t *testing.T) {
+	big := `"` + strings.Repeat("x", maxCachedBody) + `"`
+	f := &couchtest.Transport{Responses: []*http.Response{etagResponse(200, `"1"`, big)}}
+	defer uninstallFakeHTTP(installFakeHTTP(f))
+
+	d := Database{}.WithETagCache(10)
+	var s string
+	if err := d.unmarshalURL("http://localhost/big", &s); err != nil {
+		t.Fatalf("Error fetching: %v", err)
+	}
+	if len(s) != maxCachedBody {
+		t.Errorf("Expected the whole body, got %v bytes", len(s))
+	}
+	if len(d.cache.items) != 0 {
+		t.Errorf("Expected large body not to be cached")
+	}
+}
+
+func TestETagCacheVaries(t *testing.T) {
+	f := &couchtest.Transport{Responses: []*http.Response{
+		etagResponse(200, `"1-a"`, `{"_id": "x", "_rev": "1-a"}`),
+		etagResponse(200, `"1-a"`, `{"_id": "x", "_rev": "1-a", "_revisions": {"start": 1}}`),
+		etagResponse(304, `"1-a"`, ``),
+		etagResponse(200, `"1-a"`, `{"_id": "x", "_rev": "1-a"}`),
+		etagResponse(200, `"1-a"`, `{"_id": "x", "_rev": "1-a", "other": true}`),
+	}}
+	defer uninstallFakeHTTP(installFakeHTTP(f))
+
+	d := Database{}.WithETagCache(10)
+	revs := d.WithParams(map[string]interface{}{"revs": true})
+	for i, db := range []Database{d, revs, revs, d, d.WithHeader("Accept", "text/plain")} {
+		doc := map[string]interface{}{}
+		if err := db.Retrieve("x", &doc); err != nil {
+			t.Fatalf("Error retrieving %v: %v", i, err)
+		}
+		if (i == 1 || i == 2) != (doc["_revisions"] != nil) {
+			t.Errorf("Retrieve %v: unexpected doc %v", i, doc)
+		}
+	}
+	for i, exp := range []string{"", "", `"1-a"`, "", ""} {
+		if h := f.Requests[i].Header.Get("If-None-Match"); h != exp {
+			t.Errorf("Request %v: expected If-None-Match %q, got %q", i, exp, h)
+		}
+	}
+}
@@ -3,7 +3,6 @@ package couch
 import (
 	"fmt"
 	"io"
-	"log"
 	"net"
 	"net/http"
 	"net/url"
@@ -28,20 +27,32 @@ type timeoutClient struct {
 		SetReadDeadline(time.Time) error
 	}
 	readTimeout time.Duration
+	// Set when a read timed out: the connection went quiet for longer
+	// than heartbeats allow, so it's presumed dead.
+	stale bool
 }
 
 func (tc *timeoutClient) Read(p []byte) (n int, err error) {
 	if tc.readTimeout > 0 {
 		tc.underlying.SetReadDeadline(time.Now().Add(tc.readTimeout))
 	}
-	return tc.body.Read(p)
+	n, err = tc.body.Read(p)
+	if ne, ok := err.(net.Error); ok && ne.Timeout() {
+		tc.stale = true
+	}
+	return n, err
 }
 
+// Close closes the body, first closing the underlying connection (if
+// possible) so a Read blocked in another goroutine returns promptly.
 func (tc *timeoutClient) Close() error {
+	if c, ok := tc.underlying.(io.Closer); ok {
+		c.Close()
+	}
 	return tc.body.Close()
 }
 
-func i64defopt(opts map[string]interface{}, k string, def int64) int64 {
+func (p Database) i64defopt(opts map[string]interface{}, k string, def int64) int64 {
 	rv := def
 
 	if l, ok := opts[k]; ok {
@@ -58,7 +69,7 @@ func i64defopt(opts map[string]interface{}, k string, def int64) int64 {
 				rv = l
 			}
 		default:
-			log.Printf("Unknown type for '%s' param: %T", k, l)
+			p.logf("Unknown type for '%s' param: %T", k, l)
 		}
 	}
 
@@ -69,25 +80,56 @@ func i64defopt(opts map[string]interface{}, k string, def int64) int64 {
 //
 // The handler receives the body of the stream and is expected to consume
 // the contents.
+//
+// Reads fail once nothing, not even a heartbeat, has arrived for twice
+// the "heartbeat" option (default 5000ms; a minute if it's 0), so a
+// connection silently dropped by a NAT or firewall is reconnected
+// rather than waited on forever.
 func (p Database) Changes(handler ChangeHandler,
 	options map[string]interface{}) error {
 
-	largest := i64defopt(options, "since", 0)
+	since := ""
+	if largest := p.i64defopt(options, "since", 0); largest > 0 {
+		since = strconv.FormatInt(largest, 10)
+	}
+
+	return p.changes(options, since, nil, func(r io.ReadCloser) (string, bool) {
+		largest := handler(r)
+		if largest > 0 {
+			return strconv.FormatInt(largest, 10), true
+		}
+		return "", largest >= 0
+	})
+}
+
+// changes runs the changes feed loop, handing the body of each
+// connection to handler.  The handler returns the sequence to resume
+// from (or "" to use the one in options) and whether to keep going.
+// The loop also stops once done is closed.
+func (p Database) changes(options map[string]interface{}, since string,
+	done <-chan struct{}, handler func(io.ReadCloser) (string, bool)) error {
 
-	heartbeatTime := i64defopt(options, "heartbeat", 5000)
+	heartbeatTime := p.i64defopt(options, "heartbeat", 5000)
 
 	timeout := time.Minute
 	if heartbeatTime > 0 {
 		timeout = time.Millisecond * time.Duration(heartbeatTime*2)
 	}
+	if p.timeouts != nil && p.timeouts.Changes > 0 {
+		timeout = p.timeouts.Changes
+	}
+
+	for first := true; ; first = false {
+		if !first && p.metrics != nil {
+			p.metrics.ObserveChangesReconnect(p.Name)
+		}
 
-	for largest >= 0 {
 		params := url.Values{}
 		for k, v := range options {
 			params.Set(k, fmt.Sprintf("%v", v))
 		}
-		if largest > 0 {
-			params.Set("since", fmt.Sprintf("%v", largest))
+		if since != "" {
+			params.Set("since", since)
 		}
 
 		if heartbeatTime > 0 {
@@ -96,34 +138,87 @@ func (p Database) Changes(handler ChangeHandler,
 			params.Del("heartbeat")
 		}
 
-		fullURL := fmt.Sprintf("%s/_changes?%s", p.DBURL(),
-			params.Encode())
+		fullURL := p.dbURL("_changes") + "?" + params.Encode()
 
 		var conn net.Conn
 
 		// Swapping out the transport to work around a bug.
-		client := &http.Client{Transport: &http.Transport{
-			Proxy: http.ProxyFromEnvironment,
+		transport := &http.Transport{
+			Proxy:           http.ProxyFromEnvironment,
+			TLSClientConfig: p.tlsConfig,
 			Dial: func(n, addr string) (net.Conn, error) {
 				var err error
 				conn, err = p.changesDialer(n, addr)
 				return conn, err
 			},
-		}}
+		}
+		if heartbeatTime > 0 {
+			// Heartbeats start right away, so headers should too.
+			transport.ResponseHeaderTimeout = timeout
+		}
+		client := &http.Client{Transport: transport}
 
-		resp, err := client.Get(fullURL)
+		req, err := createReq(fullURL)
+		if err != nil {
+			return err
+		}
+		p.addDefaultHeaders(req)
+		if err := p.addRequestID(req); err != nil {
+			return err
+		}
+		var resp *http.Response
+		if p.session != nil {
+			// A failure to log in is retried like one to connect.
+			_, err = p.session.authorize(p, req)
+		}
+		if err == nil {
+			start := time.Now()
+			resp, err = client.Do(req)
+			if err == nil && p.session != nil {
+				p.session.refresh(resp)
+			}
+			resp = p.observe(req, resp, err, start)
+		}
 		if err == nil {
+			more := true
 			func() {
 				defer resp.Body.Close()
 				defer conn.Close()
 
-				tc := timeoutClient{resp.Body, conn, timeout}
-				largest = handler(&tc)
+				tc := timeoutClient{body: resp.Body, underlying: conn,
+					readTimeout: timeout}
+				since, more = handler(&tc)
+				if tc.stale && more {
+					p.staleChanges(timeout)
+				}
 			}()
+			if !more {
+				return nil
+			}
 		} else {
-			log.Printf("Error in stream: %v", err)
-			time.Sleep(p.changesFailDelay)
+			if ne, ok := err.(net.Error); ok && ne.Timeout() {
+				p.staleChanges(timeout)
+			}
+			select {
+			case <-done:
+				return nil
+			case <-time.After(p.changesFailDelay):
+			}
+		}
+
+		select {
+		case <-done:
+			return nil
+		default:
 		}
 	}
-	return nil
+}
+
+// staleChanges reports a changes feed abandoned after being silent for
+// the given time.
+func (p Database) staleChanges(silent time.Duration) {
+	p.logf("Changes feed of %s silent for %v, reconnecting", p.Name, silent)
+	if p.metrics != nil {
+		p.metrics.ObserveChangesStale(p.Name)
+	}
 }
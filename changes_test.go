@@ -1,8 +1,11 @@
 package couch
 
 import (
+	"bufio"
 	"io"
+	"io/ioutil"
 	"net"
+	"net/http"
 	"testing"
 	"time"
 )
@@ -33,7 +36,7 @@ func (t *testDeadliner) SetReadDeadline(time.Time) error {
 func TestTimeoutClient(t *testing.T) {
 	trc := &testRC{}
 	var td testDeadliner
-	tc := timeoutClient{trc, &td, 13}
+	tc := timeoutClient{body: trc, underlying: &td, readTimeout: 13}
 	buf := make([]byte, 4096)
 
 	_, err := tc.Read(buf)
@@ -72,7 +75,7 @@ func TestI64Opt(t *testing.T) {
 	}
 
 	for k, exp := range tests {
-		got := i64defopt(m, k, 99)
+		got := Database{}.i64defopt(m, k, 99)
 		if got != exp {
 			t.Errorf("Expected %v for %v (%v), got %v",
 				exp, k, m[k], got)
@@ -185,3 +188,62 @@ func TestChangesWithNegativeHB(t *testing.T) {
 		})
 	t.Logf("Error: %v", err)
 }
+
+// quietDialer serves one change on each connection and then goes
+// silent, as a connection dropped by a NAT would.
+func quietDialer() (func(string, string) (net.Conn, error), func()) {
+	var conns []net.Conn
+	dial := func(string, string) (net.Conn, error) {
+		client, server := net.Pipe()
+		conns = append(conns, server)
+		go func() {
+			if _, err := http.ReadRequest(bufio.NewReader(server)); err != nil {
+				return
+			}
+			server.Write([]byte("HTTP/1.0 200 OK\r\n\r\n" +
+				`{"seq":1,"id":"a","changes":[{"rev":"1-a"}]}` + "\n"))
+		}()
+		return client, nil
+	}
+	return dial, func() {
+		for _, c := range conns {
+			c.Close()
+		}
+	}
+}
+
+func TestChangesStale(t *testing.T) {
+	dial, closeAll := quietDialer()
+	defer closeAll()
+
+	m := &recordingMetrics{}
+	d := Database{
+		changesDialer:    dial,
+		changesFailDelay: 5,
+		Host:             "localhost",
+		Name:             "db",
+	}.WithMetrics(m)
+
+	calls := 0
+	err := d.Changes(func(r io.Reader) int64 {
+		calls++
+		if calls > 1 {
+			return -1
+		}
+		n, err := io.Copy(ioutil.Discard, r)
+		if n == 0 || err == nil {
+			t.Errorf("Expected a change then a timeout, got %v, %v", n, err)
+		}
+		return 1
+	}, map[string]interface{}{"heartbeat": 10})
+	if err != nil {
+		t.Fatalf("Error in changes: %v", err)
+	}
+
+	if len(m.stale) != 1 || m.stale[0] != "db" {
+		t.Errorf("Expected one stale feed for db, got %v", m.stale)
+	}
+	if len(m.reconnects) != 1 {
+		t.Errorf("Expected one reconnect, got %v", m.reconnects)
+	}
+}
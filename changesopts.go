@@ -0,0 +1,73 @@
+package couch
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// ChangesOptions are typed options for changes feeds.  Pass the result
+// of Options wherever changes options are taken, e.g.
+//
+//	db.Changes(handler, ChangesOptions{IncludeDocs: true}.Options())
+type ChangesOptions struct {
+	// Start after this sequence.  ConsumeChanges, ConsumeDBEvents,
+	// EventSource and PollChanges take it as an argument instead.
+	Since Sequence
+	// Stop after this many changes.
+	Limit int
+	// Include each changed document, and its conflicts.
+	IncludeDocs bool
+	Conflicts   bool
+	// Oldest changes last, rather than first.
+	Descending bool
+	// "all_docs" to list every leaf revision of each change rather
+	// than just the winner.
+	Style string
+	// Only changes passing this filter: a filter function as
+	// "ddoc/name", "_doc_ids" with DocIDs, "_view" with View, or
+	// "_design" for design documents.
+	Filter string
+	DocIDs []string
+	View   string // as "ddoc/name"
+	// Further parameters for a filter function.
+	FilterParams map[string]string
+	// How often the server sends a heartbeat when there are no
+	// changes (default 5s).  Millisecond granularity.
+	Heartbeat time.Duration
+	// How long the server waits for changes before ending the
+	// response.  Millisecond granularity.
+	Timeout time.Duration
+	// Only compute the sequences of every nth change, which is cheaper
+	// on clustered servers.
+	SeqInterval int
+}
+
+// Options returns the options as a map, with each value encoded as
+// CouchDB expects it.
+func (o ChangesOptions) Options() map[string]interface{} {
+	m := map[string]interface{}{}
+	for k, v := range o.FilterParams {
+		m[k] = v
+	}
+	set := func(k string, v interface{}, ok bool) {
+		if ok {
+			m[k] = v
+		}
+	}
+	set("since", string(o.Since), o.Since != "")
+	set("limit", o.Limit, o.Limit > 0)
+	set("include_docs", true, o.IncludeDocs)
+	set("conflicts", true, o.Conflicts)
+	set("descending", true, o.Descending)
+	set("style", o.Style, o.Style != "")
+	set("filter", o.Filter, o.Filter != "")
+	set("view", o.View, o.View != "")
+	set("heartbeat", int64(o.Heartbeat/time.Millisecond), o.Heartbeat > 0)
+	set("timeout", int64(o.Timeout/time.Millisecond), o.Timeout > 0)
+	set("seq_interval", o.SeqInterval, o.SeqInterval > 0)
+	if len(o.DocIDs) > 0 {
+		ids, _ := json.Marshal(o.DocIDs)
+		m["doc_ids"] = string(ids)
+	}
+	return m
+}
@@ -0,0 +1,47 @@
+package couch
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestChangesOptions(t *testing.T) {
+	m := ChangesOptions{
+		Since:        "5-g1AAA",
+		Limit:        10,
+		IncludeDocs:  true,
+		Style:        "all_docs",
+		Filter:       "_doc_ids",
+		DocIDs:       []string{"a", "b"},
+		FilterParams: map[string]string{"type": "cat"},
+		Heartbeat:    2 * time.Second,
+		Timeout:      time.Minute,
+	}.Options()
+
+	exp := map[string]string{
+		"since": "5-g1AAA", "limit": "10", "include_docs": "true",
+		"style": "all_docs", "filter": "_doc_ids", "doc_ids": `["a","b"]`,
+		"type": "cat", "heartbeat": "2000", "timeout": "60000",
+	}
+	if len(m) != len(exp) {
+		t.Errorf("Expected %v, got %v", exp, m)
+	}
+	for k, v := range exp {
+		// The changes feed formats options with %v.
+		if got := fmt.Sprintf("%v", m[k]); got != v {
+			t.Errorf("%v: expected %q, got %q", k, v, got)
+		}
+	}
+
+	if m := (ChangesOptions{}).Options(); len(m) != 0 {
+		t.Errorf("Expected no options, got %v", m)
+	}
+}
+
+func TestChangesOptionsHeartbeat(t *testing.T) {
+	m := ChangesOptions{Heartbeat: 1500 * time.Millisecond}.Options()
+	if got := (Database{}).i64defopt(m, "heartbeat", 5000); got != 1500 {
+		t.Errorf("Expected 1500ms heartbeat, got %v", got)
+	}
+}
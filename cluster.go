@@ -0,0 +1,90 @@
+package couch
+
+import (
+	"encoding/json"
+)
+
+// Membership returns the nodes the server knows of (all_nodes) and
+// those configured as members of its cluster (cluster_nodes), ignoring
+// Database.Name.  A node in the cluster but not in allNodes is down; a
+// node in allNodes but not the cluster is up but hasn't been joined.
+func (p Database) Membership() (allNodes, clusterNodes []string, err error) {
+	m := struct {
+		AllNodes     []string `json:"all_nodes"`
+		ClusterNodes []string `json:"cluster_nodes"`
+	}{}
+	err = p.unmarshalURL(p.serverURL("_membership"), &m)
+	return m.AllNodes, m.ClusterNodes, err
+}
+
+// States of cluster setup, as reported by ClusterSetupState.
+const (
+	ClusterDisabled    = "cluster_disabled"
+	ClusterEnabled     = "cluster_enabled"
+	ClusterFinished    = "cluster_finished"
+	SingleNodeDisabled = "single_node_disabled"
+	SingleNodeEnabled  = "single_node_enabled"
+)
+
+// ClusterSetupState returns how far setup of the server's cluster has
+// got (ignores Database.Name).
+func (p Database) ClusterSetupState() (string, error) {
+	s := struct {
+		State string `json:"state"`
+	}{}
+	err := p.unmarshalURL(p.serverURL("_cluster_setup"), &s)
+	return s.State, err
+}
+
+// Cluster setup actions.
+const (
+	EnableCluster    = "enable_cluster"
+	AddNode          = "add_node"
+	FinishCluster    = "finish_cluster"
+	EnableSingleNode = "enable_single_node"
+)
+
+// ClusterSetupAction is a step of cluster setup.  A cluster is
+// assembled by enabling clustering on the coordinating node, enabling
+// it on each other node (by way of the coordinator, with RemoteNode),
+// adding each other node, then finishing.  Fields not needed by the
+// action may be left empty.
+type ClusterSetupAction struct {
+	Action string `json:"action"`
+
+	// Admin credentials to create, or to use on a node being added.
+	Username string `json:"username,omitempty"`
+	Password string `json:"password,omitempty"`
+
+	// Where the node listens, for EnableCluster and EnableSingleNode.
+	BindAddress string `json:"bind_address,omitempty"`
+	// Port of the node, or of the node being added.
+	Port int `json:"port,omitempty"`
+	// Number of nodes the cluster will have, for EnableCluster.
+	NodeCount int `json:"node_count,omitempty"`
+
+	// Another node to enable clustering on, for EnableCluster, with
+	// the credentials of its current admin.
+	RemoteNode            string `json:"remote_node,omitempty"`
+	RemoteCurrentUser     string `json:"remote_current_user,omitempty"`
+	RemoteCurrentPassword string `json:"remote_current_password,omitempty"`
+
+	// Host of the node being added, for AddNode.
+	Host string `json:"host,omitempty"`
+
+	// System databases to create, for FinishCluster, if not the
+	// defaults.
+	EnsureDBsExist []string `json:"ensure_dbs_exist,omitempty"`
+}
+
+// ClusterSetup performs a step of cluster setup (ignores
+// Database.Name).
+func (p Database) ClusterSetup(a ClusterSetupAction) error {
+	body, err := json.Marshal(a)
+	if err != nil {
+		return err
+	}
+	_, err = p.interact("POST", p.serverURL("_cluster_setup"), p.defaultHdrs,
+		body, &Response{})
+	return err
+}
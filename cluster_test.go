@@ -0,0 +1,59 @@
+package couch
+
+import (
+	"io/ioutil"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/dustin/go-couch/couchtest"
+)
+
+func TestMembership(t *testing.T) {
+	f := couchtest.NewTransport(docResponse(`{
+		"all_nodes": ["couchdb@a", "couchdb@b"],
+		"cluster_nodes": ["couchdb@a", "couchdb@c"]}`))
+	defer uninstallFakeHTTP(installFakeHTTP(f))
+
+	all, cluster, err := Database{Name: "db"}.Membership()
+	if err != nil {
+		t.Fatalf("Error fetching membership: %v", err)
+	}
+	if !reflect.DeepEqual(all, []string{"couchdb@a", "couchdb@b"}) ||
+		!reflect.DeepEqual(cluster, []string{"couchdb@a", "couchdb@c"}) {
+		t.Errorf("Unexpected membership %v %v", all, cluster)
+	}
+	if f.Requests[0].URL.Path != "/_membership" {
+		t.Errorf("Unexpected request %v", f.Requests[0].URL)
+	}
+}
+
+func TestClusterSetup(t *testing.T) {
+	f := &couchtest.Transport{Responses: []*http.Response{
+		docResponse(`{"state": "cluster_disabled"}`),
+		{StatusCode: 201, Body: ioutil.NopCloser(strings.NewReader(`{"ok": true}`))},
+	}}
+	defer uninstallFakeHTTP(installFakeHTTP(f))
+
+	d := Database{}
+	state, err := d.ClusterSetupState()
+	if err != nil || state != ClusterDisabled {
+		t.Errorf("Unexpected state %q, %v", state, err)
+	}
+	err = d.ClusterSetup(ClusterSetupAction{
+		Action: AddNode, Host: "10.0.0.2", Port: 5984,
+		Username: "admin", Password: "pw",
+	})
+	if err != nil {
+		t.Fatalf("Error adding node: %v", err)
+	}
+
+	req := f.Requests[1]
+	body, _ := ioutil.ReadAll(req.Body)
+	exp := `{"action":"add_node","username":"admin","password":"pw",` +
+		`"port":5984,"host":"10.0.0.2"}`
+	if req.Method != "POST" || req.URL.Path != "/_cluster_setup" || string(body) != exp {
+		t.Errorf("Unexpected request %v %v %s", req.Method, req.URL, body)
+	}
+}
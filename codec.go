@@ -0,0 +1,338 @@
+package couch
+
+import (
+	"bytes"
+	"encoding"
+	"encoding/json"
+	"math/big"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// TimeEncoding is how a Codec stores time.Time values.
+type TimeEncoding int
+
+const (
+	// TimeRFC3339 stores times as strings, as encoding/json does.
+	TimeRFC3339 TimeEncoding = iota
+	// TimeUnix stores times as whole seconds since the epoch.
+	TimeUnix
+	// TimeUnixMilli stores times as milliseconds since the epoch, as
+	// JavaScript's Date.getTime() returns them.
+	TimeUnixMilli
+)
+
+// BytesEncoding is how a Codec stores []byte values.
+type BytesEncoding int
+
+const (
+	// BytesBase64 stores bytes as base64 strings, as encoding/json
+	// does.
+	BytesBase64 BytesEncoding = iota
+	// BytesAttachment stores the []byte fields of a document as
+	// attachments named after the fields, keeping them out of the
+	// document body and views.  Nested fields are stored as base64.
+	BytesAttachment
+)
+
+// Codec controls how values of types that JSON handles poorly are
+// stored.  See WithCodec.
+type Codec struct {
+	Time  TimeEncoding
+	Bytes BytesEncoding
+	// StringBigNumbers stores int64, uint64 and big.Int values as
+	// strings, since JavaScript, and so views, can't represent
+	// integers beyond 2^53 exactly.
+	StringBigNumbers bool
+}
+
+// WithCodec returns a copy of the database that stores the fields of
+// the documents it writes and retrieves according to c, rather than
+// each type needing its own MarshalJSON and UnmarshalJSON.
+//
+// Values are recognized by their Go types, so documents should be
+// structs or maps of them rather than interface{} values.  Types with
+// their own JSON or text marshaling are left alone, as are fields with
+// the ",string" option.  View results aren't decoded.
+func (p Database) WithCodec(c Codec) Database {
+	p.codec = &c
+	return p
+}
+
+// attachments reports whether documents need their attachments.
+func (c *Codec) attachments() bool {
+	return c != nil && c.Bytes == BytesAttachment
+}
+
+// encode converts the encoded document d for storage.
+func (c *Codec) encode(data []byte, d interface{}) ([]byte, error) {
+	tree, err := decodeTree(data)
+	if err != nil {
+		return nil, err
+	}
+	t := reflect.TypeOf(d)
+	if t == nil {
+		return data, nil
+	}
+	tree = c.walk(tree, t, c.encodeValue)
+	if c.Bytes == BytesAttachment {
+		if m, ok := tree.(map[string]interface{}); ok {
+			for _, f := range byteFields(t) {
+				if s, ok := m[f].(string); ok {
+					delete(m, f)
+					inlineAttachment(m, f, s)
+				}
+			}
+		}
+	}
+	return json.Marshal(tree)
+}
+
+// decode converts a stored document for decoding into d.
+func (c *Codec) decode(data []byte, d interface{}) ([]byte, error) {
+	tree, err := decodeTree(data)
+	if err != nil {
+		return nil, err
+	}
+	t := reflect.TypeOf(d)
+	if t == nil {
+		return data, nil
+	}
+	if c.Bytes == BytesAttachment {
+		if m, ok := tree.(map[string]interface{}); ok {
+			for _, f := range byteFields(t) {
+				if s, ok := attachmentData(m, f); ok {
+					if _, set := m[f]; !set {
+						m[f] = s
+					}
+				}
+			}
+		}
+	}
+	return json.Marshal(c.walk(tree, t, c.decodeValue))
+}
+
+func decodeTree(data []byte) (interface{}, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var v interface{}
+	err := dec.Decode(&v)
+	return v, err
+}
+
+var (
+	timeType          = reflect.TypeOf(time.Time{})
+	bigIntType        = reflect.TypeOf(big.Int{})
+	bytesType         = reflect.TypeOf([]byte(nil))
+	marshalerType     = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+	textMarshalerType = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+)
+
+// marshals reports whether t encodes itself.
+func marshals(t reflect.Type) bool {
+	pt := reflect.PtrTo(t)
+	return t.Implements(marshalerType) || pt.Implements(marshalerType) ||
+		t.Implements(textMarshalerType) || pt.Implements(textMarshalerType)
+}
+
+// handles reports whether the codec converts values of type t.
+func (c *Codec) handles(t reflect.Type) bool {
+	switch {
+	case t == timeType:
+		return c.Time != TimeRFC3339
+	case t == bigIntType:
+		return c.StringBigNumbers
+	case marshals(t):
+		return false
+	}
+	k := t.Kind()
+	return (k == reflect.Int64 || k == reflect.Uint64) && c.StringBigNumbers
+}
+
+// walk replaces each value within the decoded JSON v whose Go type,
+// following t, the codec handles with conv's conversion of it.
+func (c *Codec) walk(v interface{}, t reflect.Type,
+	conv func(reflect.Type, interface{}) interface{}) interface{} {
+
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if c.handles(t) {
+		return conv(t, v)
+	}
+	if marshals(t) {
+		return v
+	}
+
+	switch t.Kind() {
+	case reflect.Struct:
+		m, ok := v.(map[string]interface{})
+		if !ok {
+			return v
+		}
+		for _, f := range jsonFields(t) {
+			if k, ok := lookupKey(m, f.name); ok && !f.quoted {
+				m[k] = c.walk(m[k], f.typ, conv)
+			}
+		}
+	case reflect.Map:
+		if m, ok := v.(map[string]interface{}); ok {
+			for k, e := range m {
+				m[k] = c.walk(e, t.Elem(), conv)
+			}
+		}
+	case reflect.Slice, reflect.Array:
+		if a, ok := v.([]interface{}); ok {
+			for i, e := range a {
+				a[i] = c.walk(e, t.Elem(), conv)
+			}
+		}
+	}
+	return v
+}
+
+func (c *Codec) encodeValue(t reflect.Type, v interface{}) interface{} {
+	if t != timeType {
+		if n, ok := v.(json.Number); ok {
+			return string(n)
+		}
+		return v
+	}
+	s, ok := v.(string)
+	if !ok {
+		return v
+	}
+	tm, err := time.Parse(time.RFC3339Nano, s)
+	if err != nil {
+		return v
+	}
+	n := tm.Unix()
+	if c.Time == TimeUnixMilli {
+		n = n*1000 + int64(tm.Nanosecond())/int64(time.Millisecond)
+	}
+	return json.Number(strconv.FormatInt(n, 10))
+}
+
+func (c *Codec) decodeValue(t reflect.Type, v interface{}) interface{} {
+	if t != timeType {
+		if s, ok := v.(string); ok {
+			if _, ok := new(big.Int).SetString(s, 10); ok {
+				return json.Number(s)
+			}
+		}
+		return v
+	}
+	n, ok := v.(json.Number)
+	if !ok {
+		return v
+	}
+	f, err := n.Float64()
+	if err != nil {
+		return v
+	}
+	var tm time.Time
+	if c.Time == TimeUnixMilli {
+		ms := int64(f)
+		tm = time.Unix(ms/1000, (ms%1000)*int64(time.Millisecond))
+	} else {
+		tm = time.Unix(int64(f), 0)
+	}
+	return tm.UTC().Format(time.RFC3339Nano)
+}
+
+type jsonField struct {
+	name   string
+	typ    reflect.Type
+	quoted bool
+}
+
+// jsonFields lists the fields of struct type t as encoding/json names
+// them, including those of embedded structs.
+func jsonFields(t reflect.Type) []jsonField {
+	var fields []jsonField
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		tag := sf.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		opts := strings.Split(tag, ",")
+		name := opts[0]
+
+		ft := sf.Type
+		if sf.Anonymous && name == "" {
+			for ft.Kind() == reflect.Ptr {
+				ft = ft.Elem()
+			}
+			if ft.Kind() == reflect.Struct {
+				fields = append(fields, jsonFields(ft)...)
+				continue
+			}
+		}
+		if sf.PkgPath != "" {
+			continue
+		}
+		if name == "" {
+			name = sf.Name
+		}
+		f := jsonField{name: name, typ: ft}
+		for _, o := range opts[1:] {
+			f.quoted = f.quoted || o == "string"
+		}
+		fields = append(fields, f)
+	}
+	return fields
+}
+
+// lookupKey finds the key of m for the field name, matching
+// case-insensitively as encoding/json does if there's no exact match.
+func lookupKey(m map[string]interface{}, name string) (string, bool) {
+	if _, ok := m[name]; ok {
+		return name, true
+	}
+	for k := range m {
+		if strings.EqualFold(k, name) {
+			return k, true
+		}
+	}
+	return "", false
+}
+
+// byteFields lists the names of the []byte fields of the document type
+// t, if it's a struct.
+func byteFields(t reflect.Type) []string {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct {
+		return nil
+	}
+	var names []string
+	for _, f := range jsonFields(t) {
+		if f.typ == bytesType && !f.quoted {
+			names = append(names, f.name)
+		}
+	}
+	return names
+}
+
+func inlineAttachment(doc map[string]interface{}, name, data string) {
+	atts, _ := doc["_attachments"].(map[string]interface{})
+	if atts == nil {
+		atts = map[string]interface{}{}
+		doc["_attachments"] = atts
+	}
+	atts[name] = map[string]interface{}{
+		"content_type": "application/octet-stream",
+		"data":         data,
+	}
+}
+
+func attachmentData(doc map[string]interface{}, name string) (string, bool) {
+	atts, _ := doc["_attachments"].(map[string]interface{})
+	att, _ := atts[name].(map[string]interface{})
+	data, ok := att["data"].(string)
+	return data, ok
+}
@@ -0,0 +1,143 @@
+package couch
+
+import (
+	"bytes"
+	"encoding/json"
+	"math/big"
+	"net"
+	"net/http"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/dustin/go-couch/couchtest"
+)
+
+type codecDoc struct {
+	ID      string    `json:"_id,omitempty"`
+	When    time.Time `json:"when"`
+	Count   int64     `json:"count"`
+	Small   int       `json:"small"`
+	Quoted  int64     `json:"quoted,string"`
+	Huge    *big.Int  `json:"huge"`
+	Data    []byte    `json:"data"`
+	IP      net.IP    `json:"ip"`
+	History []time.Time
+	Nested  struct {
+		At *time.Time `json:"at"`
+	} `json:"nested"`
+	Any interface{} `json:"any"`
+}
+
+func newCodecDoc() codecDoc {
+	when := time.Date(2020, 5, 17, 10, 30, 0, 250e6, time.UTC)
+	d := codecDoc{
+		ID:      "c",
+		When:    when,
+		Count:   1<<62 + 1,
+		Small:   7,
+		Quoted:  3,
+		Huge:    new(big.Int).Lsh(big.NewInt(1), 70),
+		Data:    []byte("hello"),
+		IP:      net.IPv4(10, 0, 0, 1),
+		History: []time.Time{when.Add(-time.Hour)},
+		Any:     "2020-05-17T10:30:00Z",
+	}
+	d.Nested.At = &when
+	return d
+}
+
+func TestCodecEncode(t *testing.T) {
+	db := Database{}.WithCodec(Codec{Time: TimeUnixMilli, StringBigNumbers: true})
+	b, err := db.marshal(newCodecDoc())
+	if err != nil {
+		t.Fatalf("Error marshaling: %v", err)
+	}
+	got := map[string]interface{}{}
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.UseNumber()
+	must(dec.Decode(&got))
+
+	exp := map[string]interface{}{
+		"_id":     "c",
+		"when":    json.Number("1589711400250"),
+		"count":   "4611686018427387905",
+		"small":   json.Number("7"),
+		"quoted":  "3",
+		"huge":    "1180591620717411303424",
+		"data":    "aGVsbG8=",
+		"ip":      "10.0.0.1",
+		"History": []interface{}{json.Number("1589707800250")},
+		"nested":  map[string]interface{}{"at": json.Number("1589711400250")},
+		"any":     "2020-05-17T10:30:00Z",
+	}
+	if !reflect.DeepEqual(got, exp) {
+		t.Errorf("Expected\n%v\ngot\n%v", exp, got)
+	}
+}
+
+func TestCodecRoundTrip(t *testing.T) {
+	for _, c := range []Codec{
+		{},
+		{Time: TimeUnix, StringBigNumbers: true},
+		{Time: TimeUnixMilli, Bytes: BytesAttachment},
+	} {
+		db := Database{}.WithCodec(c)
+		orig := newCodecDoc()
+		if c.Time == TimeUnix {
+			orig.When = orig.When.Truncate(time.Second)
+			orig.History[0] = orig.History[0].Truncate(time.Second)
+			at := orig.Nested.At.Truncate(time.Second)
+			orig.Nested.At = &at
+		}
+		b, err := db.marshal(orig)
+		if err != nil {
+			t.Fatalf("%+v: error marshaling: %v", c, err)
+		}
+		got := codecDoc{}
+		if err := db.unmarshal(b, &got); err != nil {
+			t.Fatalf("%+v: error unmarshaling %s: %v", c, b, err)
+		}
+		if !got.When.Equal(orig.When) || !got.Nested.At.Equal(*orig.Nested.At) ||
+			!got.History[0].Equal(orig.History[0]) || got.Count != orig.Count ||
+			got.Huge.Cmp(orig.Huge) != 0 || string(got.Data) != "hello" ||
+			!got.IP.Equal(orig.IP) || got.Quoted != 3 {
+			t.Errorf("%+v: round trip changed\n%+v\nto\n%+v", c, orig, got)
+		}
+	}
+}
+
+func TestCodecAttachments(t *testing.T) {
+	f := &couchtest.Transport{Responses: []*http.Response{
+		docResponse(`{"ok": true, "id": "c", "rev": "1-a"}`),
+		docResponse(`{"_id": "c", "_rev": "1-a", "_attachments": {
+			"data": {"content_type": "application/octet-stream",
+				"data": "aGVsbG8="}}}`),
+	}}
+	defer uninstallFakeHTTP(installFakeHTTP(f))
+
+	db := Database{}.WithCodec(Codec{Bytes: BytesAttachment})
+	if _, _, err := db.Insert(codecDoc{ID: "c", Data: []byte("hello")}); err != nil {
+		t.Fatalf("Error inserting: %v", err)
+	}
+	sent := map[string]interface{}{}
+	must(json.NewDecoder(f.Requests[0].Body).Decode(&sent))
+	if _, ok := sent["data"]; ok {
+		t.Errorf("Expected data moved out of the body, got %v", sent)
+	}
+	att := sent["_attachments"].(map[string]interface{})["data"]
+	if att.(map[string]interface{})["data"] != "aGVsbG8=" {
+		t.Errorf("Expected inline attachment, got %v", att)
+	}
+
+	got := codecDoc{}
+	if err := db.Retrieve("c", &got); err != nil {
+		t.Fatalf("Error retrieving: %v", err)
+	}
+	if q := f.Requests[1].URL.Query().Get("attachments"); q != "true" {
+		t.Errorf("Expected attachments requested, got %q", q)
+	}
+	if string(got.Data) != "hello" {
+		t.Errorf("Expected data from attachment, got %q", got.Data)
+	}
+}
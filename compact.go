@@ -0,0 +1,244 @@
+package couch
+
+import (
+	"context"
+	"strings"
+	"time"
+)
+
+// ActiveTask describes a task running on the server, as listed by
+// /_active_tasks.
+type ActiveTask struct {
+	Type           string `json:"type"` // e.g. "database_compaction"
+	Node           string `json:"node"`
+	Database       string `json:"database"`
+	DesignDocument string `json:"design_document"`
+	Progress       int    `json:"progress"` // percent
+	StartedOn      int64  `json:"started_on"`
+	UpdatedOn      int64  `json:"updated_on"`
+}
+
+// forDatabase reports whether the task concerns the named database.
+// Clustered servers report per-shard tasks, with database names like
+// "shards/00000000-1fffffff/name.1234567890", where the name may
+// itself contain slashes.
+func (t ActiveTask) forDatabase(name string) bool {
+	db := t.Database
+	if rest := strings.TrimPrefix(db, "shards/"); rest != db {
+		i := strings.Index(rest, "/")
+		j := strings.LastIndex(rest, ".")
+		if i < 0 || j <= i {
+			return false
+		}
+		db = rest[i+1 : j]
+	}
+	return db == name
+}
+
+// activeTasks lists the tasks running on the server (ignores
+// Database.Name).
+func (p Database) activeTasks() ([]ActiveTask, error) {
+	tasks := []ActiveTask{}
+	err := p.unmarshalURL(p.serverURL("_active_tasks"), &tasks)
+	return tasks, err
+}
+
+var jsonContent = map[string][]string{"Content-Type": {"application/json"}}
+
+// Compact starts compacting the database, returning once it has
+// started.  See WaitCompaction.
+func (p Database) Compact() error {
+	_, err := p.interact("POST", p.dbURL("_compact"), jsonContent, nil, &Response{})
+	return err
+}
+
+// WaitCompaction waits for compaction of the database to finish,
+// checking _active_tasks and the database's info every interval (a
+// second if zero).  progress, if non-nil, is called with each of the
+// compaction's tasks as they're seen; clustered servers run one per
+// shard.  It returns ctx's error if ctx is done first.
+func (p Database) WaitCompaction(ctx context.Context, interval time.Duration,
+	progress func(ActiveTask)) error {
+
+	if interval <= 0 {
+		interval = time.Second
+	}
+	for {
+		err := p.waitTasks(ctx, interval, func(t ActiveTask) bool {
+			return t.Type == "database_compaction"
+		}, progress)
+		if err != nil {
+			return err
+		}
+		info := struct {
+			Compacting bool `json:"compact_running"`
+		}{}
+		if err := p.unmarshalURL(p.DBURL(), &info); err != nil {
+			return err
+		}
+		if !info.Compacting {
+			return nil
+		}
+	}
+}
+
+// CompactView starts compacting the views of the given design document,
+// named with or without the "_design/" prefix, returning once it has
+// started.
+func (p Database) CompactView(ddoc string) error {
+	ddoc = strings.TrimPrefix(ddoc, "_design/")
+	_, err := p.interact("POST", p.dbURL("_compact/"+pathEscape(ddoc)),
+		jsonContent, nil, &Response{})
+	return err
+}
+
+// CompactionWindow is a daily period, given as offsets from midnight,
+// during which compaction may start.  A window whose End is before its
+// Start runs past midnight.
+type CompactionWindow struct {
+	Start, End time.Duration
+}
+
+// CompactionScheduler compacts a database, then the views of selected
+// design documents, once in each of a set of daily windows.  Windows
+// only govern when compactions start; one already running when a window
+// closes carries on to completion.
+type CompactionScheduler struct {
+	// Windows in which compaction may start.
+	Windows []CompactionWindow
+	// Location the windows are in.  Defaults to local time.
+	Location *time.Location
+	// Design documents, without the "_design/" prefix, whose views are
+	// compacted after the database.
+	Views []string
+	// How often to check the time and the progress of a compaction.
+	// Defaults to a minute.
+	PollInterval time.Duration
+	// Progress, if set, is called with each of the compaction's tasks
+	// on every poll.
+	Progress func(ActiveTask)
+
+	db  Database
+	now func() time.Time
+}
+
+// NewCompactionScheduler creates a scheduler that compacts db within
+// the given windows.  Set any other fields before calling Run.
+func NewCompactionScheduler(db Database, windows ...CompactionWindow) *CompactionScheduler {
+	return &CompactionScheduler{
+		Windows:      windows,
+		PollInterval: time.Minute,
+		db:           db,
+		now:          time.Now,
+	}
+}
+
+// Run compacts in each window until ctx is done or a request fails.
+func (s *CompactionScheduler) Run(ctx context.Context) error {
+	var last time.Time
+	for {
+		if start, ok := s.window(s.now()); ok && !start.Equal(last) {
+			if err := s.compactAll(ctx); err != nil {
+				return err
+			}
+			last = start
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(s.PollInterval):
+		}
+	}
+}
+
+// window returns the start of the window t falls in, if any.
+func (s *CompactionScheduler) window(t time.Time) (time.Time, bool) {
+	loc := s.Location
+	if loc == nil {
+		loc = time.Local
+	}
+	t = t.In(loc)
+	midnight := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, loc)
+	tod := t.Sub(midnight)
+
+	for _, w := range s.Windows {
+		switch {
+		case w.Start <= w.End && tod >= w.Start && tod < w.End:
+			return midnight.Add(w.Start), true
+		case w.Start > w.End && tod >= w.Start:
+			return midnight.Add(w.Start), true
+		case w.Start > w.End && tod < w.End:
+			return midnight.AddDate(0, 0, -1).Add(w.Start), true
+		}
+	}
+	return time.Time{}, false
+}
+
+// compactAll compacts the database and then each view in turn, waiting
+// for each to finish.  Views aren't started once the window has closed.
+func (s *CompactionScheduler) compactAll(ctx context.Context) error {
+	if err := s.db.Compact(); err != nil {
+		return err
+	}
+	err := s.wait(ctx, func(t ActiveTask) bool {
+		return t.Type == "database_compaction"
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, ddoc := range s.Views {
+		if _, ok := s.window(s.now()); !ok {
+			return nil
+		}
+		if err := s.db.CompactView(ddoc); err != nil {
+			return err
+		}
+		err := s.wait(ctx, func(t ActiveTask) bool {
+			return t.Type == "view_compaction" &&
+				strings.TrimPrefix(t.DesignDocument, "_design/") == ddoc
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// wait polls until no matching compaction task of the database is
+// running.
+func (s *CompactionScheduler) wait(ctx context.Context, match func(ActiveTask) bool) error {
+	return s.db.waitTasks(ctx, s.PollInterval, match, s.Progress)
+}
+
+// waitTasks polls every interval until no matching task of the
+// database is running, passing those that are to progress, if set.
+func (p Database) waitTasks(ctx context.Context, interval time.Duration,
+	match func(ActiveTask) bool, progress func(ActiveTask)) error {
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(interval):
+		}
+
+		tasks, err := p.activeTasks()
+		if err != nil {
+			return err
+		}
+		running := false
+		for _, t := range tasks {
+			if match(t) && t.forDatabase(p.Name) {
+				running = true
+				if progress != nil {
+					progress(t)
+				}
+			}
+		}
+		if !running {
+			return nil
+		}
+	}
+}
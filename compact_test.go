@@ -0,0 +1,177 @@
+package couch
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/dustin/go-couch/couchtest"
+)
+
+func TestActiveTaskForDatabase(t *testing.T) {
+	tests := []struct {
+		db, name string
+		exp      bool
+	}{
+		{"db", "db", true},
+		{"dbx", "db", false},
+		{"shards/00000000-1fffffff/db.1525345786", "db", true},
+		{"shards/00000000-1fffffff/other.1525345786", "db", false},
+		{"a/b", "a/b", true},
+		{"a/b", "b", false},
+		{"shards/00000000-1fffffff/a/b.1525345786", "a/b", true},
+		{"shards/00000000-1fffffff/a/b.1525345786", "b", false},
+		{"shards/00000000-1fffffff/b.1525345786", "a/b", false},
+	}
+	for _, test := range tests {
+		if got := (ActiveTask{Database: test.db}).forDatabase(test.name); got != test.exp {
+			t.Errorf("%q for %q: expected %v, got %v", test.db, test.name, test.exp, got)
+		}
+	}
+}
+
+func TestCompactionWindow(t *testing.T) {
+	s := NewCompactionScheduler(Database{},
+		CompactionWindow{2 * time.Hour, 4 * time.Hour},
+		CompactionWindow{22 * time.Hour, time.Hour})
+	s.Location = time.UTC
+
+	day := func(d, h, m int) time.Time {
+		return time.Date(2020, 1, d, h, m, 0, 0, time.UTC)
+	}
+	tests := []struct {
+		t     time.Time
+		start time.Time
+		ok    bool
+	}{
+		{day(5, 1, 59), day(5, 1, 59), false},
+		{day(5, 2, 0), day(5, 2, 0), true},
+		{day(5, 3, 30), day(5, 2, 0), true},
+		{day(5, 4, 0), time.Time{}, false},
+		{day(5, 12, 0), time.Time{}, false},
+		{day(5, 23, 0), day(5, 22, 0), true},
+		{day(6, 0, 30), day(5, 22, 0), true},
+		{day(6, 1, 0), time.Time{}, false},
+	}
+	for _, test := range tests {
+		start, ok := s.window(test.t)
+		if ok != test.ok || (ok && !start.Equal(test.start)) {
+			t.Errorf("%v: expected %v, %v; got %v, %v",
+				test.t, test.start, test.ok, start, ok)
+		}
+	}
+}
+
+func TestCompactAll(t *testing.T) {
+	f := &couchtest.Transport{Responses: []*http.Response{
+		docResponse(`{"ok": true}`),
+		docResponse(`[{"type": "database_compaction", "database": "db", "progress": 40},
+			{"type": "database_compaction", "database": "other", "progress": 10}]`),
+		docResponse(`[{"type": "database_compaction", "database": "db", "progress": 90}]`),
+		docResponse(`[]`),
+		docResponse(`{"ok": true}`),
+		docResponse(`[{"type": "view_compaction", "database": "db",
+			"design_document": "_design/d", "progress": 5}]`),
+		docResponse(`[]`),
+	}}
+	defer uninstallFakeHTTP(installFakeHTTP(f))
+
+	s := NewCompactionScheduler(Database{Host: "localhost", Port: "5984", Name: "db"},
+		CompactionWindow{0, 24 * time.Hour})
+	s.Views = []string{"d"}
+	s.PollInterval = time.Millisecond
+	var progress []int
+	s.Progress = func(t ActiveTask) { progress = append(progress, t.Progress) }
+
+	if err := s.compactAll(context.Background()); err != nil {
+		t.Fatalf("Error compacting: %v", err)
+	}
+
+	if len(f.Requests) != 7 {
+		t.Fatalf("Expected 7 requests, got %v", len(f.Requests))
+	}
+	for i, exp := range map[int]string{
+		0: "POST http://localhost:5984/db/_compact",
+		1: "GET http://localhost:5984/_active_tasks",
+		4: "POST http://localhost:5984/db/_compact/d",
+	} {
+		req := f.Requests[i]
+		if got := req.Method + " " + req.URL.String(); got != exp {
+			t.Errorf("Request %v: expected %v, got %v", i, exp, got)
+		}
+	}
+	if ct := f.Requests[0].Header.Get("Content-Type"); ct != "application/json" {
+		t.Errorf("Expected json content type, got %q", ct)
+	}
+	if len(progress) != 3 || progress[0] != 40 || progress[2] != 5 {
+		t.Errorf("Expected progress 40, 90, 5; got %v", progress)
+	}
+}
+
+func TestCompactionSchedulerOutsideWindow(t *testing.T) {
+	f := &couchtest.Transport{}
+	defer uninstallFakeHTTP(installFakeHTTP(f))
+
+	s := NewCompactionScheduler(Database{}, CompactionWindow{time.Hour, 2 * time.Hour})
+	s.Location = time.UTC
+	s.PollInterval = time.Millisecond
+	s.now = func() time.Time { return time.Date(2020, 1, 1, 12, 0, 0, 0, time.UTC) }
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := s.Run(ctx); err != context.DeadlineExceeded {
+		t.Errorf("Expected deadline exceeded, got %v", err)
+	}
+	if len(f.Requests) != 0 {
+		t.Errorf("Expected no requests outside the window, got %v", len(f.Requests))
+	}
+}
+
+func TestWaitCompaction(t *testing.T) {
+	f := &couchtest.Transport{Responses: []*http.Response{
+		docResponse(`[{"type": "database_compaction", "database": "db", "progress": 50}]`),
+		docResponse(`[]`),
+		docResponse(`{"db_name": "db", "compact_running": true}`),
+		docResponse(`[]`),
+		docResponse(`{"db_name": "db", "compact_running": false}`),
+	}}
+	defer uninstallFakeHTTP(installFakeHTTP(f))
+
+	var progress []int
+	err := Database{Name: "db"}.WaitCompaction(context.Background(), time.Millisecond,
+		func(t ActiveTask) { progress = append(progress, t.Progress) })
+	if err != nil {
+		t.Fatalf("Error waiting: %v", err)
+	}
+	if len(f.Requests) != 5 || len(progress) != 1 || progress[0] != 50 {
+		t.Errorf("Unexpected %v requests, progress %v", len(f.Requests), progress)
+	}
+}
+
+func TestWaitCompactionCanceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	err := Database{}.WaitCompaction(ctx, time.Hour, nil)
+	if err != context.Canceled {
+		t.Errorf("Expected canceled, got %v", err)
+	}
+}
+
+func TestCompactView(t *testing.T) {
+	f := &couchtest.Transport{Responses: []*http.Response{
+		docResponse(`{"ok": true}`), docResponse(`{"ok": true}`)}}
+	defer uninstallFakeHTTP(installFakeHTTP(f))
+
+	d := Database{Name: "db"}
+	for _, ddoc := range []string{"d/x", "_design/d/x"} {
+		if err := d.CompactView(ddoc); err != nil {
+			t.Fatalf("Error compacting %v: %v", ddoc, err)
+		}
+	}
+	for _, req := range f.Requests {
+		if req.Method != "POST" || req.URL.EscapedPath() != "/db/_compact/d%2Fx" {
+			t.Errorf("Unexpected request %v %v", req.Method, req.URL.EscapedPath())
+		}
+	}
+}
@@ -0,0 +1,209 @@
+package couch
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"time"
+)
+
+// Config describes how to connect to a database.  See FromConfig.
+type Config struct {
+	// URL of the database, e.g. "https://couch.example.com/mydb".
+	URL string
+	// Username and Password, if set, override credentials in URL.
+	Username, Password string
+	// Authenticate with a session cookie, rather than basic
+	// authentication, using the credentials.  See WithSessionAuth.
+	CookieAuth bool
+	// TLS configuration for https connections.
+	TLS *tls.Config
+	// Timeout limits each request (but not the changes feed).
+	Timeout time.Duration
+	// Timeouts limit requests by the kind of operation.
+	Timeouts *Timeouts
+	// Create the database if it doesn't already exist.
+	Create bool
+	// Options for creating the database.
+	CreateOptions CreateDatabaseOptions
+	// Retry transient failures according to this policy.
+	Retry *RetryPolicy
+	// Retry requests refused with 429 according to this policy.
+	ThrottleRetry *RetryPolicy
+	// Fail fast according to this policy when the server misbehaves.
+	Breaker *BreakerPolicy
+	// Limit attachment and streamed document transfers to this many
+	// bytes per second.
+	BandwidthLimit int64
+	// Close connections after each request rather than reusing them.
+	DisableKeepAlive bool
+	// Headers to send with every request.
+	Headers http.Header
+}
+
+var errNoURL = errors.New("no database URL configured")
+
+// FromConfig connects to the database described by c.
+func FromConfig(c Config) (Database, error) {
+	if c.URL == "" {
+		return Database{}, errNoURL
+	}
+	u, err := url.Parse(c.URL)
+	if err != nil {
+		return Database{}, err
+	}
+	if c.Username != "" {
+		u.User = url.UserPassword(c.Username, c.Password)
+	}
+	var session *SessionAuth
+	if c.CookieAuth && u.User != nil {
+		password, _ := u.User.Password()
+		session = &SessionAuth{Username: u.User.Username(), Password: password}
+		u.User = nil
+	}
+
+	db := newDatabase(u)
+	if c.TLS != nil || c.Timeout > 0 {
+		db.client = &http.Client{
+			Timeout: c.Timeout,
+			Transport: &http.Transport{
+				Proxy:           http.ProxyFromEnvironment,
+				TLSClientConfig: c.TLS,
+			},
+		}
+		db.tlsConfig = c.TLS
+	}
+	if c.Retry != nil {
+		db = db.WithRetry(*c.Retry)
+	}
+	if c.ThrottleRetry != nil {
+		db = db.WithThrottleRetry(*c.ThrottleRetry)
+	}
+	if c.Breaker != nil {
+		db = db.WithCircuitBreaker(*c.Breaker)
+	}
+	if c.Timeouts != nil {
+		db = db.WithTimeouts(*c.Timeouts)
+	}
+	db = db.WithBandwidthLimit(c.BandwidthLimit)
+	if c.DisableKeepAlive {
+		db = db.WithoutKeepAlive()
+	}
+	if session != nil {
+		db = db.WithSessionAuth(*session)
+	}
+	if c.Headers != nil {
+		db = db.WithHeaders(c.Headers)
+	}
+
+	if !db.Running() {
+		return Database{}, errNotRunning
+	}
+	if !db.Exists() {
+		if !c.Create {
+			return Database{}, errors.New("database does not exist")
+		}
+		if err := db.CreateDatabase(c.CreateOptions); err != nil {
+			return Database{}, err
+		}
+	}
+
+	return db, nil
+}
+
+// ConfigFromEnv builds a Config from the following environment
+// variables:
+//
+//	COUCHDB_URL       database URL (required)
+//	COUCHDB_USER      username
+//	COUCHDB_PASSWORD  password
+//	COUCHDB_TIMEOUT   per-request timeout, e.g. "30s"
+//	COUCHDB_CA_FILE   PEM file of CA certificates to trust
+//	COUCHDB_INSECURE  "true" to skip TLS certificate verification
+//	COUCHDB_CREATE    "true" to create the database if it's missing
+//	COUCHDB_RETRIES   number of times to retry transient failures
+func ConfigFromEnv() (Config, error) {
+	c := Config{
+		URL:      os.Getenv("COUCHDB_URL"),
+		Username: os.Getenv("COUCHDB_USER"),
+		Password: os.Getenv("COUCHDB_PASSWORD"),
+	}
+	if c.URL == "" {
+		return c, errNoURL
+	}
+
+	if s := os.Getenv("COUCHDB_TIMEOUT"); s != "" {
+		d, err := time.ParseDuration(s)
+		if err != nil {
+			return c, fmt.Errorf("invalid COUCHDB_TIMEOUT: %v", err)
+		}
+		c.Timeout = d
+	}
+
+	if s := os.Getenv("COUCHDB_RETRIES"); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil {
+			return c, fmt.Errorf("invalid COUCHDB_RETRIES: %v", err)
+		}
+		r := DefaultRetryPolicy
+		r.MaxRetries = n
+		c.Retry = &r
+	}
+
+	var err error
+	if c.Create, err = envBool("COUCHDB_CREATE"); err != nil {
+		return c, err
+	}
+	insecure, err := envBool("COUCHDB_INSECURE")
+	if err != nil {
+		return c, err
+	}
+	if insecure {
+		c.TLS = &tls.Config{InsecureSkipVerify: true}
+	}
+
+	if fn := os.Getenv("COUCHDB_CA_FILE"); fn != "" {
+		pem, err := ioutil.ReadFile(fn)
+		if err != nil {
+			return c, err
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return c, fmt.Errorf("no certificates found in %v", fn)
+		}
+		if c.TLS == nil {
+			c.TLS = &tls.Config{}
+		}
+		c.TLS.RootCAs = pool
+	}
+
+	return c, nil
+}
+
+func envBool(k string) (bool, error) {
+	s := os.Getenv(k)
+	if s == "" {
+		return false, nil
+	}
+	b, err := strconv.ParseBool(s)
+	if err != nil {
+		return false, fmt.Errorf("invalid %v: %v", k, err)
+	}
+	return b, nil
+}
+
+// FromEnv connects to the database described by the environment.
+// See ConfigFromEnv.
+func FromEnv() (Database, error) {
+	c, err := ConfigFromEnv()
+	if err != nil {
+		return Database{}, err
+	}
+	return FromConfig(c)
+}
@@ -0,0 +1,170 @@
+package couch
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// Conflicts returns the winning revision of the document matching id,
+// and the revisions that conflict with it.
+func (p Database) Conflicts(id string) (string, []string, error) {
+	doc := struct {
+		Rev string `json:"_rev"`
+		ConflictInfo
+	}{}
+	err := p.RetrieveWith(id, map[string]interface{}{"conflicts": true}, &doc)
+	return doc.Rev, doc.Conflicts, err
+}
+
+// ConflictInfo holds the revisions of a document that conflict with
+// its winning revision, as retrieved with conflicts=true.  Embed it in
+// a document's struct to decode them along with the document.
+type ConflictInfo struct {
+	Conflicts []string `json:"_conflicts,omitempty"`
+	// Conflicting revisions that have since been deleted, as retrieved
+	// with deleted_conflicts=true.
+	DeletedConflicts []string `json:"_deleted_conflicts,omitempty"`
+}
+
+// HasConflicts reports whether there are live conflicting revisions.
+func (c ConflictInfo) HasConflicts() bool {
+	return len(c.Conflicts) > 0
+}
+
+// RetrieveConflicts retrieves the document matching id into d, as
+// Retrieve does, along with its conflicting and deleted conflicting
+// revisions.  d may be nil to get only those.
+func (p Database) RetrieveConflicts(id string, d interface{}) (ConflictInfo, error) {
+	info := ConflictInfo{}
+	if id == "" {
+		return info, errNoID
+	}
+	u, err := p.docURLWith(id, p.retrieveOptions(map[string]interface{}{
+		"conflicts": true, "deleted_conflicts": true,
+	}))
+	if err != nil {
+		return info, err
+	}
+	var raw json.RawMessage
+	if err := p.unmarshalURL(u, &raw); err != nil {
+		return info, err
+	}
+	if err := json.Unmarshal(raw, &info); err != nil {
+		return info, err
+	}
+	if d == nil {
+		return info, nil
+	}
+	return info, p.unmarshal(raw, d)
+}
+
+// DocConflicts is a document with conflicts, from EachConflict.
+type DocConflicts struct {
+	ID  string `json:"_id"`
+	Rev string `json:"_rev"` // the winning revision
+	ConflictInfo
+}
+
+// EachConflict scans every document in the database (with _all_docs,
+// limited by any options given for it) for conflicts, calling f with
+// each document that has any, as it's found.  An error from f stops the
+// scan and is returned.  Deleted conflicts aren't reported.
+func (p Database) EachConflict(options map[string]interface{}, f func(DocConflicts) error) error {
+	opts := make(map[string]interface{}, len(options)+2)
+	for k, v := range options {
+		opts[k] = v
+	}
+	opts["include_docs"] = true
+	opts["conflicts"] = true
+	return p.QueryEach("_all_docs", opts, func(row ViewRow) error {
+		if len(row.Doc) == 0 {
+			return nil
+		}
+		doc := DocConflicts{}
+		if err := json.Unmarshal(row.Doc, &doc); err != nil {
+			return err
+		}
+		if !doc.HasConflicts() {
+			return nil
+		}
+		return f(doc)
+	})
+}
+
+// A ConflictResolver settles a document's conflicts.  It's given the
+// document's live leaf revisions, the current winner first, and
+// returns the document to keep: a merge of them, or one of their Docs
+// to pick it.  Its "_id" and "_rev" are ignored.  Returning nil keeps
+// the winner as it is.
+type ConflictResolver func(leaves []LeafRev) (interface{}, error)
+
+// ResolveConflicts resolves the conflicts of the document matching id
+// with resolve, then in a single _bulk_docs request writes the result
+// over the winning revision and deletes the others.  It returns the
+// winning revision afterwards, and does nothing if there are no
+// conflicts.
+func (p Database) ResolveConflicts(id string, resolve ConflictResolver) (string, error) {
+	winner, conflicts, err := p.Conflicts(id)
+	if err != nil || len(conflicts) == 0 {
+		return winner, err
+	}
+
+	all, err := p.OpenRevs(id, append([]string{winner}, conflicts...)...)
+	if err != nil {
+		return "", err
+	}
+	var leaves []LeafRev
+	for _, l := range all {
+		if l.Missing || l.Deleted {
+			continue
+		}
+		if l.Rev == winner {
+			leaves = append([]LeafRev{l}, leaves...)
+		} else {
+			leaves = append(leaves, l)
+		}
+	}
+
+	keep, err := resolve(leaves)
+	if err != nil {
+		return "", err
+	}
+
+	var docs []interface{}
+	if keep != nil {
+		full, err := p.marshal(keep)
+		if err != nil {
+			return "", err
+		}
+		body, _, _, err := stripIDRev(full)
+		if err != nil {
+			return "", err
+		}
+		docs = append(docs, json.RawMessage(withIDRev(body, id, winner)))
+	}
+	for _, rev := range conflicts {
+		docs = append(docs, map[string]interface{}{
+			"_id": id, "_rev": rev, "_deleted": true,
+		})
+	}
+
+	body, err := json.Marshal(map[string]interface{}{"docs": docs})
+	if err != nil {
+		return "", err
+	}
+	results := []Response{}
+	if _, err := p.interact("POST", p.dbURL("_bulk_docs"), p.defaultHdrs,
+		body, &results); err != nil {
+		return "", err
+	}
+	for _, r := range results {
+		if r.Error != "" {
+			return "", fmt.Errorf("resolving conflicts of %s: %s: %s",
+				id, r.Error, r.Reason)
+		}
+	}
+	if keep != nil && len(results) > 0 {
+		return results[0].Rev, nil
+	}
+	return winner, nil
+}
@@ -0,0 +1,183 @@
+package couch
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/dustin/go-couch/couchtest"
+)
+
+func TestResolveConflicts(t *testing.T) {
+	f := &couchtest.Transport{Responses: []*http.Response{
+		docResponse(`{"_id": "a", "_rev": "2-b", "_conflicts": ["2-c"]}`),
+		docResponse(`[
+			{"ok": {"_id": "a", "_rev": "2-c", "tags": ["y"]}},
+			{"ok": {"_id": "a", "_rev": "2-b", "tags": ["x"]}}
+		]`),
+		docResponse(`[{"ok": true, "id": "a", "rev": "3-d"},
+			{"ok": true, "id": "a", "rev": "3-e"}]`),
+	}}
+	defer uninstallFakeHTTP(installFakeHTTP(f))
+
+	var seen []string
+	rev, err := Database{Name: "db"}.ResolveConflicts("a",
+		func(leaves []LeafRev) (interface{}, error) {
+			merged := map[string][]string{}
+			for _, l := range leaves {
+				seen = append(seen, l.Rev)
+				doc := struct{ Tags []string }{}
+				if err := l.Decode(&doc); err != nil {
+					return nil, err
+				}
+				merged["tags"] = append(merged["tags"], doc.Tags...)
+			}
+			return merged, nil
+		})
+	if err != nil || rev != "3-d" {
+		t.Fatalf("Expected rev 3-d, got %v, %v", rev, err)
+	}
+	if !reflect.DeepEqual(seen, []string{"2-b", "2-c"}) {
+		t.Errorf("Expected winner first, got %v", seen)
+	}
+
+	written := struct {
+		Docs []map[string]interface{} `json:"docs"`
+	}{}
+	must(json.NewDecoder(f.Requests[2].Body).Decode(&written))
+	exp := []map[string]interface{}{
+		{"_id": "a", "_rev": "2-b", "tags": []interface{}{"x", "y"}},
+		{"_id": "a", "_rev": "2-c", "_deleted": true},
+	}
+	if !reflect.DeepEqual(written.Docs, exp) {
+		t.Errorf("Expected %v, got %v", exp, written.Docs)
+	}
+}
+
+func TestResolveConflictsKeepWinner(t *testing.T) {
+	f := &couchtest.Transport{Responses: []*http.Response{
+		docResponse(`{"_id": "a", "_rev": "2-b", "_conflicts": ["2-c", "2-d"]}`),
+		docResponse(`[{"ok": {"_id": "a", "_rev": "2-b"}},
+			{"ok": {"_id": "a", "_rev": "2-c"}}, {"missing": "2-d"}]`),
+		docResponse(`[{"ok": true, "id": "a", "rev": "3-c"},
+			{"ok": true, "id": "a", "rev": "3-d"}]`),
+	}}
+	defer uninstallFakeHTTP(installFakeHTTP(f))
+
+	rev, err := Database{}.ResolveConflicts("a",
+		func(leaves []LeafRev) (interface{}, error) {
+			if len(leaves) != 2 {
+				t.Errorf("Expected missing leaf left out, got %+v", leaves)
+			}
+			return nil, nil
+		})
+	if err != nil || rev != "2-b" {
+		t.Fatalf("Expected winner 2-b kept, got %v, %v", rev, err)
+	}
+	written := struct {
+		Docs []map[string]interface{} `json:"docs"`
+	}{}
+	must(json.NewDecoder(f.Requests[2].Body).Decode(&written))
+	if len(written.Docs) != 2 || written.Docs[0]["_deleted"] != true ||
+		written.Docs[1]["_rev"] != "2-d" {
+		t.Errorf("Expected both losers deleted, got %v", written.Docs)
+	}
+}
+
+func TestResolveConflictsNone(t *testing.T) {
+	f := couchtest.NewTransport(docResponse(`{"_id": "a", "_rev": "1-a"}`))
+	defer uninstallFakeHTTP(installFakeHTTP(f))
+
+	rev, err := Database{}.ResolveConflicts("a",
+		func([]LeafRev) (interface{}, error) {
+			t.Errorf("Expected resolver not to be called")
+			return nil, nil
+		})
+	if err != nil || rev != "1-a" || len(f.Requests) != 1 {
+		t.Errorf("Expected nothing done, got %v, %v", rev, err)
+	}
+}
+
+func TestResolveConflictsErrors(t *testing.T) {
+	f := &couchtest.Transport{Responses: []*http.Response{
+		docResponse(`{"_id": "a", "_rev": "2-b", "_conflicts": ["2-c"]}`),
+		docResponse(`[{"ok": {"_id": "a", "_rev": "2-b"}}]`),
+		docResponse(`{"_id": "a", "_rev": "2-b", "_conflicts": ["2-c"]}`),
+		docResponse(`[{"ok": {"_id": "a", "_rev": "2-b"}}]`),
+		docResponse(`[{"id": "a", "error": "conflict", "reason": "Document update conflict."}]`),
+	}}
+	defer uninstallFakeHTTP(installFakeHTTP(f))
+
+	oops := errors.New("oops")
+	_, err := Database{}.ResolveConflicts("a",
+		func([]LeafRev) (interface{}, error) { return nil, oops })
+	if err != oops {
+		t.Errorf("Expected resolver's error, got %v", err)
+	}
+	_, err = Database{}.ResolveConflicts("a",
+		func([]LeafRev) (interface{}, error) { return nil, nil })
+	if err == nil || !strings.Contains(err.Error(), "conflict") {
+		t.Errorf("Expected bulk error, got %v", err)
+	}
+}
+
+func TestRetrieveConflicts(t *testing.T) {
+	f := couchtest.NewTransport(docResponse(`{"_id": "a", "_rev": "3-b", "n": 2,
+		"_conflicts": ["3-c", "2-d"], "_deleted_conflicts": ["3-e"]}`))
+	defer uninstallFakeHTTP(installFakeHTTP(f))
+
+	doc := struct {
+		N int
+		ConflictInfo
+	}{}
+	info, err := Database{Name: "db"}.RetrieveConflicts("a", &doc)
+	if err != nil {
+		t.Fatalf("Error retrieving: %v", err)
+	}
+	exp := ConflictInfo{Conflicts: []string{"3-c", "2-d"}, DeletedConflicts: []string{"3-e"}}
+	if !reflect.DeepEqual(info, exp) || !info.HasConflicts() {
+		t.Errorf("Unexpected conflicts %+v", info)
+	}
+	if doc.N != 2 || !reflect.DeepEqual(doc.ConflictInfo, exp) {
+		t.Errorf("Unexpected doc %+v", doc)
+	}
+	q := f.Requests[0].URL.Query()
+	if q.Get("conflicts") != "true" || q.Get("deleted_conflicts") != "true" {
+		t.Errorf("Unexpected parameters %v", q)
+	}
+
+	if _, err := (Database{}).RetrieveConflicts("", nil); err != errNoID {
+		t.Errorf("Expected errNoID, got %v", err)
+	}
+}
+
+func TestEachConflict(t *testing.T) {
+	f := couchtest.NewTransport(docResponse(`{"total_rows": 3, "offset": 0, "rows": [
+		{"id": "a", "key": "a", "value": {"rev": "2-a"}, "doc": {"_id": "a", "_rev": "2-a"}},
+		{"id": "b", "key": "b", "value": {"rev": "2-b"},
+			"doc": {"_id": "b", "_rev": "2-b", "_conflicts": ["2-c"]}},
+		{"key": "x", "error": "not_found"}]}`))
+	defer uninstallFakeHTTP(installFakeHTTP(f))
+
+	var found []DocConflicts
+	err := Database{Name: "db"}.EachConflict(map[string]interface{}{"limit": 10},
+		func(d DocConflicts) error {
+			found = append(found, d)
+			return nil
+		})
+	if err != nil {
+		t.Fatalf("Error scanning: %v", err)
+	}
+	if len(found) != 1 || found[0].ID != "b" || found[0].Rev != "2-b" ||
+		!reflect.DeepEqual(found[0].Conflicts, []string{"2-c"}) {
+		t.Errorf("Unexpected conflicts %+v", found)
+	}
+	q := f.Requests[0].URL.Query()
+	if f.Requests[0].URL.Path != "/db/_all_docs" || q.Get("conflicts") != "true" ||
+		q.Get("include_docs") != "true" || q.Get("limit") != "10" {
+		t.Errorf("Unexpected request %v", f.Requests[0].URL)
+	}
+}
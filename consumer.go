@@ -0,0 +1,254 @@
+package couch
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"sync"
+	"time"
+)
+
+// Sequence is a position in a database's changes feed.
+//
+// CouchDB 1.x uses integer sequences while clustered servers use
+// opaque strings.  Either form is accepted when decoding.
+type Sequence string
+
+// UnmarshalJSON accepts both string and numeric sequences.
+func (s *Sequence) UnmarshalJSON(b []byte) error {
+	switch {
+	case string(b) == "null":
+		*s = ""
+	case len(b) > 0 && b[0] == '"':
+		var str string
+		if err := json.Unmarshal(b, &str); err != nil {
+			return err
+		}
+		*s = Sequence(str)
+	default:
+		*s = Sequence(b)
+	}
+	return nil
+}
+
+// Change is a single entry from a changes feed.
+type Change struct {
+	Seq     Sequence `json:"seq"`
+	ID      string   `json:"id"`
+	Changes []struct {
+		Rev string `json:"rev"`
+	} `json:"changes"`
+	Deleted bool            `json:"deleted"`
+	Doc     json.RawMessage `json:"doc"`
+}
+
+const (
+	consumerBuffer     = 100
+	checkpointInterval = 5 * time.Second
+)
+
+// A ChangesConsumer delivers changes from a continuous changes feed to
+// a handler one at a time, checkpointing its progress along the way.
+type ChangesConsumer struct {
+	handler    func(Change) error
+	checkpoint func(Sequence) error
+
+	events    chan Change
+	stopping  chan struct{}
+	quit      chan struct{}
+	delivered chan struct{}
+	stopped   chan struct{}
+	stopOnce  sync.Once
+	drainOnce sync.Once
+
+	// Owned by the delivery goroutine until delivered is closed.
+	last, checkpointed Sequence
+	err                error
+	drainErr           error
+
+	mu      sync.Mutex
+	body    io.Closer
+	release chan struct{}
+}
+
+// ConsumeChanges starts delivering changes after since to handler until
+// Drain is called.  Drain must always be called to release the feed.
+//
+// checkpoint (if not nil) is periodically invoked with the sequence of
+// the last handled change, and once more while draining.  A handler or
+// checkpoint error stops the consumer and is returned from Drain.
+func (p Database) ConsumeChanges(since Sequence, handler func(Change) error,
+	checkpoint func(Sequence) error,
+	options map[string]interface{}) *ChangesConsumer {
+
+	opts := map[string]interface{}{}
+	for k, v := range options {
+		opts[k] = v
+	}
+	opts["feed"] = "continuous"
+	delete(opts, "since")
+
+	c := &ChangesConsumer{
+		handler:      handler,
+		checkpoint:   checkpoint,
+		events:       make(chan Change, consumerBuffer),
+		stopping:     make(chan struct{}),
+		quit:         make(chan struct{}),
+		delivered:    make(chan struct{}),
+		stopped:      make(chan struct{}),
+		release:      make(chan struct{}),
+		last:         since,
+		checkpointed: since,
+	}
+	go c.read(p, opts, since)
+	go c.deliver()
+	return c
+}
+
+func (c *ChangesConsumer) stop() {
+	c.stopOnce.Do(func() { close(c.stopping) })
+}
+
+func (c *ChangesConsumer) isStopping() bool {
+	select {
+	case <-c.stopping:
+		return true
+	default:
+		return false
+	}
+}
+
+// setBody records the current connection, returning false if the
+// consumer has already been shut down.
+func (c *ChangesConsumer) setBody(b io.Closer) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	select {
+	case <-c.release:
+		return false
+	default:
+	}
+	c.body = b
+	return true
+}
+
+// closeBody releases the feed's connection once draining is done.
+func (c *ChangesConsumer) closeBody() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	close(c.release)
+	if c.body != nil {
+		c.body.Close()
+	}
+}
+
+func (c *ChangesConsumer) read(p Database, opts map[string]interface{},
+	since Sequence) {
+
+	defer close(c.stopped)
+	p.changes(opts, string(since), c.stopping,
+		func(r io.ReadCloser) (string, bool) {
+			if !c.setBody(r) {
+				return "", false
+			}
+			d := json.NewDecoder(r)
+			for !c.isStopping() {
+				ch := Change{}
+				if err := d.Decode(&ch); err != nil {
+					break
+				}
+				if ch.ID == "" {
+					continue
+				}
+				select {
+				case c.events <- ch:
+					since = ch.Seq
+				case <-c.stopping:
+				}
+			}
+			if c.isStopping() {
+				// Hold the connection until draining is complete.
+				<-c.release
+				return "", false
+			}
+			return string(since), true
+		})
+}
+
+func (c *ChangesConsumer) deliver() {
+	defer close(c.delivered)
+	lastCheckpoint := time.Now()
+	for {
+		var ch Change
+		select {
+		case ch = <-c.events:
+		case <-c.stopping:
+			select {
+			case ch = <-c.events:
+			default:
+				return
+			}
+		}
+
+		select {
+		case <-c.quit:
+			return
+		default:
+		}
+
+		if err := c.handler(ch); err != nil {
+			c.err = err
+			c.stop()
+			return
+		}
+		c.last = ch.Seq
+
+		if c.checkpoint != nil && time.Since(lastCheckpoint) >= checkpointInterval {
+			if err := c.checkpoint(c.last); err != nil {
+				c.err = err
+				c.stop()
+				return
+			}
+			c.checkpointed = c.last
+			lastCheckpoint = time.Now()
+		}
+	}
+}
+
+// Drain stops the consumer.
+//
+// Changes that have already been buffered are delivered and the final
+// checkpoint is recorded before the feed's connection is closed.  If
+// ctx is done first, delivery stops after the change currently being
+// handled and ctx's error is returned.
+func (c *ChangesConsumer) Drain(ctx context.Context) error {
+	c.drainOnce.Do(func() { c.drainErr = c.drain(ctx) })
+	return c.drainErr
+}
+
+func (c *ChangesConsumer) drain(ctx context.Context) error {
+	c.stop()
+
+	var err error
+	select {
+	case <-c.delivered:
+	case <-ctx.Done():
+		err = ctx.Err()
+		close(c.quit)
+		<-c.delivered
+	}
+
+	if c.err != nil {
+		err = c.err
+	}
+	if c.checkpoint != nil && c.last != c.checkpointed {
+		if cerr := c.checkpoint(c.last); err == nil {
+			err = cerr
+		}
+	}
+
+	c.closeBody()
+	<-c.stopped
+
+	return err
+}
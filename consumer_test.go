@@ -0,0 +1,194 @@
+package couch
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestSequenceUnmarshal(t *testing.T) {
+	var got []struct{ Seq Sequence }
+	err := json.Unmarshal([]byte(`[{"seq": 12}, {"seq": "12-g1AAAA"}, {"seq": null}]`), &got)
+	if err != nil {
+		t.Fatalf("Error decoding sequences: %v", err)
+	}
+	exp := []Sequence{"12", "12-g1AAAA", ""}
+	for i, e := range exp {
+		if got[i].Seq != e {
+			t.Errorf("Expected %q at %v, got %q", e, i, got[i].Seq)
+		}
+	}
+}
+
+// feedConn serves a canned HTTP response once the request has been
+// written, and then blocks until closed.
+type feedConn struct {
+	mockConn
+	data    []byte
+	request []byte
+	written chan struct{}
+	closed  chan struct{}
+	wonce   sync.Once
+	once    sync.Once
+}
+
+func newFeedConn(body string) *feedConn {
+	return &feedConn{
+		data:    []byte("HTTP/1.0 200 OK\r\n\r\n" + body),
+		written: make(chan struct{}),
+		closed:  make(chan struct{}),
+	}
+}
+
+func (f *feedConn) Read(b []byte) (int, error) {
+	select {
+	case <-f.written:
+	case <-f.closed:
+		return 0, io.EOF
+	}
+	if len(f.data) > 0 {
+		n := copy(b, f.data)
+		f.data = f.data[n:]
+		return n, nil
+	}
+	<-f.closed
+	return 0, io.EOF
+}
+
+func (f *feedConn) Write(b []byte) (int, error) {
+	select {
+	case <-f.written:
+	default:
+		f.request = append(f.request, b...)
+	}
+	f.wonce.Do(func() { close(f.written) })
+	return len(b), nil
+}
+
+func (f *feedConn) Close() error {
+	f.once.Do(func() { close(f.closed) })
+	return nil
+}
+
+const threeChanges = `{"seq":1,"id":"a","changes":[{"rev":"1-a"}]}
+{"seq":2,"id":"b","changes":[{"rev":"1-b"}]}
+
+{"seq":3,"id":"c","changes":[{"rev":"1-c"}]}
+`
+
+func feedDB(conn net.Conn) Database {
+	return Database{
+		changesDialer: func(string, string) (net.Conn, error) {
+			return conn, nil
+		},
+		changesFailDelay: 5,
+		Host:             "localhost",
+	}
+}
+
+func waitFor(t *testing.T, what string, f func() bool) {
+	deadline := time.Now().Add(5 * time.Second)
+	for !f() {
+		if time.Now().After(deadline) {
+			t.Fatalf("Timed out waiting for %v", what)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestConsumerDrain(t *testing.T) {
+	conn := newFeedConn(threeChanges)
+	gate := make(chan bool)
+	var got []string
+	var checkpoints []Sequence
+
+	c := feedDB(conn).ConsumeChanges("", func(ch Change) error {
+		<-gate
+		got = append(got, ch.ID)
+		return nil
+	}, func(s Sequence) error {
+		checkpoints = append(checkpoints, s)
+		return nil
+	}, nil)
+
+	waitFor(t, "buffered changes", func() bool { return len(c.events) == 2 })
+
+	errc := make(chan error)
+	go func() { errc <- c.Drain(context.Background()) }()
+	waitFor(t, "stop", c.isStopping)
+	close(gate)
+
+	if err := <-errc; err != nil {
+		t.Fatalf("Error draining: %v", err)
+	}
+	if !reflect.DeepEqual(got, []string{"a", "b", "c"}) {
+		t.Errorf("Expected all changes delivered, got %v", got)
+	}
+	if !reflect.DeepEqual(checkpoints, []Sequence{"3"}) {
+		t.Errorf("Expected final checkpoint at 3, got %v", checkpoints)
+	}
+	select {
+	case <-conn.closed:
+	default:
+		t.Errorf("Expected connection to be closed")
+	}
+}
+
+func TestConsumerDrainDeadline(t *testing.T) {
+	conn := newFeedConn(threeChanges)
+	gate := make(chan bool)
+	var got []string
+	var checkpoints []Sequence
+
+	c := feedDB(conn).ConsumeChanges("", func(ch Change) error {
+		<-gate
+		got = append(got, ch.ID)
+		return nil
+	}, func(s Sequence) error {
+		checkpoints = append(checkpoints, s)
+		return nil
+	}, nil)
+
+	waitFor(t, "buffered changes", func() bool { return len(c.events) == 2 })
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	errc := make(chan error)
+	go func() { errc <- c.Drain(ctx) }()
+	waitFor(t, "quit", func() bool {
+		select {
+		case <-c.quit:
+			return true
+		default:
+			return false
+		}
+	})
+	close(gate)
+
+	if err := <-errc; err != context.Canceled {
+		t.Fatalf("Expected cancelation, got %v", err)
+	}
+	if !reflect.DeepEqual(got, []string{"a"}) {
+		t.Errorf("Expected only the in-flight change, got %v", got)
+	}
+	if !reflect.DeepEqual(checkpoints, []Sequence{"1"}) {
+		t.Errorf("Expected final checkpoint at 1, got %v", checkpoints)
+	}
+}
+
+func TestConsumerHandlerError(t *testing.T) {
+	conn := newFeedConn(threeChanges)
+	c := feedDB(conn).ConsumeChanges("", func(ch Change) error {
+		return io.ErrUnexpectedEOF
+	}, nil, nil)
+
+	waitFor(t, "stop", c.isStopping)
+	if err := c.Drain(context.Background()); err != io.ErrUnexpectedEOF {
+		t.Fatalf("Expected handler error, got %v", err)
+	}
+}
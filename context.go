@@ -0,0 +1,27 @@
+package couch
+
+import (
+	"context"
+)
+
+// WithContext returns a copy of the database whose requests are
+// canceled when ctx is done, including waits between retries, for a
+// slot under the concurrency limit, and for the bandwidth limit.  The
+// changes feed isn't affected.
+//
+//	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+//	defer cancel()
+//	err := db.WithContext(ctx).Retrieve(id, &doc)
+func (p Database) WithContext(ctx context.Context) Database {
+	p.ctx = ctx
+	return p
+}
+
+// Context returns the database's context, or context.Background() if
+// it has none.
+func (p Database) Context() context.Context {
+	if p.ctx != nil {
+		return p.ctx
+	}
+	return context.Background()
+}
@@ -0,0 +1,51 @@
+package couch
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/dustin/go-couch/couchtest"
+)
+
+type ctxKey struct{}
+
+func TestWithContext(t *testing.T) {
+	f := couchtest.NewTransport(docResponse(`{"_id": "a"}`))
+	defer uninstallFakeHTTP(installFakeHTTP(f))
+
+	ctx := context.WithValue(context.Background(), ctxKey{}, "v")
+	d := Database{Name: "db"}.WithContext(ctx)
+	if d.Context() != ctx || (Database{}).Context() != context.Background() {
+		t.Errorf("Unexpected contexts")
+	}
+	doc := map[string]interface{}{}
+	if err := d.Retrieve("a", &doc); err != nil {
+		t.Fatalf("Error retrieving: %v", err)
+	}
+	if f.Requests[0].Context().Value(ctxKey{}) != "v" {
+		t.Errorf("Request wasn't given the database's context")
+	}
+}
+
+func TestWithContextCancelsRetry(t *testing.T) {
+	f := &couchtest.Transport{Responses: []*http.Response{unavailable(), unavailable()}}
+	defer uninstallFakeHTTP(installFakeHTTP(f))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	d := Database{Name: "db"}.WithRetry(RetryPolicy{
+		MaxRetries: 1, InitialBackoff: time.Hour,
+	}).WithContext(ctx)
+
+	start := time.Now()
+	err := d.Retrieve("a", &map[string]interface{}{})
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected deadline exceeded, got %v", err)
+	}
+	if time.Since(start) > time.Second {
+		t.Errorf("Retry wait wasn't canceled")
+	}
+}
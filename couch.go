@@ -3,6 +3,8 @@ package couch
 
 import (
 	"bytes"
+	"context"
+	"crypto/tls"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -11,10 +13,9 @@ import (
 	"net"
 	"net/http"
 	"net/url"
+	"strconv"
 	"strings"
 	"time"
-
-	"github.com/dustin/httputil"
 )
 
 // HTTP Client used by typical requests.
@@ -36,24 +37,57 @@ func createReq(u string) (*http.Request, error) {
 	return req, nil
 }
 
-func unmarshalURL(u string, results interface{}) error {
+// getBody fetches the given URL, returning the body of a successful
+// response.
+func (p Database) getBody(u string) (io.ReadCloser, error) {
 	req, err := createReq(u)
 	if err != nil {
-		return err
+		return nil, err
+	}
+
+	// The ETag of a document is its revision, whatever parameters and
+	// headers shaped the body, so match the request as it's sent.
+	var cached *cacheEntry
+	var vary string
+	if p.cache != nil {
+		p.addDefaultHeaders(req)
+		if err := p.addParams(req); err != nil {
+			return nil, err
+		}
+		vary = cacheVary(req)
+		if cached = p.cache.get(u, vary); cached != nil {
+			req.Header.Set("If-None-Match", cached.etag)
+		}
 	}
 
-	r, err := HTTPClient.Do(req)
+	r, err := p.do(req)
 	if err != nil {
-		return err
+		return nil, err
 	}
-	defer r.Body.Close()
-	defer io.Copy(ioutil.Discard, r.Body)
 
+	if r.StatusCode == http.StatusNotModified && cached != nil {
+		r.Body.Close()
+		return ioutil.NopCloser(bytes.NewReader(cached.body)), nil
+	}
 	if r.StatusCode != 200 {
-		return httputil.HTTPError(r)
+		defer r.Body.Close()
+		return nil, newHTTPError(r)
 	}
+	if p.cache != nil {
+		return p.cache.store(u, vary, r)
+	}
+	return r.Body, nil
+}
 
-	return json.NewDecoder(r.Body).Decode(results)
+func (p Database) unmarshalURL(u string, results interface{}) error {
+	body, err := p.getBody(u)
+	if err != nil {
+		return err
+	}
+	defer body.Close()
+	defer io.Copy(ioutil.Discard, body)
+
+	return json.NewDecoder(body).Decode(results)
 }
 
 type idAndRev struct {
@@ -67,7 +101,7 @@ type idAndRev struct {
 // headers: additional headers to pass to the request
 // in: body of the request
 // out: a structure to fill in with the returned JSON document
-func interact(method, u string, headers map[string][]string, in []byte, out interface{}) (int, error) {
+func (p Database) interact(method, u string, headers map[string][]string, in []byte, out interface{}) (int, error) {
 	fullHeaders := map[string][]string{}
 	for k, v := range headers {
 		fullHeaders[k] = v
@@ -83,9 +117,12 @@ func interact(method, u string, headers map[string][]string, in []byte, out inte
 
 	req.ContentLength = int64(len(in))
 	req.Header = fullHeaders
-	req.Close = true
 
-	res, err := HTTPClient.Do(req)
+	if p.cache != nil && method != "GET" {
+		p.cache.remove(u)
+	}
+
+	res, err := p.do(req)
 	if err != nil {
 		return 0, err
 	}
@@ -93,7 +130,7 @@ func interact(method, u string, headers map[string][]string, in []byte, out inte
 	defer io.Copy(ioutil.Discard, res.Body)
 
 	if res.StatusCode < 200 || res.StatusCode >= 300 {
-		return res.StatusCode, httputil.HTTPError(res)
+		return res.StatusCode, newHTTPError(res)
 	}
 	return res.StatusCode, json.NewDecoder(res.Body).Decode(out)
 }
@@ -104,30 +141,228 @@ type Database struct {
 	Port     string
 	Name     string
 	authinfo *url.Userinfo
+	scheme   string
 
 	defaultHdrs      map[string][]string
 	changesDialer    func(string, string) (net.Conn, error)
 	changesFailDelay time.Duration
+
+	client      *http.Client
+	tlsConfig   *tls.Config
+	limiter     *limiter
+	priority    Priority
+	retry       *RetryPolicy
+	replies     *replyCache
+	idemKey     string
+	breaker     *breaker
+	throttle    *throttle
+	fields      *FieldMapping
+	logger      Logger
+	metrics     Metrics
+	cache       *etagCache
+	attachments *AttachmentPolicy
+	bandwidth   *bandwidth
+	codec       *Codec
+	ctx         context.Context
+	closeConns  bool
+	compress    *compression
+	timeouts    *Timeouts
+	session     *session
+	params      map[string]interface{}
+	requestIDs  bool
+}
+
+// httpClient returns the client used for this database's requests.
+func (p Database) httpClient() *http.Client {
+	if p.client != nil {
+		return p.client
+	}
+	return HTTPClient
+}
+
+// do sends a request on behalf of this database.  All requests other
+// than the changes feed go through here.
+func (p Database) do(req *http.Request) (*http.Response, error) {
+	p.addDefaultHeaders(req)
+	if err := p.addParams(req); err != nil {
+		return nil, err
+	}
+	if p.closeConns {
+		req.Close = true
+	}
+	if p.compress != nil {
+		if err := p.compress.prepare(req); err != nil {
+			return nil, err
+		}
+	}
+	if p.ctx != nil {
+		req = req.WithContext(p.ctx)
+	}
+	if err := p.addRequestID(req); err != nil {
+		return nil, err
+	}
+	if p.session != nil {
+		return p.session.do(p, req, p.dispatchTimed)
+	}
+	return p.dispatchTimed(req)
+}
+
+// dispatchTimed dispatches a request within the timeout for its kind,
+// if any.
+func (p Database) dispatchTimed(req *http.Request) (*http.Response, error) {
+	if d := p.timeouts.timeout(req); d > 0 {
+		return withTimeout(req, d, p.dispatch)
+	}
+	return p.dispatch(req)
+}
+
+// dispatch sends a request, retrying it if the database is configured
+// to.
+func (p Database) dispatch(req *http.Request) (*http.Response, error) {
+	if p.retry != nil && idempotent(req.Method) {
+		return p.retry.do(req, p.sendThrottled)
+	}
+	if p.retry != nil && p.retry.IdempotencyHeader != "" && req.Method == "POST" {
+		return p.doIdempotent(req)
+	}
+	return p.sendThrottled(req)
+}
+
+// sendThrottled sends a request, retrying it while the server is
+// throttling requests, if the database is configured to.
+func (p Database) sendThrottled(req *http.Request) (*http.Response, error) {
+	if p.throttle != nil {
+		return p.throttle.do(req, p.send)
+	}
+	return p.send(req)
+}
+
+// send makes a single attempt at a request.
+func (p Database) send(req *http.Request) (*http.Response, error) {
+	if p.breaker == nil {
+		return p.sendLimited(req)
+	}
+
+	probe, err := p.breaker.allow(p.priority)
+	if err != nil {
+		return nil, err
+	}
+	res, err := p.sendLimited(req)
+	if err == ErrOverloaded || req.Context().Err() != nil {
+		p.breaker.abandon(probe)
+	} else {
+		p.breaker.done(probe, healthy(res, err))
+	}
+	return res, err
+}
+
+// sendLimited sends a request once there's room under the concurrency
+// limit.
+func (p Database) sendLimited(req *http.Request) (*http.Response, error) {
+	if p.limiter == nil {
+		return p.roundTrip(req)
+	}
+
+	if err := p.limiter.acquire(req.Context(), p.priority); err != nil {
+		return nil, err
+	}
+	res, err := p.roundTrip(req)
+	if err != nil {
+		p.limiter.release()
+		return nil, err
+	}
+	res.Body = &releasingBody{ReadCloser: res.Body, release: p.limiter.release}
+	return res, nil
+}
+
+// roundTrip performs the HTTP exchange for a request.
+func (p Database) roundTrip(req *http.Request) (*http.Response, error) {
+	start := time.Now()
+	res, err := p.httpClient().Do(req)
+	if err == nil && res.Request == nil {
+		res.Request = req
+	}
+	if err == nil && p.compress != nil {
+		if err = p.compress.decompress(res); err != nil {
+			res = nil
+		}
+	}
+	return p.observe(req, res, err, start), err
+}
+
+// baseURL returns the URL of the database server containing this
+// database.
+func (p Database) baseURL() *url.URL {
+	scheme := p.scheme
+	if scheme == "" {
+		scheme = "http"
+	}
+	return &url.URL{
+		Scheme: scheme,
+		User:   p.authinfo,
+		Host:   net.JoinHostPort(p.Host, p.Port),
+	}
+}
+
+// pathEscape escapes a single path segment, including the characters
+// CouchDB treats specially in database names and document IDs.
+func pathEscape(s string) string {
+	return strings.Replace(url.PathEscape(s), "+", "%2B", -1)
+}
+
+// docPath escapes a document ID for use in a path, leaving the slash
+// in design and local document IDs intact.
+func docPath(id string) string {
+	for _, prefix := range []string{"_design/", "_local/"} {
+		if strings.HasPrefix(id, prefix) {
+			return prefix + pathEscape(id[len(prefix):])
+		}
+	}
+	return pathEscape(id)
+}
+
+// escapedURL builds a server URL from an already escaped path.
+func (p Database) escapedURL(raw string) *url.URL {
+	u := p.baseURL()
+	u.Path, _ = url.PathUnescape(raw)
+	u.RawPath = raw
+	return u
+}
+
+// serverURL returns the URL of the given escaped path on this
+// database's server.
+func (p Database) serverURL(path string) string {
+	return p.escapedURL("/" + path).String()
+}
+
+// dbURL returns the URL of the given escaped path within this database.
+func (p Database) dbURL(path string) string {
+	raw := "/" + pathEscape(p.Name)
+	if path != "" {
+		raw += "/" + path
+	}
+	return p.escapedURL(raw).String()
+}
+
+// docURL returns the URL of the document with the given ID.
+func (p Database) docURL(id string) string {
+	return p.dbURL(docPath(id))
 }
 
 // BaseURL returns the URL to the database server containing this database.
 func (p Database) BaseURL() string {
-	if p.authinfo == nil {
-		return fmt.Sprintf("http://%s:%s", p.Host, p.Port)
-	}
-	return fmt.Sprintf("http://%s@%s:%s", p.authinfo.String(), p.Host, p.Port)
+	return p.baseURL().String()
 }
 
 // DBURL returns the URL to this specific database.
 func (p Database) DBURL() string {
-	return fmt.Sprintf("%s/%s", p.BaseURL(), p.Name)
+	return p.dbURL("")
 }
 
 // Running returns true if CouchDB is running (ignores Database.Name)
 func (p Database) Running() bool {
-	dbs := []string{}
-	u := fmt.Sprintf("%s/%s", p.BaseURL(), "_all_dbs")
-	return unmarshalURL(u, &dbs) == nil && len(dbs) > 0
+	dbs, err := p.AllDBs(nil)
+	return err == nil && len(dbs) > 0
 }
 
 type databaseInfo struct {
@@ -138,12 +373,12 @@ type databaseInfo struct {
 // Exists returns true if this database exists on the CouchDB server
 func (p Database) Exists() bool {
 	di := &databaseInfo{}
-	return unmarshalURL(p.DBURL(), &di) == nil && di.DBName == p.Name
+	return p.unmarshalURL(p.DBURL(), &di) == nil && di.DBName == p.Name
 }
 
 func (p Database) simpleOp(method, url string, nokerr error) error {
 	ir := Response{}
-	if _, err := interact(method, url, p.defaultHdrs, nil, &ir); err != nil {
+	if _, err := p.interact(method, url, p.defaultHdrs, nil, &ir); err != nil {
 		return err
 	}
 	if !ir.Ok {
@@ -158,7 +393,42 @@ var (
 )
 
 func (p Database) createDatabase() error {
-	return p.simpleOp("PUT", p.DBURL(), errNewDB)
+	return p.CreateDatabase(CreateDatabaseOptions{})
+}
+
+// CreateDatabaseOptions configures a database being created.  Zero
+// values leave the server's defaults.
+type CreateDatabaseOptions struct {
+	// Q is the number of shards the database is split into.
+	Q int
+	// N is the number of copies kept of each shard.
+	N int
+	// Partitioned databases group documents by the prefix of their
+	// ids, before a colon, for efficient per-partition queries.
+	Partitioned bool
+}
+
+func (o CreateDatabaseOptions) values() url.Values {
+	values := url.Values{}
+	if o.Q > 0 {
+		values.Set("q", strconv.Itoa(o.Q))
+	}
+	if o.N > 0 {
+		values.Set("n", strconv.Itoa(o.N))
+	}
+	if o.Partitioned {
+		values.Set("partitioned", "true")
+	}
+	return values
+}
+
+// CreateDatabase creates the database with the given options.
+func (p Database) CreateDatabase(opts CreateDatabaseOptions) error {
+	u := p.DBURL()
+	if values := opts.values(); len(values) > 0 {
+		u += "?" + values.Encode()
+	}
+	return p.simpleOp("PUT", u, errNewDB)
 }
 
 // DeleteDatabase deletes the given database and all documents
@@ -168,43 +438,84 @@ func (p Database) DeleteDatabase() error {
 
 var errNotRunning = errors.New("couchdb not running")
 
+// Ports used by Connect when the URL doesn't specify one.
+var (
+	DefaultPort    = "5984"
+	DefaultTLSPort = "6984"
+)
+
+func defaultPort(scheme string) string {
+	if scheme == "https" {
+		return DefaultTLSPort
+	}
+	return DefaultPort
+}
+
 // Connect to the database at the given URL.
 // example:   couch.Connect("http://localhost:5984/testdb/")
+//
+// If the URL has no port, DefaultPort (or DefaultTLSPort for https)
+// is used.
 func Connect(dburl string) (Database, error) {
-	u, err := url.Parse(dburl)
-	if err != nil {
-		return Database{}, err
-	}
+	return FromConfig(Config{URL: dburl})
+}
 
-	host := u.Host
-	port := "80"
-	if hp := strings.Split(u.Host, ":"); len(hp) > 1 {
-		host = hp[0]
-		port = hp[1]
+func newDatabase(u *url.URL) Database {
+	port := u.Port()
+	if port == "" {
+		port = defaultPort(u.Scheme)
 	}
 
-	db := Database{host, port, u.Path[1:], u.User,
-		map[string][]string{}, net.Dial, defaultChangeDelay}
-	if !db.Running() {
-		return Database{}, errNotRunning
-	}
-	if !db.Exists() {
-		return Database{}, errors.New("database does not exist")
+	return Database{
+		Host:             u.Hostname(),
+		Port:             port,
+		Name:             strings.Trim(u.Path, "/"),
+		authinfo:         u.User,
+		scheme:           u.Scheme,
+		defaultHdrs:      map[string][]string{},
+		changesDialer:    net.Dial,
+		changesFailDelay: defaultChangeDelay,
 	}
-
-	return db, nil
 }
 
 // NewDatabase connects to a CouchDB server and creates the specified
 // database if it does not exist.
 func NewDatabase(host, port, name string) (Database, error) {
-	db := Database{host, port, name, nil,
-		map[string][]string{}, net.Dial, defaultChangeDelay}
+	return newDatabaseAuth(host, port, name, nil, CreateDatabaseOptions{})
+}
+
+// NewDatabaseWithOptions is like NewDatabase, but creates the database,
+// if it does not exist, with the given options.
+func NewDatabaseWithOptions(host, port, name string, opts CreateDatabaseOptions) (Database, error) {
+	return newDatabaseAuth(host, port, name, nil, opts)
+}
+
+// NewDatabaseWithAuth is like NewDatabase, but authenticates as the
+// given user, which is necessary to create databases on servers that
+// aren't in admin party mode.
+func NewDatabaseWithAuth(host, port, name, user, password string) (Database, error) {
+	return newDatabaseAuth(host, port, name, url.UserPassword(user, password),
+		CreateDatabaseOptions{})
+}
+
+func newDatabaseAuth(host, port, name string, auth *url.Userinfo,
+	opts CreateDatabaseOptions) (Database, error) {
+
+	db := Database{
+		Host:             host,
+		Port:             port,
+		Name:             name,
+		authinfo:         auth,
+		scheme:           "http",
+		defaultHdrs:      map[string][]string{},
+		changesDialer:    net.Dial,
+		changesFailDelay: defaultChangeDelay,
+	}
 	if !db.Running() {
 		return db, errNotRunning
 	}
 	if !db.Exists() {
-		if err := db.createDatabase(); err != nil {
+		if err := db.CreateDatabase(opts); err != nil {
 			return db, err
 		}
 	}
@@ -223,14 +534,7 @@ func cleanJSON(d interface{}) (jsonBuf []byte, id, rev string, err error) {
 	if err != nil {
 		return
 	}
-	m := map[string]interface{}{}
-	must(json.Unmarshal(jsonBuf, &m))
-	id, _ = m["_id"].(string)
-	delete(m, "_id")
-	rev, _ = m["_rev"].(string)
-	delete(m, "_rev")
-	jsonBuf, err = json.Marshal(m)
-	return
+	return stripIDRev(jsonBuf)
 }
 
 // Response represents a typical command response from against a CouchDB server.
@@ -248,6 +552,19 @@ type Response struct {
 // To delete, add a "_deleted" field with a value of "true" as well
 // as a valid "_rev" field.
 func (p Database) Bulk(docs []interface{}) ([]Response, error) {
+	originals := docs
+	if p.fields != nil || p.codec != nil {
+		mapped := make([]interface{}, len(docs))
+		for i, d := range docs {
+			b, err := p.marshal(d)
+			if err != nil {
+				return nil, err
+			}
+			mapped[i] = json.RawMessage(b)
+		}
+		docs = mapped
+	}
+
 	m := map[string]interface{}{}
 	m["docs"] = docs
 	jsonBuf, err := json.Marshal(m)
@@ -256,7 +573,14 @@ func (p Database) Bulk(docs []interface{}) ([]Response, error) {
 	}
 
 	results := []Response{}
-	_, err = interact("POST", p.DBURL()+"/_bulk_docs", p.defaultHdrs, jsonBuf, &results)
+	_, err = p.interact("POST", p.dbURL("_bulk_docs"), p.defaultHdrs, jsonBuf, &results)
+	if err == nil && len(results) == len(originals) {
+		for i, r := range results {
+			if r.Error == "" {
+				setMeta(originals[i], r.ID, r.Rev)
+			}
+		}
+	}
 	return results, err
 }
 
@@ -265,23 +589,33 @@ func (p Database) Bulk(docs []interface{}) ([]Response, error) {
 //	or just "_id" (will use that id, but not overwrite existing)
 //	or neither (will use autogenerated id)
 func (p Database) Insert(d interface{}) (string, string, error) {
-	jsonBuf, id, rev, err := cleanJSON(d)
+	full, err := p.marshal(d)
+	if err != nil {
+		return "", "", err
+	}
+	jsonBuf, id, rev, err := stripIDRev(full)
 	if err != nil {
 		return "", "", err
 	}
-	if id != "" && rev != "" {
-		newRev, err2 := p.Edit(d)
-		return id, newRev, err2
-	} else if id != "" {
-		return p.insertWith(jsonBuf, id)
+	var newRev string
+	switch {
+	case id != "" && rev != "":
+		newRev, err = p.put(id, full)
+	case id != "":
+		id, newRev, err = p.insertWith(jsonBuf, id)
+	default:
+		id, newRev, err = p.insert(jsonBuf)
 	}
-	return p.insert(jsonBuf)
+	if err == nil {
+		setMeta(d, id, newRev)
+	}
+	return id, newRev, err
 }
 
 // Private implementation of simple autogenerated-id insert
 func (p Database) insert(jsonBuf []byte) (string, string, error) {
 	ir := Response{}
-	if _, err := interact("POST", p.DBURL(), p.defaultHdrs, jsonBuf, &ir); err != nil {
+	if _, err := p.interact("POST", p.DBURL(), p.defaultHdrs, jsonBuf, &ir); err != nil {
 		return "", "", err
 	}
 	if !ir.Ok {
@@ -294,18 +628,21 @@ func (p Database) insert(jsonBuf []byte) (string, string, error) {
 // "_rev" tagged fields) using the passed 'id' as the _id. Will fail
 // if the id already exists.
 func (p Database) InsertWith(d interface{}, id string) (string, string, error) {
-	jsonBuf, err := json.Marshal(d)
+	jsonBuf, err := p.marshal(d)
 	if err != nil {
 		return "", "", err
 	}
-	return p.insertWith(jsonBuf, id)
+	id, rev, err := p.insertWith(jsonBuf, id)
+	if err == nil {
+		setMeta(d, id, rev)
+	}
+	return id, rev, err
 }
 
 // Private implementation of insert with given id
 func (p Database) insertWith(jsonBuf []byte, id string) (string, string, error) {
-	u := fmt.Sprintf("%s/%s", p.DBURL(), url.QueryEscape(id))
 	ir := Response{}
-	if _, err := interact("PUT", u, p.defaultHdrs, jsonBuf, &ir); err != nil {
+	if _, err := p.interact("PUT", p.docURL(id), p.defaultHdrs, jsonBuf, &ir); err != nil {
 		return "", "", err
 	}
 	if !ir.Ok {
@@ -319,21 +656,35 @@ var errNoRev = errors.New("rev not specified in interface (try InsertWith)")
 // Edit edits the given document, returning the new revision.
 // d must contain "_id" and "_rev" tagged fields.
 func (p Database) Edit(d interface{}) (string, error) {
-	jsonBuf, err := json.Marshal(d)
+	jsonBuf, err := p.marshal(d)
 	if err != nil {
 		return "", err
 	}
-	idRev := idAndRev{}
-	must(json.Unmarshal(jsonBuf, &idRev))
-	if idRev.ID == "" {
+	rev, err := p.edit(jsonBuf)
+	if err == nil {
+		setMeta(d, "", rev)
+	}
+	return rev, err
+}
+
+func (p Database) edit(jsonBuf []byte) (string, error) {
+	id, rev, err := docIDRev(jsonBuf)
+	if err != nil {
+		return "", err
+	}
+	if id == "" {
 		return "", errNoID
 	}
-	if idRev.Rev == "" {
+	if rev == "" {
 		return "", errNoRev
 	}
-	u := fmt.Sprintf("%s/%s", p.DBURL(), url.QueryEscape(idRev.ID))
+	return p.put(id, jsonBuf)
+}
+
+// put stores the encoded document, which includes its "_rev", under id.
+func (p Database) put(id string, jsonBuf []byte) (string, error) {
 	ir := Response{}
-	if _, err = interact("PUT", u, p.defaultHdrs, jsonBuf, &ir); err != nil {
+	if _, err := p.interact("PUT", p.docURL(id), p.defaultHdrs, jsonBuf, &ir); err != nil {
 		return "", err
 	}
 	return ir.Rev, nil
@@ -349,26 +700,177 @@ func (p Database) EditWith(d interface{}, id, rev string) (string, error) {
 	if rev == "" {
 		return "", errNoRev
 	}
-	jsonBuf, err := json.Marshal(d)
+	jsonBuf, err := p.marshal(d)
 	if err != nil {
 		return "", err
 	}
-	m := map[string]interface{}{}
-	must(json.Unmarshal(jsonBuf, &m))
-	m["_id"] = id
-	m["_rev"] = rev
-	return p.Edit(m)
+	body, _, _, err := stripIDRev(jsonBuf)
+	if err != nil {
+		return "", err
+	}
+	newRev, err := p.put(id, withIDRev(body, id, rev))
+	if err == nil {
+		setMeta(d, id, newRev)
+	}
+	return newRev, err
 }
 
 var errNoID = errors.New("no id specified")
 
 // Retrieve unmarshals the document matching id to the given interface
+//
+// Documents are often shared between applications, each knowing about
+// only some of the fields.  To keep a typed read-modify-write from
+// dropping the rest, give the struct a map field tagged like so:
+//
+//	Extra map[string]json.RawMessage `json:"-" couch:"extra"`
+//
+// Retrieve collects top-level fields that no other field decodes into
+// the map, and writes (Insert, Edit, Bulk and so on) put them back.
+// The map may have any value type json can decode into.
 func (p Database) Retrieve(id string, d interface{}) error {
 	if id == "" {
 		return errNoID
 	}
+	if p.codec.attachments() {
+		return p.RetrieveWith(id, nil, d)
+	}
+	return p.retrieve(p.docURL(id), d)
+}
+
+var errEmptyRev = errors.New("no rev specified")
 
-	return unmarshalURL(fmt.Sprintf("%s/%s", p.DBURL(), id), d)
+// RetrieveRev retrieves the given revision of the document matching
+// id, rather than the winning one.  Old revisions are only available
+// until the database is compacted.
+func (p Database) RetrieveRev(id, rev string, d interface{}) error {
+	if rev == "" {
+		return errEmptyRev
+	}
+	return p.RetrieveWith(id, map[string]interface{}{"rev": rev}, d)
+}
+
+// RetrieveWith retrieves the document matching id, passing options as
+// query parameters, e.g. {"rev": "2-abc", "conflicts": true}.  Strings
+// are passed as is, and anything else as JSON, e.g. a []string of
+// "open_revs".
+func (p Database) RetrieveWith(id string, options map[string]interface{}, d interface{}) error {
+	if id == "" {
+		return errNoID
+	}
+	u, err := p.docURLWith(id, p.retrieveOptions(options))
+	if err != nil {
+		return err
+	}
+	return p.retrieve(u, d)
+}
+
+// retrieveOptions adds to options any the database's codec needs to
+// retrieve documents.
+func (p Database) retrieveOptions(options map[string]interface{}) map[string]interface{} {
+	if _, set := options["attachments"]; p.codec.attachments() && !set {
+		withAtts := map[string]interface{}{"attachments": true}
+		for k, v := range options {
+			withAtts[k] = v
+		}
+		options = withAtts
+	}
+	return options
+}
+
+func (p Database) retrieve(u string, d interface{}) error {
+	if p.fields == nil && p.codec == nil && !hasExtraField(d) {
+		return p.unmarshalURL(u, d)
+	}
+
+	var raw json.RawMessage
+	if err := p.unmarshalURL(u, &raw); err != nil {
+		return err
+	}
+	return p.unmarshal(raw, d)
+}
+
+// docURLWith returns the URL of the document with the given ID, with
+// options as query parameters.
+func (p Database) docURLWith(id string, options map[string]interface{}) (string, error) {
+	u := p.docURL(id)
+	if len(options) == 0 {
+		return u, nil
+	}
+	values, err := encodeParams(options)
+	if err != nil {
+		return "", err
+	}
+	return u + "?" + values.Encode(), nil
+}
+
+// RetrieveRaw returns the undecoded body of the document matching id,
+// and its revision, so large documents can be streamed elsewhere
+// without being buffered.  The caller must close the body.
+//
+// The ETag cache is bypassed.
+func (p Database) RetrieveRaw(id string) (io.ReadCloser, string, error) {
+	if id == "" {
+		return nil, "", errNoID
+	}
+	req, err := createReq(p.docURL(id))
+	if err != nil {
+		return nil, "", err
+	}
+	res, err := p.do(req)
+	if err != nil {
+		return nil, "", err
+	}
+	if res.StatusCode != 200 {
+		defer res.Body.Close()
+		return nil, "", newHTTPError(res)
+	}
+	return p.limitReadCloser(res.Body),
+		strings.Trim(res.Header.Get("ETag"), `"`), nil
+}
+
+// Copy copies the document matching srcID to dstID on the server,
+// returning the new revision.  dstRev must be the current revision of
+// dstID if it already exists, or empty otherwise.
+func (p Database) Copy(srcID, dstID, dstRev string) (string, error) {
+	if srcID == "" || dstID == "" {
+		return "", errNoID
+	}
+	dest := docPath(dstID)
+	if dstRev != "" {
+		dest += "?rev=" + url.QueryEscape(dstRev)
+	}
+	headers := map[string][]string{
+		"Destination": []string{dest},
+	}
+	ir := Response{}
+	if _, err := p.interact("COPY", p.docURL(srcID), headers, nil, &ir); err != nil {
+		return "", err
+	}
+	return ir.Rev, nil
+}
+
+// Rev returns the current revision of the document matching id,
+// without fetching its body.
+func (p Database) Rev(id string) (string, error) {
+	if id == "" {
+		return "", errNoID
+	}
+	req, err := createReq(p.docURL(id))
+	if err != nil {
+		return "", err
+	}
+	req.Method = "HEAD"
+
+	res, err := p.do(req)
+	if err != nil {
+		return "", err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != 200 {
+		return "", newHTTPError(res)
+	}
+	return strings.Trim(res.Header.Get("ETag"), `"`), nil
 }
 
 // Delete deletes document given by id and rev.
@@ -376,9 +878,8 @@ func (p Database) Delete(id, rev string) error {
 	headers := map[string][]string{
 		"If-Match": []string{rev},
 	}
-	u := fmt.Sprintf("%s/%s", p.DBURL(), id)
 	ir := Response{}
-	if _, err := interact("DELETE", u, headers, nil, &ir); err != nil {
+	if _, err := p.interact("DELETE", p.docURL(id), headers, nil, &ir); err != nil {
 		return err
 	}
 	if !ir.Ok {
@@ -387,24 +888,99 @@ func (p Database) Delete(id, rev string) error {
 	return nil
 }
 
+// DeleteDoc deletes the given document, taking its id and rev from its
+// "_id" and "_rev" fields, as after a Retrieve.
+func (p Database) DeleteDoc(d interface{}) error {
+	_, id, rev, err := cleanJSON(d)
+	switch {
+	case err != nil:
+		return err
+	case id == "":
+		return errNoID
+	case rev == "":
+		return errNoRev
+	}
+	return p.Delete(id, rev)
+}
+
 // DBInfo represents the result from GetInfo
 type DBInfo struct {
 	Name        string `json:"db_name"`
 	DocCount    int64  `json:"doc_count"`
 	DocDelCount int64  `json:"doc_del_count"`
-	UpdateSeq   int64  `json:"update_seq"`
-	PurgeSeq    int64  `json:"purge_seq"`
-	Compacting  bool   `json:"compact_running"`
-	DiskSize    int64  `json:"disk_size"`
-	DataSize    int64  `json:"data_size"`
-	StartTime   string `json:"instance_start_time"`
-	Version     int    `json:"disk_format_version"`
-	CommitedSeq int64  `json:"committed_update_seq"`
+	// Sequences as numbers.  Clustered servers (CouchDB 2.0 and later)
+	// report opaque strings instead, whose leading number is given
+	// here; use Seq for the update sequence as reported.
+	UpdateSeq   int64 `json:"-"`
+	PurgeSeq    int64 `json:"-"`
+	CommitedSeq int64 `json:"-"`
+	// The update sequence as reported, e.g. to pass to Changes.
+	Seq        Sequence `json:"update_seq"`
+	Compacting bool     `json:"compact_running"`
+	// Sizes in bytes.  Servers that only report Sizes have them copied
+	// from there.
+	DiskSize  int64     `json:"disk_size"`
+	DataSize  int64     `json:"data_size"`
+	StartTime string    `json:"instance_start_time"`
+	Version   int       `json:"disk_format_version"`
+	Sizes     DBSizes   `json:"sizes"`
+	Props     DBProps   `json:"props"`
+	Cluster   DBCluster `json:"cluster"`
+}
+
+// DBProps are properties a database was created with.
+type DBProps struct {
+	Partitioned bool `json:"partitioned"`
+}
+
+// DBCluster describes how a database is spread across a cluster.
+type DBCluster struct {
+	Q int `json:"q"` // shards
+	N int `json:"n"` // copies of each shard
+	W int `json:"w"` // default write quorum
+	R int `json:"r"` // default read quorum
+}
+
+// UnmarshalJSON decodes info from CouchDB 1.x as well as later
+// versions.
+func (i *DBInfo) UnmarshalJSON(b []byte) error {
+	type info DBInfo
+	v := struct {
+		*info
+		UpdateSeq   Sequence `json:"update_seq"`
+		PurgeSeq    Sequence `json:"purge_seq"`
+		CommitedSeq Sequence `json:"committed_update_seq"`
+	}{info: (*info)(i)}
+	if err := json.Unmarshal(b, &v); err != nil {
+		return err
+	}
+	i.Seq = v.UpdateSeq
+	i.UpdateSeq = seqNumber(v.UpdateSeq)
+	i.PurgeSeq = seqNumber(v.PurgeSeq)
+	i.CommitedSeq = seqNumber(v.CommitedSeq)
+	if i.DiskSize == 0 {
+		i.DiskSize = i.Sizes.File
+	}
+	if i.DataSize == 0 {
+		i.DataSize = i.Sizes.Active
+	}
+	return nil
+}
+
+// seqNumber returns the number a sequence starts with, as in "5" or
+// "5-g1AAAA".
+func seqNumber(s Sequence) int64 {
+	str := string(s)
+	if i := strings.IndexByte(str, '-'); i >= 0 {
+		str = str[:i]
+	}
+	n, _ := strconv.ParseInt(str, 10, 64)
+	return n
 }
 
 // GetInfo gets the DBInfo for this database.
 func (p Database) GetInfo() (DBInfo, error) {
 	rv := DBInfo{}
-	err := unmarshalURL(p.DBURL(), &rv)
+	err := p.unmarshalURL(p.DBURL(), &rv)
 	return rv, err
 }
@@ -0,0 +1,111 @@
+// Package couchprom exports go-couch client metrics to Prometheus.
+//
+//	c := couchprom.NewCollector("myapp")
+//	prometheus.MustRegister(c)
+//	db = db.WithMetrics(c)
+package couchprom
+
+import (
+	"strconv"
+
+	"github.com/dustin/go-couch"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Collector implements couch.Metrics and prometheus.Collector.
+type Collector struct {
+	requests   *prometheus.CounterVec
+	latency    *prometheus.HistogramVec
+	sent       *prometheus.HistogramVec
+	received   *prometheus.HistogramVec
+	reconnects *prometheus.CounterVec
+	stale      *prometheus.CounterVec
+}
+
+var sizeBuckets = prometheus.ExponentialBuckets(64, 4, 10)
+
+// NewCollector creates a collector whose metrics are named under the
+// given namespace, e.g. myapp_couchdb_requests_total.
+func NewCollector(namespace string) *Collector {
+	opts := func(name, help string) prometheus.Opts {
+		return prometheus.Opts{
+			Namespace: namespace,
+			Subsystem: "couchdb",
+			Name:      name,
+			Help:      help,
+		}
+	}
+	histogram := func(name, help string, buckets []float64) *prometheus.HistogramVec {
+		o := opts(name, help)
+		return prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: o.Namespace,
+			Subsystem: o.Subsystem,
+			Name:      o.Name,
+			Help:      o.Help,
+			Buckets:   buckets,
+		}, []string{"operation", "method"})
+	}
+
+	return &Collector{
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts(
+			opts("requests_total", "HTTP requests made to CouchDB.")),
+			[]string{"operation", "method", "code"}),
+		latency: histogram("request_duration_seconds",
+			"Time until CouchDB's response headers arrived.",
+			prometheus.DefBuckets),
+		sent: histogram("request_size_bytes",
+			"Size of request bodies sent to CouchDB.", sizeBuckets),
+		received: histogram("response_size_bytes",
+			"Size of response bodies read from CouchDB.", sizeBuckets),
+		reconnects: prometheus.NewCounterVec(prometheus.CounterOpts(
+			opts("changes_reconnects_total", "Changes feed reconnections.")),
+			[]string{"db"}),
+		stale: prometheus.NewCounterVec(prometheus.CounterOpts(
+			opts("changes_stale_total",
+				"Changes feeds abandoned for missing heartbeats.")),
+			[]string{"db"}),
+	}
+}
+
+func (c *Collector) collectors() []prometheus.Collector {
+	return []prometheus.Collector{c.requests, c.latency, c.sent, c.received,
+		c.reconnects, c.stale}
+}
+
+// Describe implements prometheus.Collector.
+func (c *Collector) Describe(ch chan<- *prometheus.Desc) {
+	for _, m := range c.collectors() {
+		m.Describe(ch)
+	}
+}
+
+// Collect implements prometheus.Collector.
+func (c *Collector) Collect(ch chan<- prometheus.Metric) {
+	for _, m := range c.collectors() {
+		m.Collect(ch)
+	}
+}
+
+// ObserveRequest implements couch.Metrics.
+func (c *Collector) ObserveRequest(ri couch.RequestInfo) {
+	code := "error"
+	if ri.Err == nil {
+		code = strconv.Itoa(ri.StatusCode)
+	}
+	c.requests.WithLabelValues(ri.Operation, ri.Method, code).Inc()
+	c.latency.WithLabelValues(ri.Operation, ri.Method).Observe(ri.Duration.Seconds())
+	c.sent.WithLabelValues(ri.Operation, ri.Method).Observe(float64(ri.BytesSent))
+	if ri.Err == nil {
+		c.received.WithLabelValues(ri.Operation, ri.Method).Observe(float64(ri.BytesReceived))
+	}
+}
+
+// ObserveChangesReconnect implements couch.Metrics.
+func (c *Collector) ObserveChangesReconnect(db string) {
+	c.reconnects.WithLabelValues(db).Inc()
+}
+
+// ObserveChangesStale implements couch.Metrics.
+func (c *Collector) ObserveChangesStale(db string) {
+	c.stale.WithLabelValues(db).Inc()
+}
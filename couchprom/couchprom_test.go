@@ -0,0 +1,55 @@
+package couchprom
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dustin/go-couch"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+var _ couch.Metrics = &Collector{}
+
+func TestCollector(t *testing.T) {
+	c := NewCollector("test")
+	reg := prometheus.NewPedanticRegistry()
+	if err := reg.Register(c); err != nil {
+		t.Fatalf("Error registering: %v", err)
+	}
+
+	c.ObserveRequest(couch.RequestInfo{Operation: "document", Method: "GET",
+		StatusCode: 200, Duration: time.Millisecond, BytesReceived: 100})
+	c.ObserveRequest(couch.RequestInfo{Operation: "document", Method: "GET",
+		StatusCode: 404})
+	c.ObserveRequest(couch.RequestInfo{Operation: "bulk_docs", Method: "POST",
+		Err: errors.New("oops"), BytesSent: 10})
+	c.ObserveChangesReconnect("db")
+	c.ObserveChangesReconnect("db")
+	c.ObserveChangesStale("db")
+
+	exp := `
+# HELP test_couchdb_requests_total HTTP requests made to CouchDB.
+# TYPE test_couchdb_requests_total counter
+test_couchdb_requests_total{code="200",method="GET",operation="document"} 1
+test_couchdb_requests_total{code="404",method="GET",operation="document"} 1
+test_couchdb_requests_total{code="error",method="POST",operation="bulk_docs"} 1
+# HELP test_couchdb_changes_reconnects_total Changes feed reconnections.
+# TYPE test_couchdb_changes_reconnects_total counter
+test_couchdb_changes_reconnects_total{db="db"} 2
+# HELP test_couchdb_changes_stale_total Changes feeds abandoned for missing heartbeats.
+# TYPE test_couchdb_changes_stale_total counter
+test_couchdb_changes_stale_total{db="db"} 1
+`
+	if err := testutil.GatherAndCompare(reg, strings.NewReader(exp),
+		"test_couchdb_requests_total", "test_couchdb_changes_reconnects_total",
+		"test_couchdb_changes_stale_total"); err != nil {
+		t.Error(err)
+	}
+
+	if n := testutil.CollectAndCount(c); n != 10 {
+		t.Errorf("Expected 10 series, got %v", n)
+	}
+}
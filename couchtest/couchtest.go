@@ -0,0 +1,394 @@
+// Package couchtest provides stand-ins for CouchDB for testing code that
+// uses go-couch, without a real server.
+//
+// Transport returns canned responses to any request:
+//
+//	tr := &couchtest.Transport{}
+//	tr.Respond(200, `{"_id": "a", "_rev": "1-x"}`)
+//	couch.HTTPClient = &http.Client{Transport: tr}
+//
+// Server is a mock server holding canned documents, views and changes:
+//
+//	s := couchtest.NewServer()
+//	defer s.Close()
+//	s.PutDoc("mydb", "a", map[string]interface{}{"type": "user"})
+//	db, err := couch.Connect(s.DBURL("mydb"))
+package couchtest
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Response builds a canned response with the given status and body.
+func Response(status int, body string) *http.Response {
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", status, http.StatusText(status)),
+		StatusCode:    status,
+		Header:        http.Header{"Content-Type": []string{"application/json"}},
+		Body:          ioutil.NopCloser(strings.NewReader(body)),
+		ContentLength: int64(len(body)),
+	}
+}
+
+// Transport is an http.RoundTripper that answers requests with its
+// Responses in turn, recording the requests.  Once they run out, it
+// answers with a 500 error.
+type Transport struct {
+	mu        sync.Mutex
+	Responses []*http.Response
+	Requests  []*http.Request
+}
+
+// NewTransport returns a Transport answering with the given responses
+// in turn.
+func NewTransport(responses ...*http.Response) *Transport {
+	return &Transport{Responses: responses}
+}
+
+// Respond queues a response with the given status and body.
+func (t *Transport) Respond(status int, body string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.Responses = append(t.Responses, Response(status, body))
+}
+
+// RoundTrip records req and returns the next response.
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.Requests = append(t.Requests, req)
+	res := Response(500, `{"error": "unknown_error", "reason": "no canned response"}`)
+	if len(t.Responses) > 0 {
+		res = t.Responses[0]
+		t.Responses = t.Responses[1:]
+	}
+	res.Request = req
+	return res, nil
+}
+
+// Row is a canned row of a view.
+type Row struct {
+	ID    string
+	Key   interface{}
+	Value interface{}
+}
+
+// Change is a canned change.  Its sequence is its position in the
+// database's changes, counting from 1.
+type Change struct {
+	ID      string
+	Rev     string
+	Deleted bool
+}
+
+type canned struct {
+	status int
+	body   []byte
+}
+
+type database struct {
+	docs    map[string]json.RawMessage
+	views   map[string][]Row
+	changes []Change
+}
+
+// Server is a mock CouchDB server answering from canned documents,
+// views and changes, and from responses given to Handle.  It
+// understands enough of the API for reads: the server root, _all_dbs,
+// database info, documents, _all_docs, views (with limit, skip,
+// descending and include_docs) and the changes feed.  Anything else
+// is answered with a 404 unless it's handled.
+type Server struct {
+	*httptest.Server
+
+	mu       sync.Mutex
+	dbs      map[string]*database
+	handlers map[string]canned
+	requests []string
+}
+
+// NewServer starts a mock server.  Close it when done.
+func NewServer() *Server {
+	s := &Server{
+		dbs:      map[string]*database{},
+		handlers: map[string]canned{},
+	}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.serve))
+	return s
+}
+
+// DBURL returns the URL of the named database on the server.
+func (s *Server) DBURL(db string) string {
+	return s.URL + "/" + url.PathEscape(db)
+}
+
+func (s *Server) db(name string) *database {
+	d := s.dbs[name]
+	if d == nil {
+		d = &database{docs: map[string]json.RawMessage{}, views: map[string][]Row{}}
+		s.dbs[name] = d
+	}
+	return d
+}
+
+// AddDB creates an empty database.
+func (s *Server) AddDB(db string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.db(db)
+}
+
+// PutDoc stores a document, creating the database if needed.  The
+// document is encoded as JSON and given the id, and a revision of
+// "1-couchtest" unless it has one.
+func (s *Server) PutDoc(db, id string, doc interface{}) error {
+	b, err := json.Marshal(doc)
+	if err != nil {
+		return err
+	}
+	m := map[string]interface{}{}
+	if err := json.Unmarshal(b, &m); err != nil {
+		return err
+	}
+	m["_id"] = id
+	if _, ok := m["_rev"]; !ok {
+		m["_rev"] = "1-couchtest"
+	}
+	if b, err = json.Marshal(m); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.db(db).docs[id] = b
+	return nil
+}
+
+// SetView sets the rows of a view, named as it's queried, e.g.
+// "_design/d/_view/v".
+func (s *Server) SetView(db, view string, rows ...Row) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.db(db).views[strings.Trim(view, "/")] = rows
+}
+
+// AddChanges appends to a database's changes.
+func (s *Server) AddChanges(db string, changes ...Change) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	d := s.db(db)
+	d.changes = append(d.changes, changes...)
+}
+
+// Handle answers requests with the method and path (e.g.
+// "/mydb/_bulk_docs", without the query) with the given status and
+// body, in place of the server's own handling.  A body other than a
+// string or []byte is encoded as JSON.
+func (s *Server) Handle(method, path string, status int, body interface{}) error {
+	var b []byte
+	switch v := body.(type) {
+	case string:
+		b = []byte(v)
+	case []byte:
+		b = v
+	default:
+		var err error
+		if b, err = json.Marshal(body); err != nil {
+			return err
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.handlers[method+" "+path] = canned{status, b}
+	return nil
+}
+
+// Requests returns the requests made so far, each as its method and
+// URL path with any query, e.g. "GET /mydb/a?rev=1-x".
+func (s *Server) Requests() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.requests...)
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, errType, reason string) {
+	writeJSON(w, status, map[string]string{"error": errType, "reason": reason})
+}
+
+func (s *Server) serve(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.requests = append(s.requests, r.Method+" "+r.URL.RequestURI())
+
+	if c, ok := s.handlers[r.Method+" "+r.URL.Path]; ok {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(c.status)
+		w.Write(c.body)
+		return
+	}
+
+	segs := strings.Split(strings.Trim(r.URL.EscapedPath(), "/"), "/")
+	for i, seg := range segs {
+		segs[i], _ = url.PathUnescape(seg)
+	}
+	switch {
+	case segs[0] == "":
+		writeJSON(w, 200, map[string]string{"couchdb": "Welcome", "version": "3.3.0"})
+		return
+	case segs[0] == "_all_dbs":
+		names := []string{}
+		for name := range s.dbs {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		writeJSON(w, 200, names)
+		return
+	case strings.HasPrefix(segs[0], "_"):
+		writeError(w, 404, "not_found", "unsupported by couchtest")
+		return
+	}
+
+	d, ok := s.dbs[segs[0]]
+	if !ok {
+		writeError(w, 404, "not_found", "Database does not exist.")
+		return
+	}
+	if r.Method != "GET" && r.Method != "HEAD" {
+		writeError(w, 405, "method_not_allowed", "couchtest databases are read only")
+		return
+	}
+	rest := strings.Join(segs[1:], "/")
+	switch {
+	case rest == "":
+		writeJSON(w, 200, map[string]interface{}{
+			"db_name":    segs[0],
+			"doc_count":  len(d.docs),
+			"update_seq": strconv.Itoa(len(d.changes)),
+		})
+	case rest == "_all_docs":
+		ids := make([]string, 0, len(d.docs))
+		for id := range d.docs {
+			ids = append(ids, id)
+		}
+		sort.Strings(ids)
+		rows := make([]Row, len(ids))
+		for i, id := range ids {
+			rows[i] = Row{ID: id, Key: id,
+				Value: map[string]string{"rev": docRev(d.docs[id])}}
+		}
+		d.writeRows(w, r.URL.Query(), rows)
+	case rest == "_changes":
+		d.writeChanges(w, r.URL.Query())
+	case d.views[rest] != nil:
+		d.writeRows(w, r.URL.Query(), d.views[rest])
+	case d.docs[rest] != nil:
+		w.Header().Set("ETag", `"`+docRev(d.docs[rest])+`"`)
+		writeJSON(w, 200, d.docs[rest])
+	default:
+		writeError(w, 404, "not_found", "missing")
+	}
+}
+
+func docRev(doc json.RawMessage) string {
+	rev := struct {
+		Rev string `json:"_rev"`
+	}{}
+	json.Unmarshal(doc, &rev)
+	return rev.Rev
+}
+
+func intParam(q url.Values, name string) int {
+	n, _ := strconv.Atoi(q.Get(name))
+	return n
+}
+
+func (d *database) writeRows(w http.ResponseWriter, q url.Values, rows []Row) {
+	total := len(rows)
+	if q.Get("descending") == "true" {
+		rev := make([]Row, len(rows))
+		for i, row := range rows {
+			rev[len(rows)-1-i] = row
+		}
+		rows = rev
+	}
+	skip := intParam(q, "skip")
+	if skip > len(rows) {
+		skip = len(rows)
+	}
+	rows = rows[skip:]
+	if limit := intParam(q, "limit"); limit > 0 && limit < len(rows) {
+		rows = rows[:limit]
+	}
+
+	out := make([]map[string]interface{}, len(rows))
+	for i, row := range rows {
+		o := map[string]interface{}{"id": row.ID, "key": row.Key, "value": row.Value}
+		if q.Get("include_docs") == "true" {
+			if doc, ok := d.docs[row.ID]; ok {
+				o["doc"] = doc
+			} else {
+				o["doc"] = nil
+			}
+		}
+		out[i] = o
+	}
+	writeJSON(w, 200, map[string]interface{}{
+		"total_rows": total, "offset": skip, "rows": out,
+	})
+}
+
+type changeRow struct {
+	Seq     string              `json:"seq"`
+	ID      string              `json:"id"`
+	Changes []map[string]string `json:"changes"`
+	Deleted bool                `json:"deleted,omitempty"`
+}
+
+// writeChanges writes the changes after since.  A continuous feed ends
+// after them, leaving the client to reconnect.
+func (d *database) writeChanges(w http.ResponseWriter, q url.Values) {
+	since := intParam(q, "since")
+	if q.Get("since") == "now" || since > len(d.changes) {
+		since = len(d.changes)
+	}
+	rows := []changeRow{}
+	for i, c := range d.changes[since:] {
+		rows = append(rows, changeRow{
+			Seq:     strconv.Itoa(since + i + 1),
+			ID:      c.ID,
+			Changes: []map[string]string{{"rev": c.Rev}},
+			Deleted: c.Deleted,
+		})
+	}
+	lastSeq := strconv.Itoa(len(d.changes))
+
+	if q.Get("feed") != "continuous" {
+		writeJSON(w, 200, map[string]interface{}{
+			"results": rows, "last_seq": lastSeq, "pending": 0,
+		})
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(200)
+	enc := json.NewEncoder(w)
+	for _, row := range rows {
+		enc.Encode(row)
+	}
+	enc.Encode(map[string]string{"last_seq": lastSeq})
+}
@@ -0,0 +1,145 @@
+package couchtest
+
+import (
+	"errors"
+	"net/http"
+	"reflect"
+	"testing"
+
+	"github.com/dustin/go-couch"
+)
+
+func TestTransport(t *testing.T) {
+	tr := &Transport{}
+	tr.Respond(200, `{"_id": "a", "_rev": "1-x", "n": 3}`)
+	defer func(c *http.Client) { couch.HTTPClient = c }(couch.HTTPClient)
+	couch.HTTPClient = &http.Client{Transport: tr}
+
+	db := couch.Database{Host: "localhost", Port: "5984", Name: "db"}
+	doc := struct{ N int }{}
+	if err := db.Retrieve("a", &doc); err != nil {
+		t.Fatalf("Error retrieving: %v", err)
+	}
+	if doc.N != 3 {
+		t.Errorf("Expected n=3, got %+v", doc)
+	}
+	if len(tr.Requests) != 1 || tr.Requests[0].URL.Path != "/db/a" {
+		t.Errorf("Unexpected requests %v", tr.Requests)
+	}
+	if err := db.Retrieve("a", &doc); err == nil {
+		t.Errorf("Expected an error once out of responses")
+	}
+}
+
+func TestNewTransport(t *testing.T) {
+	tr := NewTransport(Response(404, `{"error": "not_found"}`), Response(200, `{}`))
+	for _, exp := range []int{404, 200, 500} {
+		req, _ := http.NewRequest("GET", "http://localhost/", nil)
+		res, err := tr.RoundTrip(req)
+		if err != nil || res.StatusCode != exp || res.Request != req {
+			t.Errorf("Expected a %v response to the request, got %v, %v", exp, res, err)
+		}
+	}
+	if len(tr.Requests) != 3 {
+		t.Errorf("Expected 3 requests recorded, got %v", len(tr.Requests))
+	}
+}
+
+func TestServer(t *testing.T) {
+	s := NewServer()
+	defer s.Close()
+	if err := s.PutDoc("db", "_design/d", map[string]string{"language": "javascript"}); err != nil {
+		t.Fatalf("Error putting doc: %v", err)
+	}
+	s.PutDoc("db", "a", map[string]int{"n": 1})
+	s.PutDoc("db", "b", map[string]interface{}{"n": 2, "_rev": "3-y"})
+	s.SetView("db", "_design/d/_view/v",
+		Row{ID: "a", Key: "x", Value: 1}, Row{ID: "b", Key: "y", Value: 2})
+
+	db, err := couch.Connect(s.DBURL("db"))
+	if err != nil {
+		t.Fatalf("Error connecting: %v", err)
+	}
+
+	doc := struct {
+		Rev string `json:"_rev"`
+		N   int
+	}{}
+	if err := db.Retrieve("b", &doc); err != nil || doc.N != 2 || doc.Rev != "3-y" {
+		t.Errorf("Unexpected doc %+v, %v", doc, err)
+	}
+	if err := db.Retrieve("c", &doc); !errors.Is(err, couch.ErrNotFound) {
+		t.Errorf("Expected not found, got %v", err)
+	}
+
+	res := struct {
+		Rows []struct {
+			ID  string
+			Doc struct{ N int }
+		}
+	}{}
+	err = db.Query("_design/d/_view/v", map[string]interface{}{
+		"descending": true, "limit": 1, "include_docs": true,
+	}, &res)
+	if err != nil {
+		t.Fatalf("Error querying: %v", err)
+	}
+	if len(res.Rows) != 1 || res.Rows[0].ID != "b" || res.Rows[0].Doc.N != 2 {
+		t.Errorf("Unexpected rows %+v", res.Rows)
+	}
+
+	ids := []string{}
+	if err := db.QueryEach("_all_docs", nil, func(r couch.ViewRow) error {
+		ids = append(ids, r.ID)
+		return nil
+	}); err != nil {
+		t.Fatalf("Error querying _all_docs: %v", err)
+	}
+	if !reflect.DeepEqual(ids, []string{"_design/d", "a", "b"}) {
+		t.Errorf("Unexpected ids %v", ids)
+	}
+
+	if _, err := db.Edit(map[string]string{"_id": "a", "_rev": "1-couchtest"}); err == nil {
+		t.Errorf("Expected writes to fail")
+	}
+}
+
+func TestServerHandle(t *testing.T) {
+	s := NewServer()
+	defer s.Close()
+	s.AddDB("db")
+	s.Handle("PUT", "/db/a", 201, map[string]interface{}{"ok": true, "id": "a", "rev": "2-z"})
+
+	db, err := couch.Connect(s.DBURL("db"))
+	if err != nil {
+		t.Fatalf("Error connecting: %v", err)
+	}
+	rev, err := db.Edit(map[string]string{"_id": "a", "_rev": "1-z"})
+	if err != nil || rev != "2-z" {
+		t.Errorf("Expected rev 2-z, got %v, %v", rev, err)
+	}
+	reqs := s.Requests()
+	if reqs[len(reqs)-1] != "PUT /db/a" {
+		t.Errorf("Unexpected requests %v", reqs)
+	}
+}
+
+func TestServerChanges(t *testing.T) {
+	s := NewServer()
+	defer s.Close()
+	s.AddChanges("db", Change{ID: "a", Rev: "1-x"}, Change{ID: "b", Rev: "1-y"},
+		Change{ID: "a", Rev: "2-x", Deleted: true})
+
+	db, err := couch.Connect(s.DBURL("db"))
+	if err != nil {
+		t.Fatalf("Error connecting: %v", err)
+	}
+	batch, err := db.PollChanges("1", 0, nil)
+	if err != nil {
+		t.Fatalf("Error polling: %v", err)
+	}
+	if len(batch.Results) != 2 || batch.Results[1].ID != "a" ||
+		!batch.Results[1].Deleted || batch.LastSeq != "3" {
+		t.Errorf("Unexpected batch %+v", batch)
+	}
+}
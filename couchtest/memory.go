@@ -0,0 +1,791 @@
+package couchtest
+
+import (
+	"crypto/md5"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// MapFunc is the map function of a Memory view, standing in for the
+// JavaScript one.  It's called with each live document, and calls
+// emit for each row the document contributes.
+type MapFunc func(doc map[string]interface{}, emit func(key, value interface{}))
+
+type memDoc struct {
+	id      string
+	rev     string
+	body    map[string]interface{}
+	deleted bool
+	seq     int
+}
+
+// json returns the document as it's retrieved.
+func (d *memDoc) json() map[string]interface{} {
+	m := make(map[string]interface{}, len(d.body)+3)
+	for k, v := range d.body {
+		m[k] = v
+	}
+	m["_id"] = d.id
+	m["_rev"] = d.rev
+	if d.deleted {
+		m["_deleted"] = true
+	}
+	return m
+}
+
+type memDB struct {
+	docs  map[string]*memDoc
+	seq   int
+	views map[string]MapFunc
+}
+
+// Memory is a minimal CouchDB kept in memory, for hermetic tests of
+// code that reads and writes documents.  It supports creating and
+// deleting databases; creating, updating and deleting documents with
+// revision checks; _bulk_docs; _all_docs; views whose map functions
+// are given in Go by DefineView; and the changes feed, including
+// longpoll and continuous feeds.
+//
+// It keeps only the latest revision of each document, so there are no
+// conflicts other than update conflicts, and views can't reduce.  Keys
+// are collated as CouchDB does, except that strings are compared
+// bytewise.  Like a new CouchDB server, it lists the _replicator and
+// _users databases, though they can't be used.
+type Memory struct {
+	*httptest.Server
+
+	mu      sync.Mutex
+	dbs     map[string]*memDB
+	changed chan struct{} // closed and replaced on each write
+}
+
+// NewMemory starts an in-memory server with no databases.  Close it
+// when done.
+func NewMemory() *Memory {
+	m := &Memory{dbs: map[string]*memDB{}, changed: make(chan struct{})}
+	m.Server = httptest.NewServer(http.HandlerFunc(m.serve))
+	return m
+}
+
+// DBURL returns the URL of the named database on the server.
+func (m *Memory) DBURL(db string) string {
+	return m.URL + "/" + url.PathEscape(db)
+}
+
+// AddDB creates a database if it doesn't exist.
+func (m *Memory) AddDB(db string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.dbs[db] == nil {
+		m.dbs[db] = newMemDB()
+	}
+}
+
+func newMemDB() *memDB {
+	return &memDB{docs: map[string]*memDoc{}, views: map[string]MapFunc{}}
+}
+
+// DefineView defines the view queried as db/_design/ddoc/_view/name,
+// creating the database if needed.  The design document itself needn't
+// exist.
+func (m *Memory) DefineView(db, ddoc, name string, f MapFunc) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	d := m.dbs[db]
+	if d == nil {
+		d = newMemDB()
+		m.dbs[db] = d
+	}
+	d.views[strings.TrimPrefix(ddoc, "_design/")+"/"+name] = f
+}
+
+func newUUID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// nextRev returns the revision following rev for the given body.
+func nextRev(rev string, body map[string]interface{}, deleted bool) string {
+	gen := 0
+	if i := strings.Index(rev, "-"); i > 0 {
+		gen, _ = strconv.Atoi(rev[:i])
+	}
+	b, _ := json.Marshal(body)
+	sum := md5.Sum([]byte(fmt.Sprintf("%s%v%s", rev, deleted, b)))
+	return strconv.Itoa(gen+1) + "-" + hex.EncodeToString(sum[:])
+}
+
+// put writes a document over rev, returning its id, which is generated
+// if empty, and new revision, or false if rev isn't current.
+func (m *Memory) put(d *memDB, id string, doc map[string]interface{},
+	rev string) (string, string, bool) {
+
+	if id == "" {
+		id = newUUID()
+	}
+	if r, ok := doc["_rev"].(string); ok && rev == "" {
+		rev = r
+	}
+	deleted, _ := doc["_deleted"].(bool)
+	body := map[string]interface{}{}
+	for k, v := range doc {
+		if !strings.HasPrefix(k, "_") || k == "_attachments" {
+			body[k] = v
+		}
+	}
+
+	old := d.docs[id]
+	switch {
+	case old == nil && rev != "":
+		return id, "", false
+	case old != nil && old.deleted && rev == "":
+		rev = old.rev
+	case old != nil && rev != old.rev:
+		return id, "", false
+	}
+
+	d.seq++
+	nd := &memDoc{id: id, rev: nextRev(rev, body, deleted), body: body,
+		deleted: deleted, seq: d.seq}
+	if deleted {
+		nd.body = map[string]interface{}{}
+	}
+	d.docs[id] = nd
+	close(m.changed)
+	m.changed = make(chan struct{})
+	return id, nd.rev, true
+}
+
+const conflictReason = "Document update conflict."
+
+func writeConflict(w http.ResponseWriter) {
+	writeError(w, http.StatusConflict, "conflict", conflictReason)
+}
+
+func (m *Memory) serve(w http.ResponseWriter, r *http.Request) {
+	segs := strings.Split(strings.Trim(r.URL.EscapedPath(), "/"), "/")
+	for i, seg := range segs {
+		segs[i], _ = url.PathUnescape(seg)
+	}
+
+	if segs[0] == "" || strings.HasPrefix(segs[0], "_") {
+		m.serveServer(w, r, segs[0])
+		return
+	}
+	if len(segs) == 1 {
+		m.serveDB(w, r, segs[0])
+		return
+	}
+	if segs[1] == "_changes" {
+		m.serveChanges(w, r, segs[0])
+		return
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	d := m.dbs[segs[0]]
+	if d == nil {
+		writeError(w, 404, "not_found", "Database does not exist.")
+		return
+	}
+	switch {
+	case segs[1] == "_all_docs":
+		m.serveAllDocs(w, r, d)
+	case segs[1] == "_bulk_docs" && r.Method == "POST":
+		m.serveBulkDocs(w, r, d)
+	case segs[1] == "_design" && len(segs) == 5 && segs[3] == "_view":
+		m.serveView(w, r, d, segs[2]+"/"+segs[4])
+	case (segs[1] == "_design" || segs[1] == "_local") && len(segs) == 3:
+		m.serveDoc(w, r, d, segs[1]+"/"+segs[2])
+	case strings.HasPrefix(segs[1], "_") || len(segs) > 2:
+		writeError(w, 404, "not_found", "unsupported by couchtest")
+	default:
+		m.serveDoc(w, r, d, segs[1])
+	}
+}
+
+func (m *Memory) serveServer(w http.ResponseWriter, r *http.Request, what string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	switch what {
+	case "":
+		writeJSON(w, 200, map[string]string{"couchdb": "Welcome", "version": "3.3.0"})
+	case "_all_dbs":
+		names := []string{"_replicator", "_users"}
+		for name := range m.dbs {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		writeJSON(w, 200, names)
+	case "_uuids":
+		n := intParam(r.URL.Query(), "count")
+		if n < 1 {
+			n = 1
+		}
+		ids := make([]string, n)
+		for i := range ids {
+			ids[i] = newUUID()
+		}
+		writeJSON(w, 200, map[string][]string{"uuids": ids})
+	default:
+		writeError(w, 404, "not_found", "unsupported by couchtest")
+	}
+}
+
+func (m *Memory) serveDB(w http.ResponseWriter, r *http.Request, name string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	d := m.dbs[name]
+	switch {
+	case r.Method == "PUT" && d != nil:
+		writeError(w, 412, "file_exists", "The database could not be created, the file already exists.")
+	case r.Method == "PUT":
+		m.dbs[name] = newMemDB()
+		writeJSON(w, 201, map[string]bool{"ok": true})
+	case d == nil:
+		writeError(w, 404, "not_found", "Database does not exist.")
+	case r.Method == "DELETE":
+		delete(m.dbs, name)
+		writeJSON(w, 200, map[string]bool{"ok": true})
+	case r.Method == "POST":
+		doc := map[string]interface{}{}
+		if err := json.NewDecoder(r.Body).Decode(&doc); err != nil {
+			writeError(w, 400, "bad_request", err.Error())
+			return
+		}
+		id, _ := doc["_id"].(string)
+		id, rev, ok := m.put(d, id, doc, "")
+		if !ok {
+			writeConflict(w)
+			return
+		}
+		writeJSON(w, 201, map[string]interface{}{"ok": true, "id": id, "rev": rev})
+	default:
+		count, deleted := 0, 0
+		for _, doc := range d.docs {
+			switch {
+			case strings.HasPrefix(doc.id, "_local/"):
+			case doc.deleted:
+				deleted++
+			default:
+				count++
+			}
+		}
+		writeJSON(w, 200, map[string]interface{}{
+			"db_name":       name,
+			"doc_count":     count,
+			"doc_del_count": deleted,
+			"update_seq":    strconv.Itoa(d.seq),
+		})
+	}
+}
+
+func (m *Memory) serveDoc(w http.ResponseWriter, r *http.Request, d *memDB, id string) {
+	rev := r.URL.Query().Get("rev")
+	if rev == "" {
+		rev = strings.Trim(r.Header.Get("If-Match"), `"`)
+	}
+
+	switch r.Method {
+	case "GET", "HEAD":
+		doc := d.docs[id]
+		if doc == nil || doc.deleted {
+			reason := "missing"
+			if doc != nil {
+				reason = "deleted"
+			}
+			writeError(w, 404, "not_found", reason)
+			return
+		}
+		if rev != "" && rev != doc.rev {
+			writeError(w, 404, "not_found", "missing")
+			return
+		}
+		w.Header().Set("ETag", `"`+doc.rev+`"`)
+		writeJSON(w, 200, doc.json())
+	case "PUT", "DELETE":
+		doc := map[string]interface{}{}
+		if r.Method == "PUT" {
+			if err := json.NewDecoder(r.Body).Decode(&doc); err != nil {
+				writeError(w, 400, "bad_request", err.Error())
+				return
+			}
+		} else {
+			doc["_deleted"] = true
+		}
+		_, newRev, ok := m.put(d, id, doc, rev)
+		if !ok {
+			writeConflict(w)
+			return
+		}
+		status := 201
+		if r.Method == "DELETE" {
+			status = 200
+		}
+		w.Header().Set("ETag", `"`+newRev+`"`)
+		writeJSON(w, status, map[string]interface{}{"ok": true, "id": id, "rev": newRev})
+	default:
+		writeError(w, 405, "method_not_allowed", "Only DELETE,GET,HEAD,PUT allowed")
+	}
+}
+
+func (m *Memory) serveBulkDocs(w http.ResponseWriter, r *http.Request, d *memDB) {
+	req := struct {
+		Docs []map[string]interface{} `json:"docs"`
+	}{}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, 400, "bad_request", err.Error())
+		return
+	}
+	results := []map[string]interface{}{}
+	for _, doc := range req.Docs {
+		id, _ := doc["_id"].(string)
+		id, rev, ok := m.put(d, id, doc, "")
+		if !ok {
+			results = append(results, map[string]interface{}{
+				"id": id, "error": "conflict", "reason": conflictReason,
+			})
+			continue
+		}
+		results = append(results, map[string]interface{}{"ok": true, "id": id, "rev": rev})
+	}
+	writeJSON(w, 201, results)
+}
+
+// memRow is a row of a view or _all_docs.
+type memRow struct {
+	id    string
+	key   interface{}
+	value interface{}
+	err   string
+}
+
+// viewQuery is the parsed query of a view or _all_docs request.
+type viewQuery struct {
+	keys         []interface{}
+	hasKey       bool
+	key          interface{}
+	start, end   interface{}
+	hasStart     bool
+	hasEnd       bool
+	descending   bool
+	inclusiveEnd bool
+	limit, skip  int
+	includeDocs  bool
+}
+
+func parseViewQuery(r *http.Request) (viewQuery, error) {
+	q := r.URL.Query()
+	vq := viewQuery{
+		descending:   q.Get("descending") == "true",
+		inclusiveEnd: q.Get("inclusive_end") != "false",
+		limit:        intParam(q, "limit"),
+		skip:         intParam(q, "skip"),
+		includeDocs:  q.Get("include_docs") == "true",
+	}
+	param := func(names ...string) (interface{}, bool, error) {
+		for _, name := range names {
+			if s, ok := q[name]; ok {
+				var v interface{}
+				err := json.Unmarshal([]byte(s[0]), &v)
+				return v, true, err
+			}
+		}
+		return nil, false, nil
+	}
+	var err error
+	if vq.key, vq.hasKey, err = param("key"); err != nil {
+		return vq, err
+	}
+	if vq.start, vq.hasStart, err = param("startkey", "start_key"); err != nil {
+		return vq, err
+	}
+	if vq.end, vq.hasEnd, err = param("endkey", "end_key"); err != nil {
+		return vq, err
+	}
+	keys, hasKeys, err := param("keys")
+	if err != nil {
+		return vq, err
+	}
+	if hasKeys {
+		vq.keys, _ = keys.([]interface{})
+		if vq.keys == nil {
+			vq.keys = []interface{}{}
+		}
+	}
+	if r.Method == "POST" {
+		body := struct {
+			Keys []interface{} `json:"keys"`
+		}{}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			return vq, err
+		}
+		if body.Keys != nil {
+			vq.keys = body.Keys
+		}
+	}
+	return vq, nil
+}
+
+// inRange reports whether key is selected by the key, startkey and
+// endkey of the query.
+func (vq viewQuery) inRange(key interface{}) bool {
+	if vq.hasKey {
+		return collate(key, vq.key) == 0
+	}
+	lo, hi := 1, -1
+	if vq.descending {
+		lo, hi = -1, 1
+	}
+	if vq.hasStart && collate(key, vq.start) == -lo {
+		return false
+	}
+	if vq.hasEnd {
+		c := collate(key, vq.end)
+		if c == -hi || c == 0 && !vq.inclusiveEnd {
+			return false
+		}
+	}
+	return true
+}
+
+// writeRows selects and writes rows, which are in key order.
+func (m *Memory) writeRows(w http.ResponseWriter, d *memDB, vq viewQuery, rows []memRow) {
+	total := len(rows)
+	var selected []memRow
+	if vq.keys != nil {
+		for _, key := range vq.keys {
+			for _, row := range rows {
+				if collate(row.key, key) == 0 {
+					selected = append(selected, row)
+				}
+			}
+		}
+	} else {
+		if vq.descending {
+			rev := make([]memRow, len(rows))
+			for i, row := range rows {
+				rev[len(rows)-1-i] = row
+			}
+			rows = rev
+		}
+		for _, row := range rows {
+			if vq.inRange(row.key) {
+				selected = append(selected, row)
+			}
+		}
+	}
+
+	offset := vq.skip
+	if offset > len(selected) {
+		offset = len(selected)
+	}
+	selected = selected[offset:]
+	if vq.limit > 0 && vq.limit < len(selected) {
+		selected = selected[:vq.limit]
+	}
+
+	out := make([]map[string]interface{}, len(selected))
+	for i, row := range selected {
+		if row.err != "" {
+			out[i] = map[string]interface{}{"key": row.key, "error": row.err}
+			continue
+		}
+		o := map[string]interface{}{"id": row.id, "key": row.key, "value": row.value}
+		if vq.includeDocs {
+			id := row.id
+			if v, ok := row.value.(map[string]interface{}); ok {
+				if linked, ok := v["_id"].(string); ok {
+					id = linked
+				}
+			}
+			if doc := d.docs[id]; doc != nil && !doc.deleted {
+				o["doc"] = doc.json()
+			} else {
+				o["doc"] = nil
+			}
+		}
+		out[i] = o
+	}
+	writeJSON(w, 200, map[string]interface{}{
+		"total_rows": total, "offset": offset, "rows": out,
+	})
+}
+
+func (m *Memory) serveAllDocs(w http.ResponseWriter, r *http.Request, d *memDB) {
+	vq, err := parseViewQuery(r)
+	if err != nil {
+		writeError(w, 400, "bad_request", err.Error())
+		return
+	}
+	ids := []string{}
+	for id, doc := range d.docs {
+		if !doc.deleted && !strings.HasPrefix(id, "_local/") {
+			ids = append(ids, id)
+		}
+	}
+	sort.Strings(ids)
+	rows := make([]memRow, len(ids))
+	for i, id := range ids {
+		rows[i] = memRow{id: id, key: id, value: map[string]interface{}{"rev": d.docs[id].rev}}
+	}
+	if vq.keys != nil {
+		// Unlike other rows, those of deleted documents are reported.
+		rows = rows[:0]
+		for _, key := range vq.keys {
+			id, _ := key.(string)
+			switch doc := d.docs[id]; {
+			case doc == nil:
+				rows = append(rows, memRow{key: key, err: "not_found"})
+			case doc.deleted:
+				rows = append(rows, memRow{id: id, key: id, value: map[string]interface{}{
+					"rev": doc.rev, "deleted": true}})
+			default:
+				rows = append(rows, memRow{id: id, key: id,
+					value: map[string]interface{}{"rev": doc.rev}})
+			}
+		}
+		vq.keys = nil
+	}
+	m.writeRows(w, d, vq, rows)
+}
+
+func (m *Memory) serveView(w http.ResponseWriter, r *http.Request, d *memDB, name string) {
+	f := d.views[name]
+	if f == nil {
+		writeError(w, 404, "not_found", "missing_named_view")
+		return
+	}
+	vq, err := parseViewQuery(r)
+	if err != nil {
+		writeError(w, 400, "bad_request", err.Error())
+		return
+	}
+	rows := []memRow{}
+	for id, doc := range d.docs {
+		if doc.deleted || strings.HasPrefix(id, "_local/") {
+			continue
+		}
+		// The map function gets a copy, through JSON as CouchDB's does.
+		var copied map[string]interface{}
+		b, _ := json.Marshal(doc.json())
+		json.Unmarshal(b, &copied)
+		f(copied, func(key, value interface{}) {
+			rows = append(rows, memRow{id: id, key: normalize(key), value: normalize(value)})
+		})
+	}
+	sort.SliceStable(rows, func(i, j int) bool {
+		if c := collate(rows[i].key, rows[j].key); c != 0 {
+			return c < 0
+		}
+		return rows[i].id < rows[j].id
+	})
+	m.writeRows(w, d, vq, rows)
+}
+
+// normalize converts an emitted value to what decoding its JSON gives,
+// so it can be collated.
+func normalize(v interface{}) interface{} {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return nil
+	}
+	var n interface{}
+	json.Unmarshal(b, &n)
+	return n
+}
+
+// collateClass orders the types of JSON values.
+func collateClass(v interface{}) int {
+	switch c := v.(type) {
+	case nil:
+		return 0
+	case bool:
+		if c {
+			return 2
+		}
+		return 1
+	case float64:
+		return 3
+	case string:
+		return 4
+	case []interface{}:
+		return 5
+	}
+	return 6
+}
+
+// collate compares decoded JSON values in view collation order,
+// returning -1, 0 or 1.
+func collate(a, b interface{}) int {
+	ca, cb := collateClass(a), collateClass(b)
+	if ca != cb {
+		if ca < cb {
+			return -1
+		}
+		return 1
+	}
+	switch av := a.(type) {
+	case float64:
+		bv := b.(float64)
+		switch {
+		case av < bv:
+			return -1
+		case av > bv:
+			return 1
+		}
+	case string:
+		return strings.Compare(av, b.(string))
+	case []interface{}:
+		bv := b.([]interface{})
+		for i := 0; i < len(av) && i < len(bv); i++ {
+			if c := collate(av[i], bv[i]); c != 0 {
+				return c
+			}
+		}
+		return collate(float64(len(av)), float64(len(bv)))
+	case map[string]interface{}:
+		bv := b.(map[string]interface{})
+		ak, bk := sortedKeys(av), sortedKeys(bv)
+		for i := 0; i < len(ak) && i < len(bk); i++ {
+			if c := strings.Compare(ak[i], bk[i]); c != 0 {
+				return c
+			}
+			if c := collate(av[ak[i]], bv[bk[i]]); c != 0 {
+				return c
+			}
+		}
+		return collate(float64(len(ak)), float64(len(bk)))
+	}
+	return 0
+}
+
+func sortedKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// changesSince returns the rows of the changes feed after since, and
+// the last sequence.
+func (m *Memory) changesSince(d *memDB, since int, includeDocs bool) ([]map[string]interface{}, int) {
+	docs := []*memDoc{}
+	for _, doc := range d.docs {
+		if doc.seq > since && !strings.HasPrefix(doc.id, "_local/") {
+			docs = append(docs, doc)
+		}
+	}
+	sort.Slice(docs, func(i, j int) bool { return docs[i].seq < docs[j].seq })
+
+	rows := []map[string]interface{}{}
+	for _, doc := range docs {
+		row := map[string]interface{}{
+			"seq":     strconv.Itoa(doc.seq),
+			"id":      doc.id,
+			"changes": []map[string]string{{"rev": doc.rev}},
+		}
+		if doc.deleted {
+			row["deleted"] = true
+		}
+		if includeDocs {
+			row["doc"] = doc.json()
+		}
+		rows = append(rows, row)
+	}
+	return rows, d.seq
+}
+
+func (m *Memory) serveChanges(w http.ResponseWriter, r *http.Request, name string) {
+	q := r.URL.Query()
+	m.mu.Lock()
+	d := m.dbs[name]
+	if d == nil {
+		m.mu.Unlock()
+		writeError(w, 404, "not_found", "Database does not exist.")
+		return
+	}
+	since := intParam(q, "since")
+	if q.Get("since") == "now" {
+		since = d.seq
+	}
+	m.mu.Unlock()
+
+	feed := q.Get("feed")
+	includeDocs := q.Get("include_docs") == "true"
+	timeout := time.Minute
+	if ms := intParam(q, "timeout"); ms > 0 {
+		timeout = time.Duration(ms) * time.Millisecond
+	}
+	heartbeat := time.Duration(intParam(q, "heartbeat")) * time.Millisecond
+	deadline := time.After(timeout)
+
+	w.Header().Set("Content-Type", "application/json")
+	if feed == "continuous" {
+		w.WriteHeader(200)
+	}
+	flusher, _ := w.(http.Flusher)
+	enc := json.NewEncoder(w)
+	for {
+		m.mu.Lock()
+		if m.dbs[name] != d {
+			m.mu.Unlock()
+			return
+		}
+		rows, last := m.changesSince(d, since, includeDocs)
+		changed := m.changed
+		m.mu.Unlock()
+
+		switch {
+		case feed == "continuous":
+			for _, row := range rows {
+				enc.Encode(row)
+			}
+			since = last
+		case len(rows) > 0 || feed != "longpoll":
+			writeJSON(w, 200, map[string]interface{}{
+				"results": rows, "last_seq": strconv.Itoa(last), "pending": 0,
+			})
+			return
+		}
+		if flusher != nil && feed == "continuous" {
+			flusher.Flush()
+		}
+
+		var beat <-chan time.Time
+		if heartbeat > 0 {
+			beat = time.After(heartbeat)
+		}
+		select {
+		case <-changed:
+		case <-beat:
+			if feed == "continuous" {
+				w.Write([]byte("\n"))
+			}
+		case <-deadline:
+			if feed == "continuous" {
+				enc.Encode(map[string]string{"last_seq": strconv.Itoa(last)})
+			} else {
+				writeJSON(w, 200, map[string]interface{}{
+					"results": []interface{}{}, "last_seq": strconv.Itoa(last), "pending": 0,
+				})
+			}
+			return
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
@@ -0,0 +1,195 @@
+package couchtest
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/dustin/go-couch"
+)
+
+type item struct {
+	ID   string `json:"_id,omitempty"`
+	Rev  string `json:"_rev,omitempty"`
+	Type string `json:"type"`
+	N    int    `json:"n"`
+}
+
+func memoryDB(t *testing.T) (*Memory, couch.Database) {
+	m := NewMemory()
+	db, err := couch.FromConfig(couch.Config{URL: m.DBURL("db"), Create: true})
+	if err != nil {
+		m.Close()
+		t.Fatalf("Error connecting: %v", err)
+	}
+	return m, db
+}
+
+func TestMemoryDocs(t *testing.T) {
+	m, db := memoryDB(t)
+	defer m.Close()
+
+	id, rev, err := db.Insert(item{Type: "thing", N: 1})
+	if err != nil {
+		t.Fatalf("Error inserting: %v", err)
+	}
+	doc := item{}
+	if err := db.Retrieve(id, &doc); err != nil {
+		t.Fatalf("Error retrieving: %v", err)
+	}
+	if doc.ID != id || doc.Rev != rev || doc.N != 1 {
+		t.Errorf("Unexpected doc %+v", doc)
+	}
+
+	doc.N = 2
+	rev2, err := db.Edit(doc)
+	if err != nil {
+		t.Fatalf("Error editing: %v", err)
+	}
+	if rev2[:2] != "2-" {
+		t.Errorf("Expected a second generation rev, got %v", rev2)
+	}
+	if _, err := db.Edit(doc); !errors.Is(err, couch.ErrConflict) {
+		t.Errorf("Expected a conflict editing a stale rev, got %v", err)
+	}
+
+	if err := db.Delete(id, rev2); err != nil {
+		t.Fatalf("Error deleting: %v", err)
+	}
+	if err := db.Retrieve(id, &doc); !errors.Is(err, couch.ErrNotFound) {
+		t.Errorf("Expected not found after delete, got %v", err)
+	}
+	info, err := db.GetInfo()
+	if err != nil {
+		t.Fatalf("Error getting info: %v", err)
+	}
+	if info.DocCount != 0 || info.DocDelCount != 1 {
+		t.Errorf("Unexpected info %+v", info)
+	}
+}
+
+func TestMemoryViews(t *testing.T) {
+	m, db := memoryDB(t)
+	defer m.Close()
+	m.DefineView("db", "_design/items", "by_type",
+		func(doc map[string]interface{}, emit func(key, value interface{})) {
+			if typ, ok := doc["type"].(string); ok {
+				emit([]interface{}{typ, doc["n"]}, nil)
+			}
+		})
+
+	docs := []interface{}{
+		item{ID: "a", Type: "x", N: 2}, item{ID: "b", Type: "y", N: 1},
+		item{ID: "c", Type: "x", N: 1}, map[string]string{"_id": "d"},
+	}
+	if _, err := db.Bulk(docs); err != nil {
+		t.Fatalf("Error writing: %v", err)
+	}
+
+	ids := []string{}
+	err := db.QueryEach("_design/items/_view/by_type", map[string]interface{}{
+		"startkey": []interface{}{"x"}, "endkey": []interface{}{"x", map[string]interface{}{}},
+	}, func(r couch.ViewRow) error {
+		ids = append(ids, r.ID)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Error querying: %v", err)
+	}
+	if !reflect.DeepEqual(ids, []string{"c", "a"}) {
+		t.Errorf("Expected [c a], got %v", ids)
+	}
+
+	res := struct {
+		TotalRows int `json:"total_rows"`
+		Rows      []struct {
+			ID  string
+			Doc item
+		}
+	}{}
+	err = db.Query("_design/items/_view/by_type", map[string]interface{}{
+		"descending": true, "limit": 1, "include_docs": true,
+	}, &res)
+	if err != nil {
+		t.Fatalf("Error querying: %v", err)
+	}
+	if res.TotalRows != 3 || len(res.Rows) != 1 || res.Rows[0].Doc.Type != "y" {
+		t.Errorf("Unexpected result %+v", res)
+	}
+
+	ids, err = db.QueryIds("_all_docs", map[string]interface{}{"startkey": "b"})
+	if err != nil {
+		t.Fatalf("Error querying _all_docs: %v", err)
+	}
+	if !reflect.DeepEqual(ids, []string{"b", "c", "d"}) {
+		t.Errorf("Expected [b c d], got %v", ids)
+	}
+}
+
+func TestMemoryChanges(t *testing.T) {
+	m, db := memoryDB(t)
+	defer m.Close()
+
+	id, rev, err := db.Insert(item{Type: "thing"})
+	if err != nil {
+		t.Fatalf("Error inserting: %v", err)
+	}
+	if _, _, err := db.Insert(item{Type: "other"}); err != nil {
+		t.Fatalf("Error inserting: %v", err)
+	}
+	if err := db.Delete(id, rev); err != nil {
+		t.Fatalf("Error deleting: %v", err)
+	}
+
+	batch, err := db.PollChanges("", time.Second, nil)
+	if err != nil {
+		t.Fatalf("Error polling: %v", err)
+	}
+	if len(batch.Results) != 2 || batch.Results[1].ID != id ||
+		!batch.Results[1].Deleted || batch.LastSeq != "3" {
+		t.Errorf("Unexpected batch %+v", batch)
+	}
+
+	// A long poll waits for the next change.
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		db.Insert(item{Type: "late"})
+	}()
+	batch, err = db.PollChanges(batch.LastSeq, 5*time.Second, nil)
+	if err != nil {
+		t.Fatalf("Error polling: %v", err)
+	}
+	if len(batch.Results) != 1 || batch.LastSeq != "4" {
+		t.Errorf("Unexpected batch %+v", batch)
+	}
+
+	var mu sync.Mutex
+	seen := []string{}
+	got := make(chan struct{})
+	c := db.ConsumeChanges("3", func(ch couch.Change) error {
+		mu.Lock()
+		defer mu.Unlock()
+		seen = append(seen, string(ch.Seq))
+		if len(seen) == 2 {
+			close(got)
+		}
+		return nil
+	}, nil, nil)
+	db.Insert(item{Type: "later"})
+	select {
+	case <-got:
+	case <-time.After(5 * time.Second):
+		t.Errorf("Timed out waiting for changes")
+	}
+	if err := c.Drain(context.Background()); err != nil {
+		t.Errorf("Error draining: %v", err)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if !reflect.DeepEqual(seen, []string{"4", "5"}) {
+		t.Errorf("Expected changes 4 and 5, got %v", seen)
+	}
+}
@@ -0,0 +1,398 @@
+package couch
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const defaultTransferBatch = 500
+
+// TransferProgress reports how far a Dump or Restore has got.
+type TransferProgress struct {
+	// Documents done, including those done by an earlier run being
+	// resumed, where known.
+	Docs int64
+	// Documents in all, or 0 if unknown.
+	Total int64
+	// Bytes written by Dump or read by Restore in this run.
+	Bytes int64
+	// ID of the last document done.  A Dump can be resumed after it.
+	Last string
+	// Sequence of the last change done by a Dump of changes, to pass
+	// as Since for the next.
+	Seq Sequence
+	// Time taken by this run.
+	Elapsed time.Duration
+	// Estimated time remaining, or 0 if unknown.
+	ETA time.Duration
+}
+
+// transfer tracks the progress of a Dump or Restore.
+type transfer struct {
+	TransferProgress
+	start    time.Time
+	resumed  int64 // docs done before this run
+	progress func(TransferProgress)
+}
+
+func newTransfer(progress func(TransferProgress)) *transfer {
+	return &transfer{start: time.Now(), progress: progress}
+}
+
+func (t *transfer) report() {
+	if t.progress == nil {
+		return
+	}
+	t.Elapsed = time.Since(t.start)
+	t.ETA = 0
+	if done := t.Docs - t.resumed; done > 0 && t.Total > t.Docs {
+		t.ETA = time.Duration(int64(t.Elapsed) / done * (t.Total - t.Docs))
+	}
+	t.progress(t.TransferProgress)
+}
+
+// DumpOptions configures a Dump.
+type DumpOptions struct {
+	// StartAfter resumes a dump after the document with this id,
+	// e.g. the Last reported by an interrupted dump.
+	StartAfter string
+	// Documents fetched per request (default 500).
+	BatchSize int
+	// Progress, if set, is called after each batch.
+	Progress func(TransferProgress)
+	// Attachments includes the content of attachments, base64 encoded,
+	// rather than stubs that can't be restored into another database.
+	Attachments bool
+	// SkipDesignDocs leaves out design documents.
+	SkipDesignDocs bool
+	// Since, if set, dumps only the documents changed after this
+	// sequence ("0" for all), read from the changes feed, including
+	// deleted ones, so the output can update an earlier dump's
+	// restore.  StartAfter is ignored.
+	Since Sequence
+}
+
+type allDocsPage struct {
+	TotalRows int64 `json:"total_rows"`
+	Offset    int64 `json:"offset"`
+	Rows      []struct {
+		ID  string          `json:"id"`
+		Doc json.RawMessage `json:"doc"`
+	} `json:"rows"`
+}
+
+// Dump writes every document in the database to w, in id order, as
+// one line of JSON each.  See Restore.
+//
+// An interrupted dump may be resumed by appending to the same output
+// with StartAfter set to the last document written.
+func (p Database) Dump(w io.Writer, opts DumpOptions) error {
+	batch := opts.BatchSize
+	if batch <= 0 {
+		batch = defaultTransferBatch
+	}
+	t := newTransfer(opts.Progress)
+	if opts.Since != "" {
+		return p.dumpChanges(w, opts, batch, t)
+	}
+	last := opts.StartAfter
+
+	buf := &bytes.Buffer{}
+	for first := true; ; first = false {
+		params := url.Values{
+			"include_docs": []string{"true"},
+			// One more, as the page starts with the last one done.
+			"limit": []string{strconv.Itoa(batch + 1)},
+		}
+		if opts.Attachments {
+			params.Set("attachments", "true")
+		}
+		if last != "" {
+			k, _ := json.Marshal(last)
+			params.Set("startkey", string(k))
+		}
+		page := allDocsPage{}
+		if err := p.dumpPage(p.dbURL("_all_docs")+"?"+params.Encode(), &page); err != nil {
+			return err
+		}
+		if first {
+			t.Total = page.TotalRows
+			t.Docs = page.Offset
+			if len(page.Rows) > 0 && last != "" && page.Rows[0].ID == last {
+				t.Docs++
+			}
+			t.resumed = t.Docs
+		}
+
+		for _, row := range page.Rows {
+			if last != "" && row.ID == last {
+				continue
+			}
+			if !opts.SkipDesignDocs || !strings.HasPrefix(row.ID, "_design/") {
+				if err := t.writeDoc(w, buf, row.Doc); err != nil {
+					return err
+				}
+			}
+			t.Docs++
+			last = row.ID
+		}
+		t.Last = last
+		t.report()
+
+		if len(page.Rows) <= batch {
+			return nil
+		}
+	}
+}
+
+// writeDoc writes a document as a line of the dump.
+func (t *transfer) writeDoc(w io.Writer, buf *bytes.Buffer, doc json.RawMessage) error {
+	buf.Reset()
+	if err := json.Compact(buf, doc); err != nil {
+		return err
+	}
+	buf.WriteByte('\n')
+	n, err := w.Write(buf.Bytes())
+	t.Bytes += int64(n)
+	return err
+}
+
+// dumpChanges dumps the documents changed after opts.Since.
+func (p Database) dumpChanges(w io.Writer, opts DumpOptions, batch int,
+	t *transfer) error {
+
+	since := opts.Since
+	buf := &bytes.Buffer{}
+	for {
+		params := url.Values{
+			"include_docs": []string{"true"},
+			"since":        []string{string(since)},
+			"limit":        []string{strconv.Itoa(batch)},
+		}
+		if opts.Attachments {
+			params.Set("attachments", "true")
+		}
+		page := ChangesBatch{}
+		if err := p.dumpPage(p.dbURL("_changes")+"?"+params.Encode(), &page); err != nil {
+			return err
+		}
+
+		for _, c := range page.Results {
+			if c.Doc != nil &&
+				(!opts.SkipDesignDocs || !strings.HasPrefix(c.ID, "_design/")) {
+				if err := t.writeDoc(w, buf, c.Doc); err != nil {
+					return err
+				}
+			}
+			t.Docs++
+			t.Last = c.ID
+			t.Seq = c.Seq
+		}
+		if page.LastSeq != "" {
+			since, t.Seq = page.LastSeq, page.LastSeq
+		}
+		t.Total = t.Docs + page.Pending
+		t.report()
+
+		if len(page.Results) < batch {
+			return nil
+		}
+	}
+}
+
+func (p Database) dumpPage(u string, page interface{}) error {
+	body, err := p.getBody(u)
+	if err != nil {
+		return err
+	}
+	body = p.limitReadCloser(body)
+	defer body.Close()
+	return json.NewDecoder(body).Decode(page)
+}
+
+// RestoreOptions configures a Restore.
+type RestoreOptions struct {
+	// Documents written per request (default 500).
+	BatchSize int
+	// Progress, if set, is called after each batch.
+	Progress func(TransferProgress)
+	// NewRevisions writes the documents as new edits, rather than
+	// keeping their dumped revisions, replacing any already in the
+	// database.  Deleted documents are deleted if present.  This suits
+	// restoring into a database with a history of its own.
+	NewRevisions bool
+	// StartAfter resumes a restore after the document with this id,
+	// e.g. the Last reported before a restore failed.  Restore fails
+	// if the dump doesn't hold it.
+	StartAfter string
+}
+
+// Restore writes the documents of a Dump, read from r, into the
+// database, keeping their revisions (new_edits=false) unless
+// NewRevisions is set.
+//
+// Documents already in the database at their dumped revision are
+// skipped, so an interrupted restore can simply be run again.  With
+// NewRevisions they'd be written again, so set StartAfter to the Last
+// reported by Progress to resume instead.
+func (p Database) Restore(r io.Reader, opts RestoreOptions) error {
+	batch := opts.BatchSize
+	if batch <= 0 {
+		batch = defaultTransferBatch
+	}
+	t := newTransfer(opts.Progress)
+	skipping := opts.StartAfter != ""
+
+	br := bufio.NewReader(p.limitReader(r))
+	var docs []json.RawMessage
+	for {
+		line, err := br.ReadBytes('\n')
+		t.Bytes += int64(len(line))
+		if line = bytes.TrimSpace(line); len(line) > 0 {
+			if skipping {
+				id, _, derr := docIDRev(line)
+				if derr != nil {
+					return derr
+				}
+				skipping = id != opts.StartAfter
+				t.Docs++
+				t.resumed = t.Docs
+			} else {
+				docs = append(docs, json.RawMessage(line))
+			}
+		}
+		if len(docs) > 0 && (len(docs) >= batch || err == io.EOF) {
+			last, rerr := p.restoreBatch(docs, opts.NewRevisions)
+			if rerr != nil {
+				return rerr
+			}
+			t.Docs += int64(len(docs))
+			t.Last = last
+			t.report()
+			docs = docs[:0]
+		}
+		if err == io.EOF && skipping {
+			return fmt.Errorf("couch: restore's StartAfter %q isn't in the dump", opts.StartAfter)
+		}
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// restoreBatch writes the docs not already in the database at the
+// same revision, returning the id of the last.  With newRevs, it
+// writes them over the database's revisions instead.
+func (p Database) restoreBatch(docs []json.RawMessage, newRevs bool) (string, error) {
+	ids := make([]string, len(docs))
+	revs := make([]string, len(docs))
+	for i, d := range docs {
+		var err error
+		if ids[i], revs[i], err = docIDRev(d); err != nil {
+			return "", err
+		}
+		if ids[i] == "" {
+			return "", errNoID
+		}
+	}
+
+	keys, err := json.Marshal(map[string]interface{}{"keys": ids})
+	if err != nil {
+		return "", err
+	}
+	existing := struct {
+		Rows []struct {
+			Key   string `json:"key"`
+			Value struct {
+				Rev     string `json:"rev"`
+				Deleted bool   `json:"deleted"`
+			} `json:"value"`
+		} `json:"rows"`
+	}{}
+	if _, err := p.interact("POST", p.dbURL("_all_docs"), p.defaultHdrs,
+		keys, &existing); err != nil {
+		return "", err
+	}
+	have := map[string]string{}
+	for _, row := range existing.Rows {
+		if row.Value.Rev != "" && (!newRevs || !row.Value.Deleted) {
+			have[row.Key] = row.Value.Rev
+		}
+	}
+	last := ids[len(ids)-1]
+	if newRevs {
+		return last, p.restoreNewRevisions(docs, ids, have)
+	}
+
+	var missing []json.RawMessage
+	for i, d := range docs {
+		if revs[i] == "" || have[ids[i]] != revs[i] {
+			missing = append(missing, d)
+		}
+	}
+	if len(missing) == 0 {
+		return last, nil
+	}
+
+	failed, err := p.bulkWriteRevisions(missing)
+	if err != nil {
+		return "", err
+	}
+	if len(failed) > 0 {
+		r := failed[0]
+		return "", fmt.Errorf("restoring %s: %s: %s", r.ID, r.Error, r.Reason)
+	}
+	return last, nil
+}
+
+// restoreNewRevisions writes docs as new edits over the revisions the
+// database has.
+func (p Database) restoreNewRevisions(docs []json.RawMessage, ids []string,
+	have map[string]string) error {
+
+	var writes []interface{}
+	for i, d := range docs {
+		body, _, _, err := stripIDRev(d)
+		if err != nil {
+			return err
+		}
+		deleted := struct {
+			Deleted bool `json:"_deleted"`
+		}{}
+		json.Unmarshal(body, &deleted)
+		rev, exists := have[ids[i]]
+		if deleted.Deleted && !exists {
+			continue
+		}
+		writes = append(writes, json.RawMessage(withIDRev(body, ids[i], rev)))
+	}
+	if len(writes) == 0 {
+		return nil
+	}
+
+	jsonBuf, err := json.Marshal(map[string]interface{}{"docs": writes})
+	if err != nil {
+		return err
+	}
+	results := []Response{}
+	if _, err := p.interact("POST", p.dbURL("_bulk_docs"), p.defaultHdrs,
+		jsonBuf, &results); err != nil {
+		return err
+	}
+	for _, r := range results {
+		if r.Error != "" {
+			return fmt.Errorf("restoring %s: %s: %s", r.ID, r.Error, r.Reason)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,309 @@
+package couch
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/dustin/go-couch/couchtest"
+)
+
+func allDocsRow(id string) string {
+	return `{"id":"` + id + `","key":"` + id + `","value":{"rev":"1-` + id +
+		`"},"doc":{"_id": "` + id + `", "_rev": "1-` + id + `"}}`
+}
+
+func TestDump(t *testing.T) {
+	f := &couchtest.Transport{Responses: []*http.Response{
+		docResponse(`{"total_rows": 4, "offset": 0, "rows": [` +
+			allDocsRow("a") + "," + allDocsRow("b") + "," + allDocsRow("c") + `]}`),
+		docResponse(`{"total_rows": 4, "offset": 2, "rows": [` +
+			allDocsRow("c") + "," + allDocsRow("d") + `]}`),
+	}}
+	defer uninstallFakeHTTP(installFakeHTTP(f))
+
+	var progress []TransferProgress
+	out := &bytes.Buffer{}
+	err := Database{Name: "db"}.Dump(out, DumpOptions{
+		BatchSize: 2,
+		Progress:  func(tp TransferProgress) { progress = append(progress, tp) },
+	})
+	if err != nil {
+		t.Fatalf("Error dumping: %v", err)
+	}
+
+	exp := `{"_id":"a","_rev":"1-a"}
+{"_id":"b","_rev":"1-b"}
+{"_id":"c","_rev":"1-c"}
+{"_id":"d","_rev":"1-d"}
+`
+	if out.String() != exp {
+		t.Errorf("Expected:\n%s\ngot:\n%s", exp, out)
+	}
+	if q := f.Requests[1].URL.Query(); q.Get("startkey") != `"c"` ||
+		q.Get("include_docs") != "true" {
+		t.Errorf("Unexpected second page query: %v", q)
+	}
+	if len(progress) != 2 {
+		t.Fatalf("Expected progress per batch, got %+v", progress)
+	}
+	last := progress[1]
+	if last.Docs != 4 || last.Total != 4 || last.Last != "d" ||
+		last.Bytes != int64(len(exp)) || last.ETA != 0 {
+		t.Errorf("Unexpected final progress: %+v", last)
+	}
+}
+
+func TestDumpResume(t *testing.T) {
+	f := &couchtest.Transport{Responses: []*http.Response{
+		docResponse(`{"total_rows": 4, "offset": 1, "rows": [` +
+			allDocsRow("b") + "," + allDocsRow("c") + `]}`),
+	}}
+	defer uninstallFakeHTTP(installFakeHTTP(f))
+
+	var progress []TransferProgress
+	out := &bytes.Buffer{}
+	err := Database{Name: "db"}.Dump(out, DumpOptions{
+		StartAfter: "b",
+		Progress:   func(tp TransferProgress) { progress = append(progress, tp) },
+	})
+	if err != nil {
+		t.Fatalf("Error dumping: %v", err)
+	}
+	if out.String() != "{\"_id\":\"c\",\"_rev\":\"1-c\"}\n" {
+		t.Errorf("Expected only c, got %s", out)
+	}
+	if q := f.Requests[0].URL.Query().Get("startkey"); q != `"b"` {
+		t.Errorf("Expected to start at b, got %q", q)
+	}
+	if len(progress) != 1 || progress[0].Docs != 3 || progress[0].Total != 4 {
+		t.Errorf("Expected a and b counted as done, got %+v", progress)
+	}
+}
+
+func TestRestore(t *testing.T) {
+	f := &couchtest.Transport{Responses: []*http.Response{
+		docResponse(`{"rows": [` + allDocsRow("a") +
+			`, {"key": "b", "error": "not_found"}]}`),
+		docResponse(`[]`),
+		docResponse(`{"rows": [{"id": "c", "key": "c", "value": {"rev": "1-c"}}]}`),
+		docResponse(`[]`),
+	}}
+	defer uninstallFakeHTTP(installFakeHTTP(f))
+
+	in := `{"_id":"a","_rev":"1-a"}
+{"_id":"b","_rev":"1-b","v":1}
+
+{"_id":"c","_rev":"2-c"}`
+	var progress []TransferProgress
+	err := Database{Name: "db"}.Restore(strings.NewReader(in), RestoreOptions{
+		BatchSize: 2,
+		Progress:  func(tp TransferProgress) { progress = append(progress, tp) },
+	})
+	if err != nil {
+		t.Fatalf("Error restoring: %v", err)
+	}
+	if len(f.Requests) != 4 {
+		t.Fatalf("Expected 4 requests, got %v", len(f.Requests))
+	}
+
+	written := struct {
+		Docs     []map[string]interface{} `json:"docs"`
+		NewEdits *bool                    `json:"new_edits"`
+	}{}
+	must(json.NewDecoder(f.Requests[1].Body).Decode(&written))
+	if written.NewEdits == nil || *written.NewEdits {
+		t.Errorf("Expected new_edits=false")
+	}
+	exp := []map[string]interface{}{{"_id": "b", "_rev": "1-b", "v": 1.0}}
+	if !reflect.DeepEqual(written.Docs, exp) {
+		t.Errorf("Expected only b written, got %v", written.Docs)
+	}
+	if f.Requests[3].URL.Path != "/db/_bulk_docs" {
+		t.Errorf("Expected c written at its newer revision, got %v",
+			f.Requests[3].URL)
+	}
+
+	if len(progress) != 2 || progress[1].Docs != 3 || progress[1].Last != "c" ||
+		progress[1].Bytes != int64(len(in)) {
+		t.Errorf("Unexpected progress: %+v", progress)
+	}
+}
+
+func TestRestoreError(t *testing.T) {
+	f := &couchtest.Transport{Responses: []*http.Response{
+		docResponse(`{"rows": [{"key": "a", "error": "not_found"}]}`),
+		docResponse(`[{"id": "a", "error": "forbidden", "reason": "nope"}]`),
+	}}
+	defer uninstallFakeHTTP(installFakeHTTP(f))
+
+	err := Database{}.Restore(strings.NewReader(`{"_id":"a","_rev":"1-a"}`),
+		RestoreOptions{})
+	if err == nil || !strings.Contains(err.Error(), "forbidden") {
+		t.Errorf("Expected forbidden error, got %v", err)
+	}
+
+	if err := (Database{}).Restore(strings.NewReader(`{"_rev":"1-a"}`),
+		RestoreOptions{}); err != errNoID {
+		t.Errorf("Expected 'no ID' error, got %v", err)
+	}
+}
+
+func TestDumpOptions(t *testing.T) {
+	f := couchtest.NewTransport(docResponse(`{"total_rows": 3, "offset": 0, "rows": [` +
+		allDocsRow("_design/app") + "," + allDocsRow("a") + "," + allDocsRow("b") + `]}`))
+	defer uninstallFakeHTTP(installFakeHTTP(f))
+
+	var progress TransferProgress
+	out := &bytes.Buffer{}
+	err := Database{Name: "db"}.Dump(out, DumpOptions{
+		Attachments: true, SkipDesignDocs: true,
+		Progress: func(tp TransferProgress) { progress = tp },
+	})
+	if err != nil {
+		t.Fatalf("Error dumping: %v", err)
+	}
+	exp := `{"_id":"a","_rev":"1-a"}
+{"_id":"b","_rev":"1-b"}
+`
+	if out.String() != exp {
+		t.Errorf("Expected:\n%s\ngot:\n%s", exp, out)
+	}
+	if f.Requests[0].URL.Query().Get("attachments") != "true" {
+		t.Errorf("Expected attachments to be requested: %v", f.Requests[0].URL)
+	}
+	if progress.Docs != 3 || progress.Total != 3 {
+		t.Errorf("Unexpected progress %+v", progress)
+	}
+}
+
+func TestDumpChanges(t *testing.T) {
+	change := func(seq, id, doc string) string {
+		return `{"seq": "` + seq + `", "id": "` + id + `", "doc": ` + doc + `}`
+	}
+	f := &couchtest.Transport{Responses: []*http.Response{
+		docResponse(`{"results": [` +
+			change("6-x", "a", `{"_id": "a", "_rev": "2-a"}`) + "," +
+			change("7-x", "_design/app", `{"_id": "_design/app", "_rev": "1-d"}`) +
+			`], "last_seq": "7-x", "pending": 1}`),
+		docResponse(`{"results": [` +
+			change("8-x", "b", `{"_id": "b", "_rev": "3-b", "_deleted": true}`) +
+			`], "last_seq": "8-x", "pending": 0}`),
+	}}
+	defer uninstallFakeHTTP(installFakeHTTP(f))
+
+	var progress []TransferProgress
+	out := &bytes.Buffer{}
+	err := Database{Name: "db"}.Dump(out, DumpOptions{
+		Since: "5-x", BatchSize: 2, SkipDesignDocs: true,
+		Progress: func(tp TransferProgress) { progress = append(progress, tp) },
+	})
+	if err != nil {
+		t.Fatalf("Error dumping: %v", err)
+	}
+	exp := `{"_id":"a","_rev":"2-a"}
+{"_id":"b","_rev":"3-b","_deleted":true}
+`
+	if out.String() != exp {
+		t.Errorf("Expected:\n%s\ngot:\n%s", exp, out)
+	}
+	if q := f.Requests[1].URL.Query(); f.Requests[1].URL.Path != "/db/_changes" ||
+		q.Get("since") != "7-x" || q.Get("include_docs") != "true" || q.Get("limit") != "2" {
+		t.Errorf("Unexpected second request %v", f.Requests[1].URL)
+	}
+	if len(progress) != 2 || progress[0].Total != 3 || progress[1].Docs != 3 ||
+		progress[1].Seq != "8-x" || progress[1].Last != "b" {
+		t.Errorf("Unexpected progress %+v", progress)
+	}
+}
+
+func TestRestoreNewRevisions(t *testing.T) {
+	f := &couchtest.Transport{Responses: []*http.Response{
+		docResponse(`{"rows": [{"id": "a", "key": "a", "value": {"rev": "5-z"}},
+			{"key": "b", "error": "not_found"}, {"key": "c", "error": "not_found"},
+			{"id": "d", "key": "d", "value": {"rev": "2-d", "deleted": true}}]}`),
+		docResponse(`[]`),
+	}}
+	defer uninstallFakeHTTP(installFakeHTTP(f))
+
+	in := `{"_id":"a","_rev":"1-a","v":1}
+{"_id":"b","_rev":"1-b"}
+{"_id":"c","_rev":"2-c","_deleted":true}
+{"_id":"d","_rev":"1-d","v":4}
+`
+	err := Database{Name: "db"}.Restore(strings.NewReader(in),
+		RestoreOptions{NewRevisions: true})
+	if err != nil {
+		t.Fatalf("Error restoring: %v", err)
+	}
+	written := struct {
+		Docs     []map[string]interface{} `json:"docs"`
+		NewEdits *bool                    `json:"new_edits"`
+	}{}
+	must(json.NewDecoder(f.Requests[1].Body).Decode(&written))
+	if written.NewEdits != nil {
+		t.Errorf("Expected new edits")
+	}
+	exp := []map[string]interface{}{
+		{"_id": "a", "_rev": "5-z", "v": 1.0},
+		{"_id": "b"},
+		{"_id": "d", "v": 4.0},
+	}
+	if !reflect.DeepEqual(written.Docs, exp) {
+		t.Errorf("Expected %v, got %v", exp, written.Docs)
+	}
+}
+
+func TestRestoreStartAfter(t *testing.T) {
+	f := &couchtest.Transport{Responses: []*http.Response{
+		docResponse(`{"rows": [{"key": "c", "error": "not_found"}]}`),
+		docResponse(`[]`),
+	}}
+	defer uninstallFakeHTTP(installFakeHTTP(f))
+
+	in := `{"_id":"a","_rev":"1-a"}
+{"_id":"b","_rev":"1-b"}
+{"_id":"c","_rev":"1-c"}
+`
+	var progress TransferProgress
+	err := Database{Name: "db"}.Restore(strings.NewReader(in), RestoreOptions{
+		StartAfter: "b",
+		Progress:   func(tp TransferProgress) { progress = tp },
+	})
+	if err != nil {
+		t.Fatalf("Error restoring: %v", err)
+	}
+	keys := struct{ Keys []string }{}
+	must(json.NewDecoder(f.Requests[0].Body).Decode(&keys))
+	if !reflect.DeepEqual(keys.Keys, []string{"c"}) {
+		t.Errorf("Expected only c restored, got %v", keys.Keys)
+	}
+	if progress.Docs != 3 || progress.Last != "c" {
+		t.Errorf("Unexpected progress %+v", progress)
+	}
+}
+
+func TestRestoreStartAfterMissing(t *testing.T) {
+	f := &couchtest.Transport{}
+	defer uninstallFakeHTTP(installFakeHTTP(f))
+
+	in := `{"_id":"a","_rev":"1-a"}
+{"_id":"b","_rev":"1-b"}
+`
+	err := Database{Name: "db"}.Restore(strings.NewReader(in), RestoreOptions{StartAfter: "x"})
+	if err == nil || !strings.Contains(err.Error(), `"x"`) {
+		t.Errorf("Expected an error naming x, got %v", err)
+	}
+	if len(f.Requests) != 0 {
+		t.Errorf("Expected nothing restored, got %v requests", len(f.Requests))
+	}
+
+	// Resuming after the last document has nothing left to do.
+	if err := (Database{Name: "db"}).Restore(strings.NewReader(in),
+		RestoreOptions{StartAfter: "b"}); err != nil {
+		t.Errorf("Expected success, got %v", err)
+	}
+}
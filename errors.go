@@ -0,0 +1,122 @@
+package couch
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+)
+
+// Errors that an HTTPError matches with errors.Is, based on its status
+// code or CouchDB error name.
+var (
+	ErrBadRequest         = errors.New("couch: bad request")
+	ErrUnauthorized       = errors.New("couch: unauthorized")
+	ErrForbidden          = errors.New("couch: forbidden")
+	ErrNotFound           = errors.New("couch: not found")
+	ErrConflict           = errors.New("couch: conflict")
+	ErrPreconditionFailed = errors.New("couch: precondition failed")
+	ErrTooManyRequests    = errors.New("couch: too many requests")
+)
+
+// HTTPError is returned when CouchDB responds with an unsuccessful
+// status.
+//
+// Use errors.Is with the above errors to check for common failures,
+// e.g. errors.Is(err, couch.ErrConflict).
+type HTTPError struct {
+	// The request, if known, with any password in URL redacted.
+	Method string
+	URL    string
+
+	StatusCode int    // e.g. 409
+	Status     string // e.g. "409 Conflict"
+	ErrorType  string // CouchDB's error name, e.g. "conflict"
+	Reason     string // CouchDB's explanation
+	// The raw response body, up to maxErrorBody bytes.
+	Body      []byte
+	Truncated bool // whether Body was cut short
+	// The server's id for the request, to find it in the server's log.
+	RequestID string
+}
+
+func (e *HTTPError) Error() string {
+	status := e.Status
+	if status == "" {
+		status = fmt.Sprintf("%d %s", e.StatusCode, http.StatusText(e.StatusCode))
+	}
+	msg := status
+	if e.Method != "" || e.URL != "" {
+		msg = strings.TrimSpace(e.Method+" "+e.URL) + ": " + status
+	}
+	switch {
+	case e.ErrorType != "":
+		msg = fmt.Sprintf("%s: %s: %s", msg, e.ErrorType, e.Reason)
+	case len(e.Body) > 0:
+		msg = fmt.Sprintf("%s - %s", msg, strings.TrimSpace(string(e.Body)))
+	}
+	if e.RequestID != "" {
+		msg += " (request " + e.RequestID + ")"
+	}
+	return msg
+}
+
+// Is reports whether e is one of the above well-known errors.
+func (e *HTTPError) Is(target error) bool {
+	switch target {
+	case ErrBadRequest:
+		return e.StatusCode == http.StatusBadRequest || e.ErrorType == "bad_request"
+	case ErrUnauthorized:
+		return e.StatusCode == http.StatusUnauthorized || e.ErrorType == "unauthorized"
+	case ErrForbidden:
+		return e.StatusCode == http.StatusForbidden || e.ErrorType == "forbidden"
+	case ErrNotFound:
+		return e.StatusCode == http.StatusNotFound || e.ErrorType == "not_found"
+	case ErrConflict:
+		return e.StatusCode == http.StatusConflict || e.ErrorType == "conflict"
+	case ErrPreconditionFailed:
+		return e.StatusCode == http.StatusPreconditionFailed ||
+			e.ErrorType == "file_exists"
+	case ErrTooManyRequests:
+		return e.StatusCode == http.StatusTooManyRequests ||
+			e.ErrorType == "too_many_requests"
+	}
+	return false
+}
+
+// maxErrorBody is the most of an unsuccessful response's body an
+// HTTPError keeps.
+const maxErrorBody = 8 << 10
+
+// newHTTPError builds an HTTPError from an unsuccessful response,
+// reading up to maxErrorBody of its body.
+func newHTTPError(res *http.Response) error {
+	body, err := ioutil.ReadAll(io.LimitReader(res.Body, maxErrorBody+1))
+	if err != nil {
+		return err
+	}
+	e := &HTTPError{
+		StatusCode: res.StatusCode,
+		Status:     res.Status,
+		Body:       body,
+		RequestID:  res.Header.Get(ServerRequestIDHeader),
+	}
+	if len(body) > maxErrorBody {
+		e.Body, e.Truncated = body[:maxErrorBody], true
+	}
+	if res.Request != nil {
+		e.Method, e.URL = res.Request.Method, res.Request.URL.Redacted()
+	}
+	ce := struct {
+		Error  string
+		Reason string
+	}{}
+	if json.Unmarshal(body, &ce) == nil {
+		e.ErrorType = ce.Error
+		e.Reason = ce.Reason
+	}
+	return e
+}
@@ -0,0 +1,248 @@
+package couch
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"strconv"
+	"sync"
+)
+
+// Event is an entry in an EventSource.
+type Event = Change
+
+// EventSource is a sequence-ordered stream of events.
+//
+// ChangesSource reads one from a database's changes feed, and
+// MemoryEventSource holds one in memory, for tests.
+type EventSource interface {
+	// Next returns the next event, waiting for one if necessary
+	// until ctx is done.
+	Next(ctx context.Context) (Event, error)
+	// Seek repositions the source so Next returns the events after
+	// the given sequence.  The empty sequence is the beginning.
+	Seek(Sequence) error
+}
+
+// ErrSourceClosed is returned by an EventSource that has been closed.
+var ErrSourceClosed = errors.New("couch: event source closed")
+
+// ChangesSource is an EventSource reading a database's continuous
+// changes feed.  The feed is connected on the first call to Next and
+// reconnected after Seek.  Close releases it.
+type ChangesSource struct {
+	db   Database
+	opts map[string]interface{}
+
+	mu     sync.Mutex
+	since  Sequence
+	feed   *changesFeed
+	closed bool
+}
+
+// EventSource returns a source of the changes after since, with the
+// given changes feed options.
+func (p Database) EventSource(since Sequence, options map[string]interface{}) *ChangesSource {
+	opts := map[string]interface{}{}
+	for k, v := range options {
+		opts[k] = v
+	}
+	opts["feed"] = "continuous"
+	delete(opts, "since")
+	return &ChangesSource{db: p, opts: opts, since: since}
+}
+
+// Next implements EventSource.
+func (s *ChangesSource) Next(ctx context.Context) (Event, error) {
+	for {
+		s.mu.Lock()
+		if s.closed {
+			s.mu.Unlock()
+			return Event{}, ErrSourceClosed
+		}
+		if s.feed == nil {
+			s.feed = startChangesFeed(s.db, s.opts, s.since)
+		}
+		f := s.feed
+		s.mu.Unlock()
+
+		select {
+		case ev := <-f.events:
+			s.mu.Lock()
+			current := s.feed == f
+			if current {
+				s.since = ev.Seq
+			}
+			s.mu.Unlock()
+			if current {
+				return ev, nil
+			}
+		case <-f.stopping:
+			// Seek or Close replaced the feed.
+		case <-ctx.Done():
+			return Event{}, ctx.Err()
+		}
+	}
+}
+
+// Seek implements EventSource.
+func (s *ChangesSource) Seek(seq Sequence) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return ErrSourceClosed
+	}
+	s.stopFeed()
+	s.since = seq
+	return nil
+}
+
+// Close disconnects the feed.
+func (s *ChangesSource) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.closed = true
+	s.stopFeed()
+	return nil
+}
+
+func (s *ChangesSource) stopFeed() {
+	if s.feed != nil {
+		s.feed.stop()
+		s.feed = nil
+	}
+}
+
+// changesFeed reads one run of a changes feed in the background.
+type changesFeed struct {
+	events   chan Change
+	stopping chan struct{}
+
+	mu   sync.Mutex
+	body io.Closer
+}
+
+func startChangesFeed(p Database, opts map[string]interface{}, since Sequence) *changesFeed {
+	f := &changesFeed{
+		events:   make(chan Change, consumerBuffer),
+		stopping: make(chan struct{}),
+	}
+	go p.changes(opts, string(since), f.stopping,
+		func(r io.ReadCloser) (string, bool) {
+			if !f.setBody(r) {
+				return "", false
+			}
+			d := json.NewDecoder(r)
+			for {
+				ch := Change{}
+				if err := d.Decode(&ch); err != nil {
+					break
+				}
+				if ch.ID == "" {
+					continue
+				}
+				select {
+				case f.events <- ch:
+					since = ch.Seq
+				case <-f.stopping:
+					return "", false
+				}
+			}
+			return string(since), !f.isStopping()
+		})
+	return f
+}
+
+func (f *changesFeed) isStopping() bool {
+	select {
+	case <-f.stopping:
+		return true
+	default:
+		return false
+	}
+}
+
+func (f *changesFeed) setBody(b io.Closer) bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.isStopping() {
+		return false
+	}
+	f.body = b
+	return true
+}
+
+// stop ends the feed, interrupting any read in progress.
+func (f *changesFeed) stop() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	close(f.stopping)
+	if f.body != nil {
+		f.body.Close()
+	}
+}
+
+// MemoryEventSource is an in-memory EventSource.  Sequences are
+// consecutive integers starting at 1.
+type MemoryEventSource struct {
+	mu     sync.Mutex
+	events []Event
+	pos    int
+	added  chan struct{}
+}
+
+// NewMemoryEventSource creates an empty MemoryEventSource.
+func NewMemoryEventSource() *MemoryEventSource {
+	return &MemoryEventSource{added: make(chan struct{})}
+}
+
+// Publish appends an event, assigning and returning its sequence.
+func (m *MemoryEventSource) Publish(ev Event) Sequence {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	ev.Seq = Sequence(strconv.Itoa(len(m.events) + 1))
+	m.events = append(m.events, ev)
+	close(m.added)
+	m.added = make(chan struct{})
+	return ev.Seq
+}
+
+// Next implements EventSource.
+func (m *MemoryEventSource) Next(ctx context.Context) (Event, error) {
+	for {
+		m.mu.Lock()
+		if m.pos < len(m.events) {
+			ev := m.events[m.pos]
+			m.pos++
+			m.mu.Unlock()
+			return ev, nil
+		}
+		added := m.added
+		m.mu.Unlock()
+
+		select {
+		case <-added:
+		case <-ctx.Done():
+			return Event{}, ctx.Err()
+		}
+	}
+}
+
+// Seek implements EventSource.
+func (m *MemoryEventSource) Seek(seq Sequence) error {
+	pos := 0
+	if seq != "" {
+		var err error
+		if pos, err = strconv.Atoi(string(seq)); err != nil || pos < 0 {
+			return errors.New("couch: invalid sequence " + strconv.Quote(string(seq)))
+		}
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if pos > len(m.events) {
+		pos = len(m.events)
+	}
+	m.pos = pos
+	return nil
+}
@@ -0,0 +1,133 @@
+package couch
+
+import (
+	"context"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+var (
+	_ EventSource = &ChangesSource{}
+	_ EventSource = &MemoryEventSource{}
+)
+
+func nextIDs(t *testing.T, s EventSource, n int) string {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	var ids []string
+	for i := 0; i < n; i++ {
+		ev, err := s.Next(ctx)
+		if err != nil {
+			t.Fatalf("Error getting event %v: %v", i, err)
+		}
+		ids = append(ids, ev.ID+"@"+string(ev.Seq))
+	}
+	return strings.Join(ids, ",")
+}
+
+func TestChangesSource(t *testing.T) {
+	conns := []*feedConn{
+		newFeedConn(threeChanges),
+		newFeedConn(`{"seq":2,"id":"b","changes":[{"rev":"1-b"}]}` + "\n"),
+	}
+	dialed := make(chan *feedConn, len(conns))
+	d := feedDB(nil)
+	d.changesDialer = func(string, string) (net.Conn, error) {
+		c := conns[0]
+		conns = conns[1:]
+		dialed <- c
+		return c, nil
+	}
+
+	s := d.EventSource("", map[string]interface{}{"include_docs": true})
+	defer s.Close()
+	if got := nextIDs(t, s, 2); got != "a@1,b@2" {
+		t.Errorf("Expected a@1,b@2, got %v", got)
+	}
+
+	if err := s.Seek("1"); err != nil {
+		t.Fatalf("Error seeking: %v", err)
+	}
+	first := <-dialed
+	select {
+	case <-first.closed:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("Expected Seek to close the first connection")
+	}
+
+	if got := nextIDs(t, s, 1); got != "b@2" {
+		t.Errorf("Expected b@2, got %v", got)
+	}
+	second := <-dialed
+	req := string(second.request)
+	for _, exp := range []string{"since=1", "feed=continuous", "include_docs=true"} {
+		if !strings.Contains(req, exp) {
+			t.Errorf("Expected %v in request:\n%s", exp, req)
+		}
+	}
+
+	s.Close()
+	if _, err := s.Next(context.Background()); err != ErrSourceClosed {
+		t.Errorf("Expected closed source, got %v", err)
+	}
+	select {
+	case <-second.closed:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("Expected Close to close the connection")
+	}
+}
+
+func TestChangesSourceContext(t *testing.T) {
+	s := feedDB(newFeedConn("")).EventSource("", nil)
+	defer s.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, err := s.Next(ctx); err != context.DeadlineExceeded {
+		t.Errorf("Expected deadline exceeded, got %v", err)
+	}
+}
+
+func TestMemoryEventSource(t *testing.T) {
+	m := NewMemoryEventSource()
+	m.Publish(Event{ID: "a"})
+	if seq := m.Publish(Event{ID: "b"}); seq != "2" {
+		t.Errorf("Expected sequence 2, got %v", seq)
+	}
+	if got := nextIDs(t, m, 2); got != "a@1,b@2" {
+		t.Errorf("Expected a@1,b@2, got %v", got)
+	}
+
+	go func() {
+		time.Sleep(time.Millisecond)
+		m.Publish(Event{ID: "c"})
+	}()
+	if got := nextIDs(t, m, 1); got != "c@3" {
+		t.Errorf("Expected c@3 once published, got %v", got)
+	}
+
+	if err := m.Seek("1"); err != nil {
+		t.Fatalf("Error seeking: %v", err)
+	}
+	if got := nextIDs(t, m, 2); got != "b@2,c@3" {
+		t.Errorf("Expected b@2,c@3, got %v", got)
+	}
+	if err := m.Seek(""); err != nil {
+		t.Fatalf("Error seeking: %v", err)
+	}
+	if got := nextIDs(t, m, 1); got != "a@1" {
+		t.Errorf("Expected a@1, got %v", got)
+	}
+	if err := m.Seek("x"); err == nil {
+		t.Errorf("Expected error seeking to a bad sequence")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	m.Seek("3")
+	if _, err := m.Next(ctx); err != context.Canceled {
+		t.Errorf("Expected canceled, got %v", err)
+	}
+}
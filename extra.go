@@ -0,0 +1,115 @@
+package couch
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+)
+
+// extraField finds the field of v tagged couch:"extra", along with the
+// (lowercased) names of the other fields json knows about.
+func extraField(v reflect.Value) (reflect.Value, map[string]bool) {
+	for v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return reflect.Value{}, nil
+		}
+		v = v.Elem()
+	}
+	if v.Kind() != reflect.Struct {
+		return reflect.Value{}, nil
+	}
+	known := map[string]bool{}
+	extra := collectFields(v, known)
+	return extra, known
+}
+
+func collectFields(v reflect.Value, known map[string]bool) reflect.Value {
+	var extra reflect.Value
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.Tag.Get("couch") == "extra" {
+			if f.Type.Kind() == reflect.Map && f.Type.Key().Kind() == reflect.String {
+				extra = v.Field(i)
+			}
+			continue
+		}
+		name := strings.Split(f.Tag.Get("json"), ",")[0]
+		if name == "-" || (f.PkgPath != "" && !f.Anonymous) {
+			continue
+		}
+		if f.Anonymous && name == "" && f.Type.Kind() == reflect.Struct {
+			if e := collectFields(v.Field(i), known); e.IsValid() {
+				extra = e
+			}
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		known[strings.ToLower(name)] = true
+	}
+	return extra
+}
+
+func hasExtraField(d interface{}) bool {
+	extra, _ := extraField(reflect.ValueOf(d))
+	return extra.IsValid()
+}
+
+// captureExtra stores fields of data that d doesn't otherwise decode
+// in its extra field, if it has one.
+func captureExtra(data []byte, d interface{}) error {
+	extra, known := extraField(reflect.ValueOf(d))
+	if !extra.IsValid() || !extra.CanSet() {
+		return nil
+	}
+	fields := map[string]json.RawMessage{}
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return err
+	}
+
+	m := reflect.MakeMap(extra.Type())
+	for k, raw := range fields {
+		if known[strings.ToLower(k)] {
+			continue
+		}
+		ev := reflect.New(extra.Type().Elem())
+		if err := json.Unmarshal(raw, ev.Interface()); err != nil {
+			return err
+		}
+		m.SetMapIndex(reflect.ValueOf(k), ev.Elem())
+	}
+	if m.Len() == 0 {
+		m = reflect.Zero(extra.Type())
+	}
+	extra.Set(m)
+	return nil
+}
+
+// restoreExtra adds fields from d's extra field, if any, to its
+// encoding in data.  Fields d encodes itself take precedence.
+func restoreExtra(data []byte, d interface{}) ([]byte, error) {
+	extra, known := extraField(reflect.ValueOf(d))
+	if !extra.IsValid() || extra.Len() == 0 {
+		return data, nil
+	}
+	fields := map[string]json.RawMessage{}
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return nil, err
+	}
+
+	iter := extra.MapRange()
+	for iter.Next() {
+		k := iter.Key().String()
+		if _, ok := fields[k]; ok || known[strings.ToLower(k)] {
+			continue
+		}
+		b, err := json.Marshal(iter.Value().Interface())
+		if err != nil {
+			return nil, err
+		}
+		fields[k] = b
+	}
+	return json.Marshal(fields)
+}
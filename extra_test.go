@@ -0,0 +1,89 @@
+package couch
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"reflect"
+	"testing"
+
+	"github.com/dustin/go-couch/couchtest"
+)
+
+type Named struct {
+	Name string `json:"name"`
+}
+
+type profile struct {
+	ID  string `json:"_id"`
+	Rev string `json:"_rev,omitempty"`
+	Named
+	Age    int
+	hidden int
+	Extra  map[string]json.RawMessage `json:"-" couch:"extra"`
+}
+
+const profileDoc = `{"_id": "p", "_rev": "1-a", "name": "bob", "age": 3,
+	"hidden": 4, "avatar": {"url": "x"}, "tags": ["a"]}`
+
+func TestRetrieveExtra(t *testing.T) {
+	defer uninstallFakeHTTP(installFakeHTTP(couchtest.NewTransport(docResponse(profileDoc))))
+
+	got := profile{}
+	if err := (Database{}).Retrieve("p", &got); err != nil {
+		t.Fatalf("Error retrieving: %v", err)
+	}
+	if got.Name != "bob" || got.Age != 3 {
+		t.Errorf("Expected known fields decoded, got %+v", got)
+	}
+	exp := map[string]json.RawMessage{
+		"hidden": json.RawMessage(`4`),
+		"avatar": json.RawMessage(`{"url": "x"}`),
+		"tags":   json.RawMessage(`["a"]`),
+	}
+	if !reflect.DeepEqual(got.Extra, exp) {
+		t.Errorf("Expected extra %s, got %s", exp, got.Extra)
+	}
+}
+
+func TestEditRestoresExtra(t *testing.T) {
+	f := &couchtest.Transport{Responses: []*http.Response{
+		docResponse(`{"ok": true, "id": "p", "rev": "2-a"}`),
+	}}
+	defer uninstallFakeHTTP(installFakeHTTP(f))
+
+	doc := profile{ID: "p", Rev: "1-a", Named: Named{"al"}, Age: 4,
+		Extra: map[string]json.RawMessage{
+			"tags": json.RawMessage(`["a"]`),
+			"name": json.RawMessage(`"stale"`),
+		}}
+	if _, err := (Database{}).Edit(doc); err != nil {
+		t.Fatalf("Error editing: %v", err)
+	}
+
+	b, _ := ioutil.ReadAll(f.Requests[0].Body)
+	exp := `{"Age":4,"_id":"p","_rev":"1-a","name":"al","tags":["a"]}`
+	if string(b) != exp {
+		t.Errorf("Expected %s, got %s", exp, b)
+	}
+}
+
+func TestExtraOtherTypes(t *testing.T) {
+	d := struct {
+		A     int
+		Extra map[string]interface{} `json:"-" couch:"extra"`
+	}{}
+	if err := (Database{}).unmarshal([]byte(`{"a": 1, "b": "c"}`), &d); err != nil {
+		t.Fatalf("Error decoding: %v", err)
+	}
+	if !reflect.DeepEqual(d.Extra, map[string]interface{}{"b": "c"}) {
+		t.Errorf("Expected b in extra, got %v", d.Extra)
+	}
+
+	if err := (Database{}).unmarshal([]byte(`{"a": 2}`), &d); err != nil {
+		t.Fatalf("Error decoding: %v", err)
+	}
+	if d.Extra != nil {
+		t.Errorf("Expected extra to be cleared, got %v", d.Extra)
+	}
+}
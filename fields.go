@@ -0,0 +1,132 @@
+package couch
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"unicode"
+)
+
+// FieldMapping renames document fields between Go and CouchDB.  See
+// WithFieldMapping.
+type FieldMapping struct {
+	// ToCouch renames a field as it's stored.
+	ToCouch func(string) string
+	// FromCouch renames a stored field for decoding.  Since
+	// encoding/json matches names case-insensitively, it needn't
+	// restore the exact Go name.
+	FromCouch func(string) string
+}
+
+// SnakeCase stores fields named like CreatedAt or UserID as created_at
+// and user_id.
+var SnakeCase = FieldMapping{ToCouch: snakeCase, FromCouch: camelCase}
+
+// WithFieldMapping returns a copy of the database that renames fields
+// of documents it writes and retrieves, so structs can use standard Go
+// naming against existing documents without a json tag on every field.
+//
+// Fields of nested objects are renamed too, including keys of maps.
+// Fields beginning with an underscore, such as _id and _rev, are left
+// alone.  View results are not renamed.
+func (p Database) WithFieldMapping(m FieldMapping) Database {
+	p.fields = &m
+	return p
+}
+
+// marshal encodes a document for storage.
+func (p Database) marshal(d interface{}) ([]byte, error) {
+	b, err := json.Marshal(d)
+	if err != nil {
+		return nil, err
+	}
+	if b, err = restoreExtra(b, d); err != nil {
+		return nil, err
+	}
+	if p.codec != nil {
+		if b, err = p.codec.encode(b, d); err != nil {
+			return nil, err
+		}
+	}
+	if p.fields == nil || p.fields.ToCouch == nil {
+		return b, nil
+	}
+	return renameFields(b, p.fields.ToCouch)
+}
+
+// unmarshal decodes a stored document.
+func (p Database) unmarshal(data []byte, d interface{}) error {
+	if p.fields != nil && p.fields.FromCouch != nil {
+		var err error
+		if data, err = renameFields(data, p.fields.FromCouch); err != nil {
+			return err
+		}
+	}
+	if p.codec != nil {
+		var err error
+		if data, err = p.codec.decode(data, d); err != nil {
+			return err
+		}
+	}
+	if err := json.Unmarshal(data, d); err != nil {
+		return err
+	}
+	return captureExtra(data, d)
+}
+
+func renameFields(data []byte, f func(string) string) ([]byte, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var v interface{}
+	if err := dec.Decode(&v); err != nil {
+		return nil, err
+	}
+	return json.Marshal(renameValue(v, f))
+}
+
+func renameValue(v interface{}, f func(string) string) interface{} {
+	switch c := v.(type) {
+	case map[string]interface{}:
+		m := make(map[string]interface{}, len(c))
+		for k, e := range c {
+			if !strings.HasPrefix(k, "_") {
+				k = f(k)
+			}
+			m[k] = renameValue(e, f)
+		}
+		return m
+	case []interface{}:
+		for i, e := range c {
+			c[i] = renameValue(e, f)
+		}
+	}
+	return v
+}
+
+func snakeCase(s string) string {
+	rs := []rune(s)
+	out := make([]rune, 0, len(rs)+4)
+	for i, r := range rs {
+		if unicode.IsUpper(r) {
+			if i > 0 && (!unicode.IsUpper(rs[i-1]) && rs[i-1] != '_' ||
+				i+1 < len(rs) && unicode.IsLower(rs[i+1])) {
+				out = append(out, '_')
+			}
+			r = unicode.ToLower(r)
+		}
+		out = append(out, r)
+	}
+	return string(out)
+}
+
+func camelCase(s string) string {
+	parts := strings.Split(s, "_")
+	for i, part := range parts {
+		rs := []rune(part)
+		if len(rs) > 0 {
+			rs[0] = unicode.ToUpper(rs[0])
+		}
+		parts[i] = string(rs)
+	}
+	return strings.Join(parts, "")
+}
@@ -0,0 +1,102 @@
+package couch
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"reflect"
+	"testing"
+
+	"github.com/dustin/go-couch/couchtest"
+)
+
+func TestSnakeCase(t *testing.T) {
+	tests := map[string]string{
+		"CreatedAt":    "created_at",
+		"UserID":       "user_id",
+		"HTTPServer":   "http_server",
+		"ID":           "id",
+		"already_done": "already_done",
+		"Address2Line": "address2_line",
+		"lower":        "lower",
+	}
+	for in, exp := range tests {
+		if got := snakeCase(in); got != exp {
+			t.Errorf("snakeCase(%q) = %q, expected %q", in, got, exp)
+		}
+	}
+}
+
+func TestCamelCase(t *testing.T) {
+	tests := map[string]string{
+		"created_at": "CreatedAt",
+		"user_id":    "UserId",
+		"plain":      "Plain",
+	}
+	for in, exp := range tests {
+		if got := camelCase(in); got != exp {
+			t.Errorf("camelCase(%q) = %q, expected %q", in, got, exp)
+		}
+	}
+}
+
+type mappedDoc struct {
+	ID        string `json:"_id"`
+	Rev       string `json:"_rev,omitempty"`
+	UserID    string
+	CreatedAt int64
+	Nested    struct{ LastSeen []int }
+}
+
+func TestFieldMappingWrite(t *testing.T) {
+	f := &couchtest.Transport{Responses: []*http.Response{
+		docResponse(`{"ok": true, "id": "x", "rev": "1-a"}`),
+	}}
+	defer uninstallFakeHTTP(installFakeHTTP(f))
+
+	doc := mappedDoc{ID: "x", UserID: "bob", CreatedAt: 5}
+	doc.Nested.LastSeen = []int{1}
+	if _, _, err := (Database{}).WithFieldMapping(SnakeCase).Insert(doc); err != nil {
+		t.Fatalf("Error inserting: %v", err)
+	}
+
+	b, _ := ioutil.ReadAll(f.Requests[0].Body)
+	exp := `{"created_at":5,"nested":{"last_seen":[1]},"user_id":"bob"}`
+	if string(b) != exp {
+		t.Errorf("Expected %s, got %s", exp, b)
+	}
+}
+
+func TestFieldMappingRetrieve(t *testing.T) {
+	defer uninstallFakeHTTP(installFakeHTTP(couchtest.NewTransport(docResponse(
+		`{"_id": "x", "_rev": "1-a", "user_id": "bob", "created_at": 5,
+		  "nested": {"last_seen": [2, 3]}}`))))
+
+	got := mappedDoc{}
+	if err := (Database{}).WithFieldMapping(SnakeCase).Retrieve("x", &got); err != nil {
+		t.Fatalf("Error retrieving: %v", err)
+	}
+	exp := mappedDoc{ID: "x", Rev: "1-a", UserID: "bob", CreatedAt: 5}
+	exp.Nested.LastSeen = []int{2, 3}
+	if !reflect.DeepEqual(got, exp) {
+		t.Errorf("Expected %+v, got %+v", exp, got)
+	}
+}
+
+func TestFieldMappingBulk(t *testing.T) {
+	f := &couchtest.Transport{Responses: []*http.Response{docResponse(`[]`)}}
+	defer uninstallFakeHTTP(installFakeHTTP(f))
+
+	d := Database{}.WithFieldMapping(SnakeCase)
+	if _, err := d.Bulk([]interface{}{mappedDoc{ID: "a", UserID: "u"}}); err != nil {
+		t.Fatalf("Error in bulk: %v", err)
+	}
+
+	got := struct{ Docs []map[string]interface{} }{}
+	if err := json.NewDecoder(f.Requests[0].Body).Decode(&got); err != nil {
+		t.Fatalf("Error decoding request: %v", err)
+	}
+	if len(got.Docs) != 1 || got.Docs[0]["user_id"] != "u" || got.Docs[0]["_id"] != "a" {
+		t.Errorf("Expected mapped document, got %v", got.Docs)
+	}
+}
@@ -0,0 +1,76 @@
+package couch
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// FindQuery is a Mango query, the body of a _find request.  Find and
+// FindRows also take any other value that encodes as one, e.g. a map.
+type FindQuery struct {
+	// Selector of documents to find, e.g.
+	// map[string]interface{}{"type": "user"}, or with a text index,
+	// map[string]interface{}{"$text": "words to search for"}.
+	Selector interface{} `json:"selector"`
+	// Fields to return of each document, rather than all of them.
+	Fields []string `json:"fields,omitempty"`
+	// Sort order, e.g. []interface{}{map[string]string{"age": "desc"}}.
+	Sort     []interface{} `json:"sort,omitempty"`
+	Limit    int           `json:"limit,omitempty"`
+	Skip     int           `json:"skip,omitempty"`
+	Bookmark string        `json:"bookmark,omitempty"`
+	// Index to use, as its design document id, or []string{ddoc, name}.
+	// Needed for a partial index.
+	UseIndex interface{} `json:"use_index,omitempty"`
+	// Ask for FindResult.ExecutionStats.
+	ExecutionStats bool `json:"execution_stats,omitempty"`
+}
+
+// FindResult is what a _find request returns besides documents.
+type FindResult struct {
+	// Pass as FindQuery.Bookmark for the next page of results.
+	Bookmark string `json:"bookmark"`
+	// Set when, e.g., no index matched and every document was scanned.
+	Warning string `json:"warning"`
+	// Present when asked for with FindQuery.ExecutionStats.
+	ExecutionStats *ExecutionStats `json:"execution_stats"`
+}
+
+// ExecutionStats describe the work done to answer a _find request.  A
+// large number of documents examined for the results returned suggests
+// the query isn't served by an index.
+type ExecutionStats struct {
+	TotalKeysExamined       int64   `json:"total_keys_examined"`
+	TotalDocsExamined       int64   `json:"total_docs_examined"`
+	TotalQuorumDocsExamined int64   `json:"total_quorum_docs_examined"`
+	ResultsReturned         int64   `json:"results_returned"`
+	ExecutionTimeMS         float64 `json:"execution_time_ms"`
+}
+
+// ExecutionTime returns ExecutionTimeMS as a Duration.
+func (s ExecutionStats) ExecutionTime() time.Duration {
+	return time.Duration(s.ExecutionTimeMS * float64(time.Millisecond))
+}
+
+// Find runs a Mango query, a FindQuery or anything else that encodes
+// as the body of a _find request, decoding the matching documents into
+// docs, typically a pointer to a slice.
+func (p Database) Find(query interface{}, docs interface{}) (FindResult, error) {
+	jsonBuf, err := json.Marshal(query)
+	if err != nil {
+		return FindResult{}, err
+	}
+	res := struct {
+		Docs json.RawMessage `json:"docs"`
+		FindResult
+	}{}
+	if _, err := p.interact("POST", p.dbURL("_find"), p.defaultHdrs, jsonBuf, &res); err != nil {
+		return FindResult{}, err
+	}
+	if len(res.Docs) > 0 {
+		if err := json.Unmarshal(res.Docs, docs); err != nil {
+			return res.FindResult, err
+		}
+	}
+	return res.FindResult, nil
+}
@@ -0,0 +1,74 @@
+package couch
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dustin/go-couch/couchtest"
+)
+
+func TestFind(t *testing.T) {
+	f := couchtest.NewTransport(docResponse(`{"docs": [{"_id": "u1", "name": "ann"}],
+		"bookmark": "xyz", "warning": "No matching index found",
+		"execution_stats": {"total_keys_examined": 0, "total_docs_examined": 200,
+			"total_quorum_docs_examined": 0, "results_returned": 1,
+			"execution_time_ms": 5.5}}`))
+	defer uninstallFakeHTTP(installFakeHTTP(f))
+
+	docs := []struct{ Name string }{}
+	res, err := Database{Name: "db"}.Find(FindQuery{
+		Selector:       map[string]interface{}{"type": "user"},
+		Limit:          10,
+		ExecutionStats: true,
+	}, &docs)
+	if err != nil {
+		t.Fatalf("Error finding: %v", err)
+	}
+	if len(docs) != 1 || docs[0].Name != "ann" {
+		t.Errorf("Unexpected docs %+v", docs)
+	}
+	if res.Bookmark != "xyz" || res.Warning == "" || res.ExecutionStats == nil {
+		t.Fatalf("Unexpected result %+v", res)
+	}
+	if s := *res.ExecutionStats; s.TotalDocsExamined != 200 || s.ResultsReturned != 1 ||
+		s.ExecutionTime() != 5500*time.Microsecond {
+		t.Errorf("Unexpected stats %+v", s)
+	}
+
+	req := f.Requests[0]
+	sent := map[string]interface{}{}
+	must(json.NewDecoder(req.Body).Decode(&sent))
+	if req.Method != "POST" || req.URL.Path != "/db/_find" ||
+		sent["execution_stats"] != true || sent["limit"] != 10.0 ||
+		sent["skip"] != nil {
+		t.Errorf("Unexpected request %v %v %v", req.Method, req.URL, sent)
+	}
+}
+
+func TestFindWithoutStats(t *testing.T) {
+	defer uninstallFakeHTTP(installFakeHTTP(couchtest.NewTransport(docResponse(`{"docs": []}`))))
+
+	docs := []map[string]interface{}{}
+	res, err := (Database{}).Find(map[string]interface{}{"selector": map[string]interface{}{}}, &docs)
+	if err != nil {
+		t.Fatalf("Error finding: %v", err)
+	}
+	if res.ExecutionStats != nil || len(docs) != 0 {
+		t.Errorf("Unexpected result %+v, %v", res, docs)
+	}
+}
+
+func TestFindError(t *testing.T) {
+	defer uninstallFakeHTTP(installFakeHTTP(couchtest.NewTransport(&http.Response{
+		StatusCode: 400,
+		Body: ioutil.NopCloser(strings.NewReader(
+			`{"error": "bad_request", "reason": "invalid selector"}`)),
+	})))
+	if _, err := (Database{}).Find(FindQuery{}, &[]struct{}{}); err == nil {
+		t.Errorf("Expected error")
+	}
+}
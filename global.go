@@ -0,0 +1,72 @@
+package couch
+
+import (
+	"errors"
+	"strings"
+)
+
+// GlobalChangesDB is the database in which CouchDB records the
+// creation, update and deletion of every other database.  It's an
+// alternative to /_db_updates where that endpoint is restricted.
+const GlobalChangesDB = "_global_changes"
+
+// Database event types recorded in GlobalChangesDB.
+const (
+	DBCreated = "created"
+	DBUpdated = "updated"
+	DBDeleted = "deleted"
+)
+
+// DBEvent is a database lifecycle event from GlobalChangesDB.
+type DBEvent struct {
+	Seq  Sequence
+	Type string // DBCreated, DBUpdated or DBDeleted
+	DB   string
+}
+
+// ParseDBEvent decodes a change from GlobalChangesDB, whose document
+// ids take the form "<type>:<db>".  It returns false for changes that
+// aren't events, such as design documents.
+func ParseDBEvent(c Change) (DBEvent, bool) {
+	i := strings.IndexByte(c.ID, ':')
+	if i <= 0 || i == len(c.ID)-1 || strings.HasPrefix(c.ID, "_") {
+		return DBEvent{}, false
+	}
+	return DBEvent{Seq: c.Seq, Type: c.ID[:i], DB: c.ID[i+1:]}, true
+}
+
+// GlobalChanges returns a database for GlobalChangesDB on the same
+// server, with the same settings.
+func (p Database) GlobalChanges() Database {
+	p.Name = GlobalChangesDB
+	return p
+}
+
+// GlobalChangesEnabled returns true if the server maintains
+// GlobalChangesDB (ignores Database.Name).
+func (p Database) GlobalChangesEnabled() (bool, error) {
+	_, err := p.GlobalChanges().GetInfo()
+	switch {
+	case err == nil:
+		return true, nil
+	case errors.Is(err, ErrNotFound):
+		return false, nil
+	}
+	return false, err
+}
+
+// ConsumeDBEvents delivers the database events recorded in
+// GlobalChangesDB after since to handler (ignores Database.Name).  It
+// behaves as ConsumeChanges, skipping changes that aren't events.
+func (p Database) ConsumeDBEvents(since Sequence, handler func(DBEvent) error,
+	checkpoint func(Sequence) error,
+	options map[string]interface{}) *ChangesConsumer {
+
+	return p.GlobalChanges().ConsumeChanges(since, func(c Change) error {
+		ev, ok := ParseDBEvent(c)
+		if !ok {
+			return nil
+		}
+		return handler(ev)
+	}, checkpoint, options)
+}
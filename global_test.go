@@ -0,0 +1,98 @@
+package couch
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"net/http"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/dustin/go-couch/couchtest"
+)
+
+func TestParseDBEvent(t *testing.T) {
+	tests := []struct {
+		id  string
+		exp DBEvent
+		ok  bool
+	}{
+		{"created:users", DBEvent{Seq: "7", Type: DBCreated, DB: "users"}, true},
+		{"deleted:a/b", DBEvent{Seq: "7", Type: DBDeleted, DB: "a/b"}, true},
+		{"_design/global", DBEvent{}, false},
+		{"updated:", DBEvent{}, false},
+		{":users", DBEvent{}, false},
+		{"users", DBEvent{}, false},
+	}
+	for _, test := range tests {
+		got, ok := ParseDBEvent(Change{Seq: "7", ID: test.id})
+		if ok != test.ok || got != test.exp {
+			t.Errorf("%q: expected %v/%v, got %v/%v",
+				test.id, test.exp, test.ok, got, ok)
+		}
+	}
+}
+
+func TestGlobalChangesEnabled(t *testing.T) {
+	f := &couchtest.Transport{Responses: []*http.Response{
+		docResponse(`{"db_name": "_global_changes"}`),
+		{
+			StatusCode: 404,
+			Body: ioutil.NopCloser(strings.NewReader(
+				`{"error": "not_found", "reason": "Database does not exist."}`)),
+		},
+		unavailable(),
+	}}
+	defer uninstallFakeHTTP(installFakeHTTP(f))
+
+	d := Database{Host: "localhost", Name: "mine"}
+	if ok, err := d.GlobalChangesEnabled(); !ok || err != nil {
+		t.Errorf("Expected enabled, got %v, %v", ok, err)
+	}
+	if !strings.HasSuffix(f.Requests[0].URL.Path, "/_global_changes") {
+		t.Errorf("Expected _global_changes request, got %v", f.Requests[0].URL)
+	}
+	if ok, err := d.GlobalChangesEnabled(); ok || err != nil {
+		t.Errorf("Expected disabled, got %v, %v", ok, err)
+	}
+	if _, err := d.GlobalChangesEnabled(); err == nil {
+		t.Errorf("Expected error from unavailable server")
+	}
+}
+
+func TestConsumeDBEvents(t *testing.T) {
+	conn := newFeedConn(`{"seq":1,"id":"created:a","changes":[{"rev":"1-a"}]}
+{"seq":2,"id":"_design/x","changes":[{"rev":"1-b"}]}
+{"seq":3,"id":"updated:a","changes":[{"rev":"2-a"}]}
+`)
+	var mu sync.Mutex
+	var got []DBEvent
+	c := feedDB(conn).ConsumeDBEvents("", func(ev DBEvent) error {
+		mu.Lock()
+		defer mu.Unlock()
+		got = append(got, ev)
+		return nil
+	}, nil, nil)
+
+	waitFor(t, "events", func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(got) == 2
+	})
+	if err := c.Drain(context.Background()); err != nil {
+		t.Fatalf("Error draining: %v", err)
+	}
+
+	exp := []DBEvent{
+		{Seq: "1", Type: DBCreated, DB: "a"},
+		{Seq: "3", Type: DBUpdated, DB: "a"},
+	}
+	if !reflect.DeepEqual(got, exp) {
+		t.Errorf("Expected %v, got %v", exp, got)
+	}
+	if !bytes.Contains(conn.request, []byte("/_global_changes/_changes?")) {
+		t.Errorf("Expected _global_changes feed, got %s", conn.request)
+	}
+}
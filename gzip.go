@@ -0,0 +1,91 @@
+package couch
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"io/ioutil"
+	"net/http"
+)
+
+type compression struct {
+	minBody int64
+}
+
+// WithCompression returns a copy of the database that asks for gzipped
+// responses and decompresses them, and gzips request bodies of at least
+// minBody bytes, such as large bulk updates.  A minBody of 0 leaves
+// request bodies alone.
+//
+// Go's default transport already asks for and decompresses gzipped
+// responses, unless its DisableCompression is set or the request sets
+// Accept-Encoding itself; this makes sure of it whatever the transport.
+// CouchDB accepts gzipped request bodies from version 2.0.
+func (p Database) WithCompression(minBody int) Database {
+	p.compress = &compression{minBody: int64(minBody)}
+	return p
+}
+
+// prepare marks req as accepting gzip, and compresses its body if it's
+// large enough.
+func (c *compression) prepare(req *http.Request) error {
+	req.Header = cloneHeader(req.Header)
+	if req.Header.Get("Accept-Encoding") == "" {
+		req.Header.Set("Accept-Encoding", "gzip")
+	}
+	if c.minBody <= 0 || req.GetBody == nil || req.ContentLength < c.minBody ||
+		req.Header.Get("Content-Encoding") != "" {
+		return nil
+	}
+
+	body, err := req.GetBody()
+	if err != nil {
+		return err
+	}
+	buf := &bytes.Buffer{}
+	w := gzip.NewWriter(buf)
+	if _, err := io.Copy(w, body); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	zipped := buf.Bytes()
+	req.Body = ioutil.NopCloser(bytes.NewReader(zipped))
+	req.GetBody = func() (io.ReadCloser, error) {
+		return ioutil.NopCloser(bytes.NewReader(zipped)), nil
+	}
+	req.ContentLength = int64(len(zipped))
+	req.Header.Set("Content-Encoding", "gzip")
+	return nil
+}
+
+// decompress replaces the body of a gzipped response with its
+// decompressed content.
+func (c *compression) decompress(res *http.Response) error {
+	if res.Uncompressed || res.Header.Get("Content-Encoding") != "gzip" {
+		return nil
+	}
+	zr, err := gzip.NewReader(res.Body)
+	if err != nil {
+		res.Body.Close()
+		return err
+	}
+	res.Body = struct {
+		io.Reader
+		io.Closer
+	}{zr, res.Body}
+	res.Header.Del("Content-Encoding")
+	res.Header.Del("Content-Length")
+	res.ContentLength = -1
+	res.Uncompressed = true
+	return nil
+}
+
+func cloneHeader(h http.Header) http.Header {
+	c := make(http.Header, len(h)+2)
+	for k, v := range h {
+		c[k] = v
+	}
+	return c
+}
@@ -0,0 +1,75 @@
+package couch
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/dustin/go-couch/couchtest"
+)
+
+func gzipped(s string) []byte {
+	buf := &bytes.Buffer{}
+	w := gzip.NewWriter(buf)
+	w.Write([]byte(s))
+	w.Close()
+	return buf.Bytes()
+}
+
+func TestCompression(t *testing.T) {
+	res := docResponse("")
+	res.Header = http.Header{"Content-Encoding": []string{"gzip"}}
+	res.Body = ioutil.NopCloser(bytes.NewReader(
+		gzipped(`{"ok": true, "id": "a", "rev": "1-x"}`)))
+	f := &couchtest.Transport{Responses: []*http.Response{res}}
+	defer uninstallFakeHTTP(installFakeHTTP(f))
+
+	d := Database{Host: "localhost", Port: "5984", Name: "db"}.WithCompression(100)
+	doc := map[string]string{"_id": "a", "_rev": "0-x", "text": strings.Repeat("x", 200)}
+	rev, err := d.Edit(doc)
+	if err != nil {
+		t.Fatalf("Error editing: %v", err)
+	}
+	if rev != "1-x" {
+		t.Errorf("Expected rev 1-x, got %v", rev)
+	}
+
+	req := f.Requests[0]
+	if req.Header.Get("Accept-Encoding") != "gzip" ||
+		req.Header.Get("Content-Encoding") != "gzip" {
+		t.Errorf("Expected gzip headers, got %v", req.Header)
+	}
+	zr, err := gzip.NewReader(req.Body)
+	if err != nil {
+		t.Fatalf("Request body isn't gzipped: %v", err)
+	}
+	body, _ := ioutil.ReadAll(zr)
+	if !bytes.Contains(body, []byte(doc["text"])) {
+		t.Errorf("Unexpected request body %s", body)
+	}
+	if req.ContentLength >= int64(len(body)) {
+		t.Errorf("Expected a compressed length, got %v for %v bytes",
+			req.ContentLength, len(body))
+	}
+}
+
+func TestCompressionSmallBody(t *testing.T) {
+	f := &couchtest.Transport{Responses: []*http.Response{
+		docResponse(`{"ok": true, "id": "a", "rev": "1-x"}`)}}
+	defer uninstallFakeHTTP(installFakeHTTP(f))
+
+	d := Database{Host: "localhost", Port: "5984", Name: "db"}.WithCompression(1000)
+	if _, err := d.Edit(map[string]string{"_id": "a", "_rev": "0-x"}); err != nil {
+		t.Fatalf("Error editing: %v", err)
+	}
+	req := f.Requests[0]
+	if req.Header.Get("Content-Encoding") != "" {
+		t.Errorf("Expected an uncompressed body, got %v", req.Header)
+	}
+	if req.Header.Get("Accept-Encoding") != "gzip" {
+		t.Errorf("Expected gzip to be accepted, got %v", req.Header)
+	}
+}
@@ -0,0 +1,41 @@
+package couch
+
+import "net/http"
+
+// WithHeaders returns a copy of the database that sends the given
+// headers with every request, including those of the changes feed,
+// e.g. a proxy's token or a tracing header.  They replace any headers
+// of the same names given before, but not those a request sets itself,
+// such as Content-Type.
+func (p Database) WithHeaders(h http.Header) Database {
+	hdrs := make(map[string][]string, len(p.defaultHdrs)+len(h))
+	for k, v := range p.defaultHdrs {
+		hdrs[k] = v
+	}
+	for k, v := range h {
+		hdrs[http.CanonicalHeaderKey(k)] = append([]string(nil), v...)
+	}
+	p.defaultHdrs = hdrs
+	return p
+}
+
+// WithHeader is WithHeaders for a single header.
+func (p Database) WithHeader(key, value string) Database {
+	return p.WithHeaders(http.Header{key: {value}})
+}
+
+// addDefaultHeaders adds the database's headers to req, except those
+// it already has.
+func (p Database) addDefaultHeaders(req *http.Request) {
+	if len(p.defaultHdrs) == 0 {
+		return
+	}
+	if req.Header == nil {
+		req.Header = http.Header{}
+	}
+	for k, v := range p.defaultHdrs {
+		if _, ok := req.Header[k]; !ok {
+			req.Header[k] = v
+		}
+	}
+}
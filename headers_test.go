@@ -0,0 +1,88 @@
+package couch
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"net/http"
+	"testing"
+
+	"github.com/dustin/go-couch/couchtest"
+)
+
+func TestWithHeaders(t *testing.T) {
+	f := &couchtest.Transport{Responses: []*http.Response{
+		docResponse(`{}`), docResponse(`{"ok": true}`), docResponse(`{}`)}}
+	defer uninstallFakeHTTP(installFakeHTTP(f))
+
+	base := Database{Name: "db"}
+	d := base.WithHeader("x-cloudant-user", "ann").
+		WithHeaders(http.Header{"X-Trace": {"1"}, "Content-Type": {"text/plain"}})
+	d = d.WithHeader("X-Trace", "2")
+
+	if err := d.Retrieve("a", &struct{}{}); err != nil {
+		t.Fatalf("Error retrieving: %v", err)
+	}
+	if err := d.Compact(); err != nil {
+		t.Fatalf("Error compacting: %v", err)
+	}
+	if err := base.Retrieve("a", &struct{}{}); err != nil {
+		t.Fatalf("Error retrieving: %v", err)
+	}
+
+	for i, req := range f.Requests[:2] {
+		if req.Header.Get("X-Cloudant-User") != "ann" || req.Header.Get("X-Trace") != "2" {
+			t.Errorf("Request %v: unexpected headers %v", i, req.Header)
+		}
+	}
+	// A request's own headers take precedence.
+	if got := f.Requests[1].Header.Get("Content-Type"); got != "application/json" {
+		t.Errorf("Expected the request's content type, got %v", got)
+	}
+	if got := f.Requests[2].Header.Get("X-Trace"); got != "" {
+		t.Errorf("Expected no headers on the original database, got %v", got)
+	}
+}
+
+func TestWithHeadersChanges(t *testing.T) {
+	got := make(chan http.Header, 1)
+	d := Database{
+		Host:             "localhost",
+		changesFailDelay: 5,
+		changesDialer: func(string, string) (net.Conn, error) {
+			client, server := net.Pipe()
+			go func() {
+				defer server.Close()
+				req, err := http.ReadRequest(bufio.NewReader(server))
+				if err != nil {
+					return
+				}
+				got <- req.Header
+				server.Write([]byte("HTTP/1.0 200 OK\r\n\r\n"))
+			}()
+			return client, nil
+		},
+	}.WithHeader("X-Proxy-Token", "t")
+
+	d.Changes(func(io.Reader) int64 { return -1 }, map[string]interface{}{})
+	if h := <-got; h.Get("X-Proxy-Token") != "t" {
+		t.Errorf("Unexpected changes headers %v", h)
+	}
+}
+
+func TestConfigHeaders(t *testing.T) {
+	f := &couchtest.Transport{Responses: []*http.Response{
+		docResponse(`["db"]`), docResponse(`{"db_name": "db"}`)}}
+	defer uninstallFakeHTTP(installFakeHTTP(f))
+
+	_, err := FromConfig(Config{URL: "http://localhost:5984/db",
+		Headers: http.Header{"X-Proxy-Token": {"t"}}})
+	if err != nil {
+		t.Fatalf("Error connecting: %v", err)
+	}
+	for i, req := range f.Requests {
+		if req.Header.Get("X-Proxy-Token") != "t" {
+			t.Errorf("Request %v: unexpected headers %v", i, req.Header)
+		}
+	}
+}
@@ -0,0 +1,62 @@
+package couch
+
+import (
+	"strconv"
+)
+
+// Revisions is a document's revision history, as its _revisions field
+// holds it with revs=true.  A struct can include it, tagged
+// `json:"_revisions,omitempty"`, to pass a history along with
+// new_edits=false writes.
+type Revisions struct {
+	// Generation of the newest revision.
+	Start int `json:"start"`
+	// Revision hashes, newest first, each one generation older.
+	IDs []string `json:"ids"`
+}
+
+// Revs returns the full revision ids of the history, newest first.
+func (r Revisions) Revs() []string {
+	revs := make([]string, len(r.IDs))
+	for i, id := range r.IDs {
+		revs[i] = strconv.Itoa(r.Start-i) + "-" + id
+	}
+	return revs
+}
+
+// Statuses of revisions in RevInfo.
+const (
+	RevAvailable = "available"
+	RevMissing   = "missing"
+	RevDeleted   = "deleted"
+)
+
+// RevInfo is a revision of a document and whether its body is still
+// available, as its _revs_info field holds it with revs_info=true.
+type RevInfo struct {
+	Rev    string `json:"rev"`
+	Status string `json:"status"`
+}
+
+// RevisionHistory returns the revision history of the document
+// matching id, from its winning revision back.  The history outlives
+// compaction, though the older revisions' bodies don't.  Histories are
+// truncated to the database's revs limit.
+func (p Database) RevisionHistory(id string) (Revisions, error) {
+	doc := struct {
+		Revisions Revisions `json:"_revisions"`
+	}{}
+	err := p.RetrieveWith(id, map[string]interface{}{"revs": true}, &doc)
+	return doc.Revisions, err
+}
+
+// RevsInfo returns the revisions in the history of the document
+// matching id, newest first, with whether each one's body is still
+// available to RetrieveRev.
+func (p Database) RevsInfo(id string) ([]RevInfo, error) {
+	doc := struct {
+		RevsInfo []RevInfo `json:"_revs_info"`
+	}{}
+	err := p.RetrieveWith(id, map[string]interface{}{"revs_info": true}, &doc)
+	return doc.RevsInfo, err
+}
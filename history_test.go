@@ -0,0 +1,64 @@
+package couch
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/dustin/go-couch/couchtest"
+)
+
+func TestRevisionHistory(t *testing.T) {
+	f := couchtest.NewTransport(docResponse(`{"_id": "a", "_rev": "3-c",
+		"_revisions": {"start": 3, "ids": ["c", "b", "a"]}}`))
+	defer uninstallFakeHTTP(installFakeHTTP(f))
+
+	revs, err := Database{Name: "db"}.RevisionHistory("a")
+	if err != nil {
+		t.Fatalf("Error fetching history: %v", err)
+	}
+	if f.Requests[0].URL.Query().Get("revs") != "true" {
+		t.Errorf("Expected revs=true, got %v", f.Requests[0].URL)
+	}
+	exp := []string{"3-c", "2-b", "1-a"}
+	if !reflect.DeepEqual(revs.Revs(), exp) {
+		t.Errorf("Expected %v, got %v", exp, revs.Revs())
+	}
+}
+
+func TestRevisionsRoundTrip(t *testing.T) {
+	doc := struct {
+		ID        string     `json:"_id"`
+		Revisions *Revisions `json:"_revisions,omitempty"`
+	}{ID: "a", Revisions: &Revisions{Start: 2, IDs: []string{"b", "a"}}}
+	b, err := json.Marshal(doc)
+	must(err)
+	if string(b) != `{"_id":"a","_revisions":{"start":2,"ids":["b","a"]}}` {
+		t.Errorf("Unexpected encoding %s", b)
+	}
+}
+
+func TestRevsInfo(t *testing.T) {
+	f := couchtest.NewTransport(docResponse(`{"_id": "a", "_rev": "2-b", "_revs_info": [
+		{"rev": "2-b", "status": "available"},
+		{"rev": "1-a", "status": "missing"}]}`))
+	defer uninstallFakeHTTP(installFakeHTTP(f))
+
+	info, err := Database{Name: "db"}.RevsInfo("a")
+	if err != nil {
+		t.Fatalf("Error fetching revs info: %v", err)
+	}
+	if f.Requests[0].URL.Query().Get("revs_info") != "true" {
+		t.Errorf("Expected revs_info=true, got %v", f.Requests[0].URL)
+	}
+	exp := []RevInfo{{"2-b", RevAvailable}, {"1-a", RevMissing}}
+	if !reflect.DeepEqual(info, exp) {
+		t.Errorf("Expected %v, got %v", exp, info)
+	}
+}
+
+func TestRevisionHistoryNoID(t *testing.T) {
+	if _, err := (Database{}).RevisionHistory(""); err != errNoID {
+		t.Errorf("Expected errNoID, got %v", err)
+	}
+}
@@ -0,0 +1,111 @@
+package couch
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"io/ioutil"
+	"net/http"
+	"sync"
+)
+
+// Number of replies kept for requests with caller-supplied keys.
+const maxReplies = 256
+
+// WithIdempotencyKey returns a copy of the database whose POST requests
+// carry the given idempotency key instead of a generated one.  Use a
+// fresh copy per logical operation.
+//
+// When the operation is repeated with the same key, say after the
+// application itself gave up and tried again, a successful reply
+// received earlier is returned without contacting the server.  This
+// only has an effect when the retry policy has an IdempotencyHeader.
+func (p Database) WithIdempotencyKey(key string) Database {
+	p.idemKey = key
+	return p
+}
+
+type reply struct {
+	status int
+	header http.Header
+	body   []byte
+}
+
+func (r *reply) response(req *http.Request) *http.Response {
+	return &http.Response{
+		Status:        http.StatusText(r.status),
+		StatusCode:    r.status,
+		Header:        r.header,
+		Body:          ioutil.NopCloser(bytes.NewReader(r.body)),
+		ContentLength: int64(len(r.body)),
+		Request:       req,
+	}
+}
+
+// replyCache remembers the replies to keyed requests, forgetting the
+// oldest first.
+type replyCache struct {
+	mu      sync.Mutex
+	order   []string
+	replies map[string]*reply
+}
+
+func newReplyCache() *replyCache {
+	return &replyCache{replies: map[string]*reply{}}
+}
+
+func (c *replyCache) get(key string) *reply {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.replies[key]
+}
+
+func (c *replyCache) put(key string, r *reply) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, ok := c.replies[key]; !ok {
+		c.order = append(c.order, key)
+	}
+	c.replies[key] = r
+	for len(c.order) > maxReplies {
+		delete(c.replies, c.order[0])
+		c.order = c.order[1:]
+	}
+}
+
+func newIdempotencyKey() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// doIdempotent sends a POST with an idempotency key, retrying it as
+// the policy allows.
+func (p Database) doIdempotent(req *http.Request) (*http.Response, error) {
+	key := p.idemKey
+	if key == "" {
+		var err error
+		if key, err = newIdempotencyKey(); err != nil {
+			return nil, err
+		}
+	} else if r := p.replies.get(key); r != nil {
+		return r.response(req), nil
+	}
+	req.Header.Set(p.retry.IdempotencyHeader, key)
+
+	res, err := p.retry.do(req, p.sendThrottled)
+	if err != nil || p.idemKey == "" || res.StatusCode < 200 || res.StatusCode >= 300 {
+		return res, err
+	}
+
+	body, err := ioutil.ReadAll(res.Body)
+	res.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	p.replies.put(key, &reply{res.StatusCode, res.Header, body})
+	res.Body = ioutil.NopCloser(bytes.NewReader(body))
+	return res, nil
+}
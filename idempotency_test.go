@@ -0,0 +1,91 @@
+package couch
+
+import (
+	"net/http"
+	"syscall"
+	"testing"
+
+	"github.com/dustin/go-couch/couchtest"
+)
+
+var keyedRetry = RetryPolicy{MaxRetries: 2, InitialBackoff: quickRetry.InitialBackoff,
+	IdempotencyHeader: "Idempotency-Key"}
+
+func TestIdempotentPostRetried(t *testing.T) {
+	f := &couchtest.Transport{Responses: []*http.Response{
+		unavailable(),
+		docResponse(`{"ok": true, "id": "x", "rev": "1-a"}`),
+	}}
+	defer uninstallFakeHTTP(installFakeHTTP(f))
+
+	d := Database{}.WithRetry(keyedRetry)
+	if _, _, err := d.insert([]byte(`{}`)); err != nil {
+		t.Fatalf("Expected success, got %v", err)
+	}
+	if len(f.Requests) != 2 {
+		t.Fatalf("Expected 2 attempts, got %v", len(f.Requests))
+	}
+	key := f.Requests[0].Header.Get("Idempotency-Key")
+	if len(key) != 32 || f.Requests[1].Header.Get("Idempotency-Key") != key {
+		t.Errorf("Expected the same key on each attempt, got %q and %q",
+			key, f.Requests[1].Header.Get("Idempotency-Key"))
+	}
+
+	// Each request gets its own key.
+	f.Responses = []*http.Response{docResponse(`{"ok": true, "id": "y", "rev": "1-a"}`)}
+	d.insert([]byte(`{}`))
+	if got := f.Requests[2].Header.Get("Idempotency-Key"); got == key || got == "" {
+		t.Errorf("Expected a new key, got %q", got)
+	}
+}
+
+func TestIdempotencyKeyReply(t *testing.T) {
+	f := &flakyHTTP{}
+	d := Database{client: &http.Client{Transport: f}}.WithRetry(keyedRetry)
+
+	op := d.WithIdempotencyKey("op-1")
+	for i := 0; i < 2; i++ {
+		_, rev, err := op.insert([]byte(`{}`))
+		if err != nil || rev != "2-x" {
+			t.Fatalf("Attempt %v: expected rev 2-x, got %v, %v", i, rev, err)
+		}
+	}
+	if len(f.bodies) != 1 {
+		t.Errorf("Expected the second attempt to use the saved reply, got %v requests",
+			len(f.bodies))
+	}
+
+	if _, _, err := d.WithIdempotencyKey("op-2").insert([]byte(`{}`)); err != nil {
+		t.Fatalf("Expected success, got %v", err)
+	}
+	if len(f.bodies) != 2 {
+		t.Errorf("Expected a new key to reach the server, got %v requests",
+			len(f.bodies))
+	}
+}
+
+func TestIdempotencyKeyFailureNotSaved(t *testing.T) {
+	f := &flakyHTTP{fails: 3, err: syscall.ECONNRESET}
+	d := Database{client: &http.Client{Transport: f}}.WithRetry(keyedRetry).
+		WithIdempotencyKey("op")
+
+	if _, _, err := d.insert([]byte(`{}`)); err == nil {
+		t.Fatalf("Expected failure")
+	}
+	if _, _, err := d.insert([]byte(`{}`)); err != nil {
+		t.Fatalf("Expected success, got %v", err)
+	}
+	if len(f.bodies) != 4 {
+		t.Errorf("Expected 4 requests, got %v", len(f.bodies))
+	}
+}
+
+func TestReplyCacheEvicts(t *testing.T) {
+	c := newReplyCache()
+	for i := 0; i <= maxReplies; i++ {
+		c.put(string(rune('a'+i)), &reply{})
+	}
+	if c.get("a") != nil || c.get("b") == nil || len(c.replies) != maxReplies {
+		t.Errorf("Expected only the oldest reply to be evicted")
+	}
+}
@@ -0,0 +1,109 @@
+package couch
+
+import "encoding/json"
+
+// Mango index types.
+const (
+	IndexJSON = "json"
+	// Full-text indexes, queried with the "$text" operator, need the
+	// server's search extension.
+	IndexText = "text"
+)
+
+// Index is a Mango index, to be created with CreateIndex.
+type Index struct {
+	// Design document to put the index in, and name for the index.
+	// The server picks them if they're left empty.
+	DDoc string
+	Name string
+	// IndexJSON, the default, or IndexText.
+	Type string
+	// Fields to index, in order, for a JSON index.
+	Fields []string
+	// Selector of the documents to index, leaving the rest out, e.g.
+	// map[string]interface{}{"status": map[string]interface{}{"$exists": true}}
+	// for an index of only those documents with a status.  Queries
+	// only use such a partial index if told to with FindQuery.UseIndex.
+	PartialFilterSelector interface{}
+
+	// For a text index, the fields to index, or nil for every field.
+	TextFields []TextField
+	// Analyzer of text fields, e.g. "english", or a
+	// map[string]interface{} defining a per-field analyzer.  Defaults
+	// to "standard".
+	Analyzer interface{}
+	// DefaultField configures the field "$text" queries search.
+	DefaultField *DefaultField
+	// Whether to index the lengths of arrays, for queries with $size.
+	// Defaults to true.
+	IndexArrayLengths *bool
+}
+
+// TextField is a field of a text index.
+type TextField struct {
+	Name string `json:"name"`
+	// "string", "number" or "boolean".
+	Type string `json:"type"`
+}
+
+// DefaultField configures the default field of a text index, which
+// holds the text of every field for "$text" queries to search.
+type DefaultField struct {
+	Enabled  bool   `json:"enabled"`
+	Analyzer string `json:"analyzer,omitempty"`
+}
+
+// body returns the body of the _index request creating the index.
+func (idx Index) body() interface{} {
+	typ := idx.Type
+	if typ == "" {
+		typ = IndexJSON
+	}
+	def := map[string]interface{}{}
+	if typ == IndexText {
+		if idx.TextFields != nil {
+			def["fields"] = idx.TextFields
+		}
+		if idx.PartialFilterSelector != nil {
+			def["selector"] = idx.PartialFilterSelector
+		}
+		if idx.Analyzer != nil {
+			def["analyzer"] = idx.Analyzer
+		}
+		if idx.DefaultField != nil {
+			def["default_field"] = idx.DefaultField
+		}
+		if idx.IndexArrayLengths != nil {
+			def["index_array_lengths"] = *idx.IndexArrayLengths
+		}
+	} else {
+		def["fields"] = idx.Fields
+		if idx.PartialFilterSelector != nil {
+			def["partial_filter_selector"] = idx.PartialFilterSelector
+		}
+	}
+	body := map[string]interface{}{"index": def, "type": typ}
+	if idx.DDoc != "" {
+		body["ddoc"] = idx.DDoc
+	}
+	if idx.Name != "" {
+		body["name"] = idx.Name
+	}
+	return body
+}
+
+// CreateIndex creates a Mango index, unless an identical one exists,
+// returning the id of its design document and its name, for
+// FindQuery.UseIndex.
+func (p Database) CreateIndex(idx Index) (ddoc, name string, err error) {
+	jsonBuf, err := json.Marshal(idx.body())
+	if err != nil {
+		return "", "", err
+	}
+	res := struct {
+		ID   string `json:"id"`
+		Name string `json:"name"`
+	}{}
+	_, err = p.interact("POST", p.dbURL("_index"), p.defaultHdrs, jsonBuf, &res)
+	return res.ID, res.Name, err
+}
@@ -0,0 +1,129 @@
+package couch
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/dustin/go-couch/couchtest"
+)
+
+func TestCreateIndex(t *testing.T) {
+	f := couchtest.NewTransport(docResponse(`{"result": "created",
+		"id": "_design/status", "name": "by-status"}`))
+	defer uninstallFakeHTTP(installFakeHTTP(f))
+
+	ddoc, name, err := Database{Name: "db"}.CreateIndex(Index{
+		DDoc:   "status",
+		Name:   "by-status",
+		Fields: []string{"status", "updated"},
+		PartialFilterSelector: map[string]interface{}{
+			"status": map[string]interface{}{"$exists": true}},
+	})
+	if err != nil {
+		t.Fatalf("Error creating index: %v", err)
+	}
+	if ddoc != "_design/status" || name != "by-status" {
+		t.Errorf("Unexpected index %v %v", ddoc, name)
+	}
+
+	req := f.Requests[0]
+	sent := map[string]interface{}{}
+	must(json.NewDecoder(req.Body).Decode(&sent))
+	exp := map[string]interface{}{
+		"ddoc": "status", "name": "by-status", "type": "json",
+		"index": map[string]interface{}{
+			"fields": []interface{}{"status", "updated"},
+			"partial_filter_selector": map[string]interface{}{
+				"status": map[string]interface{}{"$exists": true}},
+		},
+	}
+	if req.Method != "POST" || req.URL.Path != "/db/_index" || !reflect.DeepEqual(sent, exp) {
+		t.Errorf("Unexpected request %v %v %v", req.Method, req.URL, sent)
+	}
+}
+
+func TestCreateIndexDefaults(t *testing.T) {
+	f := couchtest.NewTransport(docResponse(`{"result": "exists", "id": "_design/abc", "name": "def"}`))
+	defer uninstallFakeHTTP(installFakeHTTP(f))
+
+	ddoc, name, err := (Database{}).CreateIndex(Index{Fields: []string{"type"}})
+	if err != nil || ddoc != "_design/abc" || name != "def" {
+		t.Errorf("Unexpected index %v %v, %v", ddoc, name, err)
+	}
+	sent := map[string]interface{}{}
+	must(json.NewDecoder(f.Requests[0].Body).Decode(&sent))
+	if _, ok := sent["ddoc"]; ok || sent["index"].(map[string]interface{})["partial_filter_selector"] != nil {
+		t.Errorf("Unexpected request %v", sent)
+	}
+}
+
+func TestFindUseIndex(t *testing.T) {
+	f := couchtest.NewTransport(docResponse(`{"docs": []}`))
+	defer uninstallFakeHTTP(installFakeHTTP(f))
+
+	_, err := (Database{}).Find(FindQuery{
+		Selector: map[string]interface{}{"status": "open"},
+		UseIndex: []string{"_design/status", "by-status"},
+	}, &[]struct{}{})
+	if err != nil {
+		t.Fatalf("Error finding: %v", err)
+	}
+	sent := map[string]interface{}{}
+	must(json.NewDecoder(f.Requests[0].Body).Decode(&sent))
+	if !reflect.DeepEqual(sent["use_index"], []interface{}{"_design/status", "by-status"}) {
+		t.Errorf("Unexpected request %v", sent)
+	}
+}
+
+func TestCreateTextIndex(t *testing.T) {
+	f := couchtest.NewTransport(docResponse(`{"result": "created", "id": "_design/text", "name": "t"}`))
+	defer uninstallFakeHTTP(installFakeHTTP(f))
+
+	noLengths := false
+	_, _, err := (Database{}).CreateIndex(Index{
+		DDoc:                  "text",
+		Type:                  IndexText,
+		TextFields:            []TextField{{Name: "title", Type: "string"}, {Name: "year", Type: "number"}},
+		Analyzer:              "english",
+		DefaultField:          &DefaultField{Enabled: true, Analyzer: "english"},
+		IndexArrayLengths:     &noLengths,
+		PartialFilterSelector: map[string]interface{}{"type": "book"},
+	})
+	if err != nil {
+		t.Fatalf("Error creating index: %v", err)
+	}
+	sent := map[string]interface{}{}
+	must(json.NewDecoder(f.Requests[0].Body).Decode(&sent))
+	exp := map[string]interface{}{
+		"ddoc": "text", "type": "text",
+		"index": map[string]interface{}{
+			"fields": []interface{}{
+				map[string]interface{}{"name": "title", "type": "string"},
+				map[string]interface{}{"name": "year", "type": "number"},
+			},
+			"analyzer":            "english",
+			"default_field":       map[string]interface{}{"enabled": true, "analyzer": "english"},
+			"index_array_lengths": false,
+			"selector":            map[string]interface{}{"type": "book"},
+		},
+	}
+	if !reflect.DeepEqual(sent, exp) {
+		t.Errorf("Unexpected request %v", sent)
+	}
+}
+
+func TestCreateTextIndexAllFields(t *testing.T) {
+	f := couchtest.NewTransport(docResponse(`{"result": "created", "id": "_design/text", "name": "t"}`))
+	defer uninstallFakeHTTP(installFakeHTTP(f))
+
+	if _, _, err := (Database{}).CreateIndex(Index{Type: IndexText}); err != nil {
+		t.Fatalf("Error creating index: %v", err)
+	}
+	sent := map[string]interface{}{}
+	must(json.NewDecoder(f.Requests[0].Body).Decode(&sent))
+	if !reflect.DeepEqual(sent, map[string]interface{}{
+		"type": "text", "index": map[string]interface{}{}}) {
+		t.Errorf("Unexpected request %v", sent)
+	}
+}
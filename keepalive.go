@@ -0,0 +1,11 @@
+package couch
+
+// WithoutKeepAlive returns a copy of the database that closes the
+// connection after each request rather than keeping it open for the
+// next.  Connections are reused by default, which saves a TCP and TLS
+// handshake per request; this is for servers or proxies that misbehave
+// with persistent connections.
+func (p Database) WithoutKeepAlive() Database {
+	p.closeConns = true
+	return p
+}
@@ -0,0 +1,57 @@
+package couch
+
+import (
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/dustin/go-couch/couchtest"
+)
+
+func TestKeepAlive(t *testing.T) {
+	f := &couchtest.Transport{Responses: []*http.Response{
+		docResponse(`{"ok": true, "id": "a", "rev": "1-x"}`),
+		docResponse(`{"ok": true, "id": "a", "rev": "2-x"}`),
+	}}
+	defer uninstallFakeHTTP(installFakeHTTP(f))
+
+	d := Database{Host: "localhost", Port: "5984", Name: "db"}
+	if _, err := d.Edit(map[string]string{"_id": "a", "_rev": "0-x"}); err != nil {
+		t.Fatalf("Error editing: %v", err)
+	}
+	if _, err := d.WithoutKeepAlive().Edit(
+		map[string]string{"_id": "a", "_rev": "1-x"}); err != nil {
+		t.Fatalf("Error editing: %v", err)
+	}
+	if f.Requests[0].Close {
+		t.Errorf("Expected the connection to be kept alive")
+	}
+	if !f.Requests[1].Close {
+		t.Errorf("Expected the connection to be closed")
+	}
+}
+
+type trackedBody struct {
+	*strings.Reader
+	closed bool
+}
+
+func (b *trackedBody) Close() error {
+	b.closed = true
+	return nil
+}
+
+func TestResponseDrained(t *testing.T) {
+	body := &trackedBody{Reader: strings.NewReader(`{"ok": true, "id": "a", "rev": "1-x"}` + "\n\n")}
+	f := &couchtest.Transport{Responses: []*http.Response{{StatusCode: 201, Body: body}}}
+	defer uninstallFakeHTTP(installFakeHTTP(f))
+
+	d := Database{Host: "localhost", Port: "5984", Name: "db"}
+	if _, err := d.Edit(map[string]string{"_id": "a", "_rev": "0-x"}); err != nil {
+		t.Fatalf("Error editing: %v", err)
+	}
+	if rest, _ := ioutil.ReadAll(body); len(rest) != 0 || !body.closed {
+		t.Errorf("Expected the body to be drained and closed, %q left", rest)
+	}
+}
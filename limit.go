@@ -0,0 +1,93 @@
+package couch
+
+import (
+	"context"
+	"errors"
+	"io"
+	"sync"
+)
+
+// Priority classifies requests for load shedding.
+type Priority int
+
+const (
+	// HighPriority requests wait for capacity when the database is
+	// saturated.  This is the default.
+	HighPriority Priority = iota
+	// LowPriority requests are shed with ErrOverloaded instead.
+	LowPriority
+)
+
+// ErrOverloaded is returned when a low priority request is shed.
+var ErrOverloaded = errors.New("couch: overloaded, low priority request shed")
+
+// WithPriority returns a copy of the database whose requests have the
+// given priority, e.g. db.WithPriority(couch.LowPriority) for backfills.
+func (p Database) WithPriority(pri Priority) Database {
+	p.priority = pri
+	return p
+}
+
+// WithConcurrencyLimit returns a copy of the database that allows at
+// most n requests in flight across it and every copy made from it.
+//
+// Low priority requests may only use three quarters of the capacity,
+// leaving the rest for interactive traffic, and are shed with
+// ErrOverloaded rather than waiting.  High priority requests wait for a
+// free slot, or until the request's context is done.  A limit of 0 removes the limiter.
+func (p Database) WithConcurrencyLimit(n int) Database {
+	p.limiter = nil
+	if n > 0 {
+		p.limiter = newLimiter(n)
+	}
+	return p
+}
+
+type limiter struct {
+	slots  chan struct{}
+	lowMax int
+}
+
+func newLimiter(n int) *limiter {
+	lowMax := n - n/4
+	return &limiter{slots: make(chan struct{}, n), lowMax: lowMax}
+}
+
+// acquire takes a slot, waiting for one at high priority until ctx is
+// done.
+func (l *limiter) acquire(ctx context.Context, pri Priority) error {
+	if pri != LowPriority {
+		select {
+		case l.slots <- struct{}{}:
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	if len(l.slots) >= l.lowMax {
+		return ErrOverloaded
+	}
+	select {
+	case l.slots <- struct{}{}:
+		return nil
+	default:
+		return ErrOverloaded
+	}
+}
+
+func (l *limiter) release() {
+	<-l.slots
+}
+
+// releasingBody releases a limiter slot once the response is closed.
+type releasingBody struct {
+	io.ReadCloser
+	once    sync.Once
+	release func()
+}
+
+func (b *releasingBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.once.Do(b.release)
+	return err
+}
@@ -0,0 +1,93 @@
+package couch
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLimiter(t *testing.T) {
+	l := newLimiter(4)
+	for i := 0; i < 3; i++ {
+		if err := l.acquire(context.Background(), LowPriority); err != nil {
+			t.Fatalf("Expected low priority slot %v, got %v", i, err)
+		}
+	}
+	if err := l.acquire(context.Background(), LowPriority); err != ErrOverloaded {
+		t.Fatalf("Expected low priority to be shed, got %v", err)
+	}
+	if err := l.acquire(context.Background(), HighPriority); err != nil {
+		t.Fatalf("Expected high priority slot, got %v", err)
+	}
+	l.release()
+	l.release()
+	if err := l.acquire(context.Background(), LowPriority); err != nil {
+		t.Fatalf("Expected low priority slot after release, got %v", err)
+	}
+}
+
+func TestLimiterCanceled(t *testing.T) {
+	l := newLimiter(1)
+	if err := l.acquire(context.Background(), HighPriority); err != nil {
+		t.Fatalf("Expected a slot, got %v", err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := l.acquire(ctx, HighPriority); err != context.DeadlineExceeded {
+		t.Errorf("Expected the wait to be canceled, got %v", err)
+	}
+}
+
+// blockingHTTP answers requests only once released.
+type blockingHTTP struct {
+	started, release chan bool
+}
+
+func (b *blockingHTTP) RoundTrip(*http.Request) (*http.Response, error) {
+	b.started <- true
+	<-b.release
+	return &http.Response{
+		StatusCode: 200,
+		Body:       ioutil.NopCloser(strings.NewReader(`{"db_name": "db"}`)),
+	}, nil
+}
+
+func TestConcurrencyLimitSheds(t *testing.T) {
+	b := &blockingHTTP{make(chan bool, 3), make(chan bool)}
+	d := Database{
+		Name:   "db",
+		client: &http.Client{Transport: b},
+	}.WithConcurrencyLimit(1)
+
+	errc := make(chan error)
+	go func() {
+		_, err := d.GetInfo()
+		errc <- err
+	}()
+	<-b.started
+
+	if _, err := d.WithPriority(LowPriority).GetInfo(); err != ErrOverloaded {
+		t.Errorf("Expected low priority request to be shed, got %v", err)
+	}
+
+	// A high priority request waits its turn.
+	go func() {
+		_, err := d.GetInfo()
+		errc <- err
+	}()
+
+	close(b.release)
+	<-b.started
+	for i := 0; i < 2; i++ {
+		if err := <-errc; err != nil {
+			t.Errorf("Expected success, got %v", err)
+		}
+	}
+
+	if _, err := d.WithPriority(LowPriority).GetInfo(); err != nil {
+		t.Errorf("Expected low priority request to run when idle, got %v", err)
+	}
+}
@@ -0,0 +1,130 @@
+package couch
+
+import (
+	"encoding/json"
+)
+
+// linkedID returns the id named by a view value like {"_id": "other"}.
+func linkedID(value json.RawMessage) string {
+	if len(value) == 0 || value[0] != '{' {
+		return ""
+	}
+	v := struct {
+		ID string `json:"_id"`
+	}{}
+	if json.Unmarshal(value, &v) != nil {
+		return ""
+	}
+	return v.ID
+}
+
+// ResolveLinks follows references one level beyond a linked-document
+// join.  For the document included with each of rows (see Row.Doc),
+// it reads the ids in field, a string or an array of them, and fetches
+// those documents in batched _all_docs requests, decoding them into
+// out, a pointer to a map keyed by id.  Documents that don't exist or
+// were deleted are left out.
+//
+// For example, with rows of comments joined to their posts, each post
+// having an "author" field:
+//
+//	authors := map[string]Author{}
+//	err := db.ResolveLinks(rows, "author", &authors)
+func (p Database) ResolveLinks(rows []Row, field string, out interface{}) error {
+	seen := map[string]bool{}
+	var ids []string
+	for _, row := range rows {
+		if len(row.Doc) == 0 || row.Doc[0] != '{' {
+			continue
+		}
+		doc := map[string]json.RawMessage{}
+		if err := json.Unmarshal(row.Doc, &doc); err != nil {
+			return err
+		}
+		for _, id := range refIDs(doc[field]) {
+			if id != "" && !seen[id] {
+				seen[id] = true
+				ids = append(ids, id)
+			}
+		}
+	}
+
+	docs, err := p.getMany(ids)
+	if err != nil {
+		return err
+	}
+	all, err := json.Marshal(docs)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(all, out)
+}
+
+// refIDs returns the ids in a field holding one id or an array of them.
+func refIDs(v json.RawMessage) []string {
+	var id string
+	if json.Unmarshal(v, &id) == nil {
+		return []string{id}
+	}
+	var ids []string
+	json.Unmarshal(v, &ids)
+	return ids
+}
+
+// getMany fetches the documents with the given ids, a batch at a time,
+// leaving out those that don't exist or were deleted.
+func (p Database) getMany(ids []string) (map[string]json.RawMessage, error) {
+	docs := make(map[string]json.RawMessage, len(ids))
+	err := p.allDocsByKey(ids, func(row keyRow) {
+		if row.found() {
+			docs[row.Key] = row.Doc
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	return docs, nil
+}
+
+// keyRow is a row of _all_docs fetched by key with include_docs.
+type keyRow struct {
+	Key   string          `json:"key"`
+	Doc   json.RawMessage `json:"doc"`
+	Error string          `json:"error"`
+	Value struct {
+		Deleted bool `json:"deleted"`
+	} `json:"value"`
+}
+
+// found reports whether the row holds a live document.
+func (r keyRow) found() bool {
+	return len(r.Doc) > 0 && string(r.Doc) != "null"
+}
+
+// allDocsByKey fetches the _all_docs rows for the given ids, a batch at
+// a time, passing each to f.
+func (p Database) allDocsByKey(ids []string, f func(keyRow)) error {
+	for len(ids) > 0 {
+		batch := ids
+		if len(batch) > defaultTransferBatch {
+			batch = batch[:defaultTransferBatch]
+		}
+		ids = ids[len(batch):]
+
+		keys, err := json.Marshal(map[string]interface{}{"keys": batch})
+		if err != nil {
+			return err
+		}
+		res := struct {
+			Rows []keyRow `json:"rows"`
+		}{}
+		if _, err := p.interact("POST", p.dbURL("_all_docs")+"?include_docs=true",
+			p.defaultHdrs, keys, &res); err != nil {
+			return err
+		}
+		for _, row := range res.Rows {
+			f(row)
+		}
+	}
+	return nil
+}
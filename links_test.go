@@ -0,0 +1,103 @@
+package couch
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/dustin/go-couch/couchtest"
+)
+
+func TestRowLink(t *testing.T) {
+	res := struct{ Rows []Row }{}
+	must(json.Unmarshal([]byte(`{"rows": [
+		{"id": "c1", "key": "p1", "value": {"_id": "p1"}, "doc": {"_id": "p1", "title": "hi"}},
+		{"id": "c2", "key": "p2", "value": {"_id": "p2"}, "doc": null},
+		{"id": "c3", "key": "c3", "value": 1, "doc": {"_id": "c3", "title": "own"}}
+	]}`), &res))
+
+	for i, exp := range []struct {
+		link   string
+		joined bool
+		title  string
+	}{{"p1", true, "hi"}, {"p2", false, ""}, {"", false, ""}} {
+		row := res.Rows[i]
+		doc := struct{ Title string }{}
+		joined, err := row.Joined(&doc)
+		if err != nil {
+			t.Fatalf("Error decoding joined doc of %v: %v", *row.ID, err)
+		}
+		if row.Link() != exp.link || joined != exp.joined || doc.Title != exp.title {
+			t.Errorf("Row %v: got %q %v %q, expected %+v",
+				*row.ID, row.Link(), joined, doc.Title, exp)
+		}
+	}
+}
+
+func TestRowsLink(t *testing.T) {
+	rows, err := newRows(ioutil.NopCloser(strings.NewReader(`{"rows": [
+		{"id": "c1", "key": "p1", "value": {"_id": "p1", "x": 1}},
+		{"id": "c2", "key": "c2", "value": {"rev": "1-a"}}]}`)), "rows")
+	if err != nil {
+		t.Fatalf("Error starting rows: %v", err)
+	}
+	var links []string
+	for rows.Next() {
+		links = append(links, rows.Link())
+	}
+	if !reflect.DeepEqual(links, []string{"p1", ""}) {
+		t.Errorf("Unexpected links %q", links)
+	}
+}
+
+func TestResolveLinks(t *testing.T) {
+	f := couchtest.NewTransport(docResponse(`{"rows": [
+		{"key": "ann", "id": "ann", "value": {"rev": "1-a"}, "doc": {"_id": "ann", "name": "Ann"}},
+		{"key": "bob", "id": "bob", "value": {"rev": "2-a", "deleted": true}, "doc": null},
+		{"key": "cat", "error": "not_found"}
+	]}`))
+	defer uninstallFakeHTTP(installFakeHTTP(f))
+
+	rows := []Row{
+		{Doc: json.RawMessage(`{"_id": "p1", "author": "ann"}`)},
+		{Doc: json.RawMessage(`{"_id": "p2", "author": ["bob", "ann", "cat"]}`)},
+		{Doc: json.RawMessage(`null`)},
+		{},
+	}
+	authors := map[string]struct{ Name string }{}
+	if err := (Database{Name: "db"}).ResolveLinks(rows, "author", &authors); err != nil {
+		t.Fatalf("Error resolving: %v", err)
+	}
+	if len(authors) != 1 || authors["ann"].Name != "Ann" {
+		t.Errorf("Unexpected authors %v", authors)
+	}
+
+	req := f.Requests[0]
+	sent := struct{ Keys []string }{}
+	must(json.NewDecoder(req.Body).Decode(&sent))
+	if req.Method != "POST" || req.URL.Path != "/db/_all_docs" ||
+		req.URL.Query().Get("include_docs") != "true" ||
+		!reflect.DeepEqual(sent.Keys, []string{"ann", "bob", "cat"}) {
+		t.Errorf("Unexpected request %v %v %v", req.Method, req.URL, sent.Keys)
+	}
+}
+
+func TestGetManyBatches(t *testing.T) {
+	f := &couchtest.Transport{Responses: []*http.Response{
+		docResponse(`{"rows": []}`), docResponse(`{"rows": []}`)}}
+	defer uninstallFakeHTTP(installFakeHTTP(f))
+
+	ids := make([]string, defaultTransferBatch+1)
+	for i := range ids {
+		ids[i] = strings.Repeat("x", i+1)
+	}
+	if _, err := (Database{}).getMany(ids); err != nil {
+		t.Fatalf("Error fetching: %v", err)
+	}
+	if len(f.Requests) != 2 {
+		t.Errorf("Expected 2 batches, got %v", len(f.Requests))
+	}
+}
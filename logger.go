@@ -0,0 +1,136 @@
+package couch
+
+import (
+	"io"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// RequestInfo describes an HTTP request made by a Database.
+type RequestInfo struct {
+	Operation     string // e.g. "document", "view" or "bulk_docs"
+	Method        string
+	URL           string // with any password redacted
+	StatusCode    int    // 0 if no response was received
+	Err           error  // set if no response was received
+	Duration      time.Duration
+	BytesSent     int64
+	BytesReceived int64
+	// The id sent with the request, if any (see WithRequestIDs), and
+	// the one the server gave its response.
+	RequestID       string
+	ServerRequestID string
+}
+
+// Logger receives diagnostics from a Database.  See WithLogger.
+type Logger interface {
+	// LogRequest is called for every HTTP request once its response
+	// body is closed, or as soon as it fails.  Duration covers the
+	// time until the response headers arrived.
+	LogRequest(RequestInfo)
+	// Printf logs anything else worth knowing about.
+	Printf(format string, v ...interface{})
+}
+
+// WithLogger returns a copy of the database that reports to l.  By
+// default nothing is logged.
+func (p Database) WithLogger(l Logger) Database {
+	p.logger = l
+	return p
+}
+
+// StdLogger adapts a standard library logger to Logger, writing a line
+// per request.
+func StdLogger(l *log.Logger) Logger {
+	return stdLogger{l}
+}
+
+type stdLogger struct {
+	*log.Logger
+}
+
+func (l stdLogger) LogRequest(ri RequestInfo) {
+	ids := ""
+	if ri.RequestID != "" {
+		ids += ", request " + ri.RequestID
+	}
+	if ri.ServerRequestID != "" {
+		ids += ", server request " + ri.ServerRequestID
+	}
+	if ri.Err != nil {
+		l.Printf("%s %s: %v (%v%s)", ri.Method, ri.URL, ri.Err, ri.Duration, ids)
+		return
+	}
+	l.Printf("%s %s: %d (%v, %d bytes sent, %d received%s)", ri.Method, ri.URL,
+		ri.StatusCode, ri.Duration, ri.BytesSent, ri.BytesReceived, ids)
+}
+
+func (p Database) logf(format string, v ...interface{}) {
+	if p.logger != nil {
+		p.logger.Printf(format, v...)
+	}
+}
+
+// observe arranges for a request started at the given time to be
+// reported to the logger and metrics, returning the response to use in
+// place of res.
+func (p Database) observe(req *http.Request, res *http.Response, err error,
+	start time.Time) *http.Response {
+
+	if p.logger == nil && p.metrics == nil {
+		return res
+	}
+	ri := RequestInfo{
+		Operation: operation(req.URL.EscapedPath()),
+		Method:    req.Method,
+		URL:       req.URL.Redacted(),
+		Err:       err,
+		Duration:  time.Since(start),
+		RequestID: req.Header.Get(RequestIDHeader),
+	}
+	if req.ContentLength > 0 {
+		ri.BytesSent = req.ContentLength
+	}
+	if err != nil {
+		p.report(ri)
+		return res
+	}
+	ri.StatusCode = res.StatusCode
+	ri.ServerRequestID = res.Header.Get(ServerRequestIDHeader)
+	res.Body = &countingBody{ReadCloser: res.Body, done: func(n int64) {
+		ri.BytesReceived = n
+		p.report(ri)
+	}}
+	return res
+}
+
+func (p Database) report(ri RequestInfo) {
+	if p.logger != nil {
+		p.logger.LogRequest(ri)
+	}
+	if p.metrics != nil {
+		p.metrics.ObserveRequest(ri)
+	}
+}
+
+// countingBody reports how much was read from it once closed.
+type countingBody struct {
+	io.ReadCloser
+	n    int64
+	once sync.Once
+	done func(int64)
+}
+
+func (b *countingBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	b.n += int64(n)
+	return n, err
+}
+
+func (b *countingBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.once.Do(func() { b.done(b.n) })
+	return err
+}
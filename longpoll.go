@@ -0,0 +1,49 @@
+package couch
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// ChangesBatch is a batch of changes, as returned by PollChanges.
+type ChangesBatch struct {
+	Results []Change `json:"results"`
+	// Sequence to pass as since for the following batch.
+	LastSeq Sequence `json:"last_seq"`
+	// Changes remaining after this batch, if the server says.
+	Pending int64 `json:"pending"`
+}
+
+// PollChanges waits for changes after since (feed=longpoll), returning
+// them as soon as there are any, or an empty batch once timeout
+// passes (the server's default if zero).  Pass the batch's LastSeq as
+// since to continue.  options are as for Changes.
+//
+// Unlike Changes, each batch is an ordinary request, so it works
+// through proxies that don't cope with long-lived responses.  Any
+// Config.Timeout should be longer than timeout.
+func (p Database) PollChanges(since Sequence, timeout time.Duration,
+	options map[string]interface{}) (ChangesBatch, error) {
+
+	params := url.Values{}
+	for k, v := range options {
+		params.Set(k, fmt.Sprintf("%v", v))
+	}
+	params.Set("feed", "longpoll")
+	params.Del("heartbeat")
+	if since != "" {
+		params.Set("since", string(since))
+	}
+	if timeout > 0 {
+		params.Set("timeout", strconv.FormatInt(int64(timeout/time.Millisecond), 10))
+	}
+
+	batch := ChangesBatch{}
+	err := p.unmarshalURL(p.dbURL("_changes")+"?"+params.Encode(), &batch)
+	if err == nil && batch.LastSeq == "" {
+		batch.LastSeq = since
+	}
+	return batch, err
+}
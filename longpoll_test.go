@@ -0,0 +1,45 @@
+package couch
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/dustin/go-couch/couchtest"
+)
+
+func TestPollChanges(t *testing.T) {
+	f := &couchtest.Transport{Responses: []*http.Response{
+		docResponse(`{"results": [
+			{"seq": "2-x", "id": "a", "changes": [{"rev": "1-a"}]},
+			{"seq": "3-x", "id": "b", "changes": [{"rev": "2-b"}], "deleted": true}],
+			"last_seq": "3-x", "pending": 5}`),
+		docResponse(`{"results": [], "last_seq": 3}`),
+	}}
+	defer uninstallFakeHTTP(installFakeHTTP(f))
+
+	d := Database{Name: "db"}
+	batch, err := d.PollChanges("1-x", 30*time.Second,
+		map[string]interface{}{"include_docs": true, "heartbeat": 1000})
+	if err != nil {
+		t.Fatalf("Error polling: %v", err)
+	}
+	if len(batch.Results) != 2 || batch.Results[1].ID != "b" ||
+		!batch.Results[1].Deleted || batch.LastSeq != "3-x" || batch.Pending != 5 {
+		t.Errorf("Unexpected batch %+v", batch)
+	}
+	q := f.Requests[0].URL.Query()
+	if f.Requests[0].URL.Path != "/db/_changes" || q.Get("feed") != "longpoll" ||
+		q.Get("since") != "1-x" || q.Get("timeout") != "30000" ||
+		q.Get("include_docs") != "true" || q.Get("heartbeat") != "" {
+		t.Errorf("Unexpected request %v", f.Requests[0].URL)
+	}
+
+	batch, err = d.PollChanges(batch.LastSeq, 0, nil)
+	if err != nil || len(batch.Results) != 0 || batch.LastSeq != "3" {
+		t.Errorf("Unexpected batch %+v, %v", batch, err)
+	}
+	if q := f.Requests[1].URL.Query(); q.Get("since") != "3-x" || q.Get("timeout") != "" {
+		t.Errorf("Unexpected request %v", f.Requests[1].URL)
+	}
+}
@@ -0,0 +1,98 @@
+package couch
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// MissingDocsError is returned by RetrieveMany when some of the
+// documents asked for couldn't be retrieved.  It matches ErrNotFound
+// with errors.Is.
+type MissingDocsError struct {
+	Missing []string // ids of documents that don't exist
+	Deleted []string // ids of documents that were deleted
+}
+
+func (e *MissingDocsError) Error() string {
+	var parts []string
+	if len(e.Missing) > 0 {
+		parts = append(parts, fmt.Sprintf("%d missing %q", len(e.Missing), e.Missing))
+	}
+	if len(e.Deleted) > 0 {
+		parts = append(parts, fmt.Sprintf("%d deleted %q", len(e.Deleted), e.Deleted))
+	}
+	return "couch: documents not found: " + strings.Join(parts, ", ")
+}
+
+// Is reports whether target is ErrNotFound.
+func (e *MissingDocsError) Is(target error) bool {
+	return target == ErrNotFound
+}
+
+var errRetrieveManyOut = errors.New("couch: RetrieveMany needs a pointer to a map keyed by string or to a slice")
+
+// RetrieveMany retrieves the documents with the given ids with a single
+// request per batch, rather than one per document.  out points to a
+// map keyed by string, which is given an entry per document found, or
+// to a slice, which is set to hold the documents in the order of ids,
+// leaving the zero value for those not found.
+//
+// Documents found are retrieved even if others aren't; those are then
+// listed in a *MissingDocsError.
+func (p Database) RetrieveMany(ids []string, out interface{}) error {
+	v := reflect.ValueOf(out)
+	if v.Kind() != reflect.Ptr || v.IsNil() {
+		return errRetrieveManyOut
+	}
+	v = v.Elem()
+	index := map[string][]int{}
+	switch {
+	case v.Kind() == reflect.Map && v.Type().Key().Kind() == reflect.String:
+		if v.IsNil() {
+			v.Set(reflect.MakeMapWithSize(v.Type(), len(ids)))
+		}
+	case v.Kind() == reflect.Slice:
+		v.Set(reflect.MakeSlice(v.Type(), len(ids), len(ids)))
+		for i, id := range ids {
+			index[id] = append(index[id], i)
+		}
+	default:
+		return errRetrieveManyOut
+	}
+
+	missing := &MissingDocsError{}
+	var decodeErr error
+	err := p.allDocsByKey(ids, func(row keyRow) {
+		switch {
+		case decodeErr != nil:
+		case row.found():
+			doc := reflect.New(v.Type().Elem())
+			if err := p.unmarshal(row.Doc, doc.Interface()); err != nil {
+				decodeErr = fmt.Errorf("couch: decoding %q: %w", row.Key, err)
+				return
+			}
+			if v.Kind() == reflect.Map {
+				v.SetMapIndex(reflect.ValueOf(row.Key).Convert(v.Type().Key()), doc.Elem())
+				return
+			}
+			for _, i := range index[row.Key] {
+				v.Index(i).Set(doc.Elem())
+			}
+		case row.Value.Deleted:
+			missing.Deleted = append(missing.Deleted, row.Key)
+		default:
+			missing.Missing = append(missing.Missing, row.Key)
+		}
+	})
+	switch {
+	case err != nil:
+		return err
+	case decodeErr != nil:
+		return decodeErr
+	case len(missing.Missing) > 0 || len(missing.Deleted) > 0:
+		return missing
+	}
+	return nil
+}
@@ -0,0 +1,88 @@
+package couch
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/dustin/go-couch/couchtest"
+)
+
+const manyRows = `{"rows": [
+	{"id": "a", "key": "a", "value": {"rev": "1-a"}, "doc": {"_id": "a", "n": 1}},
+	{"key": "b", "error": "not_found"},
+	{"id": "c", "key": "c", "value": {"rev": "2-c", "deleted": true}, "doc": null},
+	{"id": "d", "key": "d", "value": {"rev": "1-d"}, "doc": {"_id": "d", "n": 4}}
+]}`
+
+type manyDoc struct {
+	ID string `json:"_id"`
+	N  int    `json:"n"`
+}
+
+func TestRetrieveManyMap(t *testing.T) {
+	f := couchtest.NewTransport(docResponse(manyRows))
+	defer uninstallFakeHTTP(installFakeHTTP(f))
+
+	docs := map[string]manyDoc{}
+	err := (Database{}).RetrieveMany([]string{"a", "b", "c", "d"}, &docs)
+	missing := &MissingDocsError{}
+	if !errors.As(err, &missing) || !errors.Is(err, ErrNotFound) {
+		t.Fatalf("Expected a MissingDocsError, got %v", err)
+	}
+	if !reflect.DeepEqual(missing.Missing, []string{"b"}) ||
+		!reflect.DeepEqual(missing.Deleted, []string{"c"}) {
+		t.Errorf("Unexpected missing docs %+v", missing)
+	}
+	if len(docs) != 2 || docs["a"].N != 1 || docs["d"].N != 4 {
+		t.Errorf("Unexpected docs %+v", docs)
+	}
+
+	req := f.Requests[0]
+	body, _ := ioutil.ReadAll(req.Body)
+	sent := struct{ Keys []string }{}
+	json.Unmarshal(body, &sent)
+	if req.Method != "POST" || !strings.HasSuffix(req.URL.Path, "/_all_docs") ||
+		req.URL.Query().Get("include_docs") != "true" ||
+		!reflect.DeepEqual(sent.Keys, []string{"a", "b", "c", "d"}) {
+		t.Errorf("Unexpected request %v %v %s", req.Method, req.URL, body)
+	}
+}
+
+func TestRetrieveManySlice(t *testing.T) {
+	defer uninstallFakeHTTP(installFakeHTTP(couchtest.NewTransport(docResponse(manyRows))))
+
+	docs := []*manyDoc{}
+	err := (Database{}).RetrieveMany([]string{"a", "b", "c", "d"}, &docs)
+	if !errors.Is(err, ErrNotFound) {
+		t.Fatalf("Expected not found, got %v", err)
+	}
+	if len(docs) != 4 || docs[0].N != 1 || docs[1] != nil || docs[2] != nil ||
+		docs[3].N != 4 {
+		t.Errorf("Unexpected docs %+v", docs)
+	}
+}
+
+func TestRetrieveManyAllFound(t *testing.T) {
+	defer uninstallFakeHTTP(installFakeHTTP(couchtest.NewTransport(docResponse(`{"rows": [
+		{"id": "a", "key": "a", "value": {"rev": "1-a"}, "doc": {"_id": "a", "n": 1}}]}`))))
+
+	var docs map[string]manyDoc
+	if err := (Database{}).RetrieveMany([]string{"a"}, &docs); err != nil {
+		t.Fatalf("Error retrieving: %v", err)
+	}
+	if docs["a"].N != 1 {
+		t.Errorf("Unexpected docs %+v", docs)
+	}
+}
+
+func TestRetrieveManyBadOut(t *testing.T) {
+	for _, out := range []interface{}{nil, map[string]manyDoc{}, &map[int]manyDoc{}, &manyDoc{}} {
+		if err := (Database{}).RetrieveMany([]string{"a"}, out); err != errRetrieveManyOut {
+			t.Errorf("Expected an error for %T, got %v", out, err)
+		}
+	}
+}
@@ -0,0 +1,34 @@
+package couch
+
+// DocumentMeta is implemented by documents that want their id and
+// revision kept current.  When a pointer to such a document is passed
+// to Insert, InsertWith, Edit, EditWith or Bulk, the id and new
+// revision are written back into it after a successful write, so the
+// same value can be edited again without being retrieved first.
+type DocumentMeta interface {
+	SetID(id string)
+	SetRev(rev string)
+}
+
+// Document may be embedded in a struct to give it "_id" and "_rev"
+// fields and implement DocumentMeta.
+type Document struct {
+	ID  string `json:"_id,omitempty"`
+	Rev string `json:"_rev,omitempty"`
+}
+
+// SetID sets the document's id.
+func (d *Document) SetID(id string) { d.ID = id }
+
+// SetRev sets the document's revision.
+func (d *Document) SetRev(rev string) { d.Rev = rev }
+
+// setMeta writes id and rev back into d if it implements DocumentMeta.
+func setMeta(d interface{}, id, rev string) {
+	if m, ok := d.(DocumentMeta); ok {
+		if id != "" {
+			m.SetID(id)
+		}
+		m.SetRev(rev)
+	}
+}
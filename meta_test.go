@@ -0,0 +1,97 @@
+package couch
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/dustin/go-couch/couchtest"
+)
+
+type metaDoc struct {
+	Document
+	Name string `json:"name"`
+}
+
+func TestInsertSetsMeta(t *testing.T) {
+	defer uninstallFakeHTTP(installFakeHTTP(couchtest.NewTransport(
+		docResponse(`{"ok": true, "id": "gen", "rev": "1-a"}`))))
+
+	d := &metaDoc{Name: "x"}
+	if _, _, err := (Database{}).Insert(d); err != nil {
+		t.Fatalf("Expected success, got %v", err)
+	}
+	if d.ID != "gen" || d.Rev != "1-a" {
+		t.Errorf("Expected gen/1-a, got %q/%q", d.ID, d.Rev)
+	}
+}
+
+func TestEditSetsRev(t *testing.T) {
+	f := &couchtest.Transport{Responses: []*http.Response{
+		docResponse(`{"ok": true, "id": "a", "rev": "2-b"}`),
+		docResponse(`{"ok": true, "id": "a", "rev": "3-c"}`),
+	}}
+	defer uninstallFakeHTTP(installFakeHTTP(f))
+
+	d := &metaDoc{Document: Document{ID: "a", Rev: "1-a"}}
+	if _, err := (Database{}).Edit(d); err != nil {
+		t.Fatalf("Expected success, got %v", err)
+	}
+	if d.Rev != "2-b" {
+		t.Fatalf("Expected rev 2-b, got %q", d.Rev)
+	}
+
+	// The updated value can be edited again straight away.
+	if _, err := (Database{}).Edit(d); err != nil {
+		t.Fatalf("Expected success, got %v", err)
+	}
+	sent := idAndRev{}
+	must(json.NewDecoder(f.Requests[1].Body).Decode(&sent))
+	if sent.Rev != "2-b" || d.Rev != "3-c" {
+		t.Errorf("Expected to send 2-b and get 3-c, sent %q, got %q",
+			sent.Rev, d.Rev)
+	}
+}
+
+func TestEditWithSetsMeta(t *testing.T) {
+	defer uninstallFakeHTTP(installFakeHTTP(couchtest.NewTransport(
+		docResponse(`{"ok": true, "id": "a", "rev": "2-b"}`))))
+
+	d := &metaDoc{}
+	if _, err := (Database{}).EditWith(d, "a", "1-a"); err != nil {
+		t.Fatalf("Expected success, got %v", err)
+	}
+	if d.ID != "a" || d.Rev != "2-b" {
+		t.Errorf("Expected a/2-b, got %q/%q", d.ID, d.Rev)
+	}
+}
+
+func TestEditFailureKeepsMeta(t *testing.T) {
+	defer uninstallFakeHTTP(installFakeHTTP(couchtest.NewTransport(conflictResponse())))
+
+	d := &metaDoc{Document: Document{ID: "a", Rev: "1-a"}}
+	if _, err := (Database{}).Edit(d); err == nil {
+		t.Fatalf("Expected conflict")
+	}
+	if d.Rev != "1-a" {
+		t.Errorf("Expected rev to stay 1-a, got %q", d.Rev)
+	}
+}
+
+func TestBulkSetsMeta(t *testing.T) {
+	defer uninstallFakeHTTP(installFakeHTTP(couchtest.NewTransport(docResponse(`[
+		{"ok": true, "id": "a", "rev": "1-a"},
+		{"id": "b", "error": "conflict", "reason": "Document update conflict."}
+	]`))))
+
+	a, b := &metaDoc{}, &metaDoc{Document: Document{ID: "b"}}
+	if _, err := (Database{}).Bulk([]interface{}{a, b}); err != nil {
+		t.Fatalf("Expected success, got %v", err)
+	}
+	if a.ID != "a" || a.Rev != "1-a" {
+		t.Errorf("Expected a/1-a, got %q/%q", a.ID, a.Rev)
+	}
+	if b.Rev != "" {
+		t.Errorf("Expected failed doc to keep its revision, got %q", b.Rev)
+	}
+}
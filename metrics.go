@@ -0,0 +1,61 @@
+package couch
+
+import (
+	"strings"
+)
+
+// Metrics receives measurements from a Database.  See WithMetrics, and
+// the couchprom package for a Prometheus implementation.
+type Metrics interface {
+	// ObserveRequest is called for every HTTP request, when a Logger
+	// would be.
+	ObserveRequest(RequestInfo)
+	// ObserveChangesReconnect is called each time the changes feed of
+	// the named database reconnects.
+	ObserveChangesReconnect(db string)
+	// ObserveChangesStale is called when the changes feed of the named
+	// database is abandoned because neither changes nor heartbeats
+	// arrived in time.  A reconnect follows.
+	ObserveChangesStale(db string)
+}
+
+// WithMetrics returns a copy of the database that reports to m.
+func (p Database) WithMetrics(m Metrics) Database {
+	p.metrics = m
+	return p
+}
+
+// operation names the kind of request made to an escaped path, for
+// grouping in logs and metrics.
+func operation(path string) string {
+	segs := strings.Split(strings.Trim(path, "/"), "/")
+	switch {
+	case segs[0] == "":
+		return "server"
+	case strings.HasPrefix(segs[0], "_"):
+		return segs[0][1:]
+	case len(segs) == 1:
+		return "database"
+	}
+
+	rest := segs[1:]
+	switch rest[0] {
+	case "_design":
+		if len(rest) > 2 && strings.HasPrefix(rest[2], "_") {
+			return rest[2][1:]
+		}
+		if len(rest) > 2 {
+			return "attachment"
+		}
+		return "design_doc"
+	case "_local":
+		return "local_doc"
+	}
+	switch {
+	case strings.HasPrefix(rest[0], "_"):
+		return rest[0][1:]
+	case len(rest) > 1:
+		return "attachment"
+	}
+	return "document"
+}
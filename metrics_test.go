@@ -0,0 +1,95 @@
+package couch
+
+import (
+	"io"
+	"net/http"
+	"testing"
+
+	"github.com/dustin/go-couch/couchtest"
+)
+
+func TestOperation(t *testing.T) {
+	tests := map[string]string{
+		"/":                               "server",
+		"/_all_dbs":                       "all_dbs",
+		"/db":                             "database",
+		"/db/":                            "database",
+		"/db/doc":                         "document",
+		"/db/a%2Fb":                       "document",
+		"/db/doc/att.txt":                 "attachment",
+		"/db/_bulk_docs":                  "bulk_docs",
+		"/db/_changes":                    "changes",
+		"/db/_design/d":                   "design_doc",
+		"/db/_design/d/_view/v":           "view",
+		"/db/_design/d/_info":             "info",
+		"/db/_design/d/_view":             "view",
+		"/db/_design/d/_update/u/doc":     "update",
+		"/db/_design/d/pic.png":           "attachment",
+		"/db/_local/checkpoint":           "local_doc",
+		"/db/_design/d/_search/idx":       "search",
+		"/db/_design/d/_view/v/extra/bit": "view",
+	}
+	for path, exp := range tests {
+		if got := operation(path); got != exp {
+			t.Errorf("operation(%q) = %q, expected %q", path, got, exp)
+		}
+	}
+}
+
+type recordingMetrics struct {
+	requests   []RequestInfo
+	reconnects []string
+	stale      []string
+}
+
+func (m *recordingMetrics) ObserveRequest(ri RequestInfo) {
+	m.requests = append(m.requests, ri)
+}
+
+func (m *recordingMetrics) ObserveChangesReconnect(db string) {
+	m.reconnects = append(m.reconnects, db)
+}
+
+func (m *recordingMetrics) ObserveChangesStale(db string) {
+	m.stale = append(m.stale, db)
+}
+
+func TestMetricsObserveRequest(t *testing.T) {
+	defer uninstallFakeHTTP(installFakeHTTP(couchtest.NewTransport(docResponse(`{"a": 1}`))))
+
+	m := &recordingMetrics{}
+	d := Database{Name: "db"}.WithMetrics(m)
+	if err := d.Retrieve("x", &map[string]int{}); err != nil {
+		t.Fatalf("Error retrieving: %v", err)
+	}
+	if len(m.requests) != 1 {
+		t.Fatalf("Expected one request observed, got %v", m.requests)
+	}
+	ri := m.requests[0]
+	if ri.Operation != "document" || ri.Method != "GET" ||
+		ri.StatusCode != http.StatusOK || ri.BytesReceived != 8 {
+		t.Errorf("Unexpected request info: %+v", ri)
+	}
+}
+
+func TestMetricsChangesReconnect(t *testing.T) {
+	m := &recordingMetrics{}
+	d := Database{
+		changesDialer:    makeEmptyMock(),
+		changesFailDelay: 5,
+		Host:             "localhost",
+		Name:             "db",
+	}.WithMetrics(m)
+
+	// The mock fails the first connection.
+	if err := d.Changes(func(io.Reader) int64 { return -1 }, nil); err != nil {
+		t.Fatalf("Error in changes: %v", err)
+	}
+	if len(m.reconnects) != 1 || m.reconnects[0] != "db" {
+		t.Errorf("Expected one reconnect of db, got %v", m.reconnects)
+	}
+	if len(m.requests) != 2 || m.requests[0].Err == nil ||
+		m.requests[1].Operation != "changes" {
+		t.Errorf("Expected a failed and a successful request, got %+v", m.requests)
+	}
+}
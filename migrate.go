@@ -0,0 +1,151 @@
+package couch
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Migration is a version of the application's design documents: one
+// to put in place, and older ones it replaces.
+type Migration struct {
+	// Version orders migrations.  Each must be unique.
+	Version int
+	// ID of the design document, e.g. "_design/app_v2".
+	ID string
+	// Doc is the design document, which must encode as a JSON object.
+	// Any "_id" or "_rev" is ignored.
+	Doc interface{}
+	// Replaces lists design documents to delete once Doc is in place.
+	Replaces []string
+}
+
+// Migrator applies design document migrations that haven't been
+// applied to a database yet.  Register each migration, then call
+// Database.Migrate.
+type Migrator struct {
+	// RecordID is the local document recording which versions have been
+	// applied.  Defaults to "_local/migrations".
+	RecordID string
+	// Warm builds the views of each new design document, by querying
+	// them, before deleting the documents it replaces, so queries of
+	// the new views needn't wait.  Building a large index can take a
+	// long time; see Timeouts.
+	Warm bool
+
+	migrations []Migration
+}
+
+// Register adds a migration.
+func (m *Migrator) Register(mig Migration) {
+	m.migrations = append(m.migrations, mig)
+}
+
+const defaultMigrationRecord = "_local/migrations"
+
+type migrationRecord struct {
+	Rev     string `json:"_rev,omitempty"`
+	Applied []int  `json:"applied"`
+}
+
+// Migrate applies the migrations registered with m that haven't been
+// applied to the database, in order of version, returning the versions
+// applied.  Each is recorded once done, so after a failure, Migrate
+// may simply be called again.
+func (p Database) Migrate(m *Migrator) ([]int, error) {
+	migs := append([]Migration(nil), m.migrations...)
+	sort.SliceStable(migs, func(i, j int) bool { return migs[i].Version < migs[j].Version })
+	for i := 1; i < len(migs); i++ {
+		if migs[i].Version == migs[i-1].Version {
+			return nil, fmt.Errorf("couch: duplicate migration version %d", migs[i].Version)
+		}
+	}
+
+	recordID := m.RecordID
+	if recordID == "" {
+		recordID = defaultMigrationRecord
+	}
+	rec := migrationRecord{}
+	if err := p.unmarshalURL(p.docURL(recordID), &rec); err != nil &&
+		!errors.Is(err, ErrNotFound) {
+		return nil, err
+	}
+	done := map[int]bool{}
+	for _, v := range rec.Applied {
+		done[v] = true
+	}
+
+	var applied []int
+	for _, mig := range migs {
+		if done[mig.Version] {
+			continue
+		}
+		if err := p.migrate(mig, m.Warm); err != nil {
+			return applied, fmt.Errorf("migration %d: %w", mig.Version, err)
+		}
+		rec.Applied = append(rec.Applied, mig.Version)
+		body, err := json.Marshal(rec)
+		if err != nil {
+			return applied, err
+		}
+		if rec.Rev, err = p.put(recordID, body); err != nil {
+			return applied, err
+		}
+		applied = append(applied, mig.Version)
+	}
+	return applied, nil
+}
+
+// migrate applies a single migration.
+func (p Database) migrate(mig Migration, warm bool) error {
+	if !strings.HasPrefix(mig.ID, "_design/") {
+		return fmt.Errorf("%q isn't a design document id", mig.ID)
+	}
+	full, err := json.Marshal(mig.Doc)
+	if err != nil {
+		return err
+	}
+	body, _, _, err := stripIDRev(full)
+	if err != nil {
+		return err
+	}
+	rev, err := p.Rev(mig.ID)
+	if err != nil && !errors.Is(err, ErrNotFound) {
+		return err
+	}
+	if _, err := p.put(mig.ID, withIDRev(body, mig.ID, rev)); err != nil {
+		return err
+	}
+
+	if warm {
+		ddoc := struct {
+			Views map[string]json.RawMessage `json:"views"`
+		}{}
+		if err := json.Unmarshal(body, &ddoc); err != nil {
+			return err
+		}
+		for name := range ddoc.Views {
+			err := p.Query(mig.ID+"/_view/"+name,
+				map[string]interface{}{"limit": 0}, &struct{}{})
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	for _, old := range mig.Replaces {
+		rev, err := p.Rev(old)
+		if errors.Is(err, ErrNotFound) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		if err := p.Delete(old, rev); err != nil {
+			return err
+		}
+	}
+	return nil
+}
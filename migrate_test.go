@@ -0,0 +1,127 @@
+package couch
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/dustin/go-couch/couchtest"
+)
+
+func notFoundResponse() *http.Response {
+	return couchtest.Response(404, `{"error": "not_found", "reason": "missing"}`)
+}
+
+func TestMigrate(t *testing.T) {
+	etag := func(rev string) *http.Response {
+		r := docResponse("")
+		r.Header = http.Header{"Etag": []string{`"` + rev + `"`}}
+		return r
+	}
+	f := &couchtest.Transport{Responses: []*http.Response{
+		docResponse(`{"_rev": "0-1", "applied": [1]}`),
+		etag("3-old"), // _design/app_v2 exists
+		docResponse(`{"ok": true, "rev": "4-new"}`),
+		docResponse(`{"total_rows": 0, "rows": []}`),
+		etag("1-v1"),
+		docResponse(`{"ok": true}`),
+		docResponse(`{"ok": true, "rev": "0-2"}`),
+	}}
+	defer uninstallFakeHTTP(installFakeHTTP(f))
+
+	m := &Migrator{Warm: true}
+	m.Register(Migration{Version: 2, ID: "_design/app_v2",
+		Doc: map[string]interface{}{
+			"_rev":  "ignored",
+			"views": map[string]interface{}{"by_type": map[string]string{"map": "function(doc) {}"}},
+		},
+		Replaces: []string{"_design/app_v1"}})
+	m.Register(Migration{Version: 1, ID: "_design/app_v1", Doc: map[string]interface{}{}})
+
+	d := Database{Host: "localhost", Port: "5984", Name: "db"}
+	applied, err := d.Migrate(m)
+	if err != nil {
+		t.Fatalf("Error migrating: %v", err)
+	}
+	if !reflect.DeepEqual(applied, []int{2}) {
+		t.Errorf("Expected version 2 applied, got %v", applied)
+	}
+
+	paths := []string{}
+	for _, r := range f.Requests {
+		paths = append(paths, r.Method+" "+r.URL.Path)
+	}
+	exp := []string{
+		"GET /db/_local/migrations",
+		"HEAD /db/_design/app_v2",
+		"PUT /db/_design/app_v2",
+		"GET /db/_design/app_v2/_view/by_type",
+		"HEAD /db/_design/app_v1",
+		"DELETE /db/_design/app_v1",
+		"PUT /db/_local/migrations",
+	}
+	if !reflect.DeepEqual(paths, exp) {
+		t.Fatalf("Expected requests\n%v\ngot\n%v", exp, paths)
+	}
+
+	ddoc := map[string]interface{}{}
+	must(json.NewDecoder(f.Requests[2].Body).Decode(&ddoc))
+	if ddoc["_rev"] != "3-old" || ddoc["_id"] != "_design/app_v2" {
+		t.Errorf("Expected the design doc written over 3-old, got %v", ddoc)
+	}
+	rec := map[string]interface{}{}
+	must(json.NewDecoder(f.Requests[6].Body).Decode(&rec))
+	if !reflect.DeepEqual(rec, map[string]interface{}{
+		"_rev": "0-1", "applied": []interface{}{1.0, 2.0}}) {
+		t.Errorf("Unexpected record %v", rec)
+	}
+}
+
+func TestMigrateFresh(t *testing.T) {
+	f := &couchtest.Transport{Responses: []*http.Response{
+		notFoundResponse(),
+		notFoundResponse(),
+		docResponse(`{"ok": true, "rev": "1-a"}`),
+		docResponse(`{"ok": true, "rev": "0-1"}`),
+	}}
+	defer uninstallFakeHTTP(installFakeHTTP(f))
+
+	m := &Migrator{RecordID: "_local/schema"}
+	m.Register(Migration{Version: 1, ID: "_design/app", Doc: map[string]string{}})
+	applied, err := Database{Name: "db"}.Migrate(m)
+	if err != nil {
+		t.Fatalf("Error migrating: %v", err)
+	}
+	if !reflect.DeepEqual(applied, []int{1}) {
+		t.Errorf("Expected version 1 applied, got %v", applied)
+	}
+	ddoc, _ := ioutil.ReadAll(f.Requests[2].Body)
+	if string(ddoc) != `{"_id":"_design/app"}` {
+		t.Errorf("Unexpected design doc %s", ddoc)
+	}
+	if f.Requests[3].URL.Path != "/db/_local/schema" {
+		t.Errorf("Unexpected record request %v", f.Requests[3].URL)
+	}
+}
+
+func TestMigrateErrors(t *testing.T) {
+	m := &Migrator{}
+	m.Register(Migration{Version: 1, ID: "_design/a"})
+	m.Register(Migration{Version: 1, ID: "_design/b"})
+	if _, err := (Database{}).Migrate(m); err == nil ||
+		!strings.Contains(err.Error(), "duplicate") {
+		t.Errorf("Expected duplicate version error, got %v", err)
+	}
+
+	f := couchtest.NewTransport(notFoundResponse())
+	defer uninstallFakeHTTP(installFakeHTTP(f))
+	m = &Migrator{}
+	m.Register(Migration{Version: 1, ID: "app", Doc: map[string]string{}})
+	if _, err := (Database{}).Migrate(m); err == nil ||
+		!strings.Contains(err.Error(), "design document") {
+		t.Errorf("Expected bad id error, got %v", err)
+	}
+}
@@ -0,0 +1,67 @@
+package couch
+
+import (
+	"encoding/json"
+)
+
+// WriteRevision stores d as the revision named by its "_rev", rather
+// than as a new revision of the document with its "_id"
+// (new_edits=false), as replication does.  Include a "_revisions"
+// field (see Revisions) to graft the revision onto its history;
+// without one it starts a new branch.  Writing a revision that's
+// already stored does nothing, and revisions never conflict: the
+// document's winning revision is chosen as usual.
+func (p Database) WriteRevision(d interface{}) error {
+	jsonBuf, err := p.marshal(d)
+	if err != nil {
+		return err
+	}
+	id, rev, err := docIDRev(jsonBuf)
+	switch {
+	case err != nil:
+		return err
+	case id == "":
+		return errNoID
+	case rev == "":
+		return errNoRev
+	}
+	_, err = p.interact("PUT", p.docURL(id)+"?new_edits=false", p.defaultHdrs,
+		jsonBuf, &Response{})
+	return err
+}
+
+// BulkWriteRevisions is WriteRevision for several documents in a single
+// _bulk_docs request.  It returns responses only for the documents that
+// couldn't be written.
+func (p Database) BulkWriteRevisions(docs []interface{}) ([]Response, error) {
+	raw := make([]json.RawMessage, len(docs))
+	for i, d := range docs {
+		var err error
+		if raw[i], err = p.marshal(d); err != nil {
+			return nil, err
+		}
+	}
+	return p.bulkWriteRevisions(raw)
+}
+
+func (p Database) bulkWriteRevisions(docs []json.RawMessage) ([]Response, error) {
+	body, err := json.Marshal(map[string]interface{}{
+		"docs":      docs,
+		"new_edits": false,
+	})
+	if err != nil {
+		return nil, err
+	}
+	results := []Response{}
+	if _, err := p.interact("POST", p.dbURL("_bulk_docs"), p.defaultHdrs,
+		body, &results); err != nil {
+		return nil, err
+	}
+	var failed []Response
+	for _, r := range results {
+		if r.Error != "" {
+			failed = append(failed, r)
+		}
+	}
+	return failed, nil
+}
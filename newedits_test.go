@@ -0,0 +1,71 @@
+package couch
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/dustin/go-couch/couchtest"
+)
+
+func TestWriteRevision(t *testing.T) {
+	f := couchtest.NewTransport(&http.Response{StatusCode: 201, Body: ioutil.NopCloser(
+		strings.NewReader(`{"ok": true, "id": "a", "rev": "3-c"}`))})
+	defer uninstallFakeHTTP(installFakeHTTP(f))
+
+	doc := struct {
+		Document
+		Revisions Revisions `json:"_revisions"`
+		N         int       `json:"n"`
+	}{Document{ID: "a", Rev: "3-c"}, Revisions{Start: 3, IDs: []string{"c", "b"}}, 1}
+	if err := (Database{Name: "db"}).WriteRevision(doc); err != nil {
+		t.Fatalf("Error writing revision: %v", err)
+	}
+
+	req := f.Requests[0]
+	sent := map[string]interface{}{}
+	must(json.NewDecoder(req.Body).Decode(&sent))
+	if req.Method != "PUT" || req.URL.Path != "/db/a" ||
+		req.URL.Query().Get("new_edits") != "false" ||
+		sent["_rev"] != "3-c" || sent["_revisions"] == nil {
+		t.Errorf("Unexpected request %v %v %v", req.Method, req.URL, sent)
+	}
+}
+
+func TestWriteRevisionNoRev(t *testing.T) {
+	d := Database{}
+	if err := d.WriteRevision(map[string]string{"_rev": "1-a"}); err != errNoID {
+		t.Errorf("Expected errNoID, got %v", err)
+	}
+	if err := d.WriteRevision(map[string]string{"_id": "a"}); err != errNoRev {
+		t.Errorf("Expected errNoRev, got %v", err)
+	}
+}
+
+func TestBulkWriteRevisions(t *testing.T) {
+	f := couchtest.NewTransport(&http.Response{StatusCode: 201, Body: ioutil.NopCloser(strings.NewReader(
+		`[{"id": "b", "rev": "1-b", "error": "forbidden", "reason": "no"}]`))})
+	defer uninstallFakeHTTP(installFakeHTTP(f))
+
+	failed, err := Database{Name: "db"}.BulkWriteRevisions([]interface{}{
+		map[string]string{"_id": "a", "_rev": "2-a"},
+		map[string]string{"_id": "b", "_rev": "1-b"},
+	})
+	if err != nil {
+		t.Fatalf("Error writing revisions: %v", err)
+	}
+	if len(failed) != 1 || failed[0].ID != "b" || failed[0].Error != "forbidden" {
+		t.Errorf("Unexpected failures %+v", failed)
+	}
+
+	sent := struct {
+		Docs     []map[string]string `json:"docs"`
+		NewEdits *bool               `json:"new_edits"`
+	}{}
+	must(json.NewDecoder(f.Requests[0].Body).Decode(&sent))
+	if len(sent.Docs) != 2 || sent.NewEdits == nil || *sent.NewEdits {
+		t.Errorf("Unexpected request %+v", sent)
+	}
+}
@@ -0,0 +1,73 @@
+package couch
+
+import (
+	"encoding/json"
+	"strings"
+)
+
+// LocalNode names whichever node of the server handles a request, for
+// the node-level APIs.
+const LocalNode = "_local"
+
+// nodeURL returns the URL of the given path under the node, escaping
+// each segment.
+func (p Database) nodeURL(node string, path ...string) string {
+	segments := []string{"_node", pathEscape(node)}
+	for _, s := range path {
+		segments = append(segments, pathEscape(s))
+	}
+	return p.serverURL(strings.Join(segments, "/"))
+}
+
+// NodeConfig is a node's configuration, as values by key within
+// sections, e.g. c["couchdb"]["max_document_size"].
+type NodeConfig map[string]map[string]string
+
+// GetNodeConfig returns the whole configuration of the node (e.g.
+// LocalNode), ignoring Database.Name.  Reading and changing the
+// configuration needs a server admin.
+func (p Database) GetNodeConfig(node string) (NodeConfig, error) {
+	c := NodeConfig{}
+	err := p.unmarshalURL(p.nodeURL(node, "_config"), &c)
+	return c, err
+}
+
+// GetConfigSection returns the values of a section of the node's
+// configuration.
+func (p Database) GetConfigSection(node, section string) (map[string]string, error) {
+	values := map[string]string{}
+	err := p.unmarshalURL(p.nodeURL(node, "_config", section), &values)
+	return values, err
+}
+
+// GetConfigValue returns a value of the node's configuration.  A value
+// that isn't set returns an error matching ErrNotFound.
+func (p Database) GetConfigValue(node, section, key string) (string, error) {
+	var value string
+	err := p.unmarshalURL(p.nodeURL(node, "_config", section, key), &value)
+	return value, err
+}
+
+// SetConfigValue sets a value of the node's configuration, returning
+// the value it replaced, if any.  Changes take effect immediately, and
+// persist.
+func (p Database) SetConfigValue(node, section, key, value string) (string, error) {
+	body, err := json.Marshal(value)
+	if err != nil {
+		return "", err
+	}
+	var old string
+	_, err = p.interact("PUT", p.nodeURL(node, "_config", section, key),
+		p.defaultHdrs, body, &old)
+	return old, err
+}
+
+// DeleteConfigValue removes a value from the node's configuration,
+// returning what it was.  A value that isn't set returns an error
+// matching ErrNotFound.
+func (p Database) DeleteConfigValue(node, section, key string) (string, error) {
+	var old string
+	_, err := p.interact("DELETE", p.nodeURL(node, "_config", section, key),
+		p.defaultHdrs, nil, &old)
+	return old, err
+}
@@ -0,0 +1,79 @@
+package couch
+
+import (
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/dustin/go-couch/couchtest"
+)
+
+func TestGetNodeConfig(t *testing.T) {
+	f := &couchtest.Transport{Responses: []*http.Response{
+		docResponse(`{"couchdb": {"max_document_size": "8000000"},
+			"log": {"level": "info"}}`),
+		docResponse(`{"level": "info"}`),
+		docResponse(`"info"`),
+	}}
+	defer uninstallFakeHTTP(installFakeHTTP(f))
+
+	d := Database{Name: "db"}
+	c, err := d.GetNodeConfig(LocalNode)
+	if err != nil || c["couchdb"]["max_document_size"] != "8000000" {
+		t.Errorf("Unexpected config %v, %v", c, err)
+	}
+	section, err := d.GetConfigSection(LocalNode, "log")
+	if err != nil || section["level"] != "info" {
+		t.Errorf("Unexpected section %v, %v", section, err)
+	}
+	v, err := d.GetConfigValue(LocalNode, "log", "level")
+	if err != nil || v != "info" {
+		t.Errorf("Unexpected value %q, %v", v, err)
+	}
+
+	for i, exp := range []string{
+		"/_node/_local/_config", "/_node/_local/_config/log",
+		"/_node/_local/_config/log/level",
+	} {
+		if got := f.Requests[i].URL.Path; got != exp {
+			t.Errorf("Request %v: expected %v, got %v", i, exp, got)
+		}
+	}
+}
+
+func TestSetConfigValue(t *testing.T) {
+	f := &couchtest.Transport{Responses: []*http.Response{
+		docResponse(`"info"`), docResponse(`"debug"`)}}
+	defer uninstallFakeHTTP(installFakeHTTP(f))
+
+	d := Database{}
+	old, err := d.SetConfigValue(LocalNode, "log", "level", "debug")
+	if err != nil || old != "info" {
+		t.Errorf("Unexpected old value %q, %v", old, err)
+	}
+	old, err = d.DeleteConfigValue(LocalNode, "log", "level")
+	if err != nil || old != "debug" {
+		t.Errorf("Unexpected old value %q, %v", old, err)
+	}
+
+	body, _ := ioutil.ReadAll(f.Requests[0].Body)
+	if f.Requests[0].Method != "PUT" || string(body) != `"debug"` ||
+		f.Requests[1].Method != "DELETE" {
+		t.Errorf("Unexpected requests %v %s, %v",
+			f.Requests[0].Method, body, f.Requests[1].Method)
+	}
+}
+
+func TestGetConfigValueMissing(t *testing.T) {
+	defer uninstallFakeHTTP(installFakeHTTP(couchtest.NewTransport(&http.Response{
+		StatusCode: 404,
+		Body: ioutil.NopCloser(strings.NewReader(
+			`{"error": "not_found", "reason": "unknown_config_value"}`)),
+	})))
+	_, err := Database{}.GetConfigValue(LocalNode, "x", "y")
+	if !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected not found, got %v", err)
+	}
+}
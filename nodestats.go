@@ -0,0 +1,106 @@
+package couch
+
+import "encoding/json"
+
+// Stat is a statistic of a node: a counter, a gauge, or a histogram of
+// recent measurements.
+type Stat struct {
+	Type string // "counter", "gauge" or "histogram"
+	Desc string
+	// The count or level, for a counter or gauge.
+	Value float64
+	// The distribution, for a histogram.
+	Histogram *Histogram
+}
+
+// Histogram summarizes a distribution of measurements, e.g. of
+// request times in milliseconds.
+type Histogram struct {
+	N      int64   `json:"n"`
+	Min    float64 `json:"min"`
+	Max    float64 `json:"max"`
+	Mean   float64 `json:"arithmetic_mean"`
+	Median float64 `json:"median"`
+	StdDev float64 `json:"standard_deviation"`
+	// Values by percentile, e.g. Percentiles[99.9].
+	Percentiles map[float64]float64 `json:"-"`
+}
+
+func (s *Stat) UnmarshalJSON(data []byte) error {
+	raw := struct {
+		Type  string          `json:"type"`
+		Desc  string          `json:"desc"`
+		Value json.RawMessage `json:"value"`
+	}{}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	*s = Stat{Type: raw.Type, Desc: raw.Desc}
+	if raw.Type != "histogram" {
+		return json.Unmarshal(raw.Value, &s.Value)
+	}
+	h := struct {
+		Histogram
+		Percentile [][2]float64 `json:"percentile"`
+	}{}
+	if err := json.Unmarshal(raw.Value, &h); err != nil {
+		return err
+	}
+	s.Histogram = &h.Histogram
+	s.Histogram.Percentiles = map[float64]float64{}
+	for _, p := range h.Percentile {
+		// The 99.9th percentile is given as 999.
+		pct := p[0]
+		if pct > 100 {
+			pct /= 10
+		}
+		s.Histogram.Percentiles[pct] = p[1]
+	}
+	return nil
+}
+
+// NodeStats holds a node's statistics by their path in its statistics
+// tree, joined with dots, e.g. s["couchdb.httpd.requests"].
+type NodeStats map[string]Stat
+
+// GetNodeStats returns all the statistics of the node (e.g.
+// LocalNode), ignoring Database.Name.
+func (p Database) GetNodeStats(node string) (NodeStats, error) {
+	var tree map[string]json.RawMessage
+	if err := p.unmarshalURL(p.nodeURL(node, "_stats"), &tree); err != nil {
+		return nil, err
+	}
+	stats := NodeStats{}
+	return stats, stats.add("", tree)
+}
+
+// add adds the statistics of a subtree, whose path is prefix.
+func (s NodeStats) add(prefix string, tree map[string]json.RawMessage) error {
+	for k, v := range tree {
+		node := map[string]json.RawMessage{}
+		if err := json.Unmarshal(v, &node); err != nil {
+			return err
+		}
+		if _, leaf := node["type"]; leaf {
+			stat := Stat{}
+			if err := json.Unmarshal(v, &stat); err != nil {
+				return err
+			}
+			s[prefix+k] = stat
+			continue
+		}
+		if err := s.add(prefix+k+".", node); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// GetNodeStat returns a single statistic of the node, by its path,
+// e.g. GetNodeStat(LocalNode, "couchdb", "request_time").  A statistic
+// that doesn't exist returns an error matching ErrNotFound.
+func (p Database) GetNodeStat(node string, path ...string) (Stat, error) {
+	stat := Stat{}
+	err := p.unmarshalURL(p.nodeURL(node, append([]string{"_stats"}, path...)...), &stat)
+	return stat, err
+}
@@ -0,0 +1,69 @@
+package couch
+
+import (
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/dustin/go-couch/couchtest"
+)
+
+const histogramStat = `{"value": {"min": 1, "max": 40, "arithmetic_mean": 5.5,
+	"median": 4, "standard_deviation": 2.5, "n": 100,
+	"percentile": [[50, 4], [99, 30], [999, 39]]},
+	"type": "histogram", "desc": "length of a request inside CouchDB without MochiWeb"}`
+
+func TestGetNodeStats(t *testing.T) {
+	f := couchtest.NewTransport(docResponse(`{"couchdb": {
+		"open_databases": {"value": 12, "type": "counter", "desc": "number of open databases"},
+		"httpd": {"requests": {"value": 3000, "type": "counter", "desc": "number of HTTP requests"}},
+		"request_time": ` + histogramStat + `}}`))
+	defer uninstallFakeHTTP(installFakeHTTP(f))
+
+	stats, err := Database{Name: "db"}.GetNodeStats(LocalNode)
+	if err != nil {
+		t.Fatalf("Error getting stats: %v", err)
+	}
+	if len(stats) != 3 || stats["couchdb.open_databases"].Value != 12 ||
+		stats["couchdb.httpd.requests"].Value != 3000 ||
+		stats["couchdb.httpd.requests"].Type != "counter" {
+		t.Errorf("Unexpected stats %+v", stats)
+	}
+	h := stats["couchdb.request_time"].Histogram
+	if h == nil || h.N != 100 || h.Mean != 5.5 || h.Max != 40 ||
+		h.Percentiles[99] != 30 || h.Percentiles[99.9] != 39 {
+		t.Errorf("Unexpected histogram %+v", h)
+	}
+	if got := f.Requests[0].URL.Path; got != "/_node/_local/_stats" {
+		t.Errorf("Unexpected path %v", got)
+	}
+}
+
+func TestGetNodeStat(t *testing.T) {
+	f := couchtest.NewTransport(docResponse(histogramStat))
+	defer uninstallFakeHTTP(installFakeHTTP(f))
+
+	stat, err := (Database{}).GetNodeStat("couchdb@node1", "couchdb", "request_time")
+	if err != nil {
+		t.Fatalf("Error getting stat: %v", err)
+	}
+	if stat.Type != "histogram" || stat.Histogram == nil || stat.Histogram.Median != 4 {
+		t.Errorf("Unexpected stat %+v", stat)
+	}
+	if got := f.Requests[0].URL.Path; got != "/_node/couchdb@node1/_stats/couchdb/request_time" {
+		t.Errorf("Unexpected path %v", got)
+	}
+}
+
+func TestGetNodeStatMissing(t *testing.T) {
+	defer uninstallFakeHTTP(installFakeHTTP(couchtest.NewTransport(&http.Response{
+		StatusCode: 404,
+		Body: ioutil.NopCloser(strings.NewReader(
+			`{"error": "not_found", "reason": "could not find stat"}`)),
+	})))
+	if _, err := (Database{}).GetNodeStat(LocalNode, "nope"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected not found, got %v", err)
+	}
+}
@@ -0,0 +1,73 @@
+package couch
+
+import "encoding/json"
+
+// SystemInfo is the state of a node's Erlang VM.
+type SystemInfo struct {
+	Uptime int64 `json:"uptime"` // seconds
+	// Bytes of memory used, by kind.
+	Memory struct {
+		Other         int64 `json:"other"`
+		Atom          int64 `json:"atom"`
+		AtomUsed      int64 `json:"atom_used"`
+		Processes     int64 `json:"processes"`
+		ProcessesUsed int64 `json:"processes_used"`
+		Binary        int64 `json:"binary"`
+		Code          int64 `json:"code"`
+		ETS           int64 `json:"ets"`
+	} `json:"memory"`
+	// Processes waiting to run, a measure of how busy the node is.
+	RunQueue         int64 `json:"run_queue"`
+	RunQueueDirtyCPU int64 `json:"run_queue_dirty_cpu"`
+	ProcessCount     int64 `json:"process_count"`
+	ProcessLimit     int64 `json:"process_limit"`
+	ETSTableCount    int64 `json:"ets_table_count"`
+	// External processes, e.g. JavaScript query servers.
+	OSProcCount    int64 `json:"os_proc_count"`
+	StaleProcCount int64 `json:"stale_proc_count"`
+	// Messages waiting for the node's important processes, by process
+	// or kind of process.  Long queues mean they're falling behind.
+	MessageQueues           map[string]MessageQueue `json:"message_queues"`
+	InternalReplicationJobs int64                   `json:"internal_replication_jobs"`
+
+	ContextSwitches        int64 `json:"context_switches"`
+	Reductions             int64 `json:"reductions"`
+	GarbageCollectionCount int64 `json:"garbage_collection_count"`
+	WordsReclaimed         int64 `json:"words_reclaimed"`
+	IOInput                int64 `json:"io_input"`
+	IOOutput               int64 `json:"io_output"`
+}
+
+// MessageQueue is the length of a process's message queue, or a
+// summary of those of a kind of process, e.g. one per open file.
+type MessageQueue struct {
+	Processes int64 // 1 for a single process
+	Median    int64
+	Max       int64
+}
+
+func (q *MessageQueue) UnmarshalJSON(data []byte) error {
+	var n int64
+	if json.Unmarshal(data, &n) == nil {
+		*q = MessageQueue{Processes: 1, Median: n, Max: n}
+		return nil
+	}
+	summary := struct {
+		Count  int64 `json:"count"`
+		Median int64 `json:"50"`
+		Max    int64 `json:"max"`
+	}{}
+	if err := json.Unmarshal(data, &summary); err != nil {
+		return err
+	}
+	*q = MessageQueue{Processes: summary.Count, Median: summary.Median, Max: summary.Max}
+	return nil
+}
+
+// SystemInfo returns the state of the node's (e.g. LocalNode's) VM,
+// ignoring Database.Name.
+func (p Database) SystemInfo(node string) (SystemInfo, error) {
+	info := SystemInfo{}
+	err := p.unmarshalURL(p.nodeURL(node, "_system"), &info)
+	return info, err
+}
@@ -0,0 +1,39 @@
+package couch
+
+import (
+	"testing"
+
+	"github.com/dustin/go-couch/couchtest"
+)
+
+func TestSystemInfo(t *testing.T) {
+	f := couchtest.NewTransport(docResponse(`{"uptime": 3600,
+		"memory": {"other": 1, "atom": 2, "atom_used": 3, "processes": 4000,
+			"processes_used": 3900, "binary": 5, "code": 6, "ets": 7},
+		"run_queue": 2, "run_queue_dirty_cpu": 0, "ets_table_count": 150,
+		"process_count": 900, "process_limit": 262144,
+		"os_proc_count": 3, "stale_proc_count": 0,
+		"message_queues": {"couch_server": 12,
+			"couch_file": {"count": 40, "min": 0, "max": 30, "50": 1, "90": 5, "99": 20}},
+		"internal_replication_jobs": 0, "context_switches": 1000,
+		"distribution": {}}`))
+	defer uninstallFakeHTTP(installFakeHTTP(f))
+
+	info, err := Database{Name: "db"}.SystemInfo(LocalNode)
+	if err != nil {
+		t.Fatalf("Error getting system info: %v", err)
+	}
+	if info.Uptime != 3600 || info.Memory.Processes != 4000 || info.RunQueue != 2 ||
+		info.ProcessCount != 900 || info.OSProcCount != 3 {
+		t.Errorf("Unexpected info %+v", info)
+	}
+	if q := info.MessageQueues["couch_server"]; q != (MessageQueue{1, 12, 12}) {
+		t.Errorf("Unexpected couch_server queue %+v", q)
+	}
+	if q := info.MessageQueues["couch_file"]; q != (MessageQueue{40, 1, 30}) {
+		t.Errorf("Unexpected couch_file queues %+v", q)
+	}
+	if got := f.Requests[0].URL.Path; got != "/_node/_local/_system" {
+		t.Errorf("Unexpected path %v", got)
+	}
+}
@@ -0,0 +1,121 @@
+package couch
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+)
+
+// NouveauIndex defines a Nouveau search index, kept in the "nouveau"
+// field of a design document.  Requires CouchDB 3.4 or later.
+type NouveauIndex struct {
+	// Analyzer for fields not in FieldAnalyzers, e.g. "standard".
+	DefaultAnalyzer string `json:"default_analyzer,omitempty"`
+	// Analyzers by field name, e.g. {"sku": "keyword"}.
+	FieldAnalyzers map[string]string `json:"field_analyzers,omitempty"`
+	// Function indexing each document, e.g.
+	// function(doc) { index("text", "name", doc.name); }
+	Index string `json:"index"`
+}
+
+// PutNouveauIndex adds the named index to a design document (named
+// with or without the "_design/" prefix), or replaces it, creating the
+// design document if it doesn't exist.  It returns the design
+// document's new revision.
+func (p Database) PutNouveauIndex(ddoc, name string, idx NouveauIndex) (string, error) {
+	id := "_design/" + strings.TrimPrefix(ddoc, "_design/")
+	indexes := map[string]interface{}{name: idx}
+	rev, err := p.Patch(id, map[string]interface{}{"nouveau": indexes})
+	if errors.Is(err, ErrNotFound) {
+		_, rev, err = p.InsertWith(map[string]interface{}{"nouveau": indexes}, id)
+	}
+	return rev, err
+}
+
+// NouveauRange is a bucket for counting the results of a Nouveau query
+// whose values of a field fall within it.
+type NouveauRange struct {
+	Label        string  `json:"label"`
+	Min          float64 `json:"min"`
+	Max          float64 `json:"max"`
+	MinInclusive bool    `json:"min_inclusive"`
+	MaxInclusive bool    `json:"max_inclusive"`
+}
+
+// NouveauQuery is a query of a Nouveau index (see Nouveau).
+type NouveauQuery struct {
+	// Lucene query, e.g. "name:ann AND age:[30 TO 40]".
+	Query string `json:"q"`
+	// Bookmark of the previous page, to continue from.
+	Bookmark string `json:"bookmark,omitempty"`
+	// Results per page (server default 25).
+	Limit int `json:"limit,omitempty"`
+	// Fields to sort by, e.g. []string{"-age<double>", "name<string>"}.
+	Sort []string `json:"sort,omitempty"`
+	// Include the documents of the results.
+	IncludeDocs bool `json:"include_docs,omitempty"`
+	// String fields to count the distinct values of across all
+	// results.
+	Counts []string `json:"counts,omitempty"`
+	// Numeric fields to count results in ranges of, by field.
+	Ranges map[string][]NouveauRange `json:"ranges,omitempty"`
+	// Use the index as it is, rather than bringing it up to date
+	// first.
+	Stale bool `json:"-"`
+}
+
+// MarshalJSON encodes the query, with "update": false if Stale.
+func (q NouveauQuery) MarshalJSON() ([]byte, error) {
+	type query NouveauQuery
+	v := struct {
+		query
+		Update *bool `json:"update,omitempty"`
+	}{query: query(q)}
+	if q.Stale {
+		v.Update = new(bool)
+	}
+	return json.Marshal(v)
+}
+
+// NouveauHit is a result of a Nouveau query.
+type NouveauHit struct {
+	ID     string                 `json:"id"`
+	Order  []json.RawMessage      `json:"order"`
+	Fields map[string]interface{} `json:"fields"`
+	// With IncludeDocs.
+	Doc json.RawMessage `json:"doc"`
+}
+
+// NouveauResults are the results of a Nouveau query, suitable for the
+// results argument of Nouveau.
+type NouveauResults struct {
+	TotalHits int `json:"total_hits"`
+	// "EQUAL_TO", or "GREATER_THAN_OR_EQUAL_TO" if TotalHits is a
+	// lower bound.
+	TotalHitsRelation string       `json:"total_hits_relation"`
+	Bookmark          string       `json:"bookmark"`
+	Hits              []NouveauHit `json:"hits"`
+	// Value counts by field, with Counts.
+	Counts map[string]map[string]int `json:"counts"`
+	// Range counts by field and label, with Ranges.
+	Ranges map[string]map[string]int `json:"ranges"`
+}
+
+// Nouveau queries the named Nouveau index of a design document (named
+// with or without the "_design/" prefix), unmarshaling the response
+// into results, typically a *NouveauResults.  Pass the response's
+// bookmark in the next query to get the following page.  Requires
+// CouchDB 3.4 or later, with Nouveau enabled.
+func (p Database) Nouveau(ddoc, index string, query NouveauQuery, results interface{}) error {
+	if ddoc == "" || index == "" {
+		return errEmptyView
+	}
+	body, err := json.Marshal(query)
+	if err != nil {
+		return err
+	}
+	ddoc = strings.TrimPrefix(ddoc, "_design/")
+	u := p.dbURL("_design/" + pathEscape(ddoc) + "/_nouveau/" + pathEscape(index))
+	_, err = p.interact("POST", u, p.defaultHdrs, body, results)
+	return err
+}
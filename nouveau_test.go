@@ -0,0 +1,73 @@
+package couch
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/dustin/go-couch/couchtest"
+)
+
+func TestNouveau(t *testing.T) {
+	f := couchtest.NewTransport(docResponse(`{"total_hits": 30,
+		"total_hits_relation": "EQUAL_TO", "bookmark": "W10=",
+		"hits": [{"id": "a", "order": [{"@type": "float", "value": 1.2}],
+			"fields": {"name": "ann"}, "doc": {"_id": "a"}}],
+		"ranges": {"age": {"young": 4}}}`))
+	defer uninstallFakeHTTP(installFakeHTTP(f))
+
+	res := NouveauResults{}
+	err := Database{Name: "db"}.Nouveau("d", "people", NouveauQuery{
+		Query:       "name:ann",
+		IncludeDocs: true,
+		Ranges: map[string][]NouveauRange{"age": {
+			{Label: "young", Max: 30, MaxInclusive: true}}},
+		Stale: true,
+	}, &res)
+	if err != nil {
+		t.Fatalf("Error querying: %v", err)
+	}
+	if res.TotalHits != 30 || len(res.Hits) != 1 || res.Hits[0].ID != "a" ||
+		res.Hits[0].Fields["name"] != "ann" || res.Ranges["age"]["young"] != 4 {
+		t.Errorf("Unexpected results %+v", res)
+	}
+
+	req := f.Requests[0]
+	body, _ := ioutil.ReadAll(req.Body)
+	exp := `{"q":"name:ann","include_docs":true,"ranges":{"age":[{"label":"young",` +
+		`"min":0,"max":30,"min_inclusive":false,"max_inclusive":true}]},"update":false}`
+	if req.Method != "POST" || req.URL.Path != "/db/_design/d/_nouveau/people" ||
+		string(body) != exp {
+		t.Errorf("Unexpected request %v %v %s", req.Method, req.URL, body)
+	}
+}
+
+func TestPutNouveauIndex(t *testing.T) {
+	f := &couchtest.Transport{Responses: []*http.Response{
+		{StatusCode: 404, Body: ioutil.NopCloser(strings.NewReader(
+			`{"error": "not_found", "reason": "missing"}`))},
+		{StatusCode: 201, Body: ioutil.NopCloser(strings.NewReader(
+			`{"ok": true, "id": "_design/d", "rev": "1-a"}`))},
+	}}
+	defer uninstallFakeHTTP(installFakeHTTP(f))
+
+	rev, err := Database{Name: "db"}.PutNouveauIndex("_design/d", "people", NouveauIndex{
+		DefaultAnalyzer: "standard",
+		Index:           `function(doc) { index("text", "name", doc.name); }`,
+	})
+	if err != nil || rev != "1-a" {
+		t.Fatalf("Unexpected %v, %v", rev, err)
+	}
+
+	req := f.Requests[1]
+	sent := struct {
+		Nouveau map[string]NouveauIndex
+	}{}
+	must(json.NewDecoder(req.Body).Decode(&sent))
+	if req.Method != "PUT" || req.URL.Path != "/db/_design/d" ||
+		sent.Nouveau["people"].DefaultAnalyzer != "standard" {
+		t.Errorf("Unexpected request %v %v %+v", req.Method, req.URL, sent)
+	}
+}
@@ -0,0 +1,84 @@
+package couch
+
+import (
+	"encoding/json"
+	"io"
+	"io/ioutil"
+)
+
+// LeafRev is a leaf revision of a document, as returned by OpenRevs.
+type LeafRev struct {
+	Rev     string
+	Deleted bool
+	// Missing is set if the requested revision isn't known.
+	Missing bool
+	// The document at this revision, or nil if it's missing.
+	Doc json.RawMessage
+}
+
+// Decode decodes the document at this revision into d.
+func (l LeafRev) Decode(d interface{}) error {
+	if l.Doc == nil {
+		return ErrNotFound
+	}
+	return json.Unmarshal(l.Doc, d)
+}
+
+// OpenRevs fetches the given revisions of the document matching id, or
+// all of its leaf revisions (open_revs=all) if none are given.  With
+// replication, a document may have several leaves: the winner and its
+// conflicts, and any deleted branches.
+func (p Database) OpenRevs(id string, revs ...string) ([]LeafRev, error) {
+	if id == "" {
+		return nil, errNoID
+	}
+	var open interface{} = "all"
+	if len(revs) > 0 {
+		open = revs
+	}
+	u, err := p.docURLWith(id, map[string]interface{}{"open_revs": open})
+	if err != nil {
+		return nil, err
+	}
+	req, err := createReq(u)
+	if err != nil {
+		return nil, err
+	}
+	// Otherwise the revisions come as multipart/mixed.
+	req.Header.Set("Accept", "application/json")
+
+	res, err := p.do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	defer io.Copy(ioutil.Discard, res.Body)
+	if res.StatusCode != 200 {
+		return nil, newHTTPError(res)
+	}
+
+	results := []struct {
+		OK      json.RawMessage `json:"ok"`
+		Missing string          `json:"missing"`
+	}{}
+	if err := json.NewDecoder(res.Body).Decode(&results); err != nil {
+		return nil, err
+	}
+	leaves := make([]LeafRev, 0, len(results))
+	for _, r := range results {
+		if r.OK == nil {
+			leaves = append(leaves, LeafRev{Rev: r.Missing, Missing: true})
+			continue
+		}
+		meta := struct {
+			Rev     string `json:"_rev"`
+			Deleted bool   `json:"_deleted"`
+		}{}
+		if err := json.Unmarshal(r.OK, &meta); err != nil {
+			return nil, err
+		}
+		leaves = append(leaves, LeafRev{Rev: meta.Rev, Deleted: meta.Deleted,
+			Doc: r.OK})
+	}
+	return leaves, nil
+}
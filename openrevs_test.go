@@ -0,0 +1,67 @@
+package couch
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/dustin/go-couch/couchtest"
+)
+
+func TestOpenRevs(t *testing.T) {
+	f := couchtest.NewTransport(docResponse(`[
+		{"ok": {"_id": "a", "_rev": "2-b", "v": 2}},
+		{"ok": {"_id": "a", "_rev": "2-c", "_deleted": true}},
+		{"missing": "3-x"}
+	]`))
+	defer uninstallFakeHTTP(installFakeHTTP(f))
+
+	leaves, err := Database{Name: "db"}.OpenRevs("a")
+	if err != nil {
+		t.Fatalf("Error fetching open revs: %v", err)
+	}
+	req := f.Requests[0]
+	if req.URL.Query().Get("open_revs") != "all" ||
+		req.Header.Get("Accept") != "application/json" {
+		t.Errorf("Unexpected request %v %v", req.URL, req.Header)
+	}
+	if len(leaves) != 3 {
+		t.Fatalf("Expected 3 leaves, got %+v", leaves)
+	}
+	if leaves[0].Rev != "2-b" || leaves[0].Deleted || leaves[0].Missing {
+		t.Errorf("Unexpected first leaf %+v", leaves[0])
+	}
+	if leaves[1].Rev != "2-c" || !leaves[1].Deleted {
+		t.Errorf("Expected deleted leaf, got %+v", leaves[1])
+	}
+	if leaves[2].Rev != "3-x" || !leaves[2].Missing || leaves[2].Doc != nil {
+		t.Errorf("Expected missing leaf, got %+v", leaves[2])
+	}
+
+	doc := map[string]interface{}{}
+	if err := leaves[0].Decode(&doc); err != nil || doc["v"] != 2.0 {
+		t.Errorf("Unexpected decoded doc %v, %v", doc, err)
+	}
+	if err := leaves[2].Decode(&doc); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected not found decoding missing leaf, got %v", err)
+	}
+}
+
+func TestOpenRevsGiven(t *testing.T) {
+	f := couchtest.NewTransport(docResponse(`[{"ok": {"_id": "a", "_rev": "1-a"}}]`))
+	defer uninstallFakeHTTP(installFakeHTTP(f))
+
+	leaves, err := Database{}.OpenRevs("a", "1-a", "2-b")
+	if err != nil || len(leaves) != 1 {
+		t.Fatalf("Expected one leaf, got %v, %v", leaves, err)
+	}
+	if q := f.Requests[0].URL.Query().Get("open_revs"); q != `["1-a","2-b"]` {
+		t.Errorf("Unexpected open_revs %q", q)
+	}
+	if leaves[0].Rev != "1-a" {
+		t.Errorf("Unexpected leaves %+v", leaves)
+	}
+
+	if _, err := (Database{}).OpenRevs(""); err != errNoID {
+		t.Errorf("Expected 'no ID' error, got %v", err)
+	}
+}
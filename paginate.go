@@ -0,0 +1,97 @@
+package couch
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+)
+
+// ViewPage is a page of a view's rows, from QueryPage.
+type ViewPage struct {
+	Rows []ViewRow
+	// Next is the cursor of the following page, or "" if this is the
+	// last.
+	Next string
+	// With update_seq=true, the database sequence the view reflects.
+	UpdateSeq Sequence
+}
+
+// pageCursor is where a page starts: the key and document id of its
+// first row.
+type pageCursor struct {
+	Key   json.RawMessage `json:"k"`
+	DocID string          `json:"d,omitempty"`
+}
+
+var errBadCursor = errors.New("couch: invalid page cursor")
+
+// QueryPage returns a page of up to limit rows of a view, or of
+// _all_docs, starting at cursor, or at the start for "".  The page's
+// Next cursor is an opaque string that may be handed to clients, e.g.
+// in a web API's response, and given back to fetch the following page.
+//
+// Pages are found by key rather than skipped to, so each costs the same
+// however deep it is, and rows written meanwhile don't shift later
+// pages.  A page fetches one row more than it returns, to start the
+// next, which is found by both its key and document id, so pages split
+// rows emitting the same key correctly.
+//
+// The options are as for Query, except that the cursor replaces any
+// startkey and skip after the first page, and limit is set.  Use the
+// same options for every page.
+func (p Database) QueryPage(view string, options map[string]interface{},
+	limit int, cursor string) (ViewPage, error) {
+
+	opts := make(map[string]interface{}, len(options)+3)
+	for k, v := range options {
+		opts[k] = v
+	}
+	if cursor != "" {
+		c, err := decodeCursor(cursor)
+		if err != nil {
+			return ViewPage{}, err
+		}
+		for _, k := range []string{"startkey", "start_key",
+			"startkey_docid", "start_key_doc_id", "skip"} {
+			delete(opts, k)
+		}
+		opts["startkey"] = rawParam(c.Key)
+		if c.DocID != "" {
+			opts["startkey_docid"] = c.DocID
+		}
+	}
+	if limit < 1 {
+		limit = 1
+	}
+	opts["limit"] = limit + 1
+
+	rows, err := p.QueryView(view, opts)
+	if err != nil {
+		return ViewPage{}, err
+	}
+	defer rows.Close()
+	page := ViewPage{UpdateSeq: rows.UpdateSeq}
+	for rows.Next() {
+		row := rows.Row()
+		if len(page.Rows) == limit {
+			next, err := json.Marshal(pageCursor{Key: row.Key, DocID: row.ID})
+			if err != nil {
+				return ViewPage{}, err
+			}
+			page.Next = base64.RawURLEncoding.EncodeToString(next)
+			break
+		}
+		page.Rows = append(page.Rows, row)
+	}
+	return page, rows.Err()
+}
+
+// decodeCursor decodes a page cursor from QueryPage.
+func decodeCursor(cursor string) (pageCursor, error) {
+	c := pageCursor{}
+	b, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil || json.Unmarshal(b, &c) != nil || len(c.Key) == 0 {
+		return c, errBadCursor
+	}
+	return c, nil
+}
@@ -0,0 +1,60 @@
+package couch
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/dustin/go-couch/couchtest"
+)
+
+func TestQueryPage(t *testing.T) {
+	f := &couchtest.Transport{Responses: []*http.Response{
+		docResponse(`{"total_rows": 4, "offset": 1, "rows": [
+			{"id": "a", "key": "x", "value": 1},
+			{"id": "b", "key": "y", "value": 2},
+			{"id": "c", "key": "y", "value": 3}]}`),
+		docResponse(`{"total_rows": 4, "offset": 3, "update_seq": 7, "rows": [
+			{"id": "c", "key": "y", "value": 3}]}`),
+	}}
+	defer uninstallFakeHTTP(installFakeHTTP(f))
+
+	d := Database{Name: "db"}
+	opts := map[string]interface{}{"startkey": "x", "skip": 1}
+	page, err := d.QueryPage("_design/d/_view/v", opts, 2, "")
+	if err != nil {
+		t.Fatalf("Error querying: %v", err)
+	}
+	if len(page.Rows) != 2 || page.Rows[1].ID != "b" || page.Next == "" {
+		t.Fatalf("Unexpected page %+v", page)
+	}
+	q := f.Requests[0].URL.Query()
+	if q.Get("limit") != "3" || q.Get("skip") != "1" || q.Get("startkey") != `"x"` {
+		t.Errorf("Unexpected parameters %v", q)
+	}
+
+	// The next page starts at the row sharing the last one's key.
+	page, err = d.QueryPage("_design/d/_view/v", opts, 2, page.Next)
+	if err != nil {
+		t.Fatalf("Error querying: %v", err)
+	}
+	if len(page.Rows) != 1 || page.Rows[0].ID != "c" || page.Next != "" ||
+		page.UpdateSeq != "7" {
+		t.Errorf("Unexpected page %+v", page)
+	}
+	q = f.Requests[1].URL.Query()
+	if q.Get("startkey") != `"y"` || q.Get("startkey_docid") != "c" ||
+		q.Get("skip") != "" || q.Get("limit") != "3" {
+		t.Errorf("Unexpected parameters %v", q)
+	}
+	if len(opts) != 2 {
+		t.Errorf("Expected the options to be left alone, got %v", opts)
+	}
+}
+
+func TestQueryPageBadCursor(t *testing.T) {
+	for _, cursor := range []string{"!!", "bm90IGpzb24", "e30"} {
+		if _, err := (Database{}).QueryPage("v", nil, 10, cursor); err != errBadCursor {
+			t.Errorf("Expected a bad cursor error for %q, got %v", cursor, err)
+		}
+	}
+}
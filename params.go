@@ -0,0 +1,71 @@
+package couch
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+)
+
+// WithParams returns a copy of the database that adds the given query
+// parameters to each of its requests, other than for the changes feed,
+// which takes its own options.  As with RetrieveWith, strings are
+// passed as is, and anything else as JSON.  Parameters a request sets
+// itself take precedence.
+//
+// Together with WithHeader, this covers one-off needs of a single
+// call, e.g.
+//
+//	db.WithParams(map[string]interface{}{"batch": "ok"}).Insert(doc)
+//	db.WithHeader("If-Match", rev).Delete(id, rev)
+func (p Database) WithParams(params map[string]interface{}) Database {
+	merged := make(map[string]interface{}, len(p.params)+len(params))
+	for k, v := range p.params {
+		merged[k] = v
+	}
+	for k, v := range params {
+		merged[k] = v
+	}
+	p.params = merged
+	return p
+}
+
+// encodeParams encodes options as query parameters: strings as is,
+// and anything else as JSON.
+func encodeParams(options map[string]interface{}) (url.Values, error) {
+	values := url.Values{}
+	for k, v := range options {
+		if s, ok := v.(string); ok {
+			values.Set(k, s)
+			continue
+		}
+		b, err := json.Marshal(v)
+		if err != nil {
+			return nil, fmt.Errorf("unsupported value-type %T for %q: %v", v, k, err)
+		}
+		values.Set(k, string(b))
+	}
+	return values, nil
+}
+
+// addParams adds the database's query parameters to req, except those
+// it already has.
+func (p Database) addParams(req *http.Request) error {
+	if len(p.params) == 0 {
+		return nil
+	}
+	values, err := encodeParams(p.params)
+	if err != nil {
+		return err
+	}
+	query := req.URL.Query()
+	for k, v := range values {
+		if _, ok := query[k]; !ok {
+			query[k] = v
+		}
+	}
+	u := *req.URL
+	u.RawQuery = query.Encode()
+	req.URL = &u
+	return nil
+}
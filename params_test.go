@@ -0,0 +1,53 @@
+package couch
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/dustin/go-couch/couchtest"
+)
+
+func TestWithParams(t *testing.T) {
+	f := &couchtest.Transport{Responses: []*http.Response{
+		docResponse(`{"ok": true, "id": "a", "rev": "1-a"}`),
+		docResponse(`{}`), docResponse(`{"rows": []}`), docResponse(`{}`)}}
+	defer uninstallFakeHTTP(installFakeHTTP(f))
+
+	base := Database{Name: "db"}
+	d := base.WithParams(map[string]interface{}{"batch": "ok", "r": 2})
+	if _, _, err := d.Insert(map[string]string{"_id": "a"}); err != nil {
+		t.Fatalf("Error inserting: %v", err)
+	}
+	// The request's own parameters take precedence.
+	err := d.WithParams(map[string]interface{}{"conflicts": true}).
+		RetrieveWith("a", map[string]interface{}{"r": 3}, &struct{}{})
+	if err != nil {
+		t.Fatalf("Error retrieving: %v", err)
+	}
+	err = base.WithParams(map[string]interface{}{"stale": "ok"}).
+		Query("_design/d/_view/v", map[string]interface{}{"limit": 1}, &struct{}{})
+	if err != nil {
+		t.Fatalf("Error querying: %v", err)
+	}
+	if err := base.Retrieve("a", &struct{}{}); err != nil {
+		t.Fatalf("Error retrieving: %v", err)
+	}
+
+	for i, exp := range []string{
+		"batch=ok&r=2",
+		"batch=ok&conflicts=true&r=3",
+		"limit=1&stale=ok",
+		"",
+	} {
+		if got := f.Requests[i].URL.RawQuery; got != exp {
+			t.Errorf("Request %v: expected %q, got %q", i, exp, got)
+		}
+	}
+}
+
+func TestWithParamsBadValue(t *testing.T) {
+	d := Database{}.WithParams(map[string]interface{}{"x": func() {}})
+	if err := d.Retrieve("a", &struct{}{}); err == nil {
+		t.Errorf("Expected an error encoding the parameter")
+	}
+}
@@ -0,0 +1,78 @@
+package couch
+
+import (
+	"encoding/json"
+	"errors"
+)
+
+// Number of times Patch and SetField try to apply a change before
+// giving up on a frequently updated document.
+const maxUpdateAttempts = 10
+
+// Patch applies patch to the document with the given id using JSON
+// merge patch semantics (RFC 7386): nested objects are merged, null
+// values remove fields, and anything else replaces what was there.
+// "_id" and "_rev" in the patch are ignored.
+//
+// The document is fetched, patched and saved, starting over if someone
+// else updates it in the meantime.  The new revision is returned.
+func (p Database) Patch(id string, patch map[string]interface{}) (string, error) {
+	return p.update(id, func(doc map[string]interface{}) error {
+		rev := doc["_rev"]
+		mergePatch(doc, patch)
+		doc["_id"] = id
+		doc["_rev"] = rev
+		return nil
+	})
+}
+
+// retrieveTree retrieves the document with the given id decoded
+// generically, with numbers kept as json.Number, so that what isn't
+// changed is saved as it was, however large its numbers.
+func (p Database) retrieveTree(id string) (interface{}, error) {
+	var raw json.RawMessage
+	if err := p.Retrieve(id, &raw); err != nil {
+		return nil, err
+	}
+	return decodeTree(raw)
+}
+
+// update performs a read-modify-write of the document with the given
+// id, retrying when the write conflicts.
+func (p Database) update(id string, f func(map[string]interface{}) error) (string, error) {
+	for i := 1; ; i++ {
+		tree, err := p.retrieveTree(id)
+		if err != nil {
+			return "", err
+		}
+		doc, ok := tree.(map[string]interface{})
+		if !ok {
+			return "", errNotObject
+		}
+		if err := f(doc); err != nil {
+			return "", err
+		}
+		rev, err := p.Edit(doc)
+		if err == nil || !errors.Is(err, ErrConflict) || i >= maxUpdateAttempts {
+			return rev, err
+		}
+	}
+}
+
+func mergePatch(doc, patch map[string]interface{}) {
+	for k, v := range patch {
+		switch pv := v.(type) {
+		case nil:
+			delete(doc, k)
+		case map[string]interface{}:
+			dv, ok := doc[k].(map[string]interface{})
+			if !ok {
+				dv = map[string]interface{}{}
+			}
+			mergePatch(dv, pv)
+			doc[k] = dv
+		default:
+			doc[k] = v
+		}
+	}
+}
@@ -0,0 +1,131 @@
+package couch
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/dustin/go-couch/couchtest"
+)
+
+func TestMergePatch(t *testing.T) {
+	doc := map[string]interface{}{
+		"a": "b",
+		"c": map[string]interface{}{"d": "e", "f": "g"},
+		"h": "i",
+		"j": []interface{}{1.0},
+	}
+	mergePatch(doc, map[string]interface{}{
+		"a": "z",
+		"c": map[string]interface{}{"f": nil, "n": "o"},
+		"h": nil,
+		"j": map[string]interface{}{"k": "l", "m": nil},
+		"p": 3,
+	})
+	exp := map[string]interface{}{
+		"a": "z",
+		"c": map[string]interface{}{"d": "e", "n": "o"},
+		"j": map[string]interface{}{"k": "l"},
+		"p": 3,
+	}
+	if !reflect.DeepEqual(doc, exp) {
+		t.Errorf("Expected %v, got %v", exp, doc)
+	}
+}
+
+func docResponse(body string) *http.Response {
+	return couchtest.Response(200, body)
+}
+
+func conflictResponse() *http.Response {
+	return couchtest.Response(409, `{"error": "conflict", "reason": "Document update conflict."}`)
+}
+
+func TestPatchRetriesConflict(t *testing.T) {
+	f := &couchtest.Transport{Responses: []*http.Response{
+		docResponse(`{"_id": "x", "_rev": "1-a", "n": 1, "o": {"p": 1}}`),
+		conflictResponse(),
+		docResponse(`{"_id": "x", "_rev": "2-a", "n": 2, "o": {"p": 1}}`),
+		docResponse(`{"ok": true, "id": "x", "rev": "3-a"}`),
+	}}
+	defer uninstallFakeHTTP(installFakeHTTP(f))
+
+	d := Database{Host: "localhost", Port: "5984", Name: "db"}
+	rev, err := d.Patch("x", map[string]interface{}{
+		"o":    map[string]interface{}{"q": 2},
+		"_rev": "bogus",
+	})
+	if err != nil {
+		t.Fatalf("Expected success, got %v", err)
+	}
+	if rev != "3-a" {
+		t.Errorf("Expected rev 3-a, got %v", rev)
+	}
+
+	if len(f.Requests) != 4 {
+		t.Fatalf("Expected 4 requests, got %v", len(f.Requests))
+	}
+	got := map[string]interface{}{}
+	if err := json.NewDecoder(f.Requests[3].Body).Decode(&got); err != nil {
+		t.Fatalf("Error decoding final PUT: %v", err)
+	}
+	exp := map[string]interface{}{
+		"_id": "x", "_rev": "2-a", "n": 2.0,
+		"o": map[string]interface{}{"p": 1.0, "q": 2.0},
+	}
+	if !reflect.DeepEqual(got, exp) {
+		t.Errorf("Expected %v, got %v", exp, got)
+	}
+}
+
+func TestPatchKeepsLargeNumbers(t *testing.T) {
+	f := &couchtest.Transport{Responses: []*http.Response{
+		docResponse(`{"_id": "x", "_rev": "1-a", "big": 9007199254740993, "o": {"ns": [1234567890123456789]}}`),
+		docResponse(`{"ok": true, "id": "x", "rev": "2-a"}`),
+	}}
+	defer uninstallFakeHTTP(installFakeHTTP(f))
+
+	if _, err := (Database{}).Patch("x", map[string]interface{}{"n": 1}); err != nil {
+		t.Fatalf("Expected success, got %v", err)
+	}
+	body, _ := ioutil.ReadAll(f.Requests[1].Body)
+	for _, n := range []string{"9007199254740993", "1234567890123456789"} {
+		if !strings.Contains(string(body), n) {
+			t.Errorf("Expected %v saved unchanged, got %s", n, body)
+		}
+	}
+}
+
+func TestPatchGivesUp(t *testing.T) {
+	f := &couchtest.Transport{}
+	for i := 0; i < maxUpdateAttempts; i++ {
+		f.Responses = append(f.Responses,
+			docResponse(`{"_id": "x", "_rev": "1-a"}`), conflictResponse())
+	}
+	defer uninstallFakeHTTP(installFakeHTTP(f))
+
+	_, err := Database{}.Patch("x", map[string]interface{}{"a": 1})
+	if !errors.Is(err, ErrConflict) {
+		t.Fatalf("Expected conflict, got %v", err)
+	}
+	if len(f.Responses) != 0 {
+		t.Errorf("Expected all attempts to be used, %v responses left",
+			len(f.Responses))
+	}
+}
+
+func TestPatchMissing(t *testing.T) {
+	defer uninstallFakeHTTP(installFakeHTTP(couchtest.NewTransport(&http.Response{
+		StatusCode: 404,
+		Body:       ioutil.NopCloser(strings.NewReader(`{"error": "not_found"}`)),
+	})))
+
+	_, err := Database{}.Patch("x", map[string]interface{}{"a": 1})
+	if !errors.Is(err, ErrNotFound) {
+		t.Fatalf("Expected not found, got %v", err)
+	}
+}
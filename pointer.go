@@ -0,0 +1,130 @@
+package couch
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ErrFieldNotFound is returned by GetField when the pointer doesn't
+// resolve to anything in the document.
+var ErrFieldNotFound = errors.New("couch: field not found")
+
+// GetField returns the value at the given JSON pointer (RFC 6901, e.g.
+// "/limits/max") within the document with the given id.
+func (p Database) GetField(id, pointer string) (json.RawMessage, error) {
+	toks, err := parsePointer(pointer)
+	if err != nil {
+		return nil, err
+	}
+	doc, err := p.retrieveTree(id)
+	if err != nil {
+		return nil, err
+	}
+	v, err := lookupPointer(doc, toks)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(v)
+}
+
+// SetField sets the value at the given JSON pointer within the
+// document with the given id, creating intermediate objects as needed,
+// and returns the new revision.  A final "-" appends to an array.
+//
+// As with Patch, the update is retried if the document changes
+// underneath it.
+func (p Database) SetField(id, pointer string, value interface{}) (string, error) {
+	toks, err := parsePointer(pointer)
+	if err != nil {
+		return "", err
+	}
+	if len(toks) == 0 {
+		return "", errors.New("couch: can't replace the whole document")
+	}
+	return p.update(id, func(doc map[string]interface{}) error {
+		_, err := setPointer(doc, toks, value)
+		return err
+	})
+}
+
+var pointerUnescaper = strings.NewReplacer("~1", "/", "~0", "~")
+
+func parsePointer(pointer string) ([]string, error) {
+	if pointer == "" {
+		return nil, nil
+	}
+	if pointer[0] != '/' {
+		return nil, fmt.Errorf("couch: invalid JSON pointer %q", pointer)
+	}
+	toks := strings.Split(pointer[1:], "/")
+	for i, t := range toks {
+		toks[i] = pointerUnescaper.Replace(t)
+	}
+	return toks, nil
+}
+
+func arrayIndex(tok string, n int) (int, bool) {
+	if tok == "" || (len(tok) > 1 && tok[0] == '0') {
+		return 0, false
+	}
+	i, err := strconv.Atoi(tok)
+	return i, err == nil && i >= 0 && i < n
+}
+
+func lookupPointer(v interface{}, toks []string) (interface{}, error) {
+	for _, tok := range toks {
+		switch c := v.(type) {
+		case map[string]interface{}:
+			var ok bool
+			if v, ok = c[tok]; !ok {
+				return nil, ErrFieldNotFound
+			}
+		case []interface{}:
+			i, ok := arrayIndex(tok, len(c))
+			if !ok {
+				return nil, ErrFieldNotFound
+			}
+			v = c[i]
+		default:
+			return nil, ErrFieldNotFound
+		}
+	}
+	return v, nil
+}
+
+// setPointer returns v with value stored at toks.
+func setPointer(v interface{}, toks []string, value interface{}) (interface{}, error) {
+	if len(toks) == 0 {
+		return value, nil
+	}
+	tok, rest := toks[0], toks[1:]
+	switch c := v.(type) {
+	case nil:
+		return setPointer(map[string]interface{}{}, toks, value)
+	case map[string]interface{}:
+		child, err := setPointer(c[tok], rest, value)
+		if err != nil {
+			return nil, err
+		}
+		c[tok] = child
+		return c, nil
+	case []interface{}:
+		if tok == "-" && len(rest) == 0 {
+			return append(c, value), nil
+		}
+		i, ok := arrayIndex(tok, len(c))
+		if !ok {
+			return nil, fmt.Errorf("couch: invalid array index %q", tok)
+		}
+		child, err := setPointer(c[i], rest, value)
+		if err != nil {
+			return nil, err
+		}
+		c[i] = child
+		return c, nil
+	}
+	return nil, fmt.Errorf("couch: can't set %q in %T", tok, v)
+}
@@ -0,0 +1,151 @@
+package couch
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/dustin/go-couch/couchtest"
+)
+
+const pointerDoc = `{"_id": "cfg", "_rev": "1-a",
+	"a/b": 1, "m~n": 2, "list": [{"x": "y"}, 3], "obj": {"k": "v"}}`
+
+func TestLookupPointer(t *testing.T) {
+	var doc interface{}
+	must(json.Unmarshal([]byte(pointerDoc), &doc))
+
+	tests := []struct {
+		pointer string
+		exp     interface{}
+	}{
+		{"/a~1b", 1.0},
+		{"/m~0n", 2.0},
+		{"/list/0/x", "y"},
+		{"/list/1", 3.0},
+		{"/obj", map[string]interface{}{"k": "v"}},
+	}
+	for _, test := range tests {
+		toks, err := parsePointer(test.pointer)
+		if err != nil {
+			t.Fatalf("Error parsing %q: %v", test.pointer, err)
+		}
+		got, err := lookupPointer(doc, toks)
+		if err != nil || !reflect.DeepEqual(got, test.exp) {
+			t.Errorf("%q: expected %v, got %v, %v", test.pointer, test.exp, got, err)
+		}
+	}
+
+	for _, p := range []string{"/missing", "/list/2", "/list/01", "/list/-", "/obj/k/z"} {
+		toks, _ := parsePointer(p)
+		if _, err := lookupPointer(doc, toks); err != ErrFieldNotFound {
+			t.Errorf("%q: expected ErrFieldNotFound, got %v", p, err)
+		}
+	}
+
+	if _, err := parsePointer("a/b"); err == nil {
+		t.Errorf("Expected error for pointer without leading slash")
+	}
+}
+
+func TestSetPointer(t *testing.T) {
+	var doc map[string]interface{}
+	must(json.Unmarshal([]byte(pointerDoc), &doc))
+
+	sets := []struct {
+		pointer string
+		value   interface{}
+	}{
+		{"/obj/k", "w"},
+		{"/new/deep", true},
+		{"/list/0/x", "z"},
+		{"/list/-", "end"},
+	}
+	for _, s := range sets {
+		toks, _ := parsePointer(s.pointer)
+		if _, err := setPointer(doc, toks, s.value); err != nil {
+			t.Fatalf("Error setting %q: %v", s.pointer, err)
+		}
+	}
+
+	exp := map[string]interface{}{
+		"_id": "cfg", "_rev": "1-a", "a/b": 1.0, "m~n": 2.0,
+		"list": []interface{}{map[string]interface{}{"x": "z"}, 3.0, "end"},
+		"obj":  map[string]interface{}{"k": "w"},
+		"new":  map[string]interface{}{"deep": true},
+	}
+	if !reflect.DeepEqual(doc, exp) {
+		t.Errorf("Expected %v, got %v", exp, doc)
+	}
+
+	for _, p := range []string{"/list/5", "/a~1b/c"} {
+		toks, _ := parsePointer(p)
+		if _, err := setPointer(doc, toks, 1); err == nil {
+			t.Errorf("%q: expected error", p)
+		}
+	}
+}
+
+func TestGetField(t *testing.T) {
+	defer uninstallFakeHTTP(installFakeHTTP(couchtest.NewTransport(docResponse(pointerDoc))))
+
+	got, err := Database{}.GetField("cfg", "/list/0")
+	if err != nil {
+		t.Fatalf("Expected success, got %v", err)
+	}
+	if string(got) != `{"x":"y"}` {
+		t.Errorf("Expected {\"x\":\"y\"}, got %s", got)
+	}
+}
+
+func TestSetField(t *testing.T) {
+	f := &couchtest.Transport{Responses: []*http.Response{
+		docResponse(pointerDoc),
+		docResponse(`{"ok": true, "id": "cfg", "rev": "2-a"}`),
+	}}
+	defer uninstallFakeHTTP(installFakeHTTP(f))
+
+	rev, err := Database{}.SetField("cfg", "/obj/k", 7)
+	if err != nil || rev != "2-a" {
+		t.Fatalf("Expected rev 2-a, got %v, %v", rev, err)
+	}
+
+	got := map[string]interface{}{}
+	if err := json.NewDecoder(f.Requests[1].Body).Decode(&got); err != nil {
+		t.Fatalf("Error decoding PUT: %v", err)
+	}
+	if got["_rev"] != "1-a" || !reflect.DeepEqual(got["obj"],
+		map[string]interface{}{"k": 7.0}) {
+		t.Errorf("Unexpected document written: %v", got)
+	}
+
+	if _, err := (Database{}).SetField("cfg", "", 1); err == nil {
+		t.Errorf("Expected error replacing the whole document")
+	}
+}
+
+func TestFieldLargeNumbers(t *testing.T) {
+	doc := `{"_id": "cfg", "_rev": "1-a", "big": 9007199254740993, "other": 1234567890123456789}`
+	f := &couchtest.Transport{Responses: []*http.Response{
+		docResponse(doc),
+		docResponse(doc),
+		docResponse(`{"ok": true, "id": "cfg", "rev": "2-a"}`),
+	}}
+	defer uninstallFakeHTTP(installFakeHTTP(f))
+
+	got, err := Database{}.GetField("cfg", "/big")
+	if err != nil || string(got) != "9007199254740993" {
+		t.Errorf("Expected 9007199254740993, got %s, %v", got, err)
+	}
+
+	if _, err := (Database{}).SetField("cfg", "/big", 1); err != nil {
+		t.Fatalf("Expected success, got %v", err)
+	}
+	body, _ := ioutil.ReadAll(f.Requests[2].Body)
+	if !strings.Contains(string(body), "1234567890123456789") {
+		t.Errorf("Expected the other field saved unchanged, got %s", body)
+	}
+}
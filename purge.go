@@ -0,0 +1,44 @@
+package couch
+
+import (
+	"encoding/json"
+	"strconv"
+)
+
+// PurgeResult is the outcome of a Purge.
+type PurgeResult struct {
+	// The revisions purged, by document id.
+	Purged map[string][]string `json:"purged"`
+	// The purge sequence afterwards, if the server reports one
+	// (CouchDB 2.3 and later don't).
+	PurgeSeq json.RawMessage `json:"purge_seq"`
+}
+
+// Purge permanently removes the given revisions, by document id, from
+// the database, leaving no tombstone to replicate.  Purging a
+// document's only leaf removes the document entirely.  Unknown
+// revisions are skipped, and won't appear in the result.
+func (p Database) Purge(revs map[string][]string) (PurgeResult, error) {
+	res := PurgeResult{}
+	body, err := json.Marshal(revs)
+	if err != nil {
+		return res, err
+	}
+	_, err = p.interact("POST", p.dbURL("_purge"), p.defaultHdrs, body, &res)
+	return res, err
+}
+
+// PurgedInfosLimit returns how many purges the database remembers so
+// that its indexes and replicas can catch up with them.
+func (p Database) PurgedInfosLimit() (int, error) {
+	var limit int
+	err := p.unmarshalURL(p.dbURL("_purged_infos_limit"), &limit)
+	return limit, err
+}
+
+// SetPurgedInfosLimit sets how many purges the database remembers.
+func (p Database) SetPurgedInfosLimit(limit int) error {
+	_, err := p.interact("PUT", p.dbURL("_purged_infos_limit"), p.defaultHdrs,
+		[]byte(strconv.Itoa(limit)), &Response{})
+	return err
+}
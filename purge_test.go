@@ -0,0 +1,57 @@
+package couch
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/dustin/go-couch/couchtest"
+)
+
+func TestPurge(t *testing.T) {
+	f := couchtest.NewTransport(&http.Response{StatusCode: 201, Body: ioutil.NopCloser(strings.NewReader(
+		`{"purge_seq": null, "purged": {"a": ["1-a"], "b": []}}`))})
+	defer uninstallFakeHTTP(installFakeHTTP(f))
+
+	res, err := Database{Name: "db"}.Purge(map[string][]string{
+		"a": {"1-a"}, "b": {"1-x"},
+	})
+	if err != nil {
+		t.Fatalf("Error purging: %v", err)
+	}
+	exp := map[string][]string{"a": {"1-a"}, "b": {}}
+	if !reflect.DeepEqual(res.Purged, exp) || string(res.PurgeSeq) != "null" {
+		t.Errorf("Unexpected result %+v", res)
+	}
+
+	req := f.Requests[0]
+	sent := map[string][]string{}
+	must(json.NewDecoder(req.Body).Decode(&sent))
+	if req.Method != "POST" || req.URL.Path != "/db/_purge" || len(sent) != 2 {
+		t.Errorf("Unexpected request %v %v %v", req.Method, req.URL, sent)
+	}
+}
+
+func TestPurgedInfosLimit(t *testing.T) {
+	f := &couchtest.Transport{Responses: []*http.Response{
+		docResponse(`1000`), docResponse(`{"ok": true}`)}}
+	defer uninstallFakeHTTP(installFakeHTTP(f))
+
+	d := Database{Name: "db"}
+	limit, err := d.PurgedInfosLimit()
+	if err != nil || limit != 1000 {
+		t.Errorf("Expected 1000, got %v, %v", limit, err)
+	}
+	if err := d.SetPurgedInfosLimit(200); err != nil {
+		t.Fatalf("Error setting limit: %v", err)
+	}
+	req := f.Requests[1]
+	body, _ := ioutil.ReadAll(req.Body)
+	if req.Method != "PUT" || req.URL.Path != "/db/_purged_infos_limit" ||
+		string(body) != "200" {
+		t.Errorf("Unexpected request %v %v %s", req.Method, req.URL, body)
+	}
+}
@@ -0,0 +1,224 @@
+package couch
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+)
+
+var errNotObject = errors.New("couch: document is not a JSON object")
+
+// member is a top-level member of an encoded JSON object.
+type member struct {
+	key        []byte // still quoted
+	start, end int    // the whole member
+	value      int    // start of the value
+}
+
+// is reports whether m's key is name.
+func (m member) is(name string) bool {
+	k := m.key[1 : len(m.key)-1]
+	if bytes.IndexByte(k, '\\') < 0 {
+		return string(k) == name
+	}
+	var s string
+	return json.Unmarshal(m.key, &s) == nil && s == name
+}
+
+// stringValue returns m's value within buf if it's a string.
+func (m member) stringValue(buf []byte) string {
+	var s string
+	if buf[m.value] == '"' {
+		json.Unmarshal(buf[m.value:m.end], &s)
+	}
+	return s
+}
+
+// objectMembers lists the top-level members of the encoded object buf
+// without decoding their values.  buf is assumed to be valid JSON.
+//
+// This lets the write path marshal each document once and then pull
+// out or replace "_id" and "_rev" without a full decode.
+func objectMembers(buf []byte) ([]member, error) {
+	i := skipSpace(buf, 0)
+	if i >= len(buf) || buf[i] != '{' {
+		return nil, errNotObject
+	}
+	i = skipSpace(buf, i+1)
+	if i < len(buf) && buf[i] == '}' {
+		return nil, nil
+	}
+
+	var ms []member
+	for {
+		m := member{start: i}
+		end, err := skipString(buf, i)
+		if err != nil {
+			return nil, err
+		}
+		m.key = buf[i:end]
+		i = skipSpace(buf, end)
+		if i >= len(buf) || buf[i] != ':' {
+			return nil, errNotObject
+		}
+		m.value = skipSpace(buf, i+1)
+		if m.end, err = skipValue(buf, m.value); err != nil {
+			return nil, err
+		}
+		ms = append(ms, m)
+
+		i = skipSpace(buf, m.end)
+		if i >= len(buf) {
+			return nil, errNotObject
+		}
+		switch buf[i] {
+		case ',':
+			i = skipSpace(buf, i+1)
+		case '}':
+			return ms, nil
+		default:
+			return nil, errNotObject
+		}
+	}
+}
+
+func skipSpace(buf []byte, i int) int {
+	for i < len(buf) {
+		switch buf[i] {
+		case ' ', '\t', '\r', '\n':
+			i++
+		default:
+			return i
+		}
+	}
+	return i
+}
+
+// skipString returns the offset just past the string starting at i.
+func skipString(buf []byte, i int) (int, error) {
+	if i >= len(buf) || buf[i] != '"' {
+		return 0, errNotObject
+	}
+	for i++; i < len(buf); i++ {
+		switch buf[i] {
+		case '\\':
+			i++
+		case '"':
+			return i + 1, nil
+		}
+	}
+	return 0, errNotObject
+}
+
+// skipValue returns the offset just past the value starting at i.
+func skipValue(buf []byte, i int) (int, error) {
+	if i >= len(buf) {
+		return 0, errNotObject
+	}
+	switch buf[i] {
+	case '"':
+		return skipString(buf, i)
+	case '{', '[':
+		depth := 0
+		for ; i < len(buf); i++ {
+			switch buf[i] {
+			case '"':
+				end, err := skipString(buf, i)
+				if err != nil {
+					return 0, err
+				}
+				i = end - 1
+			case '{', '[':
+				depth++
+			case '}', ']':
+				if depth--; depth == 0 {
+					return i + 1, nil
+				}
+			}
+		}
+		return 0, errNotObject
+	}
+	start := i
+	for ; i < len(buf); i++ {
+		switch buf[i] {
+		case ',', '}', ']', ' ', '\t', '\r', '\n':
+			if i == start {
+				return 0, errNotObject
+			}
+			return i, nil
+		}
+	}
+	return i, nil
+}
+
+// docIDRev returns the string "_id" and "_rev" of an encoded document.
+func docIDRev(buf []byte) (id, rev string, err error) {
+	ms, err := objectMembers(buf)
+	if err != nil {
+		return "", "", err
+	}
+	for _, m := range ms {
+		switch {
+		case m.is("_id"):
+			id = m.stringValue(buf)
+		case m.is("_rev"):
+			rev = m.stringValue(buf)
+		}
+	}
+	return id, rev, nil
+}
+
+// stripIDRev returns an encoded document without its "_id" and "_rev",
+// along with their values if they're strings.
+func stripIDRev(buf []byte) (body []byte, id, rev string, err error) {
+	ms, err := objectMembers(buf)
+	if err != nil {
+		return nil, "", "", err
+	}
+	found := false
+	for _, m := range ms {
+		switch {
+		case m.is("_id"):
+			id, found = m.stringValue(buf), true
+		case m.is("_rev"):
+			rev, found = m.stringValue(buf), true
+		}
+	}
+	if !found {
+		return buf, id, rev, nil
+	}
+
+	body = make([]byte, 0, len(buf))
+	body = append(body, '{')
+	for _, m := range ms {
+		if m.is("_id") || m.is("_rev") {
+			continue
+		}
+		if len(body) > 1 {
+			body = append(body, ',')
+		}
+		body = append(body, buf[m.start:m.end]...)
+	}
+	return append(body, '}'), id, rev, nil
+}
+
+// withIDRev returns the encoded object body, which must not contain
+// "_id" or "_rev", with the given id and rev added.  An empty rev is
+// left out.
+func withIDRev(body []byte, id, rev string) []byte {
+	qid, _ := json.Marshal(id)
+	qrev, _ := json.Marshal(rev)
+	rest := body[skipSpace(body, 0)+1:]
+
+	out := make([]byte, 0, len(body)+len(qid)+len(qrev)+16)
+	out = append(out, `{"_id":`...)
+	out = append(out, qid...)
+	if rev != "" {
+		out = append(out, `,"_rev":`...)
+		out = append(out, qrev...)
+	}
+	if i := skipSpace(rest, 0); i < len(rest) && rest[i] != '}' {
+		out = append(out, ',')
+	}
+	return append(out, rest...)
+}
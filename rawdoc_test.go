@@ -0,0 +1,77 @@
+package couch
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"testing"
+
+	"github.com/dustin/go-couch/couchtest"
+)
+
+func TestStripIDRev(t *testing.T) {
+	tests := []struct {
+		in, body string
+		id, rev  string
+	}{
+		{`{}`, `{}`, "", ""},
+		{`{"a":1}`, `{"a":1}`, "", ""},
+		{`{"_id":"x","_rev":"1-a","a":[1,{"_id":"inner"}]}`,
+			`{"a":[1,{"_id":"inner"}]}`, "x", "1-a"},
+		{`{"a":"}\"]","_id":"x","b":{"c":null}}`,
+			`{"a":"}\"]","b":{"c":null}}`, "x", ""},
+		{` { "_id" : "y" , "n" : -1.5e3 } `, `{"n" : -1.5e3}`, "y", ""},
+		{`{"_id":3.14,"_rev":true}`, `{}`, "", ""},
+	}
+	for _, test := range tests {
+		body, id, rev, err := stripIDRev([]byte(test.in))
+		if err != nil {
+			t.Errorf("%s: error: %v", test.in, err)
+			continue
+		}
+		if string(body) != test.body || id != test.id || rev != test.rev {
+			t.Errorf("%s: expected %s %q %q, got %s %q %q", test.in,
+				test.body, test.id, test.rev, body, id, rev)
+		}
+	}
+
+	for _, in := range []string{`[1]`, `"x"`, `{"a":1`, `{"a"}`, `{"a":}`} {
+		if _, _, _, err := stripIDRev([]byte(in)); err == nil {
+			t.Errorf("%s: expected error", in)
+		}
+	}
+}
+
+func TestWithIDRev(t *testing.T) {
+	tests := map[string]string{
+		`{}`:           `{"_id":"a\"b","_rev":"1-a"}`,
+		` { } `:        `{"_id":"a\"b","_rev":"1-a" } `,
+		`{"x":[1,2]}`:  `{"_id":"a\"b","_rev":"1-a","x":[1,2]}`,
+		`{ "x":true }`: `{"_id":"a\"b","_rev":"1-a", "x":true }`,
+	}
+	for in, exp := range tests {
+		got := withIDRev([]byte(in), `a"b`, "1-a")
+		if string(got) != exp {
+			t.Errorf("%s: expected %s, got %s", in, exp, got)
+		}
+		if !json.Valid(got) {
+			t.Errorf("%s: invalid JSON %s", in, got)
+		}
+	}
+}
+
+func TestInsertPreservesNumbers(t *testing.T) {
+	f := couchtest.NewTransport(docResponse(`{"ok": true, "id": "n", "rev": "1-a"}`))
+	defer uninstallFakeHTTP(installFakeHTTP(f))
+
+	_, _, err := Database{}.Insert(map[string]interface{}{
+		"_id": "n",
+		"big": int64(1) << 60,
+	})
+	if err != nil {
+		t.Fatalf("Expected success, got %v", err)
+	}
+	body, _ := ioutil.ReadAll(f.Requests[0].Body)
+	if string(body) != `{"big":1152921504606846976}` {
+		t.Errorf("Expected big number intact, got %s", body)
+	}
+}
@@ -0,0 +1,110 @@
+package couch
+
+import "encoding/json"
+
+// ReduceRow is a row of a reduced view: the key of a group of rows,
+// or null when the whole view is reduced, and the group's reduced
+// value.
+type ReduceRow struct {
+	Key   json.RawMessage `json:"key"`
+	Value json.RawMessage `json:"value"`
+}
+
+// Scan decodes the row's key and value into the given pointers, either
+// of which may be nil to skip it.
+func (r ReduceRow) Scan(key, value interface{}) error {
+	if key != nil {
+		if err := json.Unmarshal(r.Key, key); err != nil {
+			return err
+		}
+	}
+	if value != nil {
+		return json.Unmarshal(r.Value, value)
+	}
+	return nil
+}
+
+// QueryReduce runs a view's reduce function, returning a row for each
+// group.  By default the whole view is reduced to one row; pass
+// {"group": true} for a row per distinct key, or {"group_level": n} for
+// a row per distinct first n elements of array keys (see also
+// ViewOptions).  Other options are as for Query, except that
+// "reduce" is always true.
+func (p Database) QueryReduce(view string, options map[string]interface{}) ([]ReduceRow, error) {
+	opts := make(map[string]interface{}, len(options)+1)
+	for k, v := range options {
+		opts[k] = v
+	}
+	opts["reduce"] = true
+	res := struct {
+		Rows []ReduceRow `json:"rows"`
+	}{}
+	if err := p.Query(view, opts, &res); err != nil {
+		return nil, err
+	}
+	return res.Rows, nil
+}
+
+// Count decodes the value of a row reduced by _count.
+func (r ReduceRow) Count() (int64, error) {
+	var n int64
+	err := json.Unmarshal(r.Value, &n)
+	return n, err
+}
+
+// Sum decodes the value of a row reduced by _sum, of numbers.
+func (r ReduceRow) Sum() (float64, error) {
+	var n float64
+	err := json.Unmarshal(r.Value, &n)
+	return n, err
+}
+
+// Sums decodes the value of a row reduced by _sum, of arrays of
+// numbers, which are summed element by element.
+func (r ReduceRow) Sums() ([]float64, error) {
+	var sums []float64
+	err := json.Unmarshal(r.Value, &sums)
+	return sums, err
+}
+
+// ReduceStats is the value of a row reduced by _stats.
+type ReduceStats struct {
+	Sum    float64 `json:"sum"`
+	Count  int64   `json:"count"`
+	Min    float64 `json:"min"`
+	Max    float64 `json:"max"`
+	SumSqr float64 `json:"sumsqr"`
+}
+
+// Mean returns the mean of the values, or 0 if there were none.
+func (s ReduceStats) Mean() float64 {
+	if s.Count == 0 {
+		return 0
+	}
+	return s.Sum / float64(s.Count)
+}
+
+// Variance returns the population variance of the values, or 0 if
+// there were none.
+func (s ReduceStats) Variance() float64 {
+	if s.Count == 0 {
+		return 0
+	}
+	mean := s.Mean()
+	return s.SumSqr/float64(s.Count) - mean*mean
+}
+
+// Stats decodes the value of a row reduced by _stats, of numbers.
+func (r ReduceRow) Stats() (ReduceStats, error) {
+	s := ReduceStats{}
+	err := json.Unmarshal(r.Value, &s)
+	return s, err
+}
+
+// StatsArray decodes the value of a row reduced by _stats, of arrays
+// of numbers, which have statistics element by element.
+func (r ReduceRow) StatsArray() ([]ReduceStats, error) {
+	var s []ReduceStats
+	err := json.Unmarshal(r.Value, &s)
+	return s, err
+}
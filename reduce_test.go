@@ -0,0 +1,90 @@
+package couch
+
+import (
+	"testing"
+
+	"github.com/dustin/go-couch/couchtest"
+)
+
+func TestQueryReduce(t *testing.T) {
+	f := couchtest.NewTransport(docResponse(`{"rows": [
+		{"key": ["2024", "01"], "value": 3},
+		{"key": ["2024", "02"], "value": 5}]}`))
+	defer uninstallFakeHTTP(installFakeHTTP(f))
+
+	rows, err := Database{Name: "db"}.QueryReduce("_design/d/_view/by_date",
+		ViewOptions{GroupLevel: 2, Reduce: new(bool)}.Options())
+	if err != nil {
+		t.Fatalf("Error querying: %v", err)
+	}
+	if len(rows) != 2 {
+		t.Fatalf("Expected 2 rows, got %+v", rows)
+	}
+	var key []string
+	var n int
+	if err := rows[1].Scan(&key, &n); err != nil {
+		t.Fatalf("Error scanning: %v", err)
+	}
+	if len(key) != 2 || key[1] != "02" || n != 5 {
+		t.Errorf("Unexpected row %v = %v", key, n)
+	}
+
+	q := f.Requests[0].URL.Query()
+	if q.Get("reduce") != "true" || q.Get("group_level") != "2" {
+		t.Errorf("Unexpected parameters %v", q)
+	}
+}
+
+func TestQueryReduceAll(t *testing.T) {
+	defer uninstallFakeHTTP(installFakeHTTP(couchtest.NewTransport(docResponse(
+		`{"rows": [{"key": null, "value": 42}]}`))))
+
+	rows, err := (Database{}).QueryReduce("_design/d/_view/count", nil)
+	if err != nil {
+		t.Fatalf("Error querying: %v", err)
+	}
+	var n int
+	if len(rows) != 1 || rows[0].Scan(nil, &n) != nil || n != 42 {
+		t.Errorf("Unexpected rows %+v", rows)
+	}
+}
+
+func TestQueryReduceNoView(t *testing.T) {
+	if _, err := (Database{}).QueryReduce("", nil); err != errEmptyView {
+		t.Errorf("Expected empty view error, got %v", err)
+	}
+}
+
+func TestReduceValues(t *testing.T) {
+	row := func(v string) ReduceRow { return ReduceRow{Value: []byte(v)} }
+
+	if n, err := row(`12`).Count(); err != nil || n != 12 {
+		t.Errorf("Unexpected count %v, %v", n, err)
+	}
+	if _, err := row(`{"sum": 1}`).Count(); err == nil {
+		t.Errorf("Expected an error counting an object")
+	}
+	if n, err := row(`2.5`).Sum(); err != nil || n != 2.5 {
+		t.Errorf("Unexpected sum %v, %v", n, err)
+	}
+	if s, err := row(`[1, 2.5]`).Sums(); err != nil || len(s) != 2 || s[1] != 2.5 {
+		t.Errorf("Unexpected sums %v, %v", s, err)
+	}
+
+	s, err := row(`{"sum": 10, "count": 4, "min": 1, "max": 4, "sumsqr": 30}`).Stats()
+	if err != nil {
+		t.Fatalf("Error decoding stats: %v", err)
+	}
+	if s.Count != 4 || s.Max != 4 || s.Mean() != 2.5 || s.Variance() != 1.25 {
+		t.Errorf("Unexpected stats %+v, mean %v, variance %v", s, s.Mean(), s.Variance())
+	}
+	if (ReduceStats{}).Mean() != 0 || (ReduceStats{}).Variance() != 0 {
+		t.Errorf("Expected zero for no values")
+	}
+
+	sa, err := row(`[{"sum": 1, "count": 1, "min": 1, "max": 1, "sumsqr": 1},
+		{"sum": 5, "count": 1, "min": 5, "max": 5, "sumsqr": 25}]`).StatsArray()
+	if err != nil || len(sa) != 2 || sa[1].Sum != 5 {
+		t.Errorf("Unexpected stats %+v, %v", sa, err)
+	}
+}
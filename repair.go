@@ -0,0 +1,48 @@
+package couch
+
+import "strconv"
+
+// readRepairReads is how many times ReadRepair reads a document.
+const readRepairReads = 3
+
+// ReadRepair checks the replicas of the document with the given id for
+// disagreement.  The document is read several times from all N copies
+// (r=N), which also has CouchDB repair any copies the reads find
+// behind.  If the reads still see different revisions, the database's
+// shards are synced with _sync_shards.
+//
+// It returns the distinct revisions seen; more than one means replicas
+// disagreed and a sync was triggered.
+func (p Database) ReadRepair(id string) ([]string, error) {
+	if id == "" {
+		return nil, errNoID
+	}
+
+	info, err := p.GetInfo()
+	if err != nil {
+		return nil, err
+	}
+	n := info.Cluster.N
+	if n < 1 {
+		n = 1
+	}
+
+	var revs []string
+	seen := map[string]bool{}
+	u := p.docURL(id) + "?r=" + strconv.Itoa(n)
+	for i := 0; i < readRepairReads; i++ {
+		doc := idAndRev{}
+		if err := p.unmarshalURL(u, &doc); err != nil {
+			return revs, err
+		}
+		if !seen[doc.Rev] {
+			seen[doc.Rev] = true
+			revs = append(revs, doc.Rev)
+		}
+	}
+
+	if len(revs) > 1 {
+		return revs, p.SyncShards()
+	}
+	return revs, nil
+}
@@ -0,0 +1,57 @@
+package couch
+
+import (
+	"net/http"
+	"reflect"
+	"testing"
+
+	"github.com/dustin/go-couch/couchtest"
+)
+
+func TestReadRepairConsistent(t *testing.T) {
+	f := &couchtest.Transport{Responses: []*http.Response{
+		docResponse(`{"db_name": "db", "cluster": {"q": 8, "n": 3}}`),
+		docResponse(`{"_id": "a", "_rev": "2-b"}`),
+		docResponse(`{"_id": "a", "_rev": "2-b"}`),
+		docResponse(`{"_id": "a", "_rev": "2-b"}`),
+	}}
+	defer uninstallFakeHTTP(installFakeHTTP(f))
+
+	revs, err := Database{Name: "db"}.ReadRepair("a")
+	if err != nil || !reflect.DeepEqual(revs, []string{"2-b"}) {
+		t.Fatalf("Expected [2-b], got %v, %v", revs, err)
+	}
+	if q := f.Requests[1].URL.Query().Get("r"); q != "3" {
+		t.Errorf("Expected r=3, got %q", q)
+	}
+	if len(f.Requests) != 4 {
+		t.Errorf("Expected no sync, got %v requests", len(f.Requests))
+	}
+}
+
+func TestReadRepairSyncs(t *testing.T) {
+	f := &couchtest.Transport{Responses: []*http.Response{
+		docResponse(`{"db_name": "db", "cluster": {"q": 8, "n": 3}}`),
+		docResponse(`{"_id": "a", "_rev": "1-a"}`),
+		docResponse(`{"_id": "a", "_rev": "2-b"}`),
+		docResponse(`{"_id": "a", "_rev": "2-b"}`),
+		docResponse(`{"ok": true}`),
+	}}
+	defer uninstallFakeHTTP(installFakeHTTP(f))
+
+	revs, err := Database{Name: "db"}.ReadRepair("a")
+	if err != nil || !reflect.DeepEqual(revs, []string{"1-a", "2-b"}) {
+		t.Fatalf("Expected [1-a 2-b], got %v, %v", revs, err)
+	}
+	sync := f.Requests[4]
+	if sync.Method != "POST" || sync.URL.Path != "/db/_sync_shards" {
+		t.Errorf("Expected POST to _sync_shards, got %v %v",
+			sync.Method, sync.URL.Path)
+	}
+}
+
+func TestReadRepairNoID(t *testing.T) {
+	if _, err := (Database{}).ReadRepair(""); err != errNoID {
+		t.Errorf("Expected errNoID, got %v", err)
+	}
+}
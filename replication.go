@@ -0,0 +1,296 @@
+package couch
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+)
+
+// ReplicatorDB is the database whose documents describe replications
+// for the server to run, restarting them as needed.
+const ReplicatorDB = "_replicator"
+
+// Replicator returns a database for ReplicatorDB on the same server,
+// with the same settings.  Store a Replication in it, e.g. with
+// InsertWith, to have the server run it.
+func (p Database) Replicator() Database {
+	p.Name = ReplicatorDB
+	return p
+}
+
+// Replication describes a replication, as a document in ReplicatorDB
+// or the body of a _replicate request.
+type Replication struct {
+	ID  string `json:"_id,omitempty"`
+	Rev string `json:"_rev,omitempty"`
+	// URLs of the databases to replicate from and to, including any
+	// credentials.
+	Source string `json:"source"`
+	Target string `json:"target"`
+	// Keep replicating changes as they're made.
+	Continuous bool `json:"continuous,omitempty"`
+	// Create the target database if it doesn't exist.
+	CreateTarget bool `json:"create_target,omitempty"`
+
+	// At most one of Filter, Selector and DocIDs may be given to
+	// replicate only some documents.
+
+	// Filter function, as "ddoc/name", passed QueryParams as
+	// req.query.
+	Filter      string            `json:"filter,omitempty"`
+	QueryParams map[string]string `json:"query_params,omitempty"`
+	// Mango selector of documents to replicate, e.g.
+	// map[string]interface{}{"type": "order"}.
+	Selector interface{} `json:"selector,omitempty"`
+	// Ids of the documents to replicate.
+	DocIDs []string `json:"doc_ids,omitempty"`
+}
+
+var errReplicationFilters = errors.New("couch: at most one of a replication's " +
+	"Filter, Selector and DocIDs may be set")
+
+// validate checks for settings the server would reject.
+func (r Replication) validate() error {
+	n := 0
+	for _, set := range []bool{r.Filter != "", r.Selector != nil, r.DocIDs != nil} {
+		if set {
+			n++
+		}
+	}
+	if n > 1 {
+		return errReplicationFilters
+	}
+	return nil
+}
+
+// Replicate runs a replication that isn't recorded in ReplicatorDB
+// (ignores Database.Name), so it doesn't survive a server restart.  A
+// continuous replication is started, returning its id for
+// WatchReplication; otherwise the replication runs to completion
+// before Replicate returns, with an empty id.
+func (p Database) Replicate(r Replication) (string, error) {
+	if err := r.validate(); err != nil {
+		return "", err
+	}
+	r.ID, r.Rev = "", ""
+	body, err := json.Marshal(r)
+	if err != nil {
+		return "", err
+	}
+	res := struct {
+		LocalID string `json:"_local_id"`
+	}{}
+	_, err = p.interact("POST", p.serverURL("_replicate"), p.defaultHdrs, body, &res)
+	return res.LocalID, err
+}
+
+// Replication states, as the scheduler reports them.
+const (
+	ReplicationInitializing = "initializing"
+	ReplicationPending      = "pending"
+	ReplicationRunning      = "running"
+	// Crashed, to be retried after a backoff.
+	ReplicationCrashing = "crashing"
+	// Couldn't be started, e.g. for an invalid filter, to be retried.
+	ReplicationError     = "error"
+	ReplicationCompleted = "completed"
+	// Won't be retried, e.g. for an invalid document.
+	ReplicationFailed = "failed"
+)
+
+// ReplicationStatus is the state and progress of a replication.
+type ReplicationStatus struct {
+	State string `json:"-"`
+	// Why the replication crashed or failed.
+	Reason string `json:"-"`
+
+	RevisionsChecked int64 `json:"revisions_checked"`
+	DocsRead         int64 `json:"docs_read"`
+	DocsWritten      int64 `json:"docs_written"`
+	DocWriteFailures int64 `json:"doc_write_failures"`
+	ChangesPending   int64 `json:"changes_pending"`
+}
+
+// Progress estimates the percentage of source changes the replication
+// has checked, from the revisions checked and the changes pending.  It
+// returns -1 if there's nothing to go on.
+func (s ReplicationStatus) Progress() int {
+	total := s.RevisionsChecked + s.ChangesPending
+	if total == 0 {
+		return -1
+	}
+	return int(s.RevisionsChecked * 100 / total)
+}
+
+// ReplicationWatch is called as a watched replication changes.  Any of
+// its funcs may be nil.
+type ReplicationWatch struct {
+	// Started is called when the replication starts running, including
+	// when it resumes after crashing.
+	Started func(ReplicationStatus)
+	// Progress is called on each poll while it's running.
+	Progress func(ReplicationStatus)
+	// Crashed is called when it crashes, fails, or can't be started.
+	Crashed func(ReplicationStatus)
+	// Completed is called when it completes.
+	Completed func(ReplicationStatus)
+	// How often to poll.  Defaults to a second.
+	Interval time.Duration
+}
+
+// replicationTask is a replication, as listed by /_active_tasks.
+type replicationTask struct {
+	Type          string `json:"type"`
+	DocID         string `json:"doc_id"`
+	ReplicationID string `json:"replication_id"`
+	ReplicationStatus
+}
+
+// WatchReplication polls the server's scheduler and active tasks about
+// a replication (ignores Database.Name), calling w's funcs as its state
+// changes, until it completes or fails, or ctx is done.  id is the id
+// of its document in ReplicatorDB, or for one started by Replicate, the
+// id that returned.  It returns the replication's final status.
+//
+// A replication started by Replicate that finishes is no longer
+// listed, so is taken to have completed.
+func (p Database) WatchReplication(ctx context.Context, id string,
+	w ReplicationWatch) (ReplicationStatus, error) {
+
+	interval := w.Interval
+	if interval <= 0 {
+		interval = time.Second
+	}
+	isDoc, seen := true, false
+	last := ""
+	for first := true; ; first = false {
+		if !first {
+			select {
+			case <-ctx.Done():
+				return ReplicationStatus{}, ctx.Err()
+			case <-time.After(interval):
+			}
+		}
+
+		var s ReplicationStatus
+		var err error
+		if isDoc {
+			s, err = p.replicationDocStatus(id)
+			if errors.Is(err, ErrNotFound) && !seen {
+				isDoc = false
+			}
+		}
+		if !isDoc {
+			s, err = p.replicationJobStatus(id)
+			if errors.Is(err, ErrNotFound) && seen {
+				s, err = ReplicationStatus{State: ReplicationCompleted}, nil
+			}
+		}
+		if err != nil {
+			return s, err
+		}
+		seen = true
+
+		if s.State == ReplicationRunning {
+			if err := p.replicationProgress(id, &s); err != nil {
+				return s, err
+			}
+		}
+
+		call := func(f func(ReplicationStatus)) {
+			if f != nil && s.State != last {
+				f(s)
+			}
+		}
+		switch s.State {
+		case ReplicationRunning:
+			call(w.Started)
+			if w.Progress != nil {
+				w.Progress(s)
+			}
+		case ReplicationCrashing, ReplicationError, ReplicationFailed:
+			call(w.Crashed)
+		case ReplicationCompleted:
+			call(w.Completed)
+		}
+		last = s.State
+
+		if s.State == ReplicationCompleted || s.State == ReplicationFailed {
+			return s, nil
+		}
+	}
+}
+
+// replicationDocStatus asks the scheduler about the replication of a
+// document in ReplicatorDB.
+func (p Database) replicationDocStatus(id string) (ReplicationStatus, error) {
+	doc := struct {
+		State string          `json:"state"`
+		Info  json.RawMessage `json:"info"`
+	}{}
+	err := p.unmarshalURL(p.serverURL("_scheduler/docs/"+ReplicatorDB+"/"+pathEscape(id)), &doc)
+	if err != nil {
+		return ReplicationStatus{}, err
+	}
+	s := ReplicationStatus{State: doc.State}
+	// CouchDB 2 gives a string reason, later versions an object of
+	// counters and any error.
+	var reason string
+	if json.Unmarshal(doc.Info, &reason) == nil {
+		s.Reason = reason
+	} else if len(doc.Info) > 0 {
+		info := struct {
+			Error string `json:"error"`
+			ReplicationStatus
+		}{}
+		if err := json.Unmarshal(doc.Info, &info); err == nil {
+			s = info.ReplicationStatus
+			s.State, s.Reason = doc.State, info.Error
+		}
+	}
+	return s, nil
+}
+
+// replicationJobStatus asks the scheduler about a replication started
+// by Replicate, whose state is the latest event of its history.
+func (p Database) replicationJobStatus(id string) (ReplicationStatus, error) {
+	job := struct {
+		History []struct {
+			Type   string `json:"type"`
+			Reason string `json:"reason"`
+		} `json:"history"`
+	}{}
+	if err := p.unmarshalURL(p.serverURL("_scheduler/jobs/"+pathEscape(id)), &job); err != nil {
+		return ReplicationStatus{}, err
+	}
+	s := ReplicationStatus{State: ReplicationPending}
+	if len(job.History) > 0 {
+		switch latest := job.History[0]; latest.Type {
+		case "started":
+			s.State = ReplicationRunning
+		case "crashed":
+			s.State, s.Reason = ReplicationCrashing, latest.Reason
+		case "stopped":
+			s.State = ReplicationCompleted
+		}
+	}
+	return s, nil
+}
+
+// replicationProgress fills in s's counters from the replication's
+// active task, if it's listed.
+func (p Database) replicationProgress(id string, s *ReplicationStatus) error {
+	tasks := []replicationTask{}
+	if err := p.unmarshalURL(p.serverURL("_active_tasks"), &tasks); err != nil {
+		return err
+	}
+	for _, t := range tasks {
+		if t.Type == "replication" && (t.DocID == id || t.ReplicationID == id) {
+			t.ReplicationStatus.State = s.State
+			*s = t.ReplicationStatus
+			return nil
+		}
+	}
+	return nil
+}
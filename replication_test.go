@@ -0,0 +1,185 @@
+package couch
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dustin/go-couch/couchtest"
+)
+
+func TestReplicate(t *testing.T) {
+	f := couchtest.NewTransport(docResponse(`{"ok": true, "_local_id": "abc+continuous"}`))
+	defer uninstallFakeHTTP(installFakeHTTP(f))
+
+	d := Database{Host: "localhost", Port: "5984", Name: "db"}
+	id, err := d.Replicate(Replication{ID: "ignored", Source: "http://a/src",
+		Target: "http://b/dst", Continuous: true})
+	if err != nil {
+		t.Fatalf("Error replicating: %v", err)
+	}
+	if id != "abc+continuous" {
+		t.Errorf("Expected id abc+continuous, got %v", id)
+	}
+	req := f.Requests[0]
+	if req.Method != "POST" || req.URL.Path != "/_replicate" {
+		t.Errorf("Unexpected request %v %v", req.Method, req.URL)
+	}
+	body := map[string]interface{}{}
+	json.NewDecoder(req.Body).Decode(&body)
+	exp := map[string]interface{}{
+		"source": "http://a/src", "target": "http://b/dst", "continuous": true,
+	}
+	if !reflect.DeepEqual(body, exp) {
+		t.Errorf("Expected %v, got %v", exp, body)
+	}
+	if d.Replicator().Name != ReplicatorDB {
+		t.Errorf("Unexpected replicator database %q", d.Replicator().Name)
+	}
+}
+
+func TestWatchReplicationDoc(t *testing.T) {
+	f := &couchtest.Transport{Responses: []*http.Response{
+		docResponse(`{"state": "running", "info": {"revisions_checked": 10}}`),
+		docResponse(`[{"type": "indexer"}, {"type": "replication", "doc_id": "rep",
+			"revisions_checked": 25, "changes_pending": 75, "docs_written": 20}]`),
+		docResponse(`{"state": "crashing", "info": {"error": "db_not_found"}}`),
+		docResponse(`{"state": "running", "info": null}`),
+		docResponse(`[{"type": "replication", "doc_id": "rep",
+			"revisions_checked": 100, "changes_pending": 0}]`),
+		docResponse(`{"state": "completed", "info": {"docs_written": 90}}`),
+	}}
+	defer uninstallFakeHTTP(installFakeHTTP(f))
+
+	events := []string{}
+	progress := []int{}
+	note := func(what string) func(ReplicationStatus) {
+		return func(s ReplicationStatus) { events = append(events, what+":"+s.Reason) }
+	}
+	d := Database{Host: "localhost", Port: "5984", Name: "db"}
+	s, err := d.WatchReplication(context.Background(), "rep", ReplicationWatch{
+		Started:   note("started"),
+		Crashed:   note("crashed"),
+		Completed: note("completed"),
+		Progress: func(s ReplicationStatus) {
+			progress = append(progress, s.Progress())
+		},
+		Interval: time.Millisecond,
+	})
+	if err != nil {
+		t.Fatalf("Error watching: %v", err)
+	}
+	if s.State != ReplicationCompleted || s.DocsWritten != 90 {
+		t.Errorf("Unexpected final status %+v", s)
+	}
+	exp := []string{"started:", "crashed:db_not_found", "started:", "completed:"}
+	if !reflect.DeepEqual(events, exp) {
+		t.Errorf("Expected events %v, got %v", exp, events)
+	}
+	if !reflect.DeepEqual(progress, []int{25, 100}) {
+		t.Errorf("Expected progress [25 100], got %v", progress)
+	}
+	if f.Requests[0].URL.Path != "/_scheduler/docs/_replicator/rep" ||
+		f.Requests[1].URL.Path != "/_active_tasks" {
+		t.Errorf("Unexpected requests %v, %v", f.Requests[0].URL, f.Requests[1].URL)
+	}
+}
+
+func TestWatchReplicationJob(t *testing.T) {
+	notFound := func() *http.Response {
+		return &http.Response{StatusCode: 404, Body: ioutil.NopCloser(strings.NewReader(
+			`{"error": "not_found", "reason": "missing"}`))}
+	}
+	f := &couchtest.Transport{Responses: []*http.Response{
+		notFound(),
+		docResponse(`{"history": [{"type": "crashed", "reason": "timeout"},
+			{"type": "started"}, {"type": "added"}]}`),
+		docResponse(`{"history": [{"type": "started"}, {"type": "crashed"}]}`),
+		docResponse(`[]`),
+		notFound(),
+	}}
+	defer uninstallFakeHTTP(installFakeHTTP(f))
+
+	events := []string{}
+	d := Database{Host: "localhost", Port: "5984"}
+	s, err := d.WatchReplication(context.Background(), "abc+continuous", ReplicationWatch{
+		Started:   func(ReplicationStatus) { events = append(events, "started") },
+		Crashed:   func(s ReplicationStatus) { events = append(events, "crashed:"+s.Reason) },
+		Completed: func(ReplicationStatus) { events = append(events, "completed") },
+		Interval:  time.Millisecond,
+	})
+	if err != nil {
+		t.Fatalf("Error watching: %v", err)
+	}
+	if s.State != ReplicationCompleted {
+		t.Errorf("Expected completion, got %+v", s)
+	}
+	exp := []string{"crashed:timeout", "started", "completed"}
+	if !reflect.DeepEqual(events, exp) {
+		t.Errorf("Expected events %v, got %v", exp, events)
+	}
+	if f.Requests[1].URL.EscapedPath() != "/_scheduler/jobs/abc%2Bcontinuous" {
+		t.Errorf("Unexpected request %v", f.Requests[1].URL)
+	}
+}
+
+func TestWatchReplicationMissing(t *testing.T) {
+	f := &couchtest.Transport{}
+	for i := 0; i < 2; i++ {
+		f.Responses = append(f.Responses, &http.Response{StatusCode: 404,
+			Body: ioutil.NopCloser(strings.NewReader(`{"error": "not_found"}`))})
+	}
+	defer uninstallFakeHTTP(installFakeHTTP(f))
+
+	_, err := Database{}.WatchReplication(context.Background(), "nope", ReplicationWatch{})
+	if err == nil {
+		t.Errorf("Expected an error for an unknown replication")
+	}
+}
+
+func TestReplicateFiltered(t *testing.T) {
+	f := couchtest.NewTransport(docResponse(`{"ok": true}`))
+	defer uninstallFakeHTTP(installFakeHTTP(f))
+
+	d := Database{Host: "localhost", Port: "5984"}
+	_, err := d.Replicate(Replication{Source: "a", Target: "b",
+		Filter: "app/by_owner", QueryParams: map[string]string{"owner": "bob"}})
+	if err != nil {
+		t.Fatalf("Error replicating: %v", err)
+	}
+	body := map[string]interface{}{}
+	json.NewDecoder(f.Requests[0].Body).Decode(&body)
+	exp := map[string]interface{}{
+		"source": "a", "target": "b", "filter": "app/by_owner",
+		"query_params": map[string]interface{}{"owner": "bob"},
+	}
+	if !reflect.DeepEqual(body, exp) {
+		t.Errorf("Expected %v, got %v", exp, body)
+	}
+
+	b, err := json.Marshal(Replication{Source: "a", Target: "b",
+		Selector: map[string]interface{}{"type": "order"}})
+	must(err)
+	if string(b) != `{"source":"a","target":"b","selector":{"type":"order"}}` {
+		t.Errorf("Unexpected encoding %s", b)
+	}
+	b, err = json.Marshal(Replication{Source: "a", Target: "b", DocIDs: []string{"x", "y"}})
+	must(err)
+	if string(b) != `{"source":"a","target":"b","doc_ids":["x","y"]}` {
+		t.Errorf("Unexpected encoding %s", b)
+	}
+
+	_, err = d.Replicate(Replication{Source: "a", Target: "b",
+		Filter: "app/f", DocIDs: []string{"x"}})
+	if err != errReplicationFilters {
+		t.Errorf("Expected an error for conflicting filters, got %v", err)
+	}
+	if len(f.Requests) != 1 {
+		t.Errorf("Expected no request for an invalid replication")
+	}
+}
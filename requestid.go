@@ -0,0 +1,70 @@
+package couch
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+)
+
+// RequestIDHeader is the header a request's id is sent in, for
+// proxies and servers that log it.
+const RequestIDHeader = "X-Request-ID"
+
+// ServerRequestIDHeader is the header CouchDB identifies each response
+// with, matching the request's entry in its log.
+const ServerRequestIDHeader = "X-Couch-Request-ID"
+
+type requestIDKey struct{}
+
+// ContextWithRequestID returns a copy of ctx carrying a request id, to
+// be sent with requests made with it (see WithContext), e.g. one
+// received by the service making them.
+func ContextWithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, id)
+}
+
+// RequestIDFromContext returns the request id ctx carries, if any.
+func RequestIDFromContext(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// WithRequestIDs returns a copy of the database that sends a request id
+// with each request, in RequestIDHeader: the one its context carries,
+// or else a new random one.  Ids appear in RequestInfo, for the Logger
+// and Metrics.  A request id from the context is sent even without
+// this.
+func (p Database) WithRequestIDs() Database {
+	p.requestIDs = true
+	return p
+}
+
+// addRequestID adds a request id to req, unless it has one.
+func (p Database) addRequestID(req *http.Request) error {
+	if req.Header.Get(RequestIDHeader) != "" {
+		return nil
+	}
+	id := RequestIDFromContext(req.Context())
+	if id == "" && p.requestIDs {
+		var err error
+		if id, err = newRequestID(); err != nil {
+			return err
+		}
+	}
+	if id != "" {
+		if req.Header == nil {
+			req.Header = http.Header{}
+		}
+		req.Header.Set(RequestIDHeader, id)
+	}
+	return nil
+}
+
+func newRequestID() (string, error) {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
@@ -0,0 +1,72 @@
+package couch
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/dustin/go-couch/couchtest"
+)
+
+func TestRequestIDs(t *testing.T) {
+	res := docResponse(`{}`)
+	res.Header = http.Header{}
+	res.Header.Set(ServerRequestIDHeader, "srv1")
+	f := &couchtest.Transport{Responses: []*http.Response{
+		res, docResponse(`{}`), docResponse(`{}`), docResponse(`{}`)}}
+	defer uninstallFakeHTTP(installFakeHTTP(f))
+
+	l := &recordingLogger{}
+	base := Database{Name: "db"}.WithLogger(l)
+	d := base.WithRequestIDs()
+	if err := d.Retrieve("a", &struct{}{}); err != nil {
+		t.Fatalf("Error retrieving: %v", err)
+	}
+	ctx := ContextWithRequestID(context.Background(), "caller-1")
+	if err := d.WithContext(ctx).Retrieve("a", &struct{}{}); err != nil {
+		t.Fatalf("Error retrieving: %v", err)
+	}
+	// A context's id is sent even without WithRequestIDs.
+	if err := base.WithContext(ctx).Retrieve("a", &struct{}{}); err != nil {
+		t.Fatalf("Error retrieving: %v", err)
+	}
+	if err := base.Retrieve("a", &struct{}{}); err != nil {
+		t.Fatalf("Error retrieving: %v", err)
+	}
+
+	generated := f.Requests[0].Header.Get(RequestIDHeader)
+	if len(generated) != 16 {
+		t.Errorf("Expected a generated id, got %q", generated)
+	}
+	for i, exp := range []string{generated, "caller-1", "caller-1", ""} {
+		if got := f.Requests[i].Header.Get(RequestIDHeader); got != exp {
+			t.Errorf("Request %v: expected id %q, got %q", i, exp, got)
+		}
+		if got := l.requests[i].RequestID; got != exp {
+			t.Errorf("Request %v: expected %q logged, got %q", i, exp, got)
+		}
+	}
+	if l.requests[0].ServerRequestID != "srv1" {
+		t.Errorf("Expected the server's id logged, got %+v", l.requests[0])
+	}
+}
+
+func TestRequestIDInError(t *testing.T) {
+	h := http.Header{}
+	h.Set(ServerRequestIDHeader, "srv2")
+	defer uninstallFakeHTTP(installFakeHTTP(couchtest.NewTransport(&http.Response{
+		StatusCode: 404,
+		Header:     h,
+		Body: ioutil.NopCloser(strings.NewReader(
+			`{"error": "not_found", "reason": "missing"}`)),
+	})))
+	err := (Database{}).Retrieve("a", &struct{}{})
+	he := &HTTPError{}
+	if !errors.As(err, &he) || he.RequestID != "srv2" ||
+		!strings.Contains(err.Error(), "(request srv2)") {
+		t.Errorf("Expected the server's request id in %v", err)
+	}
+}
@@ -0,0 +1,163 @@
+package couch
+
+import (
+	"context"
+	"errors"
+	"io"
+	"io/ioutil"
+	"math/rand"
+	"net"
+	"net/http"
+	"net/url"
+	"syscall"
+	"time"
+)
+
+// RetryPolicy controls retrying of requests that fail transiently.
+// See WithRetry.
+type RetryPolicy struct {
+	// Maximum number of retries after the initial attempt.
+	MaxRetries int
+	// Delay before the first retry, doubling with each one after.
+	InitialBackoff time.Duration
+	// Upper bound on any single delay.
+	MaxBackoff time.Duration
+	// IdempotencyHeader, if set, makes POST requests retryable too.
+	// Each is sent with a unique key in this header (for example
+	// "Idempotency-Key"), the same across its retries, so a gateway
+	// that deduplicates on it won't repeat work after an ambiguous
+	// failure.  See also WithIdempotencyKey.
+	IdempotencyHeader string
+}
+
+// DefaultRetryPolicy is a reasonable starting point for WithRetry.
+var DefaultRetryPolicy = RetryPolicy{
+	MaxRetries:     3,
+	InitialBackoff: 100 * time.Millisecond,
+	MaxBackoff:     5 * time.Second,
+}
+
+// WithRetry returns a copy of the database that retries idempotent
+// requests (GET, HEAD, OPTIONS, PUT and DELETE) on connection failures,
+// timeouts and 502, 503 and 504 responses, backing off exponentially
+// between attempts.  A request whose body is streamed from a reader,
+// such as an attachment upload, can't be sent again, so its first
+// failure is returned.
+func (p Database) WithRetry(r RetryPolicy) Database {
+	p.retry = &r
+	p.replies = nil
+	if r.IdempotencyHeader != "" {
+		p.replies = newReplyCache()
+	}
+	return p
+}
+
+func idempotent(method string) bool {
+	switch method {
+	case "GET", "HEAD", "OPTIONS", "PUT", "DELETE":
+		return true
+	}
+	return false
+}
+
+// transient reports whether a request that ended this way is worth
+// trying again.
+func transient(res *http.Response, err error) bool {
+	if err == nil {
+		switch res.StatusCode {
+		case http.StatusBadGateway, http.StatusServiceUnavailable,
+			http.StatusGatewayTimeout:
+			return true
+		}
+		return false
+	}
+
+	var ue *url.Error
+	if errors.As(err, &ue) {
+		err = ue.Err
+	}
+	var ne net.Error
+	return errors.As(err, &ne) ||
+		errors.Is(err, io.EOF) ||
+		errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, syscall.ECONNRESET) ||
+		errors.Is(err, syscall.ECONNREFUSED)
+}
+
+// backoff returns the delay before the given retry (starting at 0),
+// with some jitter.
+func (r RetryPolicy) backoff(retry int) time.Duration {
+	d := r.InitialBackoff
+	for i := 0; i < retry && (r.MaxBackoff <= 0 || d < r.MaxBackoff); i++ {
+		d *= 2
+	}
+	if r.MaxBackoff > 0 && d > r.MaxBackoff {
+		d = r.MaxBackoff
+	}
+	if d <= 1 {
+		return d
+	}
+	return d/2 + time.Duration(rand.Int63n(int64(d/2)))
+}
+
+// do sends req with send, retrying as the policy allows.
+func (r RetryPolicy) do(req *http.Request,
+	send func(*http.Request) (*http.Response, error)) (*http.Response, error) {
+
+	for retry := 0; ; retry++ {
+		res, err := send(req)
+		if retry >= r.MaxRetries || !transient(res, err) ||
+			req.Context().Err() != nil || !rewindable(req) {
+			return res, err
+		}
+		if res != nil {
+			io.Copy(ioutil.Discard, res.Body)
+			res.Body.Close()
+		}
+
+		if err := sleepContext(req.Context(), r.backoff(retry)); err != nil {
+			return nil, err
+		}
+
+		if err := rewind(req); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// sleepContext waits for d, or until ctx is done, returning its error.
+func sleepContext(ctx context.Context, d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+var errNoRewind = errors.New("couch: request body can't be sent again")
+
+// rewindable reports whether req can be sent again: it has no body, or
+// one that can be had afresh from GetBody.  A streamed body has been
+// consumed by sending it.
+func rewindable(req *http.Request) bool {
+	return req.Body == nil || req.Body == http.NoBody || req.GetBody != nil
+}
+
+// rewind prepares req's body to be sent again.
+func rewind(req *http.Request) error {
+	if req.GetBody == nil {
+		if !rewindable(req) {
+			return errNoRewind
+		}
+		return nil
+	}
+	body, err := req.GetBody()
+	if err != nil {
+		return err
+	}
+	req.Body = body
+	return nil
+}
@@ -0,0 +1,180 @@
+package couch
+
+import (
+	"errors"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/dustin/go-couch/couchtest"
+)
+
+var quickRetry = RetryPolicy{MaxRetries: 2, InitialBackoff: time.Microsecond}
+
+func unavailable() *http.Response {
+	return couchtest.Response(503, ``)
+}
+
+func TestRetryRecovers(t *testing.T) {
+	f := &couchtest.Transport{Responses: []*http.Response{
+		unavailable(),
+		unavailable(),
+		&http.Response{
+			StatusCode: 200,
+			Body:       ioutil.NopCloser(strings.NewReader(`{"db_name": "db"}`)),
+		},
+	}}
+	defer uninstallFakeHTTP(installFakeHTTP(f))
+
+	info, err := Database{}.WithRetry(quickRetry).GetInfo()
+	if err != nil {
+		t.Fatalf("Expected success, got %v", err)
+	}
+	if info.Name != "db" || len(f.Requests) != 3 {
+		t.Errorf("Expected db after 3 requests, got %v after %v",
+			info.Name, len(f.Requests))
+	}
+}
+
+func TestRetryGivesUp(t *testing.T) {
+	f := &couchtest.Transport{Responses: []*http.Response{
+		unavailable(), unavailable(), unavailable(), unavailable(),
+	}}
+	defer uninstallFakeHTTP(installFakeHTTP(f))
+
+	_, err := Database{}.WithRetry(quickRetry).GetInfo()
+	if he, ok := err.(*HTTPError); !ok || he.StatusCode != 503 {
+		t.Fatalf("Expected 503, got %v", err)
+	}
+	if len(f.Requests) != 3 {
+		t.Errorf("Expected 3 attempts, got %v", len(f.Requests))
+	}
+}
+
+func TestRetrySkipsPost(t *testing.T) {
+	f := &couchtest.Transport{Responses: []*http.Response{unavailable(), unavailable()}}
+	defer uninstallFakeHTTP(installFakeHTTP(f))
+
+	_, _, err := Database{}.WithRetry(quickRetry).insert([]byte(`{}`))
+	if err == nil {
+		t.Fatalf("Expected error")
+	}
+	if len(f.Requests) != 1 {
+		t.Errorf("Expected one attempt, got %v", len(f.Requests))
+	}
+}
+
+// flakyHTTP fails with err before answering successfully.
+type flakyHTTP struct {
+	fails  int
+	err    error
+	bodies []string
+}
+
+func (f *flakyHTTP) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Body != nil {
+		b, _ := ioutil.ReadAll(req.Body)
+		f.bodies = append(f.bodies, string(b))
+	}
+	if f.fails > 0 {
+		f.fails--
+		return nil, f.err
+	}
+	return &http.Response{
+		StatusCode: 200,
+		Body:       ioutil.NopCloser(strings.NewReader(`{"ok": true, "rev": "2-x"}`)),
+	}, nil
+}
+
+func TestRetryReplaysBody(t *testing.T) {
+	f := &flakyHTTP{fails: 2, err: syscall.ECONNRESET}
+	d := Database{client: &http.Client{Transport: f}}.WithRetry(quickRetry)
+
+	rev, err := d.EditWith(map[string]string{"a": "b"}, "x", "1-x")
+	if err != nil {
+		t.Fatalf("Expected success, got %v", err)
+	}
+	if rev != "2-x" {
+		t.Errorf("Expected rev 2-x, got %v", rev)
+	}
+	if len(f.bodies) != 3 {
+		t.Fatalf("Expected 3 attempts, got %v", f.bodies)
+	}
+	for _, b := range f.bodies {
+		if b != f.bodies[0] || b == "" {
+			t.Errorf("Expected identical bodies, got %q", f.bodies)
+		}
+	}
+}
+
+func TestRetryStreamedBody(t *testing.T) {
+	req, _ := http.NewRequest("PUT", "http://localhost/db/x/a",
+		io.MultiReader(strings.NewReader("hello "), strings.NewReader("world")))
+	var bodies []string
+	res, err := quickRetry.do(req, func(req *http.Request) (*http.Response, error) {
+		b, _ := ioutil.ReadAll(req.Body)
+		bodies = append(bodies, string(b))
+		return unavailable(), nil
+	})
+	if err != nil || res.StatusCode != 503 {
+		t.Errorf("Expected the 503 returned, got %v, %v", res, err)
+	}
+	if len(bodies) != 1 || bodies[0] != "hello world" {
+		t.Errorf("Expected a single attempt, got %q", bodies)
+	}
+	if err := rewind(req); err != errNoRewind {
+		t.Errorf("Expected errNoRewind, got %v", err)
+	}
+}
+
+func TestTransient(t *testing.T) {
+	timeout := &net.OpError{Op: "read", Err: &timeoutErr{}}
+	tests := []struct {
+		status int
+		err    error
+		exp    bool
+	}{
+		{200, nil, false},
+		{404, nil, false},
+		{500, nil, false},
+		{502, nil, true},
+		{503, nil, true},
+		{504, nil, true},
+		{0, io.EOF, true},
+		{0, syscall.ECONNREFUSED, true},
+		{0, timeout, true},
+		{0, errors.New("unsupported protocol scheme"), false},
+		{0, ErrOverloaded, false},
+	}
+	for _, test := range tests {
+		var res *http.Response
+		if test.err == nil {
+			res = &http.Response{StatusCode: test.status}
+		}
+		if got := transient(res, test.err); got != test.exp {
+			t.Errorf("transient(%v, %v) = %v", test.status, test.err, got)
+		}
+	}
+}
+
+type timeoutErr struct{}
+
+func (timeoutErr) Error() string   { return "timeout" }
+func (timeoutErr) Timeout() bool   { return true }
+func (timeoutErr) Temporary() bool { return true }
+
+func TestBackoff(t *testing.T) {
+	r := RetryPolicy{InitialBackoff: 100 * time.Millisecond, MaxBackoff: time.Second}
+	for i, max := range []time.Duration{100, 200, 400, 800, 1000, 1000} {
+		max *= time.Millisecond
+		got := r.backoff(i)
+		if got < max/2 || got > max {
+			t.Errorf("Expected backoff %v in [%v, %v], got %v", i, max/2, max, got)
+		}
+	}
+}
@@ -0,0 +1,23 @@
+package couch
+
+import (
+	"strconv"
+)
+
+// RevsLimit returns how many revisions of each document's history the
+// database keeps track of (1000 by default).
+func (p Database) RevsLimit() (int, error) {
+	var limit int
+	err := p.unmarshalURL(p.dbURL("_revs_limit"), &limit)
+	return limit, err
+}
+
+// SetRevsLimit sets how many revisions of each document's history the
+// database keeps track of.  Lowering it saves space in databases whose
+// documents change often, at the risk of spurious conflicts when
+// replicating with a peer that has been out of touch for longer.
+func (p Database) SetRevsLimit(limit int) error {
+	_, err := p.interact("PUT", p.dbURL("_revs_limit"), p.defaultHdrs,
+		[]byte(strconv.Itoa(limit)), &Response{})
+	return err
+}
@@ -0,0 +1,30 @@
+package couch
+
+import (
+	"io/ioutil"
+	"net/http"
+	"testing"
+
+	"github.com/dustin/go-couch/couchtest"
+)
+
+func TestRevsLimit(t *testing.T) {
+	f := &couchtest.Transport{Responses: []*http.Response{
+		docResponse(`1000`), docResponse(`{"ok": true}`)}}
+	defer uninstallFakeHTTP(installFakeHTTP(f))
+
+	d := Database{Name: "db"}
+	limit, err := d.RevsLimit()
+	if err != nil || limit != 1000 {
+		t.Errorf("Expected 1000, got %v, %v", limit, err)
+	}
+	if err := d.SetRevsLimit(10); err != nil {
+		t.Fatalf("Error setting limit: %v", err)
+	}
+	req := f.Requests[1]
+	body, _ := ioutil.ReadAll(req.Body)
+	if req.Method != "PUT" || req.URL.Path != "/db/_revs_limit" ||
+		string(body) != "10" {
+		t.Errorf("Unexpected request %v %v %s", req.Method, req.URL, body)
+	}
+}
@@ -0,0 +1,272 @@
+package couch
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+// Rows is the result of QueryView or FindRows, decoded one row at a
+// time as it's read, in the manner of database/sql:
+//
+//	rows, err := db.QueryView("_design/d/_view/v", nil)
+//	if err != nil { ... }
+//	defer rows.Close()
+//	for rows.Next() {
+//		var key string
+//		var value int
+//		if err := rows.Scan(&key, &value, nil); err != nil { ... }
+//	}
+//	if err := rows.Err(); err != nil { ... }
+type Rows struct {
+	// Totals from the response, if they precede the rows (as they do
+	// for views and _all_docs).
+	TotalRows uint64
+	Offset    uint64
+	// The database sequence the view reflects, with update_seq=true
+	// (see ViewOptions.UpdateSeq), e.g. to follow the changes feed
+	// from there.
+	UpdateSeq Sequence
+
+	body io.ReadCloser
+	dec  *json.Decoder
+	docs bool // the rows are documents, from _find
+	row  ViewRow
+	err  error
+	done bool
+}
+
+// ViewRow is a row of a view, or of _all_docs, left undecoded.
+type ViewRow struct {
+	ID    string          `json:"id"`
+	Key   json.RawMessage `json:"key"`
+	Value json.RawMessage `json:"value"`
+	// The document, with include_docs.
+	Doc json.RawMessage `json:"doc"`
+	// Why there's no such row, e.g. "not_found" for a key passed to
+	// _all_docs that doesn't exist.
+	Error string `json:"error"`
+}
+
+// ScanKey decodes the row's key into dest.
+func (r ViewRow) ScanKey(dest interface{}) error {
+	return scanRaw(r.Key, dest)
+}
+
+// ScanValue decodes the row's value into dest, e.g. a struct for a view
+// emitting objects.  A row reporting an error, such as a key passed to
+// _all_docs that wasn't found, returns it, and a row without a value
+// leaves dest alone.
+func (r ViewRow) ScanValue(dest interface{}) error {
+	if r.Error != "" {
+		return fmt.Errorf("couch: row %s: %s", r.Key, r.Error)
+	}
+	return scanRaw(r.Value, dest)
+}
+
+// scanRaw decodes part of a row, if it's there, into dest.
+func scanRaw(part json.RawMessage, dest interface{}) error {
+	if part == nil {
+		return nil
+	}
+	return json.Unmarshal(part, dest)
+}
+
+// QueryView runs a view, or _all_docs, returning its rows to be read
+// incrementally.  The view and options are as for Query.
+func (p Database) QueryView(view string, options map[string]interface{}) (*Rows, error) {
+	if view == "" {
+		return nil, errEmptyView
+	}
+	fullURL, err := p.ViewURL(view, options)
+	if err != nil {
+		return nil, err
+	}
+	body, err := p.getBody(fullURL)
+	if err != nil {
+		return nil, err
+	}
+	return newRows(body, "rows")
+}
+
+// FindRows runs a Mango query (a FindQuery, or the body of a _find
+// request, e.g. {"selector": {"type": "user"}}), returning the matching documents to
+// be read incrementally.  Each row holds only a document.
+func (p Database) FindRows(query interface{}) (*Rows, error) {
+	jsonBuf, err := json.Marshal(query)
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequest("POST", p.dbURL("_find"), bytes.NewReader(jsonBuf))
+	if err != nil {
+		return nil, err
+	}
+	req.Header = http.Header{}
+	for k, v := range p.defaultHdrs {
+		req.Header[k] = v
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	res, err := p.do(req)
+	if err != nil {
+		return nil, err
+	}
+	if res.StatusCode != 200 {
+		defer res.Body.Close()
+		return nil, newHTTPError(res)
+	}
+	return newRows(res.Body, "docs")
+}
+
+// newRows reads body up to the start of the named array of rows.
+func newRows(body io.ReadCloser, field string) (*Rows, error) {
+	r := &Rows{body: body, dec: json.NewDecoder(body), docs: field == "docs"}
+	if err := r.start(field); err != nil {
+		body.Close()
+		return nil, err
+	}
+	return r, nil
+}
+
+func (r *Rows) start(field string) error {
+	if err := expectDelim(r.dec, '{'); err != nil {
+		return err
+	}
+	for r.dec.More() {
+		tok, err := r.dec.Token()
+		if err != nil {
+			return err
+		}
+		switch tok {
+		case field:
+			return expectDelim(r.dec, '[')
+		case "total_rows":
+			err = r.dec.Decode(&r.TotalRows)
+		case "offset":
+			err = r.dec.Decode(&r.Offset)
+		case "update_seq":
+			err = r.dec.Decode(&r.UpdateSeq)
+		default:
+			err = r.dec.Decode(&json.RawMessage{})
+		}
+		if err != nil {
+			return err
+		}
+	}
+	// No rows at all, e.g. an empty _find result without "docs".
+	r.done = true
+	return nil
+}
+
+// Next prepares the next row for Scan, returning false when there are
+// no more rows or an error occurred (see Err).  The body is closed
+// after the last row.
+func (r *Rows) Next() bool {
+	if r.done || r.err != nil || r.body == nil {
+		return false
+	}
+	if !r.dec.More() {
+		r.done = true
+		r.Close()
+		return false
+	}
+	r.row = ViewRow{}
+	if r.docs {
+		r.err = r.dec.Decode(&r.row.Doc)
+	} else {
+		r.err = r.dec.Decode(&r.row)
+	}
+	if r.err != nil {
+		r.Close()
+		return false
+	}
+	return true
+}
+
+// Row returns the current row.  For FindRows, only its Doc is set.
+func (r *Rows) Row() ViewRow {
+	return r.row
+}
+
+// ID returns the document id of the current row.
+func (r *Rows) ID() string {
+	if r.docs {
+		doc := idAndRev{}
+		json.Unmarshal(r.row.Doc, &doc)
+		return doc.ID
+	}
+	return r.row.ID
+}
+
+// Link returns the id of the document the current row links to, as
+// for Row.Link.  With include_docs, the doc that Scan decodes is then
+// the linked document rather than the one that emitted the row.
+func (r *Rows) Link() string {
+	return linkedID(r.row.Value)
+}
+
+var errNoRow = errors.New("couch: Scan called without a row")
+
+// Scan decodes the current row's key, value and document (with
+// include_docs) into the given pointers, any of which may be nil to
+// skip it.  Missing parts are left alone.  A row reporting an error,
+// such as a key passed to _all_docs that wasn't found, returns it.
+func (r *Rows) Scan(key, value, doc interface{}) error {
+	if r.row.Key == nil && r.row.Doc == nil && r.row.Error == "" {
+		return errNoRow
+	}
+	if r.row.Error != "" {
+		return fmt.Errorf("couch: row %s: %s", r.row.Key, r.row.Error)
+	}
+	for _, part := range []struct {
+		raw json.RawMessage
+		dst interface{}
+	}{{r.row.Key, key}, {r.row.Value, value}, {r.row.Doc, doc}} {
+		if part.dst == nil || part.raw == nil {
+			continue
+		}
+		if err := json.Unmarshal(part.raw, part.dst); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Err returns the error, if any, that stopped Next.
+func (r *Rows) Err() error {
+	return r.err
+}
+
+// Close releases the response, abandoning any rows not yet read.  It's
+// safe to call more than once, and needn't be called once Next has
+// returned false.
+func (r *Rows) Close() error {
+	if r.body == nil {
+		return nil
+	}
+	err := r.body.Close()
+	r.body = nil
+	return err
+}
+
+// QueryEach runs a view, or _all_docs, calling f with each row as it's
+// decoded, so results needn't fit in memory.  An error from f stops
+// the query and is returned.
+func (p Database) QueryEach(view string, options map[string]interface{},
+	f func(ViewRow) error) error {
+
+	rows, err := p.QueryView(view, options)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		if err := f(rows.Row()); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
@@ -0,0 +1,241 @@
+package couch
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/dustin/go-couch/couchtest"
+)
+
+type closeRecorder struct {
+	*strings.Reader
+	closed bool
+}
+
+func (c *closeRecorder) Close() error {
+	c.closed = true
+	return nil
+}
+
+func TestQueryView(t *testing.T) {
+	f := couchtest.NewTransport(docResponse(`{"total_rows": 3, "offset": 1, "rows": [
+		{"id": "a", "key": ["x", 1], "value": 10, "doc": {"_id": "a", "n": 1}},
+		{"id": "b", "key": ["x", 2], "value": 20, "doc": null}
+	]}`))
+	defer uninstallFakeHTTP(installFakeHTTP(f))
+
+	rows, err := Database{Name: "db"}.QueryView("_design/d/_view/v",
+		map[string]interface{}{"include_docs": true})
+	if err != nil {
+		t.Fatalf("Error querying: %v", err)
+	}
+	defer rows.Close()
+	if rows.TotalRows != 3 || rows.Offset != 1 {
+		t.Errorf("Unexpected totals %v/%v", rows.TotalRows, rows.Offset)
+	}
+
+	var ids []string
+	var keys [][]interface{}
+	var values []int
+	var docs []map[string]interface{}
+	for rows.Next() {
+		var key []interface{}
+		var value int
+		var doc map[string]interface{}
+		if err := rows.Scan(&key, &value, &doc); err != nil {
+			t.Fatalf("Error scanning: %v", err)
+		}
+		ids = append(ids, rows.ID())
+		keys = append(keys, key)
+		values = append(values, value)
+		docs = append(docs, doc)
+	}
+	if err := rows.Err(); err != nil {
+		t.Fatalf("Error reading rows: %v", err)
+	}
+
+	if !reflect.DeepEqual(ids, []string{"a", "b"}) ||
+		!reflect.DeepEqual(values, []int{10, 20}) ||
+		!reflect.DeepEqual(keys, [][]interface{}{{"x", 1.0}, {"x", 2.0}}) {
+		t.Errorf("Unexpected rows %v %v %v", ids, keys, values)
+	}
+	if docs[0]["n"] != 1.0 || docs[1] != nil {
+		t.Errorf("Unexpected docs %v", docs)
+	}
+}
+
+func TestQueryViewUpdateSeq(t *testing.T) {
+	f := couchtest.NewTransport(docResponse(`{"total_rows": 1, "offset": 0,
+		"update_seq": "12-g1AAAABXeJzLYWBgYMpgTmHgz8tPSTV0MDQy", "rows": [
+		{"id": "a", "key": 1, "value": null}]}`))
+	defer uninstallFakeHTTP(installFakeHTTP(f))
+
+	rows, err := Database{Name: "db"}.QueryView("_design/d/_view/v",
+		ViewOptions{UpdateSeq: true}.Options())
+	if err != nil {
+		t.Fatalf("Error querying: %v", err)
+	}
+	defer rows.Close()
+	if rows.UpdateSeq != "12-g1AAAABXeJzLYWBgYMpgTmHgz8tPSTV0MDQy" {
+		t.Errorf("Unexpected update seq %q", rows.UpdateSeq)
+	}
+	if !rows.Next() || rows.Row().ID != "a" {
+		t.Errorf("Expected a row, got %+v, %v", rows.Row(), rows.Err())
+	}
+	if got := f.Requests[0].URL.Query().Get("update_seq"); got != "true" {
+		t.Errorf("Expected update_seq=true, got %q", got)
+	}
+}
+
+func TestRowsEarlyClose(t *testing.T) {
+	body := &closeRecorder{Reader: strings.NewReader(`{"rows": [
+		{"id": "a", "key": "a", "value": 1},
+		{"id": "b", "key": "b", "value": 2}]}`)}
+	rows, err := newRows(body, "rows")
+	if err != nil {
+		t.Fatalf("Error starting rows: %v", err)
+	}
+	if !rows.Next() {
+		t.Fatalf("Expected a row, got %v", rows.Err())
+	}
+	rows.Close()
+	if !body.closed || rows.Next() {
+		t.Errorf("Expected rows closed")
+	}
+	rows.Close()
+}
+
+func TestRowsErrors(t *testing.T) {
+	rows, err := newRows(ioutil.NopCloser(strings.NewReader(`{"rows": [
+		{"key": "missing", "error": "not_found"},
+		{"id": "a", "key": "a", "value": {"rev": "1-a"}},
+		{"id": "b", "key"`)), "rows")
+	if err != nil {
+		t.Fatalf("Error starting rows: %v", err)
+	}
+	if err := rows.Scan(nil, nil, nil); err != errNoRow {
+		t.Errorf("Expected errNoRow before Next, got %v", err)
+	}
+	rows.Next()
+	if err := rows.Scan(nil, nil, nil); err == nil ||
+		!strings.Contains(err.Error(), "not_found") {
+		t.Errorf("Expected not_found row, got %v", err)
+	}
+	if !rows.Next() || rows.Next() {
+		t.Errorf("Expected one good row before the truncation")
+	}
+	if rows.Err() == nil {
+		t.Errorf("Expected an error from the truncated row")
+	}
+
+	if _, err := newRows(ioutil.NopCloser(strings.NewReader(`[]`)), "rows"); err == nil {
+		t.Errorf("Expected error for a response that isn't an object")
+	}
+}
+
+func TestFindRows(t *testing.T) {
+	f := couchtest.NewTransport(docResponse(`{"docs": [{"_id": "u1", "name": "ann"},
+		{"_id": "u2", "name": "bob"}], "bookmark": "xyz"}`))
+	defer uninstallFakeHTTP(installFakeHTTP(f))
+
+	rows, err := Database{Name: "db"}.FindRows(map[string]interface{}{
+		"selector": map[string]interface{}{"type": "user"},
+	})
+	if err != nil {
+		t.Fatalf("Error finding: %v", err)
+	}
+	var names []string
+	for rows.Next() {
+		doc := struct{ Name string }{}
+		if err := rows.Scan(nil, nil, &doc); err != nil {
+			t.Fatalf("Error scanning: %v", err)
+		}
+		names = append(names, rows.ID()+"="+doc.Name)
+	}
+	if rows.Err() != nil || !reflect.DeepEqual(names, []string{"u1=ann", "u2=bob"}) {
+		t.Errorf("Unexpected docs %v, %v", names, rows.Err())
+	}
+
+	req := f.Requests[0]
+	sent := map[string]interface{}{}
+	must(json.NewDecoder(req.Body).Decode(&sent))
+	if req.Method != "POST" || req.URL.Path != "/db/_find" || sent["selector"] == nil {
+		t.Errorf("Unexpected request %v %v %v", req.Method, req.URL, sent)
+	}
+}
+
+func TestFindRowsError(t *testing.T) {
+	defer uninstallFakeHTTP(installFakeHTTP(couchtest.NewTransport(&http.Response{
+		StatusCode: 400,
+		Body: ioutil.NopCloser(strings.NewReader(
+			`{"error": "bad_request", "reason": "invalid selector"}`)),
+	})))
+	if _, err := (Database{}).FindRows(map[string]interface{}{}); err == nil {
+		t.Errorf("Expected error")
+	}
+}
+
+func TestQueryEach(t *testing.T) {
+	f := couchtest.NewTransport(docResponse(`{"total_rows": 3, "offset": 0, "rows": [
+		{"id": "a", "key": 1, "value": null},
+		{"id": "b", "key": 2, "value": null},
+		{"id": "c", "key": 3, "value": null}]}`))
+	defer uninstallFakeHTTP(installFakeHTTP(f))
+
+	var ids []string
+	stop := errors.New("stop")
+	err := Database{Name: "db"}.QueryEach("_all_docs", nil, func(row ViewRow) error {
+		ids = append(ids, row.ID)
+		if string(row.Key) == "2" {
+			return stop
+		}
+		return nil
+	})
+	if err != stop || !reflect.DeepEqual(ids, []string{"a", "b"}) {
+		t.Errorf("Unexpected %v, %v", ids, err)
+	}
+}
+
+func TestViewRowScan(t *testing.T) {
+	f := couchtest.NewTransport(docResponse(`{"rows": [
+		{"id": "a", "key": ["user", 1], "value": {"name": "ann"}},
+		{"id": "b", "key": ["order", 2], "value": 9.5},
+		{"id": "c", "key": ["user", 3]},
+		{"key": ["user", 4], "error": "not_found"}]}`))
+	defer uninstallFakeHTTP(installFakeHTTP(f))
+
+	var got []string
+	err := Database{Name: "db"}.QueryEach("_design/d/_view/v", nil, func(row ViewRow) error {
+		var key []interface{}
+		if err := row.ScanKey(&key); err != nil {
+			return err
+		}
+		switch key[0] {
+		case "user":
+			user := struct{ Name string }{"none"}
+			if err := row.ScanValue(&user); err != nil {
+				return err
+			}
+			got = append(got, user.Name)
+		case "order":
+			var total float64
+			if err := row.ScanValue(&total); err != nil {
+				return err
+			}
+			got = append(got, fmt.Sprint(total))
+		}
+		return nil
+	})
+	if err == nil || !strings.Contains(err.Error(), "not_found") {
+		t.Errorf("Expected the missing row's error, got %v", err)
+	}
+	if !reflect.DeepEqual(got, []string{"ann", "9.5", "none"}) {
+		t.Errorf("Unexpected values %v", got)
+	}
+}
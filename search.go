@@ -0,0 +1,78 @@
+package couch
+
+import (
+	"encoding/json"
+	"strings"
+)
+
+// SearchQuery is a query of a search index (see Search).
+type SearchQuery struct {
+	// Lucene query, e.g. "name:ann AND age:[30 TO 40]".
+	Query string `json:"query"`
+	// Bookmark of the previous page, to continue from.
+	Bookmark string `json:"bookmark,omitempty"`
+	// Results per page (server default 25, maximum 200).
+	Limit int `json:"limit,omitempty"`
+	// Fields to sort by, e.g. []string{"-age<number>", "name<string>"}.
+	Sort []string `json:"sort,omitempty"`
+	// Include the documents of the results.
+	IncludeDocs bool `json:"include_docs,omitempty"`
+	// Fields of the index to return, if not all stored ones.
+	IncludeFields []string `json:"include_fields,omitempty"`
+	// Fields to count the distinct values of across all results.
+	Counts []string `json:"counts,omitempty"`
+	// Restrict results to these field values, each a field and value,
+	// e.g. {"type", "cat"}.  Several values of a field are ORed.
+	Drilldown [][]string `json:"drilldown,omitempty"`
+	// Fields to highlight matches in.
+	Highlights []string `json:"highlight_fields,omitempty"`
+	// Tags around highlighted terms (default <em> and </em>).
+	HighlightPreTag  string `json:"highlight_pre_tag,omitempty"`
+	HighlightPostTag string `json:"highlight_post_tag,omitempty"`
+	// Number and size of highlighted fragments per field.
+	HighlightNumber int `json:"highlight_number,omitempty"`
+	HighlightSize   int `json:"highlight_size,omitempty"`
+	// "ok" to use the index as it is, rather than bringing it up to
+	// date first.
+	Stale string `json:"stale,omitempty"`
+}
+
+// SearchRow is a result of a search.
+type SearchRow struct {
+	ID     string                 `json:"id"`
+	Order  []interface{}          `json:"order"`
+	Fields map[string]interface{} `json:"fields"`
+	// With IncludeDocs.
+	Doc json.RawMessage `json:"doc"`
+	// Highlighted fragments by field, with Highlights.
+	Highlights map[string][]string `json:"highlights"`
+}
+
+// SearchResults are the results of a search, suitable for the results
+// argument of Search.
+type SearchResults struct {
+	TotalRows int         `json:"total_rows"`
+	Bookmark  string      `json:"bookmark"`
+	Rows      []SearchRow `json:"rows"`
+	// Value counts by field, with Counts.
+	Counts map[string]map[string]int `json:"counts"`
+}
+
+// Search queries the named search index of a design document (named
+// with or without the "_design/" prefix), as supported by Cloudant
+// and by CouchDB 3's search plugin, unmarshaling the response into
+// results, typically a *SearchResults.  Pass the response's bookmark
+// in the next query to get the following page.
+func (p Database) Search(ddoc, index string, query SearchQuery, results interface{}) error {
+	if ddoc == "" || index == "" {
+		return errEmptyView
+	}
+	body, err := json.Marshal(query)
+	if err != nil {
+		return err
+	}
+	ddoc = strings.TrimPrefix(ddoc, "_design/")
+	u := p.dbURL("_design/" + pathEscape(ddoc) + "/_search/" + pathEscape(index))
+	_, err = p.interact("POST", u, p.defaultHdrs, body, results)
+	return err
+}
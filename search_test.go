@@ -0,0 +1,65 @@
+package couch
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/dustin/go-couch/couchtest"
+)
+
+func TestSearch(t *testing.T) {
+	f := couchtest.NewTransport(docResponse(`{"total_rows": 12, "bookmark": "g1AAA",
+		"rows": [{"id": "a", "order": [1.5, 0], "fields": {"name": "ann"},
+			"doc": {"_id": "a"}, "highlights": {"name": ["<em>ann</em>"]}}],
+		"counts": {"type": {"cat": 3, "dog": 9}}}`))
+	defer uninstallFakeHTTP(installFakeHTTP(f))
+
+	res := SearchResults{}
+	err := Database{Name: "db"}.Search("_design/d", "animals", SearchQuery{
+		Query:       "name:ann",
+		Bookmark:    "g1AAZ",
+		Limit:       1,
+		IncludeDocs: true,
+		Counts:      []string{"type"},
+		Drilldown:   [][]string{{"type", "cat"}},
+		Highlights:  []string{"name"},
+		Stale:       "ok",
+	}, &res)
+	if err != nil {
+		t.Fatalf("Error searching: %v", err)
+	}
+	if res.TotalRows != 12 || res.Bookmark != "g1AAA" || len(res.Rows) != 1 ||
+		res.Rows[0].Fields["name"] != "ann" || res.Rows[0].Highlights["name"][0] != "<em>ann</em>" ||
+		string(res.Rows[0].Doc) != `{"_id": "a"}` || res.Counts["type"]["dog"] != 9 {
+		t.Errorf("Unexpected results %+v", res)
+	}
+
+	req := f.Requests[0]
+	sent := map[string]interface{}{}
+	must(json.NewDecoder(req.Body).Decode(&sent))
+	if req.Method != "POST" || req.URL.Path != "/db/_design/d/_search/animals" {
+		t.Errorf("Unexpected request %v %v", req.Method, req.URL)
+	}
+	exp := map[string]interface{}{
+		"query": "name:ann", "bookmark": "g1AAZ", "limit": 1.0,
+		"include_docs": true, "counts": []interface{}{"type"},
+		"drilldown":        []interface{}{[]interface{}{"type", "cat"}},
+		"highlight_fields": []interface{}{"name"}, "stale": "ok",
+	}
+	if len(sent) != len(exp) {
+		t.Errorf("Expected %v, sent %v", exp, sent)
+	}
+	for k, v := range exp {
+		a, _ := json.Marshal(sent[k])
+		b, _ := json.Marshal(v)
+		if string(a) != string(b) {
+			t.Errorf("%v: expected %s, sent %s", k, b, a)
+		}
+	}
+}
+
+func TestSearchNoIndex(t *testing.T) {
+	if err := (Database{}).Search("d", "", SearchQuery{}, nil); err != errEmptyView {
+		t.Errorf("Expected errEmptyView, got %v", err)
+	}
+}
@@ -0,0 +1,39 @@
+package couch
+
+import (
+	"encoding/json"
+)
+
+// SecurityGroup lists the users, by name or role, in a group of a
+// database's security object.
+type SecurityGroup struct {
+	Names []string `json:"names,omitempty"`
+	Roles []string `json:"roles,omitempty"`
+}
+
+// Security is a database's security object.  Members may read and
+// write documents, and admins may also change design documents and the
+// security object.  A database with no members is public (though
+// CouchDB 3 and later restrict new databases to server admins).
+type Security struct {
+	Admins  SecurityGroup `json:"admins"`
+	Members SecurityGroup `json:"members"`
+}
+
+// GetSecurity returns the database's security object.
+func (p Database) GetSecurity() (Security, error) {
+	sec := Security{}
+	err := p.unmarshalURL(p.dbURL("_security"), &sec)
+	return sec, err
+}
+
+// SetSecurity replaces the database's security object.  Only server
+// and database admins may do so.
+func (p Database) SetSecurity(sec Security) error {
+	body, err := json.Marshal(sec)
+	if err != nil {
+		return err
+	}
+	_, err = p.interact("PUT", p.dbURL("_security"), p.defaultHdrs, body, &Response{})
+	return err
+}
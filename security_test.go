@@ -0,0 +1,59 @@
+package couch
+
+import (
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/dustin/go-couch/couchtest"
+)
+
+func TestGetSecurity(t *testing.T) {
+	defer uninstallFakeHTTP(installFakeHTTP(couchtest.NewTransport(docResponse(
+		`{"admins": {"names": ["root"], "roles": []},
+		  "members": {"roles": ["tenant-a"]}}`))))
+
+	sec, err := Database{Name: "db"}.GetSecurity()
+	if err != nil {
+		t.Fatalf("Error getting security: %v", err)
+	}
+	exp := Security{
+		Admins:  SecurityGroup{Names: []string{"root"}, Roles: []string{}},
+		Members: SecurityGroup{Roles: []string{"tenant-a"}},
+	}
+	if !reflect.DeepEqual(sec, exp) {
+		t.Errorf("Expected %+v, got %+v", exp, sec)
+	}
+}
+
+func TestSetSecurity(t *testing.T) {
+	f := couchtest.NewTransport(docResponse(`{"ok": true}`))
+	defer uninstallFakeHTTP(installFakeHTTP(f))
+
+	err := Database{Name: "db"}.SetSecurity(Security{
+		Members: SecurityGroup{Names: []string{"ann"}},
+	})
+	if err != nil {
+		t.Fatalf("Error setting security: %v", err)
+	}
+	req := f.Requests[0]
+	body, _ := ioutil.ReadAll(req.Body)
+	if req.Method != "PUT" || req.URL.Path != "/db/_security" ||
+		string(body) != `{"admins":{},"members":{"names":["ann"]}}` {
+		t.Errorf("Unexpected request %v %v %s", req.Method, req.URL, body)
+	}
+}
+
+func TestSetSecurityForbidden(t *testing.T) {
+	defer uninstallFakeHTTP(installFakeHTTP(couchtest.NewTransport(&http.Response{
+		StatusCode: 403,
+		Body: ioutil.NopCloser(strings.NewReader(
+			`{"error": "forbidden", "reason": "You are not a db or server admin."}`)),
+	})))
+	if err := (Database{}).SetSecurity(Security{}); !errors.Is(err, ErrForbidden) {
+		t.Errorf("Expected forbidden, got %v", err)
+	}
+}
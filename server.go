@@ -0,0 +1,131 @@
+package couch
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// ErrMaintenanceMode is returned by Up when the node is in maintenance
+// mode, or otherwise asking load balancers to stop sending it requests.
+var ErrMaintenanceMode = errors.New("couch: node in maintenance mode")
+
+// Up checks that the node is up and accepting requests, using /_up
+// (ignores Database.Name).  It's cheaper than Running, and needs no
+// credentials, making it suitable for health checks.
+func (p Database) Up() error {
+	req, err := createReq(p.serverURL("_up"))
+	if err != nil {
+		return err
+	}
+	res, err := p.do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	defer io.Copy(ioutil.Discard, res.Body)
+	if res.StatusCode == http.StatusOK {
+		return nil
+	}
+
+	body, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return err
+	}
+	s := struct {
+		Status string `json:"status"`
+	}{}
+	if res.StatusCode == http.StatusNotFound && json.Unmarshal(body, &s) == nil &&
+		(s.Status == "maintenance_mode" || s.Status == "nolb") {
+		return ErrMaintenanceMode
+	}
+	res.Body = ioutil.NopCloser(bytes.NewReader(body))
+	return newHTTPError(res)
+}
+
+// ServerInfo is the server's welcome document.
+type ServerInfo struct {
+	CouchDB  string   `json:"couchdb"` // "Welcome"
+	Version  string   `json:"version"` // e.g. "3.3.2"
+	GitSHA   string   `json:"git_sha"`
+	UUID     string   `json:"uuid"`
+	Features []string `json:"features"` // e.g. "partitioned"
+	Vendor   struct {
+		Name    string `json:"name"`
+		Version string `json:"version"`
+	} `json:"vendor"`
+}
+
+// ServerInfo returns the server's welcome document (ignores
+// Database.Name).
+func (p Database) ServerInfo() (ServerInfo, error) {
+	info := ServerInfo{}
+	err := p.unmarshalURL(p.serverURL(""), &info)
+	return info, err
+}
+
+// AtLeast reports whether the server's version is the given one, such
+// as "3.2", or later.
+func (s ServerInfo) AtLeast(version string) bool {
+	have, want := versionParts(s.Version), versionParts(version)
+	for i, w := range want {
+		h := 0
+		if i < len(have) {
+			h = have[i]
+		}
+		if h != w {
+			return h > w
+		}
+	}
+	return true
+}
+
+// versionParts splits a version like "3.2.1-rc1" into its numbers.
+func versionParts(v string) []int {
+	var parts []int
+	for _, s := range strings.Split(v, ".") {
+		end := 0
+		for end < len(s) && s[end] >= '0' && s[end] <= '9' {
+			end++
+		}
+		n, _ := strconv.Atoi(s[:end])
+		parts = append(parts, n)
+		if end < len(s) {
+			break
+		}
+	}
+	return parts
+}
+
+// HasFeature reports whether the server lists the named feature, e.g.
+// "partitioned" or "access-ready".
+func (s ServerInfo) HasFeature(name string) bool {
+	for _, f := range s.Features {
+		if f == name {
+			return true
+		}
+	}
+	return false
+}
+
+// AllDBs lists the names of the databases on the server, in order
+// (ignores Database.Name).  options are as for Query, e.g.
+// {"startkey": "a", "endkey": "b\ufff0", "limit": 10}.
+func (p Database) AllDBs(options map[string]interface{}) ([]string, error) {
+	values, err := viewParams(options)
+	if err != nil {
+		return nil, err
+	}
+	u := p.serverURL("_all_dbs")
+	if len(values) > 0 {
+		u += "?" + values.Encode()
+	}
+	dbs := []string{}
+	err = p.unmarshalURL(u, &dbs)
+	return dbs, err
+}
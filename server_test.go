@@ -0,0 +1,102 @@
+package couch
+
+import (
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/dustin/go-couch/couchtest"
+)
+
+func TestUp(t *testing.T) {
+	respond := func(status int, body string) *http.Response {
+		return &http.Response{StatusCode: status,
+			Body: ioutil.NopCloser(strings.NewReader(body))}
+	}
+	f := &couchtest.Transport{Responses: []*http.Response{
+		respond(200, `{"status": "ok"}`),
+		respond(404, `{"status": "maintenance_mode"}`),
+		respond(404, `{"status": "nolb"}`),
+		respond(404, `{"error": "not_found", "reason": "missing"}`),
+	}}
+	defer uninstallFakeHTTP(installFakeHTTP(f))
+
+	d := Database{Name: "db"}
+	if err := d.Up(); err != nil {
+		t.Errorf("Expected up, got %v", err)
+	}
+	if f.Requests[0].URL.Path != "/_up" {
+		t.Errorf("Unexpected request %v", f.Requests[0].URL)
+	}
+	for i := 0; i < 2; i++ {
+		if err := d.Up(); err != ErrMaintenanceMode {
+			t.Errorf("Expected maintenance mode, got %v", err)
+		}
+	}
+	if err := d.Up(); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected not found, got %v", err)
+	}
+	if err := d.Up(); err == nil {
+		t.Errorf("Expected error for server error")
+	}
+}
+
+func TestServerInfo(t *testing.T) {
+	f := couchtest.NewTransport(docResponse(`{"couchdb": "Welcome", "version": "3.2.1",
+		"features": ["access-ready", "partitioned"],
+		"vendor": {"name": "The Apache Software Foundation"}}`))
+	defer uninstallFakeHTTP(installFakeHTTP(f))
+
+	info, err := Database{Name: "db"}.ServerInfo()
+	if err != nil {
+		t.Fatalf("Error fetching server info: %v", err)
+	}
+	if f.Requests[0].URL.Path != "/" {
+		t.Errorf("Unexpected request %v", f.Requests[0].URL)
+	}
+	if info.Version != "3.2.1" || info.Vendor.Name != "The Apache Software Foundation" ||
+		!info.HasFeature("partitioned") || info.HasFeature("nouveau") {
+		t.Errorf("Unexpected info %+v", info)
+	}
+}
+
+func TestServerInfoAtLeast(t *testing.T) {
+	tests := []struct {
+		have, want string
+		exp        bool
+	}{
+		{"3.2.1", "3.2", true},
+		{"3.2.1", "3.2.1", true},
+		{"3.2.1", "3.2.2", false},
+		{"3.2", "3.2.0", true},
+		{"3.10.0", "3.9", true},
+		{"2.3.1", "3", false},
+		{"3.3.0-rc1", "3.3", true},
+		{"1.6.1", "1.7", false},
+	}
+	for _, test := range tests {
+		if got := (ServerInfo{Version: test.have}).AtLeast(test.want); got != test.exp {
+			t.Errorf("%v at least %v: expected %v", test.have, test.want, test.exp)
+		}
+	}
+}
+
+func TestAllDBs(t *testing.T) {
+	f := couchtest.NewTransport(docResponse(`["a1", "a2"]`))
+	defer uninstallFakeHTTP(installFakeHTTP(f))
+
+	dbs, err := Database{Name: "db"}.AllDBs(map[string]interface{}{
+		"startkey": "a", "endkey": "a\ufff0", "limit": 2,
+	})
+	if err != nil || len(dbs) != 2 || dbs[1] != "a2" {
+		t.Fatalf("Unexpected dbs %v, %v", dbs, err)
+	}
+	u := f.Requests[0].URL
+	q := u.Query()
+	if u.Path != "/_all_dbs" || q.Get("startkey") != `"a"` ||
+		q.Get("endkey") != `"a`+"\ufff0"+`"` || q.Get("limit") != "2" {
+		t.Errorf("Unexpected request %v", u)
+	}
+}
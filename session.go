@@ -0,0 +1,180 @@
+package couch
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// SessionAuth configures cookie authentication, for servers that
+// allow it in place of basic authentication, which otherwise sends the
+// password with every request and has it checked every time.
+type SessionAuth struct {
+	Username, Password string
+	// Renew the session once it's this old, before the server expires
+	// it.  Defaults to 5 minutes, half of CouchDB's default session
+	// timeout, or half the lifetime of the cookie if it has one.
+	RenewAfter time.Duration
+}
+
+const (
+	sessionCookie       = "AuthSession"
+	defaultSessionRenew = 5 * time.Minute
+)
+
+// session holds the cookie of a session, shared by copies of a
+// Database.
+type session struct {
+	auth SessionAuth
+
+	mu      sync.Mutex
+	cookie  *http.Cookie
+	renewAt time.Time
+}
+
+// WithSessionAuth returns a copy of the database that authenticates
+// with a session cookie, logging in with the given credentials before
+// its first request.
+//
+// The session is renewed when it's due, before the next request
+// (including a reconnection of a changes feed), and whenever the
+// server refreshes the cookie.  A request refused because the session
+// expired early, e.g. as the server restarted, logs in again and is
+// retried once.
+func (p Database) WithSessionAuth(a SessionAuth) Database {
+	p.session = &session{auth: a}
+	return p
+}
+
+// authorize adds the session cookie to req, logging in if there's no
+// session or it's due for renewal.  It reports whether it logged in.
+func (s *session) authorize(p Database, req *http.Request) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	fresh := false
+	if s.cookie == nil || !time.Now().Before(s.renewAt) {
+		if err := s.login(p); err != nil {
+			return false, err
+		}
+		fresh = true
+	}
+	if req.Header == nil {
+		req.Header = http.Header{}
+	}
+	req.Header.Set("Cookie", (&http.Cookie{Name: s.cookie.Name, Value: s.cookie.Value}).String())
+	return fresh, nil
+}
+
+// login starts a session.  s.mu must be held.
+func (s *session) login(p Database) error {
+	body, err := json.Marshal(map[string]string{
+		"name": s.auth.Username, "password": s.auth.Password,
+	})
+	if err != nil {
+		return err
+	}
+	u := p.baseURL()
+	u.User = nil
+	u.Path = "/_session"
+	req, err := http.NewRequest("POST", u.String(), bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	p.addDefaultHeaders(req)
+	if p.ctx != nil {
+		req = req.WithContext(p.ctx)
+	}
+	res, err := p.httpClient().Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.Request == nil {
+		res.Request = req
+	}
+	if res.StatusCode != 200 {
+		return newHTTPError(res)
+	}
+	s.cookie = nil
+	s.update(res)
+	if s.cookie == nil {
+		return &HTTPError{StatusCode: res.StatusCode, Status: res.Status,
+			Reason: "no session cookie in response"}
+	}
+	return nil
+}
+
+// update takes in a refreshed session cookie, or forgets the session
+// if res says it's no longer valid.  s.mu must be held.
+func (s *session) update(res *http.Response) {
+	if res.StatusCode == http.StatusUnauthorized {
+		s.cookie = nil
+		return
+	}
+	for _, c := range res.Cookies() {
+		if c.Name != sessionCookie {
+			continue
+		}
+		if c.Value == "" || c.MaxAge < 0 {
+			s.cookie = nil
+			return
+		}
+		s.cookie = c
+		s.renewAt = time.Now().Add(s.renewAfter(c))
+	}
+}
+
+// refresh is update, for a response to a request in the session.
+func (s *session) refresh(res *http.Response) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.update(res)
+}
+
+// renewAfter returns how long after it's set to renew the session
+// whose cookie is c.
+func (s *session) renewAfter(c *http.Cookie) time.Duration {
+	d := s.auth.RenewAfter
+	if d <= 0 {
+		d = defaultSessionRenew
+	}
+	var lifetime time.Duration
+	switch {
+	case c.MaxAge > 0:
+		lifetime = time.Duration(c.MaxAge) * time.Second
+	case !c.Expires.IsZero():
+		lifetime = time.Until(c.Expires)
+	}
+	if lifetime > 0 && lifetime/2 < d {
+		d = lifetime / 2
+	}
+	return d
+}
+
+// do sends req with send in the session, logging in again and
+// retrying once if the session turns out to have expired.
+func (s *session) do(p Database, req *http.Request,
+	send func(*http.Request) (*http.Response, error)) (*http.Response, error) {
+
+	for {
+		fresh, err := s.authorize(p, req)
+		if err != nil {
+			return nil, err
+		}
+		res, err := send(req)
+		if err != nil {
+			return res, err
+		}
+		s.refresh(res)
+		if res.StatusCode != http.StatusUnauthorized || fresh || !rewindable(req) {
+			return res, nil
+		}
+		res.Body.Close()
+		if err := rewind(req); err != nil {
+			return nil, err
+		}
+	}
+}
@@ -0,0 +1,128 @@
+package couch
+
+import (
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"math"
+)
+
+// ViewShard returns the shard, from 0 to n-1, that the document with
+// the given id contributes to.
+//
+// A view whose reduction for one key is updated constantly makes every
+// write contend for the same reduce tree nodes.  The usual cure is to
+// spread the key over several sub-keys: documents store a shard number
+// from ViewShard and the map function emits [key, shard] instead of
+// key.  QuerySharded puts the key back together at query time.
+//
+//	function(doc) { emit([doc.counter, doc.shard], 1); }
+func ViewShard(docID string, n int) int {
+	if n <= 1 {
+		return 0
+	}
+	h := fnv.New32a()
+	h.Write([]byte(docID))
+	return int(h.Sum32() % uint32(n))
+}
+
+// QuerySharded returns the reduced value for key from a reduce view
+// whose keys are [key, shard] pairs, merging the per-shard values with
+// merge.  A nil merge adds numbers together, which suits the _sum,
+// _count and _stats built-in reduce functions.  The result is null if
+// no shard has a value.
+func (p Database) QuerySharded(view string, key interface{},
+	merge func(a, b json.RawMessage) (json.RawMessage, error)) (json.RawMessage, error) {
+
+	if merge == nil {
+		merge = mergeSums
+	}
+	res := struct {
+		Rows []struct {
+			Value json.RawMessage
+		}
+	}{}
+	err := p.Query(view, map[string]interface{}{
+		"startkey":    []interface{}{key},
+		"endkey":      []interface{}{key, map[string]interface{}{}},
+		"group_level": 2,
+	}, &res)
+	if err != nil {
+		return nil, err
+	}
+
+	var acc json.RawMessage
+	for _, row := range res.Rows {
+		if acc == nil {
+			acc = row.Value
+			continue
+		}
+		if acc, err = merge(acc, row.Value); err != nil {
+			return nil, err
+		}
+	}
+	if acc == nil {
+		acc = json.RawMessage("null")
+	}
+	return acc, nil
+}
+
+// mergeSums adds two reduced values: numbers, arrays of numbers, or
+// _stats objects.
+func mergeSums(a, b json.RawMessage) (json.RawMessage, error) {
+	var av, bv interface{}
+	if err := json.Unmarshal(a, &av); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(b, &bv); err != nil {
+		return nil, err
+	}
+	v, err := addReduced(av, bv)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(v)
+}
+
+func addReduced(a, b interface{}) (interface{}, error) {
+	switch at := a.(type) {
+	case float64:
+		if bt, ok := b.(float64); ok {
+			return at + bt, nil
+		}
+	case []interface{}:
+		if bt, ok := b.([]interface{}); ok {
+			if len(bt) > len(at) {
+				at, bt = bt, at
+			}
+			for i := range bt {
+				v, err := addReduced(at[i], bt[i])
+				if err != nil {
+					return nil, err
+				}
+				at[i] = v
+			}
+			return at, nil
+		}
+	case map[string]interface{}:
+		if bt, ok := b.(map[string]interface{}); ok {
+			for k, v := range bt {
+				av, ok := at[k].(float64)
+				bv, ok2 := v.(float64)
+				if !ok || !ok2 {
+					return nil, fmt.Errorf("can't merge %q of %v and %v", k, a, b)
+				}
+				switch k {
+				case "min":
+					at[k] = math.Min(av, bv)
+				case "max":
+					at[k] = math.Max(av, bv)
+				default:
+					at[k] = av + bv
+				}
+			}
+			return at, nil
+		}
+	}
+	return nil, fmt.Errorf("can't merge reduced values %v and %v", a, b)
+}
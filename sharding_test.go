@@ -0,0 +1,76 @@
+package couch
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/dustin/go-couch/couchtest"
+)
+
+func TestViewShard(t *testing.T) {
+	seen := map[int]bool{}
+	for _, id := range []string{"a", "b", "c", "d", "e", "f", "g", "h"} {
+		s := ViewShard(id, 4)
+		if s < 0 || s >= 4 {
+			t.Fatalf("Shard %v of %v out of range", s, id)
+		}
+		if s != ViewShard(id, 4) {
+			t.Fatalf("Expected a stable shard for %v", id)
+		}
+		seen[s] = true
+	}
+	if len(seen) < 2 {
+		t.Errorf("Expected ids to spread over shards, got %v", seen)
+	}
+	if ViewShard("a", 1) != 0 || ViewShard("a", 0) != 0 {
+		t.Errorf("Expected a single shard to be 0")
+	}
+}
+
+func TestMergeSums(t *testing.T) {
+	tests := []struct{ a, b, exp string }{
+		{`1`, `2.5`, `3.5`},
+		{`[1, 2]`, `[3, 4, 5]`, `[4,6,5]`},
+		{`{"sum": 3, "count": 2, "min": 1, "max": 2, "sumsqr": 5}`,
+			`{"sum": 4, "count": 1, "min": 4, "max": 4, "sumsqr": 16}`,
+			`{"count":3,"max":4,"min":1,"sum":7,"sumsqr":21}`},
+	}
+	for _, test := range tests {
+		got, err := mergeSums(json.RawMessage(test.a), json.RawMessage(test.b))
+		if err != nil || string(got) != test.exp {
+			t.Errorf("mergeSums(%s, %s) = %s, %v; expected %s",
+				test.a, test.b, got, err, test.exp)
+		}
+	}
+	if _, err := mergeSums(json.RawMessage(`1`), json.RawMessage(`"x"`)); err == nil {
+		t.Errorf("Expected error merging a number and a string")
+	}
+}
+
+func TestQuerySharded(t *testing.T) {
+	f := &couchtest.Transport{Responses: []*http.Response{
+		docResponse(`{"rows": [
+			{"key": ["hits", 0], "value": 3},
+			{"key": ["hits", 2], "value": 4},
+			{"key": ["hits", 3], "value": 5}]}`),
+		docResponse(`{"rows": []}`),
+	}}
+	defer uninstallFakeHTTP(installFakeHTTP(f))
+
+	d := Database{Host: "localhost", Port: "5984", Name: "db"}
+	got, err := d.QuerySharded("_design/c/_view/counts", "hits", nil)
+	if err != nil || string(got) != "12" {
+		t.Fatalf("Expected 12, got %s, %v", got, err)
+	}
+	q := f.Requests[0].URL.Query()
+	if q.Get("startkey") != `["hits"]` || q.Get("endkey") != `["hits",{}]` ||
+		q.Get("group_level") != "2" {
+		t.Errorf("Unexpected query: %v", q)
+	}
+
+	got, err = d.QuerySharded("_design/c/_view/counts", "misses", nil)
+	if err != nil || string(got) != "null" {
+		t.Errorf("Expected null, got %s, %v", got, err)
+	}
+}
@@ -0,0 +1,46 @@
+package couch
+
+import "errors"
+
+var errSyncShards = errors.New("sync shards operation returned not-OK")
+
+// Shards maps the ranges of document id hashes that a database's shards
+// hold, e.g. "00000000-1fffffff", to the nodes holding a replica.
+type Shards map[string][]string
+
+// GetShards returns the database's shard map.
+func (p Database) GetShards() (Shards, error) {
+	res := struct {
+		Shards Shards `json:"shards"`
+	}{}
+	err := p.unmarshalURL(p.dbURL("_shards"), &res)
+	return res.Shards, err
+}
+
+// DocShard returns the range of the shard holding the document with
+// the given id, whether or not it exists, and the nodes holding a
+// replica of it.
+func (p Database) DocShard(id string) (shardRange string, nodes []string, err error) {
+	if id == "" {
+		return "", nil, errNoID
+	}
+	res := struct {
+		Range string   `json:"range"`
+		Nodes []string `json:"nodes"`
+	}{}
+	err = p.unmarshalURL(p.dbURL("_shards/"+pathEscape(id)), &res)
+	return res.Range, res.Nodes, err
+}
+
+// SyncShards has the replicas of each of the database's shards
+// synchronized, e.g. after adding a node, rather than waiting for it to
+// happen in due course.  It returns once the synchronization is queued.
+func (p Database) SyncShards() error {
+	ir := Response{}
+	_, err := p.interact("POST", p.dbURL("_sync_shards"), p.defaultHdrs,
+		[]byte("{}"), &ir)
+	if err == nil && !ir.Ok {
+		err = errSyncShards
+	}
+	return err
+}
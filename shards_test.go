@@ -0,0 +1,59 @@
+package couch
+
+import (
+	"io/ioutil"
+	"net/http"
+	"reflect"
+	"testing"
+
+	"github.com/dustin/go-couch/couchtest"
+)
+
+func TestShards(t *testing.T) {
+	f := &couchtest.Transport{Responses: []*http.Response{
+		docResponse(`{"shards": {"00000000-7fffffff": ["couchdb@n1", "couchdb@n2"],
+			"80000000-ffffffff": ["couchdb@n2", "couchdb@n3"]}}`),
+		docResponse(`{"range": "80000000-ffffffff", "nodes": ["couchdb@n2", "couchdb@n3"]}`),
+		docResponse(`{"ok": true}`),
+	}}
+	defer uninstallFakeHTTP(installFakeHTTP(f))
+
+	d := Database{Name: "db"}
+	shards, err := d.GetShards()
+	if err != nil || len(shards) != 2 ||
+		!reflect.DeepEqual(shards["00000000-7fffffff"], []string{"couchdb@n1", "couchdb@n2"}) {
+		t.Errorf("Unexpected shards %v, %v", shards, err)
+	}
+	r, nodes, err := d.DocShard("a/b")
+	if err != nil || r != "80000000-ffffffff" || len(nodes) != 2 {
+		t.Errorf("Unexpected shard %v %v, %v", r, nodes, err)
+	}
+	if err := d.SyncShards(); err != nil {
+		t.Errorf("Error syncing shards: %v", err)
+	}
+
+	for i, exp := range []string{"GET /db/_shards", "GET /db/_shards/a%2Fb", "POST /db/_sync_shards"} {
+		if got := f.Requests[i].Method + " " + f.Requests[i].URL.EscapedPath(); got != exp {
+			t.Errorf("Request %v: expected %v, got %v", i, exp, got)
+		}
+	}
+}
+
+func TestDocShardNoID(t *testing.T) {
+	if _, _, err := (Database{}).DocShard(""); err != errNoID {
+		t.Errorf("Expected errNoID, got %v", err)
+	}
+}
+
+func TestSyncShardsNotOK(t *testing.T) {
+	f := couchtest.NewTransport(docResponse(`{"ok": false}`))
+	defer uninstallFakeHTTP(installFakeHTTP(f))
+
+	if err := (Database{Name: "db"}).SyncShards(); err != errSyncShards {
+		t.Errorf("Expected errSyncShards, got %v", err)
+	}
+	body, _ := ioutil.ReadAll(f.Requests[0].Body)
+	if string(body) != "{}" {
+		t.Errorf("Expected an empty object body, got %q", body)
+	}
+}
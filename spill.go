@@ -0,0 +1,192 @@
+package couch
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+)
+
+// rowDecoder incrementally decodes the rows of a view response.
+type rowDecoder struct {
+	d *json.Decoder
+}
+
+var errNoRows = errors.New("no rows in view response")
+
+// newRowDecoder positions a decoder at the start of the rows array of
+// the view response in r.
+func newRowDecoder(r io.Reader) (*rowDecoder, error) {
+	d := json.NewDecoder(r)
+	if err := expectDelim(d, '{'); err != nil {
+		return nil, err
+	}
+	for d.More() {
+		t, err := d.Token()
+		if err != nil {
+			return nil, err
+		}
+		if t == "rows" {
+			if err := expectDelim(d, '['); err != nil {
+				return nil, err
+			}
+			return &rowDecoder{d}, nil
+		}
+		var skip json.RawMessage
+		if err := d.Decode(&skip); err != nil {
+			return nil, err
+		}
+	}
+	return nil, errNoRows
+}
+
+func expectDelim(d *json.Decoder, delim json.Delim) error {
+	t, err := d.Token()
+	if err != nil {
+		return err
+	}
+	if t != delim {
+		return fmt.Errorf("expected %v in view response, got %v", delim, t)
+	}
+	return nil
+}
+
+// next returns the next row, or io.EOF after the last one.
+func (rd *rowDecoder) next() (json.RawMessage, error) {
+	if !rd.d.More() {
+		return nil, io.EOF
+	}
+	var row json.RawMessage
+	err := rd.d.Decode(&row)
+	return row, err
+}
+
+// SpilledRows iterates over view rows that were written to a temporary
+// file by QueryToDisk.
+type SpilledRows struct {
+	// Number of rows spilled.
+	Count int
+
+	f   *os.File
+	s   *bufio.Scanner
+	row []byte
+	err error
+}
+
+// QueryToDisk executes a view request like Query, but writes rows to a
+// temporary file in dir (or the default temporary directory if dir is
+// empty) as they arrive, rather than holding the result in memory.
+// This also works for _all_docs by passing "_all_docs" as the view.
+//
+// The returned rows must be closed to remove the file.
+func (p Database) QueryToDisk(view string, options map[string]interface{},
+	dir string) (*SpilledRows, error) {
+
+	if view == "" {
+		return nil, errEmptyView
+	}
+	u, err := p.ViewURL(view, options)
+	if err != nil {
+		return nil, err
+	}
+	body, err := p.getBody(u)
+	if err != nil {
+		return nil, err
+	}
+	defer body.Close()
+
+	f, err := ioutil.TempFile(dir, "couch-spill")
+	if err != nil {
+		return nil, err
+	}
+
+	rv := &SpilledRows{f: f}
+	if err := rv.fill(body); err != nil {
+		rv.Close()
+		return nil, err
+	}
+	return rv, nil
+}
+
+func (s *SpilledRows) fill(body io.Reader) error {
+	rd, err := newRowDecoder(body)
+	if err != nil {
+		return err
+	}
+
+	w := bufio.NewWriter(s.f)
+	buf := &bytes.Buffer{}
+	for {
+		row, err := rd.next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		// Raw rows may contain newlines, so compact them to one per line.
+		buf.Reset()
+		if err := json.Compact(buf, row); err != nil {
+			return err
+		}
+		buf.WriteByte('\n')
+		if _, err := w.Write(buf.Bytes()); err != nil {
+			return err
+		}
+		s.Count++
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	if _, err := s.f.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	s.s = bufio.NewScanner(s.f)
+	s.s.Buffer(nil, maxSpilledRow)
+	return nil
+}
+
+// Largest row QueryToDisk can read back.
+const maxSpilledRow = 64 * 1024 * 1024
+
+// Next advances to the next row, returning false at the end or on error.
+func (s *SpilledRows) Next() bool {
+	if s.err != nil || s.s == nil || !s.s.Scan() {
+		if s.s != nil && s.err == nil {
+			s.err = s.s.Err()
+		}
+		return false
+	}
+	s.row = s.s.Bytes()
+	return true
+}
+
+// Row returns the raw JSON of the current row.  It's only valid until
+// the next call to Next.
+func (s *SpilledRows) Row() json.RawMessage {
+	return s.row
+}
+
+// Scan unmarshals the current row into v.
+func (s *SpilledRows) Scan(v interface{}) error {
+	return json.Unmarshal(s.row, v)
+}
+
+// Err returns the error, if any, that stopped iteration.
+func (s *SpilledRows) Err() error {
+	return s.err
+}
+
+// Close closes and removes the temporary file.
+func (s *SpilledRows) Close() error {
+	err := s.f.Close()
+	if rerr := os.Remove(s.f.Name()); err == nil {
+		err = rerr
+	}
+	return err
+}
@@ -0,0 +1,136 @@
+package couch
+
+import (
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/dustin/go-couch/couchtest"
+)
+
+func TestRowDecoder(t *testing.T) {
+	tests := []struct {
+		in   string
+		rows int
+		fail bool
+	}{
+		{`{"total_rows": 2, "offset": {"x": [1]}, "rows": [{"id": "a"}, {"id": "b"}]}`, 2, false},
+		{`{"rows": []}`, 0, false},
+		{`{"total_rows": 2}`, 0, true},
+		{`[]`, 0, true},
+		{`{"rows": {}}`, 0, true},
+		{``, 0, true},
+	}
+
+	for _, test := range tests {
+		rd, err := newRowDecoder(strings.NewReader(test.in))
+		if err != nil {
+			if !test.fail {
+				t.Errorf("Unexpected error on %v: %v", test.in, err)
+			}
+			continue
+		}
+		if test.fail {
+			t.Errorf("Expected failure on %v", test.in)
+			continue
+		}
+		n := 0
+		for {
+			_, err := rd.next()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				t.Fatalf("Error reading rows from %v: %v", test.in, err)
+			}
+			n++
+		}
+		if n != test.rows {
+			t.Errorf("Expected %v rows from %v, got %v", test.rows, test.in, n)
+		}
+	}
+}
+
+func TestQueryToDisk(t *testing.T) {
+	hres := `{"total_rows": 3, "offset": 0, "rows": [
+{"id": "a", "key": "a", "value": {"text": "multi\nline"}},
+{"id": "b", "key": "b", "value": 2},
+{"id": "c", "key": "c", "value": 3}
+]}`
+	defer uninstallFakeHTTP(installFakeHTTP(couchtest.NewTransport(&http.Response{
+		StatusCode: 200,
+		Body:       ioutil.NopCloser(strings.NewReader(hres)),
+	})))
+
+	dir := t.TempDir()
+	d := Database{Host: "localhost", Port: "5984"}
+	rows, err := d.QueryToDisk("_all_docs", nil, dir)
+	if err != nil {
+		t.Fatalf("Error spilling view: %v", err)
+	}
+	if rows.Count != 3 {
+		t.Errorf("Expected 3 rows, got %v", rows.Count)
+	}
+
+	var ids []string
+	for rows.Next() {
+		r := struct {
+			ID string
+		}{}
+		if err := rows.Scan(&r); err != nil {
+			t.Fatalf("Error scanning %s: %v", rows.Row(), err)
+		}
+		ids = append(ids, r.ID)
+	}
+	if err := rows.Err(); err != nil {
+		t.Errorf("Error iterating: %v", err)
+	}
+	if strings.Join(ids, ",") != "a,b,c" {
+		t.Errorf("Expected a,b,c, got %v", ids)
+	}
+
+	if err := rows.Close(); err != nil {
+		t.Errorf("Error closing: %v", err)
+	}
+	if files, _ := ioutil.ReadDir(dir); len(files) != 0 {
+		t.Errorf("Expected spill file to be removed, found %v", files[0].Name())
+	}
+}
+
+func TestQueryToDiskErrors(t *testing.T) {
+	d := Database{Host: "localhost", Port: "5984"}
+	if _, err := d.QueryToDisk("", nil, ""); err != errEmptyView {
+		t.Errorf("Expected empty view error, got %v", err)
+	}
+	if _, err := d.QueryToDisk("v", map[string]interface{}{"x": make(chan bool)}, ""); err == nil {
+		t.Errorf("Expected error on bad param")
+	}
+
+	defer uninstallFakeHTTP(installFakeHTTP(couchtest.NewTransport(&http.Response{
+		StatusCode: 200,
+		Body:       ioutil.NopCloser(strings.NewReader(`{"rows": [{"id": `)),
+	})))
+	dir := t.TempDir()
+	if _, err := d.QueryToDisk("v", nil, dir); err == nil {
+		t.Errorf("Expected error on truncated response")
+	}
+	if files, _ := ioutil.ReadDir(dir); len(files) != 0 {
+		t.Errorf("Expected spill file to be removed, found %v", files[0].Name())
+	}
+
+	installFakeHTTP(&couchtest.Transport{})
+	if _, err := d.QueryToDisk("v", nil, dir); err == nil {
+		t.Errorf("Expected error on HTTP failure")
+	}
+
+	installFakeHTTP(&couchtest.Transport{Responses: []*http.Response{{
+		StatusCode: 200,
+		Body:       ioutil.NopCloser(strings.NewReader(`{"rows": []}`)),
+	}}})
+	if _, err := d.QueryToDisk("v", nil, os.DevNull+"/nope"); err == nil {
+		t.Errorf("Expected error creating spill file")
+	}
+}
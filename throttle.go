@@ -0,0 +1,104 @@
+package couch
+
+import (
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"sync/atomic"
+	"time"
+)
+
+// ThrottleStats counts requests refused with 429 Too Many Requests.
+type ThrottleStats struct {
+	// Responses with status 429.
+	Throttled int64
+	// Requests sent again after being throttled.
+	Retried int64
+	// Requests that were still throttled after the last retry.
+	Failed int64
+}
+
+type throttle struct {
+	policy RetryPolicy
+
+	throttled, retried, failed int64
+}
+
+// WithThrottleRetry returns a copy of the database that retries any
+// request refused with 429 Too Many Requests, as Cloudant does when a
+// request rate limit is exceeded.  Since the server did no work, this
+// is safe even for non-idempotent requests such as bulk inserts.  A
+// request whose body is streamed from a reader, such as an attachment
+// upload, can't be sent again, so it isn't retried.
+//
+// Delays follow the policy's exponential backoff, but are never shorter
+// than the server's Retry-After.  The database and every copy made from
+// it share counters reported by ThrottleStats.
+func (p Database) WithThrottleRetry(r RetryPolicy) Database {
+	p.throttle = &throttle{policy: r}
+	return p
+}
+
+// ThrottleStats reports how often requests have been throttled since
+// WithThrottleRetry.
+func (p Database) ThrottleStats() ThrottleStats {
+	if p.throttle == nil {
+		return ThrottleStats{}
+	}
+	return ThrottleStats{
+		Throttled: atomic.LoadInt64(&p.throttle.throttled),
+		Retried:   atomic.LoadInt64(&p.throttle.retried),
+		Failed:    atomic.LoadInt64(&p.throttle.failed),
+	}
+}
+
+// do sends req with send, retrying while it's throttled.
+func (t *throttle) do(req *http.Request,
+	send func(*http.Request) (*http.Response, error)) (*http.Response, error) {
+
+	for retry := 0; ; retry++ {
+		res, err := send(req)
+		if err != nil || res.StatusCode != http.StatusTooManyRequests {
+			return res, err
+		}
+		atomic.AddInt64(&t.throttled, 1)
+		if retry >= t.policy.MaxRetries || req.Context().Err() != nil ||
+			!rewindable(req) {
+			atomic.AddInt64(&t.failed, 1)
+			return res, err
+		}
+
+		d := t.policy.backoff(retry)
+		if after := retryAfter(res); after > d {
+			d = after
+		}
+		io.Copy(ioutil.Discard, res.Body)
+		res.Body.Close()
+
+		if err := sleepContext(req.Context(), d); err != nil {
+			atomic.AddInt64(&t.failed, 1)
+			return nil, err
+		}
+
+		if err := rewind(req); err != nil {
+			return nil, err
+		}
+		atomic.AddInt64(&t.retried, 1)
+	}
+}
+
+// retryAfter returns how long the response asks clients to wait.
+func retryAfter(res *http.Response) time.Duration {
+	h := res.Header.Get("Retry-After")
+	if h == "" {
+		return 0
+	}
+	if secs, err := strconv.Atoi(h); err == nil && secs > 0 {
+		return time.Duration(secs) * time.Second
+	}
+	if t, err := http.ParseTime(h); err == nil {
+		return time.Until(t)
+	}
+	return 0
+}
@@ -0,0 +1,121 @@
+package couch
+
+import (
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dustin/go-couch/couchtest"
+)
+
+func tooMany() *http.Response {
+	return couchtest.Response(429,
+		`{"error": "too_many_requests", "reason": "You've exceeded your rate limit allowance."}`)
+}
+
+func TestThrottleRetriesPost(t *testing.T) {
+	f := &couchtest.Transport{Responses: []*http.Response{
+		tooMany(),
+		tooMany(),
+		docResponse(`{"ok": true, "id": "x", "rev": "1-a"}`),
+	}}
+	defer uninstallFakeHTTP(installFakeHTTP(f))
+
+	d := Database{}.WithThrottleRetry(quickRetry)
+	id, rev, err := d.insert([]byte(`{"a": 1}`))
+	if err != nil {
+		t.Fatalf("Expected success, got %v", err)
+	}
+	if id != "x" || rev != "1-a" {
+		t.Errorf("Expected x/1-a, got %v/%v", id, rev)
+	}
+	if len(f.Requests) != 3 {
+		t.Fatalf("Expected 3 attempts, got %v", len(f.Requests))
+	}
+	b, _ := ioutil.ReadAll(f.Requests[2].Body)
+	if string(b) != `{"a": 1}` {
+		t.Errorf("Expected body to be replayed, got %q", b)
+	}
+
+	exp := ThrottleStats{Throttled: 2, Retried: 2}
+	if got := d.ThrottleStats(); got != exp {
+		t.Errorf("Expected %+v, got %+v", exp, got)
+	}
+}
+
+func TestThrottleStreamedBody(t *testing.T) {
+	req, _ := http.NewRequest("PUT", "http://localhost/db/x/a",
+		io.MultiReader(strings.NewReader("hello "), strings.NewReader("world")))
+	var bodies []string
+	th := &throttle{policy: quickRetry}
+	res, err := th.do(req, func(req *http.Request) (*http.Response, error) {
+		b, _ := ioutil.ReadAll(req.Body)
+		bodies = append(bodies, string(b))
+		return tooMany(), nil
+	})
+	if err != nil || res.StatusCode != 429 {
+		t.Errorf("Expected the 429 returned, got %v, %v", res, err)
+	}
+	if len(bodies) != 1 || bodies[0] != "hello world" {
+		t.Errorf("Expected a single attempt, got %q", bodies)
+	}
+	if th.failed != 1 || th.retried != 0 {
+		t.Errorf("Expected a failure and no retries, got %v and %v", th.failed, th.retried)
+	}
+}
+
+func TestThrottleGivesUp(t *testing.T) {
+	f := &couchtest.Transport{Responses: []*http.Response{tooMany(), tooMany(), tooMany()}}
+	defer uninstallFakeHTTP(installFakeHTTP(f))
+
+	d := Database{}.WithThrottleRetry(quickRetry)
+	_, err := d.WithPriority(LowPriority).GetInfo()
+	if !errors.Is(err, ErrTooManyRequests) {
+		t.Fatalf("Expected too many requests, got %v", err)
+	}
+
+	// Counters are shared with copies.
+	exp := ThrottleStats{Throttled: 3, Retried: 2, Failed: 1}
+	if got := d.ThrottleStats(); got != exp {
+		t.Errorf("Expected %+v, got %+v", exp, got)
+	}
+}
+
+func TestThrottleOff(t *testing.T) {
+	f := &couchtest.Transport{Responses: []*http.Response{tooMany()}}
+	defer uninstallFakeHTTP(installFakeHTTP(f))
+
+	if _, err := (Database{}).GetInfo(); !errors.Is(err, ErrTooManyRequests) {
+		t.Fatalf("Expected too many requests, got %v", err)
+	}
+	if got := (Database{}).ThrottleStats(); got != (ThrottleStats{}) {
+		t.Errorf("Expected no stats, got %+v", got)
+	}
+}
+
+func TestRetryAfter(t *testing.T) {
+	tests := []struct {
+		h        string
+		min, max time.Duration
+	}{
+		{"", 0, 0},
+		{"junk", 0, 0},
+		{"3", 3 * time.Second, 3 * time.Second},
+		{time.Now().Add(time.Minute).UTC().Format(http.TimeFormat),
+			58 * time.Second, time.Minute},
+	}
+	for _, test := range tests {
+		res := &http.Response{Header: http.Header{}}
+		if test.h != "" {
+			res.Header.Set("Retry-After", test.h)
+		}
+		if got := retryAfter(res); got < test.min || got > test.max {
+			t.Errorf("Retry-After %q: expected %v-%v, got %v",
+				test.h, test.min, test.max, got)
+		}
+	}
+}
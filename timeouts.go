@@ -0,0 +1,96 @@
+package couch
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"time"
+)
+
+// Timeouts bound requests according to the kind of operation, so that,
+// say, a document lookup can fail fast while a view is still allowed
+// time to build.  Each covers a request from start to finish,
+// including any retries and reading the response.  A zero timeout
+// leaves that kind of request bounded only by the HTTP client.
+type Timeouts struct {
+	// Document, attachment and database requests, and anything else
+	// not listed below.
+	Quick time.Duration
+	// Queries (views, _all_docs, _find, search), bulk requests and
+	// compaction, which may have to wait for indexes to build.
+	Long time.Duration
+	// PollChanges requests, which should be longer than the timeout
+	// they're given.  For the continuous Changes feed, how long it may
+	// go silent before reconnecting, instead of twice the heartbeat.
+	Changes time.Duration
+}
+
+// longOps are the operations, as named by operation, under
+// Timeouts.Long.
+var longOps = map[string]bool{
+	"view":         true,
+	"list":         true,
+	"all_docs":     true,
+	"design_docs":  true,
+	"local_docs":   true,
+	"find":         true,
+	"explain":      true,
+	"search":       true,
+	"nouveau":      true,
+	"bulk_docs":    true,
+	"bulk_get":     true,
+	"dbs_info":     true,
+	"compact":      true,
+	"view_cleanup": true,
+	"purge":        true,
+	"revs_diff":    true,
+}
+
+// WithTimeouts returns a copy of the database that bounds its requests
+// by t.
+func (p Database) WithTimeouts(t Timeouts) Database {
+	p.timeouts = &t
+	return p
+}
+
+// timeout returns how long req may take.
+func (t *Timeouts) timeout(req *http.Request) time.Duration {
+	if t == nil {
+		return 0
+	}
+	switch op := operation(req.URL.EscapedPath()); {
+	case op == "changes":
+		return t.Changes
+	case longOps[op]:
+		return t.Long
+	}
+	return t.Quick
+}
+
+// withTimeout sends req through send, canceling it if it's not done,
+// including reading the response, within d.
+func withTimeout(req *http.Request, d time.Duration,
+	send func(*http.Request) (*http.Response, error)) (*http.Response, error) {
+
+	ctx, cancel := context.WithTimeout(req.Context(), d)
+	res, err := send(req.WithContext(ctx))
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	res.Body = cancelingBody{res.Body, cancel}
+	return res, nil
+}
+
+// cancelingBody releases a request's context once its response is
+// closed.
+type cancelingBody struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (b cancelingBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.cancel()
+	return err
+}
@@ -0,0 +1,75 @@
+package couch
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/dustin/go-couch/couchtest"
+)
+
+func TestTimeouts(t *testing.T) {
+	f := &couchtest.Transport{Responses: []*http.Response{
+		docResponse(`{"_id": "a"}`),
+		docResponse(`{"rows": []}`),
+		docResponse(`{"results": [], "last_seq": "1"}`),
+	}}
+	defer uninstallFakeHTTP(installFakeHTTP(f))
+
+	d := Database{Name: "db"}.WithTimeouts(Timeouts{
+		Quick: time.Second, Long: time.Hour, Changes: 2 * time.Hour,
+	})
+	if err := d.Retrieve("a", &map[string]interface{}{}); err != nil {
+		t.Fatalf("Error retrieving: %v", err)
+	}
+	if err := d.Query("_design/d/_view/v", nil, &map[string]interface{}{}); err != nil {
+		t.Fatalf("Error querying: %v", err)
+	}
+	if _, err := d.PollChanges("", 0, nil); err != nil {
+		t.Fatalf("Error polling changes: %v", err)
+	}
+
+	for i, exp := range []time.Duration{time.Second, time.Hour, 2 * time.Hour} {
+		deadline, ok := f.Requests[i].Context().Deadline()
+		if !ok {
+			t.Errorf("Request %v had no deadline", f.Requests[i].URL)
+			continue
+		}
+		if left := time.Until(deadline); left > exp || left < exp-time.Minute {
+			t.Errorf("Expected %v for %v, got %v", exp, f.Requests[i].URL, left)
+		}
+	}
+}
+
+func TestTimeoutsUnset(t *testing.T) {
+	f := couchtest.NewTransport(docResponse(`{"rows": []}`))
+	defer uninstallFakeHTTP(installFakeHTTP(f))
+
+	d := Database{Name: "db"}.WithTimeouts(Timeouts{Quick: time.Second})
+	if err := d.Query("_design/d/_view/v", nil, &map[string]interface{}{}); err != nil {
+		t.Fatalf("Error querying: %v", err)
+	}
+	if _, ok := f.Requests[0].Context().Deadline(); ok {
+		t.Errorf("Expected no deadline for a long operation")
+	}
+}
+
+func TestTimeoutsCoverRetries(t *testing.T) {
+	f := &couchtest.Transport{Responses: []*http.Response{unavailable(), unavailable()}}
+	defer uninstallFakeHTTP(installFakeHTTP(f))
+
+	d := Database{Name: "db"}.WithRetry(RetryPolicy{
+		MaxRetries: 1, InitialBackoff: time.Hour,
+	}).WithTimeouts(Timeouts{Quick: 20 * time.Millisecond})
+
+	start := time.Now()
+	err := d.Retrieve("a", &map[string]interface{}{})
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected deadline exceeded, got %v", err)
+	}
+	if time.Since(start) > time.Second {
+		t.Errorf("Retry wait wasn't cut short")
+	}
+}
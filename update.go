@@ -0,0 +1,74 @@
+package couch
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+)
+
+// UpdateHandler calls the named update handler of a design document
+// (named with or without the "_design/" prefix) on the document
+// matching docid, or with no document if docid is empty.  body is sent
+// as JSON, unless it's a []byte or string, which are sent as they are.
+//
+// It returns the revision the handler saved, if it saved one, and the
+// handler's response.
+func (p Database) UpdateHandler(ddoc, name, docid string, body interface{}) (string, []byte, error) {
+	if ddoc == "" || name == "" {
+		return "", nil, errEmptyView
+	}
+	var in []byte
+	contentType := "application/json"
+	switch b := body.(type) {
+	case []byte:
+		in = b
+		contentType = "application/octet-stream"
+	case string:
+		in = []byte(b)
+		contentType = "text/plain; charset=utf-8"
+	case nil:
+	default:
+		var err error
+		if in, err = json.Marshal(body); err != nil {
+			return "", nil, err
+		}
+	}
+
+	// Always POST: an update handler needn't be idempotent, so it
+	// mustn't be retried as a PUT would be.
+	ddoc = strings.TrimPrefix(ddoc, "_design/")
+	path := "_design/" + pathEscape(ddoc) + "/_update/" + pathEscape(name)
+	if docid != "" {
+		path += "/" + pathEscape(docid)
+	}
+
+	req, err := http.NewRequest("POST", p.dbURL(path), bytes.NewReader(in))
+	if err != nil {
+		return "", nil, err
+	}
+	req.Header = http.Header{}
+	for k, v := range p.defaultHdrs {
+		req.Header[k] = v
+	}
+	if in != nil {
+		req.Header.Set("Content-Type", contentType)
+	}
+	if p.cache != nil && docid != "" {
+		p.cache.remove(p.docURL(docid))
+	}
+
+	res, err := p.do(req)
+	if err != nil {
+		return "", nil, err
+	}
+	defer res.Body.Close()
+	defer io.Copy(ioutil.Discard, res.Body)
+	if res.StatusCode < 200 || res.StatusCode >= 300 {
+		return "", nil, newHTTPError(res)
+	}
+	out, err := ioutil.ReadAll(res.Body)
+	return res.Header.Get("X-Couch-Update-NewRev"), out, err
+}
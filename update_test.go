@@ -0,0 +1,69 @@
+package couch
+
+import (
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/dustin/go-couch/couchtest"
+)
+
+func TestUpdateHandler(t *testing.T) {
+	f := &couchtest.Transport{Responses: []*http.Response{
+		{
+			StatusCode: 201,
+			Header:     http.Header{"X-Couch-Update-Newrev": {"2-b"}},
+			Body:       ioutil.NopCloser(strings.NewReader("incremented")),
+		},
+		{StatusCode: 200, Header: http.Header{},
+			Body: ioutil.NopCloser(strings.NewReader("no change"))},
+	}}
+	defer uninstallFakeHTTP(installFakeHTTP(f))
+
+	d := Database{Name: "db"}
+	rev, out, err := d.UpdateHandler("_design/d", "inc", "a/b",
+		map[string]int{"by": 2})
+	if err != nil || rev != "2-b" || string(out) != "incremented" {
+		t.Errorf("Unexpected %q %q %v", rev, out, err)
+	}
+	rev, out, err = d.UpdateHandler("d", "stamp", "", "raw")
+	if err != nil || rev != "" || string(out) != "no change" {
+		t.Errorf("Unexpected %q %q %v", rev, out, err)
+	}
+
+	doc, post := f.Requests[0], f.Requests[1]
+	body, _ := ioutil.ReadAll(doc.Body)
+	if doc.Method != "POST" || doc.URL.EscapedPath() != "/db/_design/d/_update/inc/a%2Fb" ||
+		string(body) != `{"by":2}` || doc.Header.Get("Content-Type") != "application/json" {
+		t.Errorf("Unexpected request %v %v %s", doc.Method, doc.URL, body)
+	}
+	body, _ = ioutil.ReadAll(post.Body)
+	if post.Method != "POST" || post.URL.Path != "/db/_design/d/_update/stamp" ||
+		string(body) != "raw" || !strings.HasPrefix(post.Header.Get("Content-Type"), "text/plain") {
+		t.Errorf("Unexpected request %v %v %s", post.Method, post.URL, body)
+	}
+}
+
+func TestUpdateHandlerNotRetried(t *testing.T) {
+	f := &couchtest.Transport{Responses: []*http.Response{unavailable(), unavailable()}}
+	defer uninstallFakeHTTP(installFakeHTTP(f))
+
+	d := Database{}.WithRetry(quickRetry)
+	if _, _, err := d.UpdateHandler("d", "inc", "a", nil); err == nil {
+		t.Errorf("Expected error")
+	}
+	if len(f.Requests) != 1 {
+		t.Errorf("Expected a single attempt, got %v", len(f.Requests))
+	}
+}
+
+func TestUpdateHandlerError(t *testing.T) {
+	defer uninstallFakeHTTP(installFakeHTTP(couchtest.NewTransport(conflictResponse())))
+	if _, _, err := (Database{}).UpdateHandler("d", "inc", "a", nil); err == nil {
+		t.Errorf("Expected error")
+	}
+	if _, _, err := (Database{}).UpdateHandler("d", "", "a", nil); err != errEmptyView {
+		t.Errorf("Expected errEmptyView, got %v", err)
+	}
+}
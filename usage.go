@@ -0,0 +1,205 @@
+package couch
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+)
+
+// DBSizes are a database's sizes in bytes, as reported by CouchDB 2.0
+// and later.
+type DBSizes struct {
+	File     int64 `json:"file"`     // size of the database file
+	External int64 `json:"external"` // uncompressed size of the data
+	Active   int64 `json:"active"`   // live data in the file
+}
+
+func (s *DBSizes) add(o DBSizes) {
+	s.File += o.File
+	s.External += o.External
+	s.Active += o.Active
+}
+
+// DiskUsage is the disk usage of one database.
+type DiskUsage struct {
+	Name  string
+	Sizes DBSizes
+}
+
+// Wasted returns the space compaction could reclaim.
+func (u DiskUsage) Wasted() int64 {
+	return u.Sizes.File - u.Sizes.Active
+}
+
+// Fragmentation returns the ratio of file size to live data.  A
+// freshly compacted database is close to 1.
+func (u DiskUsage) Fragmentation() float64 {
+	if u.Sizes.Active == 0 {
+		if u.Sizes.File == 0 {
+			return 0
+		}
+		return math.Inf(1)
+	}
+	return float64(u.Sizes.File) / float64(u.Sizes.Active)
+}
+
+// DiskUsageReport summarizes the disk usage of several databases.
+type DiskUsageReport struct {
+	// Databases, most wasteful first.
+	Databases []DiskUsage
+	// Requested databases that don't exist.
+	Missing []string
+	Total   DBSizes
+}
+
+// DiskUsageThresholds decide when a database needs compaction.
+type DiskUsageThresholds struct {
+	// Fragmentation above which to compact, e.g. 2 when the file is
+	// more than twice the size of its live data.
+	MaxFragmentation float64
+	// Databases with smaller files aren't worth bothering with.
+	MinFileSize int64
+}
+
+// Exceeds reports whether u calls for compaction.
+func (t DiskUsageThresholds) Exceeds(u DiskUsage) bool {
+	return u.Sizes.File >= t.MinFileSize && u.Fragmentation() > t.MaxFragmentation
+}
+
+// Check calls f for each database in the report that needs compaction,
+// returning how many did.
+func (r *DiskUsageReport) Check(t DiskUsageThresholds, f func(DiskUsage)) int {
+	n := 0
+	for _, u := range r.Databases {
+		if t.Exceeds(u) {
+			n++
+			if f != nil {
+				f(u)
+			}
+		}
+	}
+	return n
+}
+
+// String formats the report as a table.
+func (r *DiskUsageReport) String() string {
+	b := &strings.Builder{}
+	fmt.Fprintf(b, "%-30s %14s %14s %14s %6s\n",
+		"database", "file", "active", "wasted", "frag")
+	for _, u := range r.Databases {
+		fmt.Fprintf(b, "%-30s %14d %14d %14d %6.2f\n", u.Name,
+			u.Sizes.File, u.Sizes.Active, u.Wasted(), u.Fragmentation())
+	}
+	total := DiskUsage{Name: "total", Sizes: r.Total}
+	fmt.Fprintf(b, "%-30s %14d %14d %14d %6.2f\n", total.Name,
+		total.Sizes.File, total.Sizes.Active, total.Wasted(), total.Fragmentation())
+	for _, name := range r.Missing {
+		fmt.Fprintf(b, "%-30s missing\n", name)
+	}
+	return b.String()
+}
+
+// Most servers refuse larger _dbs_info requests.
+const dbsInfoBatch = 100
+
+type dbsInfoResult struct {
+	Key   string
+	Info  json.RawMessage
+	Error string
+}
+
+// dbsInfo fetches info for the named databases in one request
+// (ignores Database.Name).  Requires CouchDB 2.2 or later.
+func (p Database) dbsInfo(names []string) ([]dbsInfoResult, error) {
+	body, err := json.Marshal(map[string][]string{"keys": names})
+	if err != nil {
+		return nil, err
+	}
+	results := []dbsInfoResult{}
+	_, err = p.interact("POST", p.serverURL("_dbs_info"), p.defaultHdrs, body, &results)
+	return results, err
+}
+
+// eachDBInfo calls f with the _dbs_info result of each named database,
+// fetching them dbsInfoBatch at a time.
+func (p Database) eachDBInfo(names []string, f func(dbsInfoResult) error) error {
+	for len(names) > 0 {
+		batch := names
+		if len(batch) > dbsInfoBatch {
+			batch = batch[:dbsInfoBatch]
+		}
+		names = names[len(batch):]
+
+		results, err := p.dbsInfo(batch)
+		if err != nil {
+			return err
+		}
+		for _, res := range results {
+			if err := f(res); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// DBsInfo returns the info of the named databases, in batched requests
+// rather than a GetInfo each (ignores Database.Name).  Databases that
+// don't exist are left out.  Requires CouchDB 2.2 or later.
+func (p Database) DBsInfo(names []string) ([]DBInfo, error) {
+	var infos []DBInfo
+	err := p.eachDBInfo(names, func(res dbsInfoResult) error {
+		if res.Error != "" || res.Info == nil {
+			return nil
+		}
+		info := DBInfo{}
+		if err := json.Unmarshal(res.Info, &info); err != nil {
+			return err
+		}
+		infos = append(infos, info)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return infos, nil
+}
+
+// DiskUsage reports the disk usage of the named databases, or of every
+// database on the server if none are named (ignores Database.Name).
+// Requires CouchDB 2.2 or later.
+func (p Database) DiskUsage(names ...string) (*DiskUsageReport, error) {
+	if len(names) == 0 {
+		var err error
+		if names, err = p.AllDBs(nil); err != nil {
+			return nil, err
+		}
+	}
+
+	r := &DiskUsageReport{}
+	err := p.eachDBInfo(names, func(res dbsInfoResult) error {
+		info := struct {
+			Sizes DBSizes `json:"sizes"`
+		}{}
+		if res.Error != "" || res.Info == nil {
+			r.Missing = append(r.Missing, res.Key)
+			return nil
+		}
+		if err := json.Unmarshal(res.Info, &info); err != nil {
+			return err
+		}
+		r.Databases = append(r.Databases, DiskUsage{res.Key, info.Sizes})
+		r.Total.add(info.Sizes)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.SliceStable(r.Databases, func(i, j int) bool {
+		return r.Databases[i].Wasted() > r.Databases[j].Wasted()
+	})
+	return r, nil
+}
@@ -0,0 +1,115 @@
+package couch
+
+import (
+	"encoding/json"
+	"math"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/dustin/go-couch/couchtest"
+)
+
+func TestFragmentation(t *testing.T) {
+	tests := []struct {
+		sizes DBSizes
+		exp   float64
+	}{
+		{DBSizes{}, 0},
+		{DBSizes{File: 10}, math.Inf(1)},
+		{DBSizes{File: 30, Active: 10}, 3},
+	}
+	for _, test := range tests {
+		if got := (DiskUsage{Sizes: test.sizes}).Fragmentation(); got != test.exp {
+			t.Errorf("%+v: expected %v, got %v", test.sizes, test.exp, got)
+		}
+	}
+}
+
+func TestDiskUsage(t *testing.T) {
+	f := &couchtest.Transport{Responses: []*http.Response{
+		docResponse(`["a", "b", "gone"]`),
+		docResponse(`[
+			{"key": "a", "info": {"db_name": "a", "update_seq": "5-g1AAA",
+				"sizes": {"file": 1000, "external": 300, "active": 400}}},
+			{"key": "b", "info": {"db_name": "b",
+				"sizes": {"file": 5000, "external": 1000, "active": 1000}}},
+			{"key": "gone", "error": "not_found"}]`),
+	}}
+	defer uninstallFakeHTTP(installFakeHTTP(f))
+
+	r, err := Database{Host: "localhost", Port: "5984"}.DiskUsage()
+	if err != nil {
+		t.Fatalf("Error getting disk usage: %v", err)
+	}
+
+	if got := f.Requests[1].URL.String(); got != "http://localhost:5984/_dbs_info" {
+		t.Errorf("Expected _dbs_info request, got %v", got)
+	}
+	keys := struct{ Keys []string }{}
+	if err := json.NewDecoder(f.Requests[1].Body).Decode(&keys); err != nil ||
+		strings.Join(keys.Keys, ",") != "a,b,gone" {
+		t.Errorf("Expected keys a,b,gone, got %v, %v", keys.Keys, err)
+	}
+
+	if len(r.Databases) != 2 || r.Databases[0].Name != "b" || r.Databases[1].Name != "a" {
+		t.Fatalf("Expected b then a, got %+v", r.Databases)
+	}
+	if r.Total != (DBSizes{File: 6000, External: 1300, Active: 1400}) {
+		t.Errorf("Unexpected total: %+v", r.Total)
+	}
+	if len(r.Missing) != 1 || r.Missing[0] != "gone" {
+		t.Errorf("Expected gone to be missing, got %v", r.Missing)
+	}
+
+	var alerts []string
+	n := r.Check(DiskUsageThresholds{MaxFragmentation: 2, MinFileSize: 2000},
+		func(u DiskUsage) { alerts = append(alerts, u.Name) })
+	if n != 1 || len(alerts) != 1 || alerts[0] != "b" {
+		t.Errorf("Expected an alert for b, got %v (%v)", alerts, n)
+	}
+
+	s := r.String()
+	for _, exp := range []string{"b ", "4000", "a ", "2.50", "total", "gone", "missing"} {
+		if !strings.Contains(s, exp) {
+			t.Errorf("Expected %q in report:\n%s", exp, s)
+		}
+	}
+}
+
+func TestDiskUsageBatches(t *testing.T) {
+	names := make([]string, dbsInfoBatch+1)
+	for i := range names {
+		names[i] = "db"
+	}
+	f := &couchtest.Transport{Responses: []*http.Response{docResponse(`[]`), docResponse(`[]`)}}
+	defer uninstallFakeHTTP(installFakeHTTP(f))
+
+	if _, err := (Database{}).DiskUsage(names...); err != nil {
+		t.Fatalf("Error getting disk usage: %v", err)
+	}
+	if len(f.Requests) != 2 {
+		t.Errorf("Expected 2 batches, got %v", len(f.Requests))
+	}
+}
+
+func TestDBsInfo(t *testing.T) {
+	f := couchtest.NewTransport(docResponse(`[
+		{"key": "a", "info": {"db_name": "a", "doc_count": 3, "update_seq": "7-g1AAA",
+			"sizes": {"file": 1000, "external": 300, "active": 400}}},
+		{"key": "gone", "error": "not_found"}]`))
+	defer uninstallFakeHTTP(installFakeHTTP(f))
+
+	infos, err := Database{}.DBsInfo([]string{"a", "gone"})
+	if err != nil {
+		t.Fatalf("Error getting info: %v", err)
+	}
+	if len(infos) != 1 || infos[0].Name != "a" || infos[0].DocCount != 3 ||
+		infos[0].UpdateSeq != 7 ||
+		infos[0].Sizes.Active != 400 {
+		t.Errorf("Unexpected info %+v", infos)
+	}
+	if f.Requests[0].Method != "POST" || f.Requests[0].URL.Path != "/_dbs_info" {
+		t.Errorf("Unexpected request %v %v", f.Requests[0].Method, f.Requests[0].URL)
+	}
+}
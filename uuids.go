@@ -0,0 +1,94 @@
+package couch
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io"
+	mrand "math/rand"
+	"strconv"
+	"sync"
+)
+
+// UUIDs fetches n ids generated by the server, by whichever algorithm
+// it's configured to use (ignores Database.Name).  Assigning ids before
+// inserting, e.g. with InsertWith, makes retrying an insert safe.
+func (p Database) UUIDs(n int) ([]string, error) {
+	res := struct {
+		UUIDs []string `json:"uuids"`
+	}{}
+	err := p.unmarshalURL(p.serverURL("_uuids")+"?count="+strconv.Itoa(n), &res)
+	return res.UUIDs, err
+}
+
+// A UUIDGenerator generates document ids locally, saving a request to
+// the server for each.  UUID fails only if the system's secure random
+// source does.
+type UUIDGenerator interface {
+	UUID() (string, error)
+}
+
+// RandomUUIDs generates random ids, as the server's "random" algorithm
+// does.
+var RandomUUIDs UUIDGenerator = randomUUIDs{}
+
+type randomUUIDs struct{}
+
+func (randomUUIDs) UUID() (string, error) {
+	b, err := randomBytes(16)
+	return hex.EncodeToString(b), err
+}
+
+// randSource is the secure random source ids are made from.
+var randSource = rand.Reader
+
+func randomBytes(n int) ([]byte, error) {
+	b := make([]byte, n)
+	if _, err := io.ReadFull(randSource, b); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+// NewSequentialUUIDs returns a generator of ids in increasing order, as
+// the server's "sequential" algorithm makes them: a random prefix with
+// an increasing suffix, which keeps inserts into the database's b-tree
+// cheap.  A new random prefix is chosen when the suffix runs out.  It's
+// safe for concurrent use.
+func NewSequentialUUIDs() (UUIDGenerator, error) {
+	s := &sequentialUUIDs{}
+	if err := s.reset(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+const maxSequentialSuffix = 0xfff000
+
+type sequentialUUIDs struct {
+	mu     sync.Mutex
+	prefix string
+	suffix int
+}
+
+func (s *sequentialUUIDs) reset() error {
+	b, err := randomBytes(13)
+	if err != nil {
+		return err
+	}
+	s.prefix = hex.EncodeToString(b)
+	s.suffix = mrand.Intn(0x1000)
+	return nil
+}
+
+func (s *sequentialUUIDs) UUID() (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.suffix += 1 + mrand.Intn(0xffe)
+	if s.suffix >= maxSequentialSuffix {
+		if err := s.reset(); err != nil {
+			return "", err
+		}
+	}
+	return fmt.Sprintf("%s%06x", s.prefix, s.suffix), nil
+}
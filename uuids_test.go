@@ -0,0 +1,81 @@
+package couch
+
+import (
+	"errors"
+	"io"
+	"reflect"
+	"testing"
+	"testing/iotest"
+
+	"github.com/dustin/go-couch/couchtest"
+)
+
+func TestUUIDs(t *testing.T) {
+	f := couchtest.NewTransport(docResponse(`{"uuids": ["a", "b", "c"]}`))
+	defer uninstallFakeHTTP(installFakeHTTP(f))
+
+	ids, err := Database{Name: "db"}.UUIDs(3)
+	if err != nil || !reflect.DeepEqual(ids, []string{"a", "b", "c"}) {
+		t.Errorf("Unexpected ids %v, %v", ids, err)
+	}
+	u := f.Requests[0].URL
+	if u.Path != "/_uuids" || u.Query().Get("count") != "3" {
+		t.Errorf("Unexpected request %v", u)
+	}
+}
+
+func TestRandomUUIDs(t *testing.T) {
+	a, err := RandomUUIDs.UUID()
+	if err != nil {
+		t.Fatalf("Error generating id: %v", err)
+	}
+	b, err := RandomUUIDs.UUID()
+	if err != nil || len(a) != 32 || a == b {
+		t.Errorf("Unexpected ids %q %q, %v", a, b, err)
+	}
+}
+
+func TestSequentialUUIDs(t *testing.T) {
+	gen, err := NewSequentialUUIDs()
+	if err != nil {
+		t.Fatalf("Error creating generator: %v", err)
+	}
+	g := gen.(*sequentialUUIDs)
+	prev, err := g.UUID()
+	if err != nil || len(prev) != 32 {
+		t.Fatalf("Unexpected id %q, %v", prev, err)
+	}
+	for i := 0; i < 100; i++ {
+		id, err := g.UUID()
+		if err != nil || id <= prev || id[:26] != prev[:26] {
+			t.Fatalf("Expected %q to follow %q, %v", id, prev, err)
+		}
+		prev = id
+	}
+
+	g.suffix = maxSequentialSuffix - 1
+	if id, err := g.UUID(); err != nil || id[:26] == prev[:26] || len(id) != 32 {
+		t.Errorf("Expected a new prefix after %q, got %q, %v", prev, id, err)
+	}
+}
+
+func TestUUIDsRandomFailure(t *testing.T) {
+	g, err := NewSequentialUUIDs()
+	if err != nil {
+		t.Fatalf("Error creating generator: %v", err)
+	}
+
+	defer func(r io.Reader) { randSource = r }(randSource)
+	randSource = iotest.ErrReader(errors.New("no entropy"))
+
+	if id, err := RandomUUIDs.UUID(); err == nil {
+		t.Errorf("Expected an error, got %q", id)
+	}
+	if _, err := NewSequentialUUIDs(); err == nil {
+		t.Errorf("Expected an error creating a generator")
+	}
+	g.(*sequentialUUIDs).suffix = maxSequentialSuffix - 1
+	if id, err := g.UUID(); err == nil {
+		t.Errorf("Expected an error choosing a new prefix, got %q", id)
+	}
+}
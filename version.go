@@ -0,0 +1,140 @@
+package couch
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+)
+
+// ErrStaleVersion matches a StaleVersionError with errors.Is.
+var ErrStaleVersion = errors.New("couch: stale version")
+
+// StaleVersionError is returned by EditVersion when the stored document
+// no longer has the expected version.
+type StaleVersionError struct {
+	ID       string
+	Field    string
+	Expected json.RawMessage
+	Actual   json.RawMessage // null if the field is missing
+}
+
+func (e *StaleVersionError) Error() string {
+	return fmt.Sprintf("couch: stale version of %q: %s is %s, expected %s",
+		e.ID, e.Field, e.Actual, e.Expected)
+}
+
+// Is reports whether target is ErrStaleVersion.
+func (e *StaleVersionError) Is(target error) bool {
+	return target == ErrStaleVersion
+}
+
+// EditVersion saves d over the stored document with the same "_id",
+// but only if the stored document's field still holds expected,
+// returning the new revision.  Use nil to expect no version at all.
+//
+// This provides optimistic concurrency on an application-defined
+// version (a counter, timestamp, or anything else that encodes as
+// JSON) rather than on _rev, which changes with every write and is
+// forgotten on compaction.  d should carry the new version in field;
+// any _rev it has is ignored.  d is encoded as Edit would, and field
+// is renamed by the database's FieldMapping, if any.  A mismatch
+// returns a *StaleVersionError.
+func (p Database) EditVersion(d interface{}, field string, expected interface{}) (string, error) {
+	jsonBuf, err := p.marshal(d)
+	if err != nil {
+		return "", err
+	}
+	body, id, _, err := stripIDRev(jsonBuf)
+	if err != nil {
+		return "", err
+	}
+	if id == "" {
+		return "", errNoID
+	}
+	want, err := json.Marshal(expected)
+	if err != nil {
+		return "", err
+	}
+	if p.fields != nil && p.fields.ToCouch != nil && !strings.HasPrefix(field, "_") {
+		field = p.fields.ToCouch(field)
+	}
+
+	wantTree, err := decodeTree(want)
+	if err != nil {
+		return "", err
+	}
+
+	for i := 1; ; i++ {
+		doc := map[string]json.RawMessage{}
+		if err := p.unmarshalURL(p.docURL(id), &doc); err != nil {
+			return "", err
+		}
+		got, ok := doc[field]
+		if !ok {
+			got = json.RawMessage("null")
+		}
+		gotTree, err := decodeTree(got)
+		if err != nil {
+			return "", err
+		}
+		if !sameJSON(gotTree, wantTree) {
+			return "", &StaleVersionError{
+				ID:       id,
+				Field:    field,
+				Expected: want,
+				Actual:   got,
+			}
+		}
+
+		var stored string
+		json.Unmarshal(doc["_rev"], &stored)
+		rev, err := p.put(id, withIDRev(body, id, stored))
+		if err == nil {
+			setMeta(d, "", rev)
+		}
+		if err == nil || !errors.Is(err, ErrConflict) || i >= maxUpdateAttempts {
+			return rev, err
+		}
+	}
+}
+
+// sameJSON reports whether two values decoded by decodeTree are equal
+// as JSON: numbers by value, however they're written, and objects
+// whatever the order of their members.
+func sameJSON(a, b interface{}) bool {
+	switch a := a.(type) {
+	case json.Number:
+		b, ok := b.(json.Number)
+		if !ok {
+			return false
+		}
+		x, okx := new(big.Rat).SetString(string(a))
+		y, oky := new(big.Rat).SetString(string(b))
+		return okx && oky && x.Cmp(y) == 0
+	case map[string]interface{}:
+		b, ok := b.(map[string]interface{})
+		if !ok || len(a) != len(b) {
+			return false
+		}
+		for k, v := range a {
+			if w, ok := b[k]; !ok || !sameJSON(v, w) {
+				return false
+			}
+		}
+		return true
+	case []interface{}:
+		b, ok := b.([]interface{})
+		if !ok || len(a) != len(b) {
+			return false
+		}
+		for i := range a {
+			if !sameJSON(a[i], b[i]) {
+				return false
+			}
+		}
+		return true
+	}
+	return a == b
+}
@@ -0,0 +1,187 @@
+package couch
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"reflect"
+	"testing"
+
+	"github.com/dustin/go-couch/couchtest"
+)
+
+type versioned struct {
+	ID      string `json:"_id"`
+	Rev     string `json:"_rev,omitempty"`
+	Version int    `json:"version"`
+	Value   string `json:"value"`
+}
+
+func TestEditVersion(t *testing.T) {
+	f := &couchtest.Transport{Responses: []*http.Response{
+		docResponse(`{"_id": "x", "_rev": "5-a", "version": 2, "old": true}`),
+		docResponse(`{"ok": true, "id": "x", "rev": "6-a"}`),
+	}}
+	defer uninstallFakeHTTP(installFakeHTTP(f))
+
+	doc := versioned{ID: "x", Rev: "1-stale", Version: 3, Value: "new"}
+	rev, err := Database{}.EditVersion(doc, "version", 2)
+	if err != nil || rev != "6-a" {
+		t.Fatalf("Expected rev 6-a, got %v, %v", rev, err)
+	}
+
+	got := map[string]interface{}{}
+	if err := json.NewDecoder(f.Requests[1].Body).Decode(&got); err != nil {
+		t.Fatalf("Error decoding PUT: %v", err)
+	}
+	exp := map[string]interface{}{
+		"_id": "x", "_rev": "5-a", "version": 3.0, "value": "new",
+	}
+	if !reflect.DeepEqual(got, exp) {
+		t.Errorf("Expected %v, got %v", exp, got)
+	}
+}
+
+func TestEditVersionStale(t *testing.T) {
+	tests := []struct {
+		stored   string
+		expected interface{}
+	}{
+		{`{"_id": "x", "_rev": "5-a", "version": 4}`, 2},
+		{`{"_id": "x", "_rev": "5-a"}`, 2},
+		{`{"_id": "x", "_rev": "5-a", "version": 1}`, nil},
+	}
+	for _, test := range tests {
+		f := &couchtest.Transport{Responses: []*http.Response{docResponse(test.stored)}}
+		uninstall := installFakeHTTP(f)
+
+		_, err := Database{}.EditVersion(versioned{ID: "x", Version: 3},
+			"version", test.expected)
+		uninstallFakeHTTP(uninstall)
+
+		if !errors.Is(err, ErrStaleVersion) {
+			t.Errorf("%s: expected stale version, got %v", test.stored, err)
+			continue
+		}
+		if len(f.Requests) != 1 {
+			t.Errorf("%s: expected no write, got %v requests",
+				test.stored, len(f.Requests))
+		}
+	}
+}
+
+func TestEditVersionFirst(t *testing.T) {
+	f := &couchtest.Transport{Responses: []*http.Response{
+		docResponse(`{"_id": "x", "_rev": "1-a"}`),
+		docResponse(`{"ok": true, "id": "x", "rev": "2-a"}`),
+	}}
+	defer uninstallFakeHTTP(installFakeHTTP(f))
+
+	if _, err := (Database{}).EditVersion(versioned{ID: "x", Version: 1},
+		"version", nil); err != nil {
+		t.Fatalf("Expected success, got %v", err)
+	}
+}
+
+func TestEditVersionExact(t *testing.T) {
+	tests := []struct {
+		stored   string
+		expected interface{}
+	}{
+		{`1700000000123456789`, int64(1700000000123456789)},
+		{`1e3`, 1000},
+		{`{"b": 2, "a": "x"}`, struct {
+			A string `json:"a"`
+			B int    `json:"b"`
+		}{"x", 2}},
+	}
+	for _, test := range tests {
+		f := &couchtest.Transport{Responses: []*http.Response{
+			docResponse(`{"_id": "x", "_rev": "1-a", "version": ` + test.stored + `}`),
+			docResponse(`{"ok": true, "id": "x", "rev": "2-a"}`),
+		}}
+		uninstall := installFakeHTTP(f)
+		_, err := (Database{}).EditVersion(versioned{ID: "x"}, "version", test.expected)
+		uninstallFakeHTTP(uninstall)
+		if err != nil {
+			t.Errorf("%s: expected a match, got %v", test.stored, err)
+		}
+	}
+
+	// One off a large version still doesn't match.
+	f := couchtest.NewTransport(docResponse(`{"_id": "x", "_rev": "1-a", "version": 1700000000123456789}`))
+	defer uninstallFakeHTTP(installFakeHTTP(f))
+	_, err := (Database{}).EditVersion(versioned{ID: "x"}, "version", int64(1700000000123456788))
+	if !errors.Is(err, ErrStaleVersion) {
+		t.Errorf("Expected stale version, got %v", err)
+	}
+}
+
+func TestSameJSON(t *testing.T) {
+	tests := []struct {
+		a, b string
+		exp  bool
+	}{
+		{`1`, `1.0`, true},
+		{`9007199254740993`, `9007199254740992`, false},
+		{`{"a": [1, "x"], "b": null}`, `{"b": null, "a": [1, "x"]}`, true},
+		{`{"a": 1}`, `{"a": 1, "b": 2}`, false},
+		{`[1, 2]`, `[2, 1]`, false},
+		{`"1"`, `1`, false},
+		{`true`, `true`, true},
+	}
+	for _, test := range tests {
+		a, _ := decodeTree([]byte(test.a))
+		b, _ := decodeTree([]byte(test.b))
+		if got := sameJSON(a, b); got != test.exp {
+			t.Errorf("%s and %s: expected %v, got %v", test.a, test.b, test.exp, got)
+		}
+	}
+}
+
+func TestEditVersionNoID(t *testing.T) {
+	if _, err := (Database{}).EditVersion(versioned{}, "version", 1); err != errNoID {
+		t.Errorf("Expected errNoID, got %v", err)
+	}
+}
+
+func TestEditVersionNotObject(t *testing.T) {
+	if _, err := (Database{}).EditVersion([]int{1}, "version", 1); err != errNotObject {
+		t.Errorf("Expected errNotObject, got %v", err)
+	}
+}
+
+func TestEditVersionFieldMapping(t *testing.T) {
+	f := &couchtest.Transport{Responses: []*http.Response{
+		docResponse(`{"_id": "x", "_rev": "1-a", "doc_version": 1}`),
+		docResponse(`{"ok": true, "id": "x", "rev": "2-a"}`),
+	}}
+	defer uninstallFakeHTTP(installFakeHTTP(f))
+
+	doc := struct {
+		ID         string `json:"_id"`
+		DocVersion int
+	}{ID: "x", DocVersion: 2}
+	if _, err := (Database{}).WithFieldMapping(SnakeCase).EditVersion(doc,
+		"DocVersion", 1); err != nil {
+		t.Fatalf("Expected success, got %v", err)
+	}
+
+	got := map[string]interface{}{}
+	if err := json.NewDecoder(f.Requests[1].Body).Decode(&got); err != nil {
+		t.Fatalf("Error decoding PUT: %v", err)
+	}
+	exp := map[string]interface{}{"_id": "x", "_rev": "1-a", "doc_version": 2.0}
+	if !reflect.DeepEqual(got, exp) {
+		t.Errorf("Expected %v, got %v", exp, got)
+	}
+}
+
+func TestStaleVersionError(t *testing.T) {
+	e := &StaleVersionError{ID: "x", Field: "version",
+		Expected: json.RawMessage("2"), Actual: json.RawMessage("4")}
+	exp := `couch: stale version of "x": version is 4, expected 2`
+	if e.Error() != exp {
+		t.Errorf("Expected %q, got %q", exp, e.Error())
+	}
+}
@@ -0,0 +1,37 @@
+package couch
+
+// MaxString sorts after almost every other string in CouchDB's
+// collation, so appending it to a string gives the end of a range of
+// strings with that prefix, e.g. "user:" + MaxString.
+const MaxString = "\ufff0"
+
+// endOfRange encodes as {}, which sorts after every number, string
+// and array.
+type endOfRange struct{}
+
+func (endOfRange) MarshalJSON() ([]byte, error) {
+	return []byte("{}"), nil
+}
+
+// EndOfRange sorts after any other key element, ending a range of
+// array keys with a given prefix, e.g.
+//
+//	ViewOptions{StartKey: Key("user1"), EndKey: Key("user1", EndOfRange)}
+var EndOfRange interface{} = endOfRange{}
+
+// Key builds an array key from its elements, for use as a key,
+// startkey or endkey view option.
+func Key(elems ...interface{}) []interface{} {
+	if elems == nil {
+		return []interface{}{}
+	}
+	return elems
+}
+
+// KeyRange returns the start and end keys of the rows whose array keys
+// begin with prefix.  Swap them for a descending query.
+func KeyRange(prefix ...interface{}) (start, end []interface{}) {
+	start = Key(prefix...)
+	end = append(append([]interface{}{}, prefix...), EndOfRange)
+	return start, end
+}
@@ -0,0 +1,43 @@
+package couch
+
+import (
+	"net/url"
+	"testing"
+)
+
+func TestViewKeys(t *testing.T) {
+	start, end := KeyRange("user1", 2)
+	tests := []struct {
+		params map[string]interface{}
+		exp    string
+	}{
+		{map[string]interface{}{"startkey": Key("user1", MaxString)},
+			`["user1","` + MaxString + `"]`},
+		{map[string]interface{}{"startkey": Key()}, `[]`},
+		{map[string]interface{}{"startkey": EndOfRange}, `{}`},
+		{map[string]interface{}{"startkey": start}, `["user1",2]`},
+		{map[string]interface{}{"startkey": end}, `["user1",2,{}]`},
+		{ViewOptions{StartKey: Key("a", EndOfRange)}.Options(), `["a",{}]`},
+		{map[string]interface{}{"startkey": "user:" + MaxString}, `"user:` + MaxString + `"`},
+	}
+	for _, test := range tests {
+		u, err := (Database{}).ViewURL("_all_docs", test.params)
+		if err != nil {
+			t.Fatalf("Error building URL for %v: %v", test.params, err)
+		}
+		parsed, err := url.Parse(u)
+		must(err)
+		if got := parsed.Query().Get("startkey"); got != test.exp {
+			t.Errorf("Expected startkey %s, got %s", test.exp, got)
+		}
+	}
+
+	// The prefix isn't shared with the end key.
+	prefix := make([]interface{}, 1, 2)
+	prefix[0] = "x"
+	start, end = KeyRange(prefix...)
+	end[0] = "y"
+	if start[0] != "x" {
+		t.Errorf("Expected the start key to be unchanged, got %v", start)
+	}
+}
@@ -0,0 +1,99 @@
+package couch
+
+import (
+	"encoding/json"
+	"strconv"
+)
+
+// ViewOptions are typed options for view queries.  Pass the result of
+// Options wherever view options are taken, e.g.
+//
+//	db.Query(view, ViewOptions{StartKey: []interface{}{"a", 1}}.Options(), &res)
+//
+// Keys may be any value that encodes as JSON; zero values are left
+// out.
+type ViewOptions struct {
+	Key      interface{}
+	Keys     []interface{}
+	StartKey interface{}
+	EndKey   interface{}
+	// Document ids to start and end at among rows with the same key.
+	StartKeyDocID string
+	EndKeyDocID   string
+	// Exclude rows matching EndKey.
+	ExclusiveEnd bool
+
+	Limit      int
+	Skip       int
+	Descending bool
+
+	// Whether to reduce, for views with a reduce function, which do by
+	// default.
+	Reduce *bool
+	// Group reduced rows by key, or by the first GroupLevel elements of
+	// array keys.
+	Group      bool
+	GroupLevel int
+
+	IncludeDocs bool
+	Conflicts   bool
+
+	// UpdateTrue, UpdateFalse or UpdateLazy: whether the view is
+	// brought up to date before, or after, responding.
+	Update string
+	// Use the same shard replicas each time, for consistent results.
+	Stable bool
+	// Deprecated StaleOK or StaleUpdateAfter, in place of Update and
+	// Stable.
+	Stale string
+
+	// Include the database sequence the view reflects, as
+	// "update_seq", e.g. Rows.UpdateSeq.
+	UpdateSeq bool
+}
+
+// Options returns the options as a map, with each value encoded as
+// CouchDB expects it.
+func (o ViewOptions) Options() map[string]interface{} {
+	m := map[string]interface{}{}
+	key := func(k string, v interface{}) {
+		if v != nil {
+			b, err := json.Marshal(v)
+			if err != nil {
+				// ViewURL reports the error.
+				m[k] = v
+				return
+			}
+			m[k] = rawParam(b)
+		}
+	}
+	raw := func(k, v string, ok bool) {
+		if ok {
+			m[k] = rawParam(v)
+		}
+	}
+	key("key", o.Key)
+	if o.Keys != nil {
+		key("keys", o.Keys)
+	}
+	key("startkey", o.StartKey)
+	key("endkey", o.EndKey)
+	raw("startkey_docid", o.StartKeyDocID, o.StartKeyDocID != "")
+	raw("endkey_docid", o.EndKeyDocID, o.EndKeyDocID != "")
+	raw("inclusive_end", "false", o.ExclusiveEnd)
+	raw("limit", strconv.Itoa(o.Limit), o.Limit > 0)
+	raw("skip", strconv.Itoa(o.Skip), o.Skip > 0)
+	raw("descending", "true", o.Descending)
+	if o.Reduce != nil {
+		raw("reduce", strconv.FormatBool(*o.Reduce), true)
+	}
+	raw("group", "true", o.Group)
+	raw("group_level", strconv.Itoa(o.GroupLevel), o.GroupLevel > 0)
+	raw("include_docs", "true", o.IncludeDocs)
+	raw("conflicts", "true", o.Conflicts)
+	raw("update", o.Update, o.Update != "")
+	raw("stable", "true", o.Stable)
+	raw("stale", o.Stale, o.Stale != "")
+	raw("update_seq", "true", o.UpdateSeq)
+	return m
+}
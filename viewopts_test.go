@@ -0,0 +1,65 @@
+package couch
+
+import (
+	"net/url"
+	"testing"
+)
+
+func TestViewOptions(t *testing.T) {
+	noReduce := false
+	opts := ViewOptions{
+		Key:           `say "hi"`,
+		StartKey:      []interface{}{"a", 1},
+		EndKey:        map[string]interface{}{},
+		StartKeyDocID: "doc 1",
+		EndKeyDocID:   "doc 9",
+		ExclusiveEnd:  true,
+		Limit:         10,
+		Descending:    true,
+		Reduce:        &noReduce,
+		IncludeDocs:   true,
+		Update:        "lazy",
+		Stable:        true,
+	}.Options()
+
+	u, err := Database{Name: "db"}.ViewURL("_design/d/_view/v", opts)
+	if err != nil {
+		t.Fatalf("Error building URL: %v", err)
+	}
+	parsed, err := url.Parse(u)
+	must(err)
+	q := parsed.Query()
+	exp := map[string]string{
+		"key": `"say \"hi\""`, "startkey": `["a",1]`, "endkey": `{}`,
+		"startkey_docid": "doc 1", "endkey_docid": "doc 9",
+		"inclusive_end": "false", "limit": "10", "descending": "true",
+		"reduce": "false", "include_docs": "true", "update": "lazy",
+		"stable": "true",
+	}
+	if len(q) != len(exp) {
+		t.Errorf("Expected %v, got %v", exp, q)
+	}
+	for k, v := range exp {
+		if got := q.Get(k); got != v {
+			t.Errorf("%v: expected %s, got %s", k, v, got)
+		}
+	}
+}
+
+func TestViewOptionsKeys(t *testing.T) {
+	opts := ViewOptions{Keys: []interface{}{"a", 2}, Group: true, GroupLevel: 2}.Options()
+	u, err := Database{}.ViewURL("v", opts)
+	if err != nil {
+		t.Fatalf("Error building URL: %v", err)
+	}
+	parsed, _ := url.Parse(u)
+	q := parsed.Query()
+	if q.Get("keys") != `["a",2]` || q.Get("group") != "true" || q.Get("group_level") != "2" {
+		t.Errorf("Unexpected parameters %v", q)
+	}
+
+	if _, err := (Database{}).ViewURL("v",
+		ViewOptions{Key: make(chan int)}.Options()); err == nil {
+		t.Errorf("Expected error for an unencodable key")
+	}
+}
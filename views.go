@@ -4,13 +4,36 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"net/url"
+	"strings"
 )
 
 // Row represents a single row in a view response
 type Row struct {
-	ID  *string
-	Key *string
+	ID    *string
+	Key   *string
+	Value json.RawMessage
+	// Doc is the document included with include_docs.  If the row
+	// links to another document (see Link), it's that one rather than
+	// the document that emitted the row.
+	Doc json.RawMessage
+}
+
+// Link returns the id of the document the row links to, when the view
+// emitted a value like {"_id": "other"}, or "" if it doesn't link.
+func (r Row) Link() string {
+	return linkedID(r.Value)
+}
+
+// Joined decodes the linked document included with the row into d,
+// reporting whether there was one.  A row that doesn't link, or whose
+// linked document doesn't exist, leaves d alone.
+func (r Row) Joined(d interface{}) (bool, error) {
+	if r.Link() == "" || len(r.Doc) == 0 || string(r.Doc) == "null" {
+		return false, nil
+	}
+	return true, json.Unmarshal(r.Doc, d)
 }
 
 type keyedViewResponse struct {
@@ -40,24 +63,71 @@ func (p Database) QueryIds(view string, options map[string]interface{}) ([]strin
 
 var errEmptyView = errors.New("empty view")
 
-// DocID is a string type that isn't escaped in a view param
+// DocID is a string type that isn't escaped in a view param.
 type DocID string
 
+// Values of the stale view parameter.  Prefer the update and stable
+// parameters of CouchDB 2.1 and later.
+const (
+	StaleOK          = "ok"
+	StaleUpdateAfter = "update_after"
+)
+
+// Values of the update view parameter: whether the view is brought up
+// to date before responding, not at all, or after responding.
+const (
+	UpdateTrue  = "true"
+	UpdateFalse = "false"
+	UpdateLazy  = "lazy"
+)
+
+// rawParam is a view parameter already encoded as CouchDB expects it.
+type rawParam string
+
+// rawParams are the view parameters that aren't JSON, whose string
+// values are passed as is.
+var rawParams = map[string]bool{
+	"startkey_docid": true, "start_key_doc_id": true,
+	"endkey_docid": true, "end_key_doc_id": true,
+	"stale": true, "update": true, "stable": true,
+}
+
 func qParam(k, v string) string {
-	format := `"%s"`
-	switch k {
-	case "startkey_docid", "stale":
-		format = "%s"
+	if rawParams[k] {
+		return v
 	}
-	return fmt.Sprintf(format, v)
+	b, err := json.Marshal(v)
+	must(err)
+	return string(b)
 }
 
 // ViewURL builds a URL for a view with the given ddoc, view name, and
 // parameters.
 func (p Database) ViewURL(view string, params map[string]interface{}) (string, error) {
+	values, err := viewParams(params)
+	if err != nil {
+		return "", err
+	}
+
+	segments := strings.Split(view, "/")
+	for i := range segments {
+		segments[i] = pathEscape(segments[i])
+	}
+
+	u, err := url.Parse(p.dbURL(strings.Join(segments, "/")))
+	must(err)
+	u.RawQuery = values.Encode()
+
+	return u.String(), nil
+}
+
+// viewParams encodes view parameters as query parameters.
+func viewParams(params map[string]interface{}) (url.Values, error) {
 	values := url.Values{}
 	for k, v := range params {
 		switch t := v.(type) {
+		case rawParam:
+			values[k] = []string{string(t)}
 		case DocID:
 			values[k] = []string{string(t)}
 		case string:
@@ -69,18 +139,13 @@ func (p Database) ViewURL(view string, params map[string]interface{}) (string, e
 		default:
 			b, err := json.Marshal(v)
 			if err != nil {
-				return "", fmt.Errorf("unsupported value-type %T in Query, "+
+				return nil, fmt.Errorf("unsupported value-type %T in Query, "+
 					"json encoder said %v", t, err)
 			}
 			values[k] = []string{fmt.Sprintf(`%v`, string(b))}
 		}
 	}
-
-	u, err := url.Parse(p.DBURL() + "/" + view)
-	must(err)
-	u.RawQuery = values.Encode()
-
-	return u.String(), nil
+	return values, nil
 }
 
 // Query executes and unmarshals a view request.
@@ -92,5 +157,23 @@ func (p Database) Query(view string, options map[string]interface{}, results int
 	if err != nil {
 		return err
 	}
-	return unmarshalURL(fullURL, results)
+	return p.unmarshalURL(fullURL, results)
+}
+
+// QueryRaw executes a view request, returning the undecoded response
+// body to be copied elsewhere or decoded as the caller likes.  The
+// caller must close it.
+func (p Database) QueryRaw(view string, options map[string]interface{}) (io.ReadCloser, error) {
+	if view == "" {
+		return nil, errEmptyView
+	}
+	fullURL, err := p.ViewURL(view, options)
+	if err != nil {
+		return nil, err
+	}
+	body, err := p.getBody(fullURL)
+	if err != nil {
+		return nil, err
+	}
+	return p.limitReadCloser(body), nil
 }
@@ -6,6 +6,8 @@ import (
 	"net/url"
 	"strings"
 	"testing"
+
+	"github.com/dustin/go-couch/couchtest"
 )
 
 func TestQueryNoView(t *testing.T) {
@@ -46,6 +48,15 @@ func TestViewURL(t *testing.T) {
 			map[string]string{"stale": "update_after"}},
 		{map[string]interface{}{"startkey": []string{"a"}},
 			map[string]string{"startkey": `["a"]`}},
+		{map[string]interface{}{"endkey_docid": "doc 9", "end_key_doc_id": "x"},
+			map[string]string{"endkey_docid": "doc 9", "end_key_doc_id": "x"}},
+		{map[string]interface{}{"update": UpdateLazy, "stable": "true", "stale": StaleOK},
+			map[string]string{"update": "lazy", "stable": "true", "stale": "ok"}},
+		{map[string]interface{}{"key": DocID(`"a"`), "startkey": DocID(`[1,"b"]`),
+			"endkey_docid": DocID("b")},
+			map[string]string{"key": `"a"`, "startkey": `[1,"b"]`, "endkey_docid": "b"}},
+		{map[string]interface{}{"key": `say "hi"`},
+			map[string]string{"key": `"say \"hi\""`}},
 	}
 
 	for _, test := range tests {
@@ -77,6 +88,18 @@ func TestViewURL(t *testing.T) {
 	}
 }
 
+func TestViewURLEscaping(t *testing.T) {
+	d := Database{Host: "localhost", Port: "5984", Name: "a+b"}
+	u, err := d.ViewURL("_design/d d/_view/v/x", nil)
+	if err != nil {
+		t.Fatalf("Error building view URL: %v", err)
+	}
+	exp := "http://localhost:5984/a%2Bb/_design/d%20d/_view/v/x"
+	if u != exp {
+		t.Errorf("Expected %v, got %v", exp, u)
+	}
+}
+
 func TestBadViewParam(t *testing.T) {
 	d := Database{Host: "localhost", Port: "5984"}
 	thing, err := d.ViewURL("aview", map[string]interface{}{
@@ -100,7 +123,7 @@ func TestQueryBadViewParam(t *testing.T) {
 }
 
 func TestQuerySuccess(t *testing.T) {
-	defer uninstallFakeHTTP(installFakeHTTP(oneFake(http.Response{
+	defer uninstallFakeHTTP(installFakeHTTP(couchtest.NewTransport(&http.Response{
 		StatusCode: 200,
 		Body:       ioutil.NopCloser(strings.NewReader(`{"k": "v"}`)),
 	})))
@@ -118,7 +141,7 @@ func TestQuerySuccess(t *testing.T) {
 
 func TestQueryIDsSuccess(t *testing.T) {
 	hres := `{"rows": [{"id": "one"}, {}, {"id": "three"}]}`
-	defer uninstallFakeHTTP(installFakeHTTP(oneFake(http.Response{
+	defer uninstallFakeHTTP(installFakeHTTP(couchtest.NewTransport(&http.Response{
 		StatusCode: 200,
 		Body:       ioutil.NopCloser(strings.NewReader(hres)),
 	})))
@@ -133,3 +156,28 @@ func TestQueryIDsSuccess(t *testing.T) {
 	}
 
 }
+
+func TestQueryRaw(t *testing.T) {
+	const resp = `{"total_rows": 1, "offset": 0, "rows": [{"id": "a", "key": "a", "value": 1}]}`
+	f := couchtest.NewTransport(docResponse(resp))
+	defer uninstallFakeHTTP(installFakeHTTP(f))
+
+	body, err := Database{Name: "db"}.QueryRaw("_design/d/_view/v",
+		map[string]interface{}{"limit": 1})
+	if err != nil {
+		t.Fatalf("Error querying: %v", err)
+	}
+	defer body.Close()
+	b, err := ioutil.ReadAll(body)
+	if err != nil || string(b) != resp {
+		t.Errorf("Unexpected body %s, %v", b, err)
+	}
+	if u := f.Requests[0].URL; u.Path != "/db/_design/d/_view/v" ||
+		u.Query().Get("limit") != "1" {
+		t.Errorf("Unexpected request %v", u)
+	}
+
+	if _, err := (Database{}).QueryRaw("", nil); err != errEmptyView {
+		t.Errorf("Expected errEmptyView, got %v", err)
+	}
+}